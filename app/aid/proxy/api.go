@@ -0,0 +1,178 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/henrylee2cn/pholcus/config"
+)
+
+// 请求代理API的超时
+const API_TIMEOUT = 30 * time.Second
+
+// 按minute分钟的间隔从代理API刷新代理列表，再次调用时停止原有的定时刷新并按新的间隔重新开始，minute<=0时仅停止
+func (self *Proxy) Refresh(minute int64) {
+	self.StopRefresh()
+	if minute <= 0 {
+		return
+	}
+	stop := make(chan bool)
+	self.Lock()
+	self.refreshStop = stop
+	self.Unlock()
+	go self.refreshLoop(time.Duration(minute)*time.Minute, stop)
+}
+
+// 停止代理API的定时刷新
+func (self *Proxy) StopRefresh() {
+	self.Lock()
+	defer self.Unlock()
+	if self.refreshStop != nil {
+		close(self.refreshStop)
+		self.refreshStop = nil
+	}
+}
+
+func (self *Proxy) refreshLoop(interval time.Duration, stop chan bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			self.Update()
+		}
+	}
+}
+
+// 从代理API获取代理列表
+func (self *Proxy) fetchApi() ([]string, error) {
+	req, err := http.NewRequest("GET", config.PROXY_API, nil)
+	if err != nil {
+		return nil, err
+	}
+	if config.PROXY_API_AUTH != "" {
+		req.Header.Set("Authorization", config.PROXY_API_AUTH)
+	}
+	client := &http.Client{Timeout: API_TIMEOUT}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("proxy api: %v", resp.Status)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	proxys := parseProxyList(b)
+	if len(proxys) == 0 {
+		return nil, fmt.Errorf("proxy api: no proxy in response")
+	}
+	return proxys, nil
+}
+
+// 解析代理列表，支持JSON（字符串数组，或含ip/host与port字段的对象，可嵌套于任意字段中）及每行一个代理的文本，
+// 代理格式为 [scheme://][user:password@]host:port，未指定scheme时为http
+func parseProxyList(b []byte) []string {
+	var (
+		proxys []string
+		seen   = make(map[string]bool)
+		add    = func(s string) {
+			if proxy := normalizeProxy(s); proxy != "" && !seen[proxy] {
+				seen[proxy] = true
+				proxys = append(proxys, proxy)
+			}
+		}
+	)
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err == nil {
+		walkProxyJson(v, add)
+		return proxys
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		// 兼容以逗号分隔的单行列表
+		for _, s := range strings.Split(scanner.Text(), ",") {
+			add(s)
+		}
+	}
+	return proxys
+}
+
+// 遍历JSON中的代理
+func walkProxyJson(v interface{}, add func(string)) {
+	switch v := v.(type) {
+	case string:
+		add(v)
+	case []interface{}:
+		for _, e := range v {
+			walkProxyJson(e, add)
+		}
+	case map[string]interface{}:
+		host := firstString(v, "ip", "host", "addr", "address", "proxy")
+		port := firstString(v, "port")
+		if host != "" && port != "" {
+			add(net.JoinHostPort(host, port))
+			return
+		}
+		if host != "" {
+			add(host)
+			return
+		}
+		for _, e := range v {
+			walkProxyJson(e, add)
+		}
+	}
+}
+
+// 返回首个存在的字段值，数值转为字符串，不区分大小写
+func firstString(m map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		for k, v := range m {
+			if !strings.EqualFold(k, key) {
+				continue
+			}
+			switch v := v.(type) {
+			case string:
+				return strings.TrimSpace(v)
+			case float64:
+				return fmt.Sprint(int64(v))
+			}
+		}
+	}
+	return ""
+}
+
+// 规范化代理地址，无效时返回空字符串
+func normalizeProxy(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.HasPrefix(s, "#") {
+		return ""
+	}
+	if !strings.Contains(s, "://") {
+		s = "http://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Hostname() == "" || u.Port() == "" {
+		return ""
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "socks5":
+	default:
+		return ""
+	}
+	u.Path, u.RawQuery, u.Fragment = "", "", ""
+	return u.String()
+}
@@ -29,6 +29,8 @@ type Proxy struct {
 	usable      map[string]*ProxyForHost
 	ticker      *time.Ticker
 	tickMinute  int64
+	apiProxys   []string  // 上次从代理API获取的代理列表，获取失败时沿用
+	refreshStop chan bool // 关闭时停止代理API的定时刷新，未开启时为nil
 	threadPool  chan bool
 	surf        surfer.Surfer
 	sync.Mutex
@@ -53,6 +55,9 @@ func New() *Proxy {
 		surf:        surfer.New(),
 	}
 	go p.Update()
+	if config.PROXY_API != "" {
+		p.Refresh(config.PROXY_API_MINUTE)
+	}
 	return p
 }
 
@@ -61,22 +66,44 @@ func (self *Proxy) Count() int {
 	return self.online
 }
 
-// 更新代理IP列表
+// 更新代理IP列表，来源为代理IP文件及代理API，代理API获取失败时沿用上次获取的API代理，代理IP文件的变化照常生效
 func (self *Proxy) Update() *Proxy {
-	f, err := os.Open(config.PROXY)
-	if err != nil {
-		// logs.Log.Error("Error: %v\n", err)
+	var proxys []string
+	if f, err := os.Open(config.PROXY); err == nil {
+		b, _ := ioutil.ReadAll(f)
+		f.Close()
+		proxys = self.proxyRegexp.FindAllString(string(b), -1)
+	}
+	if config.PROXY_API != "" {
+		apiProxys, err := self.fetchApi()
+		self.Lock()
+		if err != nil {
+			logs.Log.Error(" *     从代理API获取代理IP失败，沿用上次获取的列表: %v\n", err)
+		} else {
+			self.apiProxys = apiProxys
+		}
+		proxys = append(proxys, self.apiProxys...)
+		self.Unlock()
+	}
+	if len(proxys) == 0 {
 		return self
 	}
-	b, _ := ioutil.ReadAll(f)
-	f.Close()
 
-	proxys := self.proxyRegexp.FindAllString(string(b), -1)
+	self.Lock()
+	self.allIps = make(map[string]string, len(proxys))
+	self.all = make(map[string]bool, len(proxys))
 	for _, proxy := range proxys {
-		self.allIps[proxy] = self.ipRegexp.FindString(proxy)
+		if ip := self.ipRegexp.FindString(proxy); ip != "" {
+			self.allIps[proxy] = ip
+		} else if u, err := url.Parse(proxy); err == nil {
+			self.allIps[proxy] = u.Hostname()
+		}
 		self.all[proxy] = false
 		// fmt.Printf("+ 代理IP %v：%v\n", i, proxy)
 	}
+	// 代理列表变化后，各主机的可用代理须重新测试
+	self.usable = make(map[string]*ProxyForHost)
+	self.Unlock()
 	log.Printf(" *     读取代理IP: %v 条\n", len(self.all))
 
 	self.findOnline()
@@ -112,6 +139,10 @@ func (self *Proxy) findOnline() *Proxy {
 // 更新继时器
 func (self *Proxy) UpdateTicker(tickMinute int64) {
 	self.tickMinute = tickMinute
+	// 停止上次任务的继时器，以免泄漏
+	if self.ticker != nil {
+		self.ticker.Stop()
+	}
 	self.ticker = time.NewTicker(time.Duration(self.tickMinute) * time.Minute)
 	for _, proxyForHost := range self.usable {
 		proxyForHost.curIndex++
@@ -140,27 +171,30 @@ func (self *Proxy) GetOne(u string) (curProxy string) {
 	var ok = true
 	var proxyForHost = self.usable[key]
 
-	select {
-	case <-self.ticker.C:
-		proxyForHost.curIndex++
-		if proxyForHost.curIndex >= proxyForHost.Len() {
-			_, ok = self.testAndSort(key, u2.Scheme+"://"+u2.Host)
+	// 代理列表更新后各主机的可用代理被清空，须重新测试
+	if proxyForHost == nil {
+		self.usable[key] = &ProxyForHost{
+			proxys:    []string{},
+			timedelay: []time.Duration{},
+			isEcho:    true,
 		}
-		proxyForHost.isEcho = true
-
-	default:
-		if proxyForHost == nil {
-			self.usable[key] = &ProxyForHost{
-				proxys:    []string{},
-				timedelay: []time.Duration{},
-				isEcho:    true,
+		proxyForHost, ok = self.testAndSort(key, u2.Scheme+"://"+u2.Host)
+	} else {
+		select {
+		case <-self.ticker.C:
+			proxyForHost.curIndex++
+			if proxyForHost.curIndex >= proxyForHost.Len() {
+				_, ok = self.testAndSort(key, u2.Scheme+"://"+u2.Host)
 			}
-			proxyForHost, ok = self.testAndSort(key, u2.Scheme+"://"+u2.Host)
-		} else if l := proxyForHost.Len(); l == 0 {
-			ok = false
-		} else if proxyForHost.curIndex >= l {
-			_, ok = self.testAndSort(key, u2.Scheme+"://"+u2.Host)
 			proxyForHost.isEcho = true
+
+		default:
+			if l := proxyForHost.Len(); l == 0 {
+				ok = false
+			} else if proxyForHost.curIndex >= l {
+				_, ok = self.testAndSort(key, u2.Scheme+"://"+u2.Host)
+				proxyForHost.isEcho = true
+			}
 		}
 	}
 	if !ok {
@@ -178,6 +212,30 @@ func (self *Proxy) GetOne(u string) (curProxy string) {
 	return
 }
 
+// 弃用目标主机当前所用的代理IP，此后GetOne()换用下一个，如响应被判定为封禁时；
+// proxy须为该请求实际所用的代理IP，以免并发失败的请求连续跳过多个代理
+func (self *Proxy) Next(u, proxy string) {
+	u2, _ := url.Parse(u)
+	if u2 == nil || u2.Host == "" {
+		return
+	}
+	var key = u2.Host
+	if strings.Count(key, ".") > 1 {
+		key = key[strings.Index(key, ".")+1:]
+	}
+
+	self.Lock()
+	defer self.Unlock()
+
+	proxyForHost := self.usable[key]
+	if proxyForHost == nil || proxyForHost.curIndex >= proxyForHost.Len() || proxyForHost.proxys[proxyForHost.curIndex] != proxy {
+		return
+	}
+	proxyForHost.curIndex++
+	proxyForHost.isEcho = true
+	logs.Log.Informational(" *     [%v]弃用代理IP [%v]\n", key, proxy)
+}
+
 // 测试并排序
 func (self *Proxy) testAndSort(key string, testHost string) (*ProxyForHost, bool) {
 	logs.Log.Informational(" *     [%v]正在测试与排序代理IP……", key)
@@ -5,6 +5,8 @@ package app
 import (
 	"fmt"
 	"io"
+	"math/rand"
+	"net/http"
 	"reflect"
 	"runtime"
 	"strconv"
@@ -14,10 +16,13 @@ import (
 
 	"github.com/henrylee2cn/pholcus/app/crawler"
 	"github.com/henrylee2cn/pholcus/app/distribute"
+	"github.com/henrylee2cn/pholcus/app/downloader"
 	"github.com/henrylee2cn/pholcus/app/pipeline"
 	"github.com/henrylee2cn/pholcus/app/pipeline/collector"
+	"github.com/henrylee2cn/pholcus/app/pipeline/collector/data"
 	"github.com/henrylee2cn/pholcus/app/scheduler"
 	"github.com/henrylee2cn/pholcus/app/spider"
+	"github.com/henrylee2cn/pholcus/config"
 	"github.com/henrylee2cn/pholcus/logs"
 	"github.com/henrylee2cn/pholcus/runtime/cache"
 	"github.com/henrylee2cn/pholcus/runtime/status"
@@ -43,21 +48,31 @@ type (
 		Status() int                                                  // 返回当前状态
 		GetSpiderLib() []*spider.Spider                               // 获取全部蜘蛛种类
 		GetSpiderByName(string) *spider.Spider                        // 通过名字获取某蜘蛛
+		GetSpiderInfos() []spider.SpiderInfo                          // 获取全部蜘蛛种类的元数据
 		GetSpiderQueue() crawler.SpiderQueue                          // 获取蜘蛛队列接口实例
 		GetOutputLib() []string                                       // 获取全部输出方式
 		GetTaskJar() *distribute.TaskJar                              // 返回任务库
+		GetQueueDump() []scheduler.QueueItem                          // 返回当前待处理请求的快照
+		GetInflight() []scheduler.InflightItem                        // 返回当前执行中请求的快照
+		GetFailures() []scheduler.FailureRecord                       // 返回本次任务的失败请求报告
+		SaveRunConf(fileName string) error                            // 将当前生效的运行配置及蜘蛛保存至文件
+		GetRuleItemCounts() map[string]map[string]int64               // 返回当前任务各蜘蛛各规则已收集的结果数量
+		GetMemoryItems() []data.DataCell                              // 返回最近一次任务经内存输出(memory)的文本数据
 		distribute.Distributer                                        // 实现分布式接口
 	}
 	Logic struct {
-		*cache.AppConf                      // 全局配置
-		*spider.SpiderSpecies               // 全部蜘蛛种类
-		crawler.SpiderQueue                 // 当前任务的蜘蛛队列
-		*distribute.TaskJar                 // 服务器与客户端间传递任务的存储库
-		crawler.CrawlerPool                 // 爬行回收池
-		teleport.Teleport                   // socket长连接双工通信接口，json数据传输
-		sum                   [2]uint64     // 执行计数
-		takeTime              time.Duration // 执行计时
-		status                int           // 运行状态
+		*cache.AppConf                             // 全局配置
+		*spider.SpiderSpecies                      // 全部蜘蛛种类
+		crawler.SpiderQueue                        // 当前任务的蜘蛛队列
+		*distribute.TaskJar                        // 服务器与客户端间传递任务的存储库
+		crawler.CrawlerPool                        // 爬行回收池
+		teleport.Teleport                          // socket长连接双工通信接口，json数据传输
+		sum                   [2]uint64            // 执行计数
+		takeTime              time.Duration        // 执行计时
+		status                int                  // 运行状态
+		seedServer            *http.Server         // 接收种子URL的HTTP服务
+		profile               *config.ProfileState // 本次运行应用的礼貌度预设，运行结束后据此恢复原配置
+		memory                *pipeline.Memory     // 本次运行内存输出的缓冲区，输出方式不含memory时为nil
 		finish                chan bool
 		finishOnce            sync.Once
 		canSocketLog          bool
@@ -150,8 +165,12 @@ func (self *Logic) SetAppConf(k string, v interface{}) App {
 
 	acv := reflect.ValueOf(self.AppConf).Elem()
 	key := strings.Title(k)
-	if acv.FieldByName(key).CanSet() {
-		acv.FieldByName(key).Set(reflect.ValueOf(v))
+	if field := acv.FieldByName(key); field.CanSet() {
+		// 改为不同于当前的值即视为显式设置，不再被礼貌度预设替换
+		if !reflect.DeepEqual(field.Interface(), v) {
+			self.AppConf.SetExplicit(key)
+		}
+		field.Set(reflect.ValueOf(v))
 	}
 
 	if k == "DockerCap" {
@@ -225,6 +244,7 @@ func (self *Logic) ReInit(mode int, port int, master string, w ...io.Writer) App
 // client模式下不调用该方法
 func (self *Logic) SpiderPrepare(original []*spider.Spider) App {
 	self.SpiderQueue.Reset()
+	self.applyProfile()
 	// 遍历任务
 	for _, sp := range original {
 		spcopy := sp.Copy()
@@ -246,6 +266,45 @@ func (self *Logic) GetOutputLib() []string {
 	return collector.DataOutputLib
 }
 
+// 返回当前待处理请求的快照
+func (self *Logic) GetQueueDump() []scheduler.QueueItem {
+	return scheduler.Dump()
+}
+
+// 返回当前执行中请求的快照
+func (self *Logic) GetInflight() []scheduler.InflightItem {
+	return scheduler.Inflight()
+}
+
+// 返回本次任务的失败请求报告
+func (self *Logic) GetFailures() []scheduler.FailureRecord {
+	return scheduler.Failures()
+}
+
+// 返回当前任务各蜘蛛各规则已收集的结果数量，[蜘蛛名][规则名]数量，供界面轮询展示
+func (self *Logic) GetRuleItemCounts() map[string]map[string]int64 {
+	counts := make(map[string]map[string]int64)
+	for _, sp := range self.SpiderQueue.GetAll() {
+		name := sp.GetName()
+		if sp.GetSubName() != "" {
+			name += "__" + sp.GetSubName()
+		}
+		counts[name] = sp.GetRuleItemCounts()
+	}
+	return counts
+}
+
+// 返回最近一次任务经内存输出(memory)的文本数据，输出方式不含memory时为nil
+func (self *Logic) GetMemoryItems() []data.DataCell {
+	self.RWMutex.RLock()
+	memory := self.memory
+	self.RWMutex.RUnlock()
+	if memory == nil {
+		return nil
+	}
+	return memory.Items()
+}
+
 // 获取全部蜘蛛种类
 func (self *Logic) GetSpiderLib() []*spider.Spider {
 	return self.SpiderSpecies.Get()
@@ -256,6 +315,11 @@ func (self *Logic) GetSpiderByName(name string) *spider.Spider {
 	return self.SpiderSpecies.GetByName(name)
 }
 
+// 获取全部蜘蛛种类的元数据，包括名称、描述、自定义输入及各规则的结果字段
+func (self *Logic) GetSpiderInfos() []spider.SpiderInfo {
+	return self.SpiderSpecies.Infos()
+}
+
 // 返回当前运行模式
 func (self *Logic) GetMode() int {
 	return self.AppConf.Mode
@@ -285,6 +349,9 @@ func (self *Logic) Run() {
 		self.LogRest()
 		return
 	}
+	// 等待RunSpider()执行完毕
+	runLock.Lock()
+	defer runLock.Unlock()
 	self.finish = make(chan bool)
 	self.finishOnce = sync.Once{}
 	// 重置计数
@@ -294,6 +361,8 @@ func (self *Logic) Run() {
 	// 设置状态
 	self.setStatus(status.RUN)
 	defer self.setStatus(status.STOPPED)
+	// 恢复被礼貌度预设替换的配置
+	defer self.restoreProfile()
 	// 任务执行
 	switch self.AppConf.Mode {
 	case status.OFFLINE:
@@ -326,6 +395,7 @@ func (self *Logic) Stop() {
 	self.setStatus(status.STOP)
 	self.CrawlerPool.Stop()
 	scheduler.Stop()
+	scheduler.StopMetrics()
 	for !self.IsStopped() {
 		runtime.Gosched()
 	}
@@ -443,6 +513,11 @@ func (self *Logic) client() {
 		// 准备运行
 		self.taskToRun(t)
 
+		// 随机延迟，避免各子节点同时开始抓取
+		if !self.startJitter() {
+			return
+		}
+
 		// 重置计数
 		self.sum[0], self.sum[1] = 0, 0
 		// 重置计时
@@ -476,13 +551,58 @@ ReStartLabel:
 	return self.TaskJar.Pull()
 }
 
+// 客户端模式下开始执行任务前随机延迟0~StartJitter秒，期间被停止时返回false
+func (self *Logic) startJitter() bool {
+	if self.AppConf.StartJitter <= 0 {
+		return true
+	}
+	d := time.Duration(rand.Int63n(self.AppConf.StartJitter * int64(time.Second)))
+	logs.Log.Informational(" *     随机延迟 %v 后开始执行任务\n", d.Truncate(time.Millisecond))
+	for deadline := time.Now().Add(d); time.Now().Before(deadline); {
+		if self.Status() == status.STOP || self.Status() == status.STOPPED {
+			return false
+		}
+		// 每隔100毫秒检查一次是否已停止
+		wait := time.Until(deadline)
+		if wait > 100*time.Millisecond {
+			wait = 100 * time.Millisecond
+		}
+		time.Sleep(wait)
+	}
+	return true
+}
+
+// 按礼貌度预设调整本次运行的全局运行参数，须在为蜘蛛设置暂停时长之前执行，
+// 先恢复上次应用预设前的配置，使预设只对仍为默认值的配置生效
+func (self *Logic) applyProfile() {
+	self.restoreProfile()
+	if self.AppConf.Profile == "" {
+		return
+	}
+	state, ok := config.ApplyProfile(self.AppConf)
+	if !ok {
+		logs.Log.Warning(" *     礼貌度预设 %q 不存在，已忽略\n", self.AppConf.Profile)
+		return
+	}
+	self.profile = state
+	logs.Log.Informational(" *     采用礼貌度预设 %v\n", self.AppConf.Profile)
+}
+
+// 恢复被礼貌度预设替换的配置
+func (self *Logic) restoreProfile() {
+	self.profile.Restore()
+	self.profile = nil
+}
+
 // client模式下从task准备运行条件
 func (self *Logic) taskToRun(t *distribute.Task) {
 	// 清空历史任务
 	self.SpiderQueue.Reset()
 
 	// 更改全局配置
+	self.restoreProfile()
 	self.setAppConf(t)
+	self.applyProfile()
 
 	// 初始化蜘蛛队列
 	for _, n := range t.Spiders {
@@ -508,10 +628,42 @@ func (self *Logic) taskToRun(t *distribute.Task) {
 func (self *Logic) exec() {
 	count := self.SpiderQueue.Len()
 	cache.ResetPageCount()
+	// 每次运行各用一个内存输出的缓冲区
+	var memory *pipeline.Memory
+	for _, outType := range cache.OutTypes() {
+		if outType == "memory" {
+			memory = pipeline.NewMemory()
+		}
+	}
+	self.RWMutex.Lock()
+	self.memory = memory
+	self.RWMutex.Unlock()
 	// 刷新输出方式的状态
 	pipeline.RefreshOutput()
+	// 检查输出目标是否可用，不可用时放弃本次任务
+	if err := pipeline.CheckOutput(); err != nil {
+		logs.Log.Error(" *     输出目标不可用，放弃本次任务: %v\n", err)
+		if self.AppConf.Mode == status.OFFLINE {
+			self.LogRest()
+			self.finishOnce.Do(func() { close(self.finish) })
+		}
+		return
+	}
 	// 初始化资源队列
 	scheduler.Init()
+	// 刷新下载器配置
+	downloader.SurferDownloader.Refresh()
+	// 开始采样运行指标
+	if self.AppConf.MetricsInterval > 0 {
+		fileName := config.METRICS_DIR + "/metrics_" + time.Now().Format("20060102150405") + ".csv"
+		if err := scheduler.StartMetrics(fileName, time.Duration(self.AppConf.MetricsInterval)*time.Second); err != nil {
+			logs.Log.Error(" *     运行指标采样启动失败: %v\n", err)
+		}
+	}
+	// 开启种子URL接收服务
+	if self.AppConf.SeedAddr != "" {
+		self.seedServer = self.startSeedServer(self.AppConf.SeedAddr)
+	}
 
 	// 设置爬虫队列
 	crawlerCap := self.CrawlerPool.Reset(count)
@@ -524,7 +676,7 @@ func (self *Logic) exec() {
 	logs.Log.Informational(` *********************************************************************************************************************************** `)
 
 	// 开始计时
-	cache.StartTime = time.Now()
+	cache.StartRun()
 
 	// 根据模式选择合理的并发
 	if self.AppConf.Mode == status.OFFLINE {
@@ -549,9 +701,13 @@ func (self *Logic) goRun(count int) {
 		// 从爬行队列取出空闲蜘蛛，并发执行
 		c := self.CrawlerPool.Use()
 		if c != nil {
+			var memory collector.MemoryBuffer
+			if self.memory != nil {
+				memory = self.memory
+			}
 			go func(i int, c crawler.Crawler) {
 				// 执行并返回结果消息
-				c.Init(self.SpiderQueue.GetByIndex(i)).Run()
+				c.SetMemory(memory).Init(self.SpiderQueue.GetByIndex(i)).Run()
 				// 任务结束后回收该蜘蛛
 				self.CrawlerPool.Free(c)
 			}(i, c)
@@ -580,6 +736,29 @@ func (self *Logic) goRun(count int) {
 		self.sum[1] += s.FileNum
 	}
 
+	// 停止采样运行指标
+	scheduler.StopMetrics()
+
+	// 关闭种子URL接收服务
+	if self.seedServer != nil {
+		self.seedServer.Close()
+		self.seedServer = nil
+	}
+
+	// 输出失败请求报告
+	if self.AppConf.FailureReport {
+		if err := scheduler.FlushFailures(config.FAILURE_REPORT); err != nil {
+			logs.Log.Error(" *     失败请求报告保存失败: %v\n", err)
+		}
+	}
+
+	// 输出运行报告
+	if self.AppConf.RunReport {
+		if err := scheduler.FlushReport(config.RUN_REPORT); err != nil {
+			logs.Log.Error(" *     运行报告保存失败: %v\n", err)
+		}
+	}
+
 	// 总耗时
 	self.takeTime = time.Since(cache.StartTime)
 	var prefix = func() string {
@@ -606,6 +785,12 @@ func (self *Logic) goRun(count int) {
 		logs.Log.App(" *                            —— %s合计采集【数据 %v 条 + 文件 %v 个】，实爬【成功 %v URL + 失败 %v URL = 合计 %v URL】，耗时【%v】 ——",
 			prefix, self.sum[0], self.sum[1], cache.GetPageCount(1), cache.GetPageCount(-1), cache.GetPageCount(0), self.takeTime)
 	}
+	if n := cache.GetPageEmptyCount(); n > 0 {
+		logs.Log.App(" *                            —— 成功的URL中有 %v 个为无数据页面 ——", n)
+	}
+	if n := cache.GetFileSkipCount(); n > 0 {
+		logs.Log.App(" *                            —— 另有 %v 个文件已存在，跳过下载 ——", n)
+	}
 	logs.Log.Informational(" * ")
 	logs.Log.Informational(` *********************************************************************************************************************************** `)
 
@@ -658,6 +843,51 @@ func (self *Logic) setAppConf(task *distribute.Task) {
 	self.AppConf.FailureInherit = task.FailureInherit
 	self.AppConf.Limit = task.Limit
 	self.AppConf.ProxyMinute = task.ProxyMinute
+	self.AppConf.PriorityAging = task.PriorityAging
+	self.AppConf.Explain = task.Explain
+	self.AppConf.TLSFingerprint = task.TLSFingerprint
+	self.AppConf.ReadIdleTimeout = task.ReadIdleTimeout
+	self.AppConf.HeaderTimeout = task.HeaderTimeout
+	self.AppConf.Manifest = task.Manifest
+	self.AppConf.SamplePages = task.SamplePages
+	self.AppConf.MaxBodySize = task.MaxBodySize
+	self.AppConf.NatsSubject = task.NatsSubject
+	self.AppConf.NatsUrl = task.NatsUrl
+	self.AppConf.MaxConnsPerHost = task.MaxConnsPerHost
+	self.AppConf.ItemBufferPolicy = task.ItemBufferPolicy
+	self.AppConf.ItemBuffer = task.ItemBuffer
+	self.AppConf.RunId = task.RunId
+	self.AppConf.RunTag = task.RunTag
+	self.AppConf.InflightWarn = task.InflightWarn
+	self.AppConf.Profile = task.Profile
+	self.AppConf.Explicit = task.Explicit
+	self.AppConf.SeedWait = task.SeedWait
+	self.AppConf.OutputWait = task.OutputWait
+	self.AppConf.RotateInterval = task.RotateInterval
+	self.AppConf.RotateRows = task.RotateRows
+	self.AppConf.DropParams = task.DropParams
+	self.AppConf.URLNormalize = task.URLNormalize
+	self.AppConf.CollectOnce = task.CollectOnce
+	self.AppConf.StartJitter = task.StartJitter
+	self.AppConf.OnDuplicate = task.OnDuplicate
+	self.AppConf.DNSCacheSize = task.DNSCacheSize
+	self.AppConf.DNSCacheTTL = task.DNSCacheTTL
+	self.AppConf.OutputSchema = task.OutputSchema
+	self.AppConf.CollectorThreadNum = task.CollectorThreadNum
+	self.AppConf.OrderedOutput = task.OrderedOutput
+	self.AppConf.InvalidUTF8 = task.InvalidUTF8
+	self.AppConf.Canonical = task.Canonical
+	self.AppConf.Strategy = task.Strategy
+	self.AppConf.MaxRetries = task.MaxRetries
+	self.AppConf.FileResume = task.FileResume
+	self.AppConf.FileThreadNum = task.FileThreadNum
+	self.AppConf.MaxRunTime = task.MaxRunTime
+	self.AppConf.RetryAllErrors = task.RetryAllErrors
+	self.AppConf.MaxConsecutiveFails = task.MaxConsecutiveFails
+	self.AppConf.MaxFailRate = task.MaxFailRate
+	self.AppConf.FileSkipExists = task.FileSkipExists
+	self.AppConf.MaxRequests = task.MaxRequests
+	self.AppConf.StopGrace = task.StopGrace
 	self.AppConf.Keyins = task.Keyins
 }
 func (self *Logic) setTask(task *distribute.Task) {
@@ -670,5 +900,50 @@ func (self *Logic) setTask(task *distribute.Task) {
 	task.FailureInherit = self.AppConf.FailureInherit
 	task.Limit = self.AppConf.Limit
 	task.ProxyMinute = self.AppConf.ProxyMinute
+	task.PriorityAging = self.AppConf.PriorityAging
+	task.Explain = self.AppConf.Explain
+	task.TLSFingerprint = self.AppConf.TLSFingerprint
+	task.ReadIdleTimeout = self.AppConf.ReadIdleTimeout
+	task.HeaderTimeout = self.AppConf.HeaderTimeout
+	task.Manifest = self.AppConf.Manifest
+	task.SamplePages = self.AppConf.SamplePages
+	task.MaxBodySize = self.AppConf.MaxBodySize
+	task.NatsSubject = self.AppConf.NatsSubject
+	task.NatsUrl = self.AppConf.NatsUrl
+	task.MaxConnsPerHost = self.AppConf.MaxConnsPerHost
+	task.ItemBufferPolicy = self.AppConf.ItemBufferPolicy
+	task.ItemBuffer = self.AppConf.ItemBuffer
+	task.RunId = self.AppConf.RunId
+	task.RunTag = self.AppConf.RunTag
+	task.InflightWarn = self.AppConf.InflightWarn
+	task.Profile = self.AppConf.Profile
+	task.Explicit = self.AppConf.Explicit
+	task.SeedWait = self.AppConf.SeedWait
+	task.OutputWait = self.AppConf.OutputWait
+	task.RotateInterval = self.AppConf.RotateInterval
+	task.RotateRows = self.AppConf.RotateRows
+	task.DropParams = self.AppConf.DropParams
+	task.URLNormalize = self.AppConf.URLNormalize
+	task.CollectOnce = self.AppConf.CollectOnce
+	task.StartJitter = self.AppConf.StartJitter
+	task.OnDuplicate = self.AppConf.OnDuplicate
+	task.DNSCacheSize = self.AppConf.DNSCacheSize
+	task.DNSCacheTTL = self.AppConf.DNSCacheTTL
+	task.OutputSchema = self.AppConf.OutputSchema
+	task.CollectorThreadNum = self.AppConf.CollectorThreadNum
+	task.OrderedOutput = self.AppConf.OrderedOutput
+	task.InvalidUTF8 = self.AppConf.InvalidUTF8
+	task.Canonical = self.AppConf.Canonical
+	task.Strategy = self.AppConf.Strategy
+	task.MaxRetries = self.AppConf.MaxRetries
+	task.FileResume = self.AppConf.FileResume
+	task.FileThreadNum = self.AppConf.FileThreadNum
+	task.MaxRunTime = self.AppConf.MaxRunTime
+	task.RetryAllErrors = self.AppConf.RetryAllErrors
+	task.MaxConsecutiveFails = self.AppConf.MaxConsecutiveFails
+	task.MaxFailRate = self.AppConf.MaxFailRate
+	task.FileSkipExists = self.AppConf.FileSkipExists
+	task.MaxRequests = self.AppConf.MaxRequests
+	task.StopGrace = self.AppConf.StopGrace
 	task.Keyins = self.AppConf.Keyins
 }
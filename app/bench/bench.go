@@ -0,0 +1,232 @@
+// 基准测试，在本地启动生成测试网页的服务器，以参考蜘蛛完整抓取并报告吞吐量、内存及正确性，
+// 用于比较调度、暂停、批量添加及下载等改动对引擎性能的影响；仅经命令行参数-a_bench开启，不影响正常运行
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/henrylee2cn/pholcus/app"
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+	"github.com/henrylee2cn/pholcus/app/spider"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+// 测试网页的生成参数
+type Options struct {
+	Pages   int           // 测试网页总数
+	Links   int           // 每个网页中的链接数
+	Latency time.Duration // 每个网页的响应延迟
+	Size    int           // 每个网页的大致字节数
+}
+
+// 默认的生成参数
+var DefaultOptions = Options{
+	Pages:   1000,
+	Links:   10,
+	Latency: 20 * time.Millisecond,
+	Size:    4096,
+}
+
+// 解析命令行参数-a_bench的值，格式为 页数,每页链接数,延迟ms,页面字节数，省略或为空的项采用DefaultOptions
+func ParseOptions(s string) (Options, error) {
+	opts := DefaultOptions
+	fields := strings.Split(s, ",")
+	if len(fields) > 4 {
+		return opts, fmt.Errorf("基准测试参数过多: %q", s)
+	}
+	targets := []*int{&opts.Pages, &opts.Links, nil, &opts.Size}
+	for i, field := range fields {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("基准测试参数无效: %q", field)
+		}
+		if targets[i] == nil {
+			opts.Latency = time.Duration(n) * time.Millisecond
+		} else {
+			*targets[i] = n
+		}
+	}
+	if opts.Pages < 1 {
+		return opts, errors.New("基准测试的页数须大于0")
+	}
+	return opts, nil
+}
+
+// 基准测试结果
+type Report struct {
+	Options
+	ThreadNum  int           // 全局并发量
+	Pausetime  int64         // 暂停时长参考/ms
+	Succeeded  uint64        // 下载成功的页数
+	Failed     uint64        // 下载失败的页数
+	Items      int           // 收集的结果数
+	Missing    int           // 可从0号网页到达但未收集到结果的测试网页数
+	Duplicates int           // 重复收集的结果数
+	Elapsed    time.Duration // 总用时
+	TotalAlloc uint64        // 期间累计分配的内存字节数
+	PeakHeap   uint64        // 期间堆内存的峰值字节数
+}
+
+// 每秒成功抓取的页数
+func (self *Report) Throughput() float64 {
+	if self.Elapsed <= 0 {
+		return 0
+	}
+	return float64(self.Succeeded) / self.Elapsed.Seconds()
+}
+
+// 是否完整且无重复地抓取了全部可到达的测试网页
+func (self *Report) OK() bool {
+	return self.Failed == 0 && self.Missing == 0 && self.Duplicates == 0
+}
+
+func (self *Report) String() string {
+	result := "通过"
+	if !self.OK() {
+		result = "未通过"
+	}
+	return fmt.Sprintf(
+		"基准测试: 页数 %v，每页链接 %v，延迟 %v，页面 %v 字节，并发 %v，暂停参考 %vms\n"+
+			"  用时 %v，吞吐量 %.1f 页/秒，成功 %v 页，失败 %v 页\n"+
+			"  累计分配内存 %.1f MB，堆内存峰值 %.1f MB\n"+
+			"  正确性: %v（结果 %v 条，缺失 %v 页，重复 %v 条）\n",
+		self.Pages, self.Links, self.Latency, self.Size, self.ThreadNum, self.Pausetime,
+		self.Elapsed.Truncate(time.Millisecond), self.Throughput(), self.Succeeded, self.Failed,
+		float64(self.TotalAlloc)/(1<<20), float64(self.PeakHeap)/(1<<20),
+		result, self.Items, self.Missing, self.Duplicates,
+	)
+}
+
+var (
+	// 参考蜘蛛在首次运行基准测试时才注册，以免出现在正常运行的蜘蛛列表中
+	referenceSpider *spider.Spider
+	registerOnce    sync.Once
+	// 测试网页的服务器根地址，仅在基准测试运行期间有效
+	fixtureUrl string
+
+	pageIdRe   = regexp.MustCompile(`<h1 id="page">(\d+)</h1>`)
+	pageLinkRe = regexp.MustCompile(`<a href="(/page/\d+)">`)
+)
+
+// 以正则而非Dom解析测试网页，使结果主要反映引擎本身的开销
+func register() {
+	referenceSpider = spider.Spider{
+		Name:            "基准测试",
+		Description:     "基准测试的参考蜘蛛 [仅用于-a_bench]",
+		NotDefaultField: true,
+		RuleTree: &spider.RuleTree{
+			Root: func(ctx *spider.Context) {
+				ctx.AddQueue(&request.Request{Url: fixtureUrl + "/page/0", Rule: "网页"})
+			},
+			Trunk: map[string]*spider.Rule{
+				"网页": {
+					ItemFields: []string{"编号", "链接数"},
+					ParseFunc: func(ctx *spider.Context) {
+						text := ctx.GetText()
+						links := pageLinkRe.FindAllStringSubmatch(text, -1)
+						reqs := make([]*request.Request, len(links))
+						for i, link := range links {
+							reqs[i] = &request.Request{Url: fixtureUrl + link[1], Rule: "网页"}
+						}
+						ctx.AddQueueBatch(reqs)
+						var id string
+						if m := pageIdRe.FindStringSubmatch(text); m != nil {
+							id = m[1]
+						}
+						ctx.Output(map[int]interface{}{0: id, 1: len(links)})
+					},
+				},
+			},
+		},
+	}.Register()
+}
+
+// 运行基准测试，其余运行参数(并发量、暂停时长等)取自cache.Task；
+// 期间不继承也不保存历史记录，须在未运行其他任务时调用
+func Run(opts Options) (*Report, error) {
+	registerOnce.Do(register)
+	fx, err := startFixture(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer fx.close()
+	fixtureUrl = fx.url()
+
+	success, failure := cache.Task.SuccessInherit, cache.Task.FailureInherit
+	cache.Task.SuccessInherit, cache.Task.FailureInherit = false, false
+	defer func() {
+		cache.Task.SuccessInherit, cache.Task.FailureInherit = success, failure
+	}()
+
+	report := &Report{
+		Options:   opts,
+		ThreadNum: cache.Task.ThreadNum,
+		Pausetime: cache.Task.Pausetime,
+	}
+
+	// 期间定时采样堆内存的峰值
+	runtime.GC()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+	report.PeakHeap = before.HeapAlloc
+	stop := make(chan bool)
+	sampled := make(chan bool)
+	go func() {
+		defer close(sampled)
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		var m runtime.MemStats
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				runtime.ReadMemStats(&m)
+				if m.HeapAlloc > report.PeakHeap {
+					report.PeakHeap = m.HeapAlloc
+				}
+			}
+		}
+	}()
+
+	start := time.Now()
+	items, err := app.RunSpider(context.Background(), referenceSpider, "", 0)
+	report.Elapsed = time.Since(start)
+	close(stop)
+	<-sampled
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+	report.TotalAlloc = after.TotalAlloc - before.TotalAlloc
+	report.Succeeded = cache.GetPageCount(1)
+	report.Failed = cache.GetPageCount(-1)
+
+	// 每个可到达的测试网页应恰好收集一条结果
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		fields, _ := item["Data"].(map[string]interface{})
+		id, _ := fields["编号"].(string)
+		if seen[id] {
+			report.Duplicates++
+			continue
+		}
+		seen[id] = true
+	}
+	report.Items = len(items)
+	for _, id := range fx.reachable() {
+		if !seen[strconv.Itoa(id)] {
+			report.Missing++
+		}
+	}
+	return report, err
+}
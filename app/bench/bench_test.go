@@ -0,0 +1,39 @@
+package bench
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseOptions(t *testing.T) {
+	opts, err := ParseOptions("200,,5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DefaultOptions
+	want.Pages, want.Latency = 200, 5*time.Millisecond
+	if opts != want {
+		t.Fatalf("got %+v, want %+v", opts, want)
+	}
+	for _, s := range []string{"0", "a", "-1", "1,2,3,4,5"} {
+		if _, err := ParseOptions(s); err == nil {
+			t.Fatalf("%q: expected error", s)
+		}
+	}
+}
+
+func TestFixtureLinks(t *testing.T) {
+	fx := &fixture{opts: Options{Pages: 50, Links: 3, Size: 512}}
+	// 从0号网页出发应可到达全部网页
+	if n := len(fx.reachable()); n != fx.opts.Pages {
+		t.Fatalf("reached %d pages, want %d", n, fx.opts.Pages)
+	}
+	if n := len(fx.render(7)); n != fx.opts.Size {
+		t.Fatalf("page size %d, want %d", n, fx.opts.Size)
+	}
+	// 无链接时仅可到达0号网页
+	fx.opts.Links = 0
+	if ids := fx.reachable(); len(ids) != 1 || ids[0] != 0 {
+		t.Fatalf("Links=0: reached %v, want [0]", ids)
+	}
+}
@@ -0,0 +1,99 @@
+package bench
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 本地测试网页服务器，生成编号为0至Pages-1的网页，网页N链接至 (N*Links+1+i) % Pages (0<=i<Links)，
+// Links大于0时从0号网页出发可到达全部网页，末层网页的链接回指已有网页，以检验请求去重
+type fixture struct {
+	opts     Options
+	listener net.Listener
+	server   *http.Server
+}
+
+// 在127.0.0.1的随机端口启动测试网页服务器
+func startFixture(opts Options) (*fixture, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	self := &fixture{opts: opts, listener: ln}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page/", self.page)
+	self.server = &http.Server{Handler: mux}
+	go self.server.Serve(ln)
+	return self, nil
+}
+
+// 测试网页服务器的根地址，如http://127.0.0.1:12345
+func (self *fixture) url() string {
+	return "http://" + self.listener.Addr().String()
+}
+
+func (self *fixture) close() {
+	self.server.Close()
+}
+
+// 按延迟返回编号对应的网页，编号无效时返回404
+func (self *fixture) page(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/page/"))
+	if err != nil || id < 0 || id >= self.opts.Pages {
+		http.NotFound(w, r)
+		return
+	}
+	if self.opts.Latency > 0 {
+		time.Sleep(self.opts.Latency)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(self.render(id))
+}
+
+// 生成网页内容，以填充段落补足至Size字节
+func (self *fixture) render(id int) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<html><head><title>page %d</title></head><body>\n<h1 id=\"page\">%d</h1>\n", id, id)
+	for _, link := range self.links(id) {
+		fmt.Fprintf(&b, "<a href=\"/page/%d\">page %d</a>\n", link, link)
+	}
+	const tail = "</body></html>\n"
+	if pad := self.opts.Size - b.Len() - len(tail) - len("<p></p>\n"); pad > 0 {
+		b.WriteString("<p>")
+		b.WriteString(strings.Repeat("x", pad))
+		b.WriteString("</p>\n")
+	}
+	b.WriteString(tail)
+	return []byte(b.String())
+}
+
+// 网页链接的各网页编号
+func (self *fixture) links(id int) []int {
+	if self.opts.Pages <= 1 {
+		return nil
+	}
+	links := make([]int, self.opts.Links)
+	for i := range links {
+		links[i] = (id*self.opts.Links + 1 + i) % self.opts.Pages
+	}
+	return links
+}
+
+// 从0号网页出发可到达的各网页编号，按到达顺序排列；Links为0时仅有0号网页
+func (self *fixture) reachable() []int {
+	seen := map[int]bool{0: true}
+	ids := []int{0}
+	for i := 0; i < len(ids); i++ {
+		for _, link := range self.links(ids[i]) {
+			if !seen[link] {
+				seen[link] = true
+				ids = append(ids, link)
+			}
+		}
+	}
+	return ids
+}
@@ -2,11 +2,17 @@ package crawler
 
 import (
 	"math/rand"
+	"runtime/debug"
+	"strconv"
 	"time"
 
 	"github.com/henrylee2cn/pholcus/app/downloader"
 	"github.com/henrylee2cn/pholcus/app/downloader/request"
+	"github.com/henrylee2cn/pholcus/app/event"
 	"github.com/henrylee2cn/pholcus/app/pipeline"
+	"github.com/henrylee2cn/pholcus/app/pipeline/collector"
+	"github.com/henrylee2cn/pholcus/app/pipeline/collector/data"
+	"github.com/henrylee2cn/pholcus/app/scheduler"
 	"github.com/henrylee2cn/pholcus/app/spider"
 	"github.com/henrylee2cn/pholcus/logs"
 	"github.com/henrylee2cn/pholcus/runtime/cache"
@@ -15,24 +21,33 @@ import (
 // 采集引擎
 type (
 	Crawler interface {
-		Init(*spider.Spider) Crawler //初始化采集引擎
-		Run()                        //运行任务
-		Stop()                       //主动终止
-		GetId() int                  //获取引擎ID
+		Init(*spider.Spider) Crawler              //初始化采集引擎
+		Run()                                     //运行任务
+		Stop()                                    //主动终止
+		GetId() int                               //获取引擎ID
+		SetMemory(collector.MemoryBuffer) Crawler //指定本次任务内存输出的缓冲区
 	}
 	crawler struct {
-		*spider.Spider                 //执行的采集规则
-		downloader.Downloader          //全局公用的下载器
-		pipeline.Pipeline              //结果收集与输出管道
-		id                    int      //引擎ID
-		pause                 [2]int64 //[请求间隔的最短时长,请求间隔的增幅时长]
+		*spider.Spider                             //执行的采集规则
+		downloader.Downloader                      //全局公用的下载器
+		pipeline.Pipeline                          //结果收集与输出管道
+		id                    int                  //引擎ID
+		pause                 [2]int64             //[请求间隔的最短时长,请求间隔的增幅时长]
+		ruleLimits            map[string]chan bool //[规则名]并发量控制，仅包含设置了Concurrency的规则
+		rulePauses            map[string][2]int64  //[规则名]请求间隔，格式同pause，仅包含设置了MinPause或MaxPause的规则
+		orderer               *orderer             //按请求入队顺序输出结果，未开启有序输出时为nil
 	}
 )
 
 func New(id int) Crawler {
+	return NewWithPipeline(id, pipeline.New())
+}
+
+// 创建使用指定结果收集管道的采集引擎
+func NewWithPipeline(id int, p pipeline.Pipeline) Crawler {
 	return &crawler{
 		id:         id,
-		Pipeline:   pipeline.New(),
+		Pipeline:   p,
 		Downloader: downloader.SurferDownloader,
 	}
 }
@@ -40,11 +55,42 @@ func New(id int) Crawler {
 func (self *crawler) Init(sp *spider.Spider) Crawler {
 	self.Spider = sp.ReqmatrixInit()
 	self.Pipeline.Init(sp)
-	self.pause[0] = cache.Task.Pausetime / 2
-	if self.pause[0] > 0 {
-		self.pause[1] = self.pause[0] * 3
+	// 优先使用蜘蛛指定的下载器
+	if sp.Downloader != nil {
+		self.Downloader = sp.Downloader
 	} else {
-		self.pause[1] = 1
+		self.Downloader = downloader.SurferDownloader
+	}
+	// 预热种子请求所在主机的连接
+	if warmer, ok := self.Downloader.(interface {
+		Warmup(*request.Request)
+	}); ok && cache.Task.Warmup {
+		sp.SetWarmup(warmer.Warmup)
+	}
+	self.pause = pauseRange(cache.Task.Pausetime)
+	self.ruleLimits = make(map[string]chan bool)
+	for ruleName, rule := range sp.RuleTree.Trunk {
+		if rule.Concurrency > 0 {
+			self.ruleLimits[ruleName] = make(chan bool, rule.Concurrency)
+		}
+	}
+	self.rulePauses = make(map[string][2]int64)
+	for ruleName, rule := range sp.RuleTree.Trunk {
+		if rule.MinPause <= 0 && rule.MaxPause <= 0 {
+			continue
+		}
+		min, max := rule.MinPause, rule.MaxPause
+		if min < 0 {
+			min = 0
+		}
+		if max < min {
+			max = min
+		}
+		self.rulePauses[ruleName] = [2]int64{min, max - min + 1}
+	}
+	self.orderer = nil
+	if cache.Task.OrderedOutput {
+		self.orderer = newOrderer(self.Pipeline.CollectData)
 	}
 	return self
 }
@@ -62,10 +108,16 @@ func (self *crawler) Run() {
 	}()
 
 	// 启动任务
+	event.Emit(event.Event{Type: event.CRAWL_START, Spider: self.Spider.GetName()})
+	self.Spider.CallOnStart()
 	self.Spider.Start()
 
 	<-c // 等待处理协程退出
 
+	// 无论正常完成或主动终止，均调用结束回调
+	self.Spider.CallOnStop()
+	event.Emit(event.Event{Type: event.CRAWL_STOP, Spider: self.Spider.GetName()})
+
 	// 停止数据收集/输出管道
 	self.Pipeline.Stop()
 }
@@ -79,70 +131,261 @@ func (self *crawler) Stop() {
 func (self *crawler) run() {
 	for {
 		// 队列中取出一条请求并处理
-		if req := self.GetOne(); req == nil {
+		req := self.GetOne()
+		if req == nil {
 			// 停止任务
 			if self.Spider.CanStop() {
 				break
 			}
 
-		} else {
-			// 执行请求
-			self.UseOne()
-			go func(req *request.Request) {
-				defer func() {
-					self.FreeOne()
-				}()
-				logs.Log.Debug(" *     Start: %v", req.GetUrl())
-				self.Process(req)
-			}(req)
+		} else if self.Spider.SampleFull(req.GetRuleName()) {
+			// 预览模式下该规则已解析足够的页面，不再下载，亦无需等待
+			if self.orderer != nil {
+				self.orderer.skip(req.GetSeq())
+			}
+			// 放弃该请求，使串行规则的队列可取出下一个请求
+			self.Spider.RequestSkip(req)
+			logs.Log.Debug(" *     Skip  [sample]: %v\n", req.GetUrl())
+			continue
+
+		} else if self.skipExists(req) {
+			// 文件已存在，未占用并发量，亦无需等待
+			continue
+
+		} else if !self.dispatch(req) {
+			// 所属规则的并发量或所需并发池已满，放回后稍后再取，以免阻塞其他规则或并发池的请求
+			self.Spider.RequestRequeue(req)
+			time.Sleep(requeueWait)
+			continue
 		}
 
 		// 随机等待
-		self.sleep()
+		self.sleep(req)
 	}
 
 	// 等待处理中的任务完成
 	self.Spider.Defer()
+
+	// 输出有序输出模式下剩余的结果
+	if self.orderer != nil {
+		self.orderer.flush()
+	}
+
+	// 汇总规则解析时发生的panic
+	for ruleName, n := range self.Spider.GetPanics() {
+		logs.Log.Error(" *     [%v] 规则 %v 解析时共发生 %v 次panic\n", self.Spider.GetName(), ruleName, n)
+	}
+
+	// Context复用池的命中情况，用于调整Spider.ContextPool
+	hits, misses := self.Spider.GetContextPoolStats()
+	logs.Log.Debug(" *     [%v] Context复用池命中 %v 次，新建 %v 次\n", self.Spider.GetName(), hits, misses)
+}
+
+// 开启run::fileskipexists且请求对应的文件已存在时跳过下载，视为成功并返回true；
+// 在占用并发量之前检查，以免跳过的请求等待并发空位及暂停时长
+func (self *crawler) skipExists(req *request.Request) bool {
+	if !cache.Task.FileSkipExists || !self.Pipeline.HasFile(req) {
+		return false
+	}
+	self.Spider.DoHistory(req, true)
+	cache.FileSkipCount()
+	if self.orderer != nil {
+		self.orderer.skip(req.GetSeq())
+	}
+	// 放弃该请求，使串行规则的队列可取出下一个请求
+	self.Spider.RequestSkip(req)
+	logs.Log.Informational(" *     Skip  [file exists]: %v\n", req.GetUrl())
+	return true
+}
+
+// 资源不足而放回请求后，再次取出前的等待时长
+const requeueWait = 10 * time.Millisecond
+
+// 不等待地占用规则级并发量及并发池后执行请求，任一已满时不执行并返回false；
+// 先占用规则级并发量，以免等待中的请求占用全局并发量
+func (self *crawler) dispatch(req *request.Request) bool {
+	limit, limited := self.ruleLimits[req.GetRuleName()]
+	if limited {
+		select {
+		case limit <- true:
+		default:
+			return false
+		}
+	}
+	if !self.Spider.RequestTryUse(req) {
+		if limited {
+			<-limit
+		}
+		return false
+	}
+	go func() {
+		defer func() {
+			self.FreeOne(req)
+			if limited {
+				<-limit
+			}
+		}()
+		logs.Log.Debug(" *     Start: %v", req.GetUrl())
+		self.Process(req)
+	}()
+	return true
 }
 
 // core processer
 func (self *crawler) Process(req *request.Request) {
+	// 有序输出模式下，处理结束时按入队序号提交结果，仍将重新执行的失败请求待重新执行后再提交
+	var items []data.DataCell
+	if self.orderer != nil {
+		defer func() {
+			if !self.Spider.WillRetry(req) {
+				self.orderer.done(req.GetSeq(), items)
+			}
+		}()
+	}
+
+	// 开启HEAD比对且网页未变化时跳过下载及解析，视为成功
+	var meta *spider.PageMeta
+	if self.Spider.HeadCheck {
+		var unchanged bool
+		if meta, unchanged = self.headCheck(req); unchanged {
+			self.Spider.DoHistory(req, true)
+			logs.Log.Informational(" *     Skip  [unchanged]: %v\n", req.GetUrl())
+			return
+		}
+	}
+
+	// 按规则选择下载网络原始响应或渲染后的网页
+	both := self.Spider.SetBodyDownloader(req)
+
 	var (
-		ctx     = self.Downloader.Download(self.Spider, req) // download page
+		start   = time.Now()
+		ctx     = self.Downloader.Download(self.Spider, self.resume(self.Spider.UseSession(req))) // download page
 		downUrl = req.GetUrl()
 	)
+	scheduler.RecordTiming(time.Since(start), ctx.GetError())
+	self.Spider.CheckSession(ctx)
+
+	// 渲染失败时改以Surf内核下载网络原始响应，成功后无需另行下载原始响应
+	if ctx.GetError() != nil && self.Spider.RenderFallback && req.GetDownloaderID() == request.PHANTOM_ID {
+		if fctx, ok := self.renderFallback(ctx); ok {
+			ctx, both = fctx, false
+		}
+	}
 
 	if err := ctx.GetError(); err != nil {
+		self.Spider.RecordFailure(req, err)
+		// 永久性错误不再重试
+		if !cache.Task.RetryAllErrors && request.IsPermanent(err) {
+			self.Spider.DoPermanentFailure(req)
+			cache.PageFailCount()
+			logs.Log.Error(" *     Fail  [download][%v][%v]: %v (不再重试)\n", request.ErrorClass(err), downUrl, err)
+			return
+		}
 		// 返回是否作为新的失败请求被添加至队列尾部
 		if self.Spider.DoHistory(req, false) {
 			// 统计失败数
 			cache.PageFailCount()
 		}
 		// 提示错误
-		logs.Log.Error(" *     Fail  [download][%v]: %v\n", downUrl, err)
+		logs.Log.Error(" *     Fail  [download][%v][%v]: %v\n", request.ErrorClass(err), downUrl, err)
 		return
 	}
 
+	// 同时下载网络原始响应，失败时仅记录警告，仍以渲染后的网页解析
+	if both {
+		self.downloadSource(ctx)
+	}
+
+	var canonical string
 	defer func() {
 		if err := recover(); err != nil {
 			if activeStop, _ := err.(string); activeStop == spider.ACTIVE_STOP {
 				return
 			}
+			if canonical != "" {
+				self.Spider.ForgetCanonical(canonical)
+			}
+			stack := debug.Stack()
+			self.Spider.RecordPanic(req.GetRuleName(), err, stack)
+			self.Spider.RecordFailure(req, request.PanicError(err))
 			// 返回是否作为新的失败请求被添加至队列尾部
 			if self.Spider.DoHistory(req, false) {
 				// 统计失败数
 				cache.PageFailCount()
 			}
 			// 提示错误
-			logs.Log.Error(" *     Panic  [process][%v]: %v\n", downUrl, err)
+			logs.Log.Error(" *     Panic  [process][%v]: %v\n%s", downUrl, err, stack)
+			// 保存下载中断前已读取的文件部分，以便续传
+			for _, f := range ctx.PullFiles() {
+				if partial, _ := f["Partial"].(bool); partial {
+					self.Pipeline.CollectFile(f)
+				}
+			}
 		}
 	}()
 
+	// 响应未通过规则的校验时按下载失败处理，封禁或校验失败时重试将更换代理IP，匹配重试规则时先退避
+	if err := ctx.Validate(); err != nil {
+		self.Spider.RecordFailure(req, err)
+		switch request.ErrorClass(err) {
+		case request.ERR_INVALID, request.ERR_BLOCKED:
+			scheduler.ChangeProxy(req)
+		case request.ERR_PATTERN:
+			ctx.Backoff(req.GetRetryPause())
+		}
+		// 返回是否作为新的失败请求被添加至队列尾部
+		if self.Spider.DoHistory(req, false) {
+			// 统计失败数
+			cache.PageFailCount()
+		}
+		logs.Log.Error(" *     Fail  [validate][%v][%v]: %v\n", request.ErrorClass(err), downUrl, err)
+		spider.PutContext(ctx)
+		return
+	}
+
+	// 跟随软跳转时已添加目标网址的请求，本页不再解析，视为成功
+	if target, ok := ctx.FollowSoftRedirect(); ok {
+		self.Spider.DoHistory(req, true)
+		logs.Log.Informational(" *     Redirect  [soft]: %v -> %v\n", downUrl, target)
+		spider.PutContext(ctx)
+		return
+	}
+
+	// 规范网址已处理过时丢弃，视为成功
+	if cache.Task.Canonical {
+		if canonical = ctx.GetCanonical(); canonical != "" && self.Spider.SeenCanonical(canonical) {
+			self.Spider.DoHistory(req, true)
+			logs.Log.Informational(" *     Skip  [canonical]: %v -> %v\n", downUrl, canonical)
+			spider.PutContext(ctx)
+			return
+		}
+	}
+
+	// 无数据页面不解析，视为成功并单独计数
+	if ctx.IsEmpty() {
+		self.Spider.DoHistory(req, true)
+		cache.PageSuccCount()
+		cache.PageEmptyCount()
+		logs.Log.Informational(" *     Success [empty]: %v\n", downUrl)
+		spider.PutContext(ctx)
+		return
+	}
+
+	// 预览模式下该规则已解析足够的页面时丢弃
+	if !self.Spider.TakeSample(req.GetRuleName()) {
+		logs.Log.Debug(" *     Skip  [sample]: %v\n", downUrl)
+		spider.PutContext(ctx)
+		return
+	}
+
 	// 过程处理，提炼数据
 	ctx.Parse(req.GetRuleName())
 
 	// 处理成功请求记录
 	self.Spider.DoHistory(req, true)
+	if meta != nil {
+		self.Spider.SetPageMeta(req.GetUrl(), *meta)
+	}
 
 	// 统计成功页数
 	cache.PageSuccCount()
@@ -150,9 +393,15 @@ func (self *crawler) Process(req *request.Request) {
 	// 提示抓取成功
 	logs.Log.Informational(" *     Success: %v\n", downUrl)
 
-	// 该条请求文本结果存入pipeline
-	for _, item := range ctx.PullItems() {
-		self.Pipeline.CollectData(item)
+	// 该条请求文本结果存入pipeline，开启幂等收集时丢弃重试前已收集过的结果
+	items = ctx.PullItems()
+	if cache.Task.CollectOnce {
+		items = self.Spider.CollectOnce(req, items)
+	}
+	if self.orderer == nil {
+		for _, item := range items {
+			self.Pipeline.CollectData(item)
+		}
 	}
 	// 该条请求文件结果存入pipeline
 	for _, f := range ctx.PullFiles() {
@@ -163,24 +412,112 @@ func (self *crawler) Process(req *request.Request) {
 }
 
 // 常用基础方法
-func (self *crawler) sleep() {
-	sleeptime := self.pause[0] + rand.Int63n(self.pause[1])
+// 随机等待，刚派发的请求所属规则设置了暂停区间时采用该区间，否则采用全局暂停区间
+func (self *crawler) sleep(req *request.Request) {
+	pause := self.pause
+	if p, ok := self.Spider.GetAdjustedPause(); ok {
+		// 规则动态调整的暂停时长作用于全部规则
+		pause = pauseRange(p)
+	} else if req != nil {
+		if p, ok := self.rulePauses[req.GetRuleName()]; ok {
+			pause = p
+		}
+	}
+	sleeptime := pause[0] + rand.Int63n(pause[1])
 	time.Sleep(time.Duration(sleeptime) * time.Millisecond)
 }
 
+// 暂停时长参考对应的随机暂停区间(pausetime/2 ~ pausetime*2)，格式同crawler.pause
+func pauseRange(pausetime int64) (pause [2]int64) {
+	pause[0] = pausetime / 2
+	if pause[0] > 0 {
+		pause[1] = pause[0] * 3
+	} else {
+		pause[1] = 1
+	}
+	return
+}
+
+// 发送HEAD请求获取网页的新鲜度元数据，并返回是否与上次抓取成功时相同；
+// 仅支持Surf内核的GET请求，HEAD请求失败或响应未提供元数据时返回nil
+func (self *crawler) headCheck(req *request.Request) (*spider.PageMeta, bool) {
+	if req.GetMethod() != "GET" || req.GetDownloaderID() != request.SURF_ID {
+		return nil, false
+	}
+	head := req.Copy()
+	head.SetMethod("HEAD")
+	ctx := self.Downloader.Download(self.Spider, head)
+	defer spider.PutContext(ctx)
+	if ctx.GetError() != nil || ctx.Response == nil {
+		return nil, false
+	}
+	ctx.Response.Body.Close()
+	meta, ok := spider.NewPageMeta(ctx.Response)
+	if !ok {
+		return nil, false
+	}
+	old, ok := self.Spider.GetPageMeta(req.GetUrl())
+	return &meta, ok && meta.Unchanged(old)
+}
+
+// 以Surf内核下载网络原始响应，并设置于渲染后网页的上下文中
+func (self *crawler) downloadSource(ctx *spider.Context) {
+	source := ctx.Request.Copy()
+	source.SetDownloaderID(request.SURF_ID)
+	sctx := self.Downloader.Download(self.Spider, self.Spider.UseSession(source))
+	if err := sctx.GetError(); err != nil {
+		logs.Log.Warning(" *     原始响应下载失败 [%v]: %v\n", source.GetUrl(), err)
+		spider.PutContext(sctx)
+		return
+	}
+	ctx.SetSourceContext(sctx)
+}
+
+// 以Surf内核重新下载渲染失败的网页，成功时回收渲染失败的上下文，返回标记了渲染错误的新上下文及true；
+// 仍失败时保留原上下文，按渲染失败处理
+func (self *crawler) renderFallback(ctx *spider.Context) (*spider.Context, bool) {
+	renderErr := ctx.GetError()
+	raw := ctx.Request.Copy()
+	raw.SetDownloaderID(request.SURF_ID)
+	fctx := self.Downloader.Download(self.Spider, self.Spider.UseSession(raw))
+	if err := fctx.GetError(); err != nil {
+		logs.Log.Warning(" *     渲染失败后的原始响应下载亦失败 [%v]: %v\n", raw.GetUrl(), err)
+		spider.PutContext(fctx)
+		return ctx, false
+	}
+	logs.Log.Warning(" *     渲染失败，改以未执行js的原始响应解析 [%v]: %v\n", raw.GetUrl(), renderErr)
+	spider.PutContext(ctx)
+	return fctx.SetRenderError(renderErr), true
+}
+
+// 开启断点续传且文件已下载部分时，返回带有Range请求头的请求副本
+func (self *crawler) resume(req *request.Request) *request.Request {
+	if !cache.Task.FileResume || !req.IsFile() || req.GetSampleRange() != "" {
+		return req
+	}
+	size := self.Pipeline.PartSize(req)
+	if size <= 0 {
+		return req
+	}
+	reqcopy := req.Copy()
+	reqcopy.Header.Set("Range", "bytes="+strconv.FormatInt(size, 10)+"-")
+	logs.Log.Informational(" *     Resume [%v bytes]: %v\n", size, req.GetUrl())
+	return reqcopy
+}
+
 // 从调度读取一个请求
 func (self *crawler) GetOne() *request.Request {
 	return self.Spider.RequestPull()
 }
 
 //从调度使用一个资源空位
-func (self *crawler) UseOne() {
-	self.Spider.RequestUse()
+func (self *crawler) UseOne(req *request.Request) {
+	self.Spider.RequestUse(req)
 }
 
 //从调度释放一个资源空位
-func (self *crawler) FreeOne() {
-	self.Spider.RequestFree()
+func (self *crawler) FreeOne(req *request.Request) {
+	self.Spider.RequestFree(req)
 }
 
 func (self *crawler) SetId(id int) {
@@ -190,3 +527,10 @@ func (self *crawler) SetId(id int) {
 func (self *crawler) GetId() int {
 	return self.id
 }
+
+func (self *crawler) SetMemory(buf collector.MemoryBuffer) Crawler {
+	if c, ok := self.Pipeline.(*collector.Collector); ok {
+		c.SetMemory(buf)
+	}
+	return self
+}
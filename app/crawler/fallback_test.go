@@ -0,0 +1,55 @@
+package crawler
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+	"github.com/henrylee2cn/pholcus/app/spider"
+)
+
+// 记录下载的请求，err不为nil时下载失败
+type fakeDownloader struct {
+	err  error
+	reqs []*request.Request
+}
+
+func (self *fakeDownloader) Download(sp *spider.Spider, req *request.Request) *spider.Context {
+	self.reqs = append(self.reqs, req)
+	ctx := spider.GetContext(sp, req)
+	ctx.SetError(self.err)
+	return ctx
+}
+
+func renderFailed(sp *spider.Spider, err error) *spider.Context {
+	req := &request.Request{Url: "http://a/", Rule: "r", DownloaderID: request.PHANTOM_ID}
+	ctx := spider.GetContext(sp, req)
+	ctx.SetError(err)
+	return ctx
+}
+
+// 渲染失败后以Surf内核重新下载，成功时返回标记了渲染错误的新上下文，失败时保留原上下文
+func TestRenderFallback(t *testing.T) {
+	renderErr := errors.New("phantomjs crashed")
+	sp := &spider.Spider{Name: "test"}
+
+	dl := &fakeDownloader{}
+	c := &crawler{Spider: sp, Downloader: dl}
+	fctx, ok := c.renderFallback(renderFailed(sp, renderErr))
+	if !ok || fctx.GetError() != nil || fctx.GetRenderError() != renderErr {
+		t.Fatalf("ok = %v, err = %v, render error = %v", ok, fctx.GetError(), fctx.GetRenderError())
+	}
+	if len(dl.reqs) != 1 || dl.reqs[0].GetDownloaderID() != request.SURF_ID {
+		t.Fatalf("downloaded %v, want one surf request", dl.reqs)
+	}
+	if fctx.Request.GetDownloaderID() != request.SURF_ID {
+		t.Errorf("fallback context downloader = %v, want surf", fctx.Request.GetDownloaderID())
+	}
+
+	dl = &fakeDownloader{err: errors.New("connection refused")}
+	c = &crawler{Spider: sp, Downloader: dl}
+	ctx := renderFailed(sp, renderErr)
+	if fctx, ok = c.renderFallback(ctx); ok || fctx != ctx || fctx.GetError() != renderErr {
+		t.Errorf("ok = %v, err = %v, want the original render failure", ok, fctx.GetError())
+	}
+}
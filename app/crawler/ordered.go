@@ -0,0 +1,103 @@
+package crawler
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/henrylee2cn/pholcus/app/pipeline/collector/data"
+	"github.com/henrylee2cn/pholcus/logs"
+)
+
+// 按请求入队序号输出文本结果，开启run::orderedoutput时使用；
+// 请求处理完成（无论成功与否）或被放弃(skip)后，其结果须等待所有序号更小的请求处理完成才输出；
+// 仍将重新执行的失败请求不提交，以便重新执行后按原序号输出；
+// 等待中的序号超过maxPending个时，不再等待最早缺失的序号，此后该序号的结果及未分配序号的请求的结果在处理完成时直接输出
+type orderer struct {
+	next    int64                     // 下一个待输出的序号
+	pending map[int64][]data.DataCell // [序号]已处理完成、等待前序请求的结果
+	emit    func(data.DataCell)
+	lock    sync.Mutex
+}
+
+// 有序输出最多缓存的已完成序号数，超出后跳过最早缺失的序号，以免某个请求迟迟未完成时无限缓存
+const maxPending = 10000
+
+func newOrderer(emit func(data.DataCell)) *orderer {
+	return &orderer{
+		next:    1,
+		pending: make(map[int64][]data.DataCell),
+		emit:    emit,
+	}
+}
+
+// 记录该序号的请求已处理完成，并输出所有前序请求均已完成的结果
+func (self *orderer) done(seq int64, items []data.DataCell) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if seq < self.next {
+		for _, item := range items {
+			self.emit(item)
+		}
+		return
+	}
+	self.pending[seq] = append(self.pending[seq], items...)
+	self.release()
+	if len(self.pending) > maxPending {
+		// 跳过缺失的序号，自最早的已完成序号继续输出
+		skipped := self.next
+		self.next = self.minPending()
+		logs.Log.Warning(" *     有序输出等待的结果过多，跳过未完成的序号 %v~%v\n", skipped, self.next-1)
+		self.release()
+	}
+}
+
+// 放弃该序号的请求而不输出结果，如预览模式下不再下载的请求，使后续请求的结果不再等待它
+func (self *orderer) skip(seq int64) {
+	self.done(seq, nil)
+}
+
+// 输出自next起连续的已完成序号的结果，需在加锁状态下调用
+func (self *orderer) release() {
+	for {
+		items, ok := self.pending[self.next]
+		if !ok {
+			return
+		}
+		delete(self.pending, self.next)
+		for _, item := range items {
+			self.emit(item)
+		}
+		self.next++
+	}
+}
+
+// 最小的已完成序号，需在加锁状态下调用
+func (self *orderer) minPending() int64 {
+	min := int64(-1)
+	for seq := range self.pending {
+		if min < 0 || seq < min {
+			min = seq
+		}
+	}
+	return min
+}
+
+// 按序号输出全部剩余的结果，任务结束时调用，此时未处理的请求不再等待
+func (self *orderer) flush() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	seqs := make([]int64, 0, len(self.pending))
+	for seq := range self.pending {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	for _, seq := range seqs {
+		for _, item := range self.pending[seq] {
+			self.emit(item)
+		}
+		delete(self.pending, seq)
+		if seq >= self.next {
+			self.next = seq + 1
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package crawler
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/henrylee2cn/pholcus/app/pipeline/collector/data"
+)
+
+func testOrderer() (*orderer, *[]string) {
+	var out []string
+	return newOrderer(func(cell data.DataCell) {
+		out = append(out, cell["Url"].(string))
+	}), &out
+}
+
+func cells(urls ...string) []data.DataCell {
+	items := make([]data.DataCell, len(urls))
+	for i, url := range urls {
+		items[i] = data.DataCell{"Url": url}
+	}
+	return items
+}
+
+// 结果按序号输出，被放弃的序号不再阻塞后续结果
+func TestOrdererSkip(t *testing.T) {
+	o, out := testOrderer()
+	o.done(3, cells("c"))
+	o.done(1, cells("a"))
+	if fmt.Sprint(*out) != "[a]" {
+		t.Fatalf("got %v, want [a]", *out)
+	}
+	o.skip(2)
+	if fmt.Sprint(*out) != "[a c]" {
+		t.Fatalf("got %v after skip, want [a c]", *out)
+	}
+}
+
+// 等待的序号超出上限后跳过最早缺失的序号
+func TestOrdererBounded(t *testing.T) {
+	o, out := testOrderer()
+	for seq := int64(2); seq <= maxPending+1; seq++ {
+		o.done(seq, cells(fmt.Sprint(seq)))
+	}
+	if len(*out) != 0 || len(o.pending) != maxPending {
+		t.Fatalf("emitted %d, pending %d before the cap", len(*out), len(o.pending))
+	}
+	o.done(maxPending+2, cells("last"))
+	if len(*out) != maxPending+1 || len(o.pending) != 0 || (*out)[0] != "2" {
+		t.Fatalf("emitted %d, pending %d after the cap", len(*out), len(o.pending))
+	}
+	// 被跳过的序号随后完成时直接输出
+	o.done(1, cells("late"))
+	if (*out)[len(*out)-1] != "late" {
+		t.Fatalf("late result not emitted: %v", (*out)[len(*out)-1])
+	}
+}
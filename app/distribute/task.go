@@ -2,17 +2,62 @@
 
 // 用于分布式分发的任务
 type Task struct {
-	Id             int
-	Spiders        []map[string]string // 蜘蛛规则name字段与keyin字段，规定格式map[string]string{"name":"baidu","keyin":"henry"}
-	ThreadNum      int                 // 全局最大并发量
-	Pausetime      int64               // 暂停时长参考/ms(随机: Pausetime/2 ~ Pausetime*2)
-	OutType        string              // 输出方式
-	DockerCap      int                 // 分段转储容器容量
-	DockerQueueCap int                 // 分段输出池容量，不小于2
-	SuccessInherit bool                // 继承历史成功记录
-	FailureInherit bool                // 继承历史失败记录
-	Limit          int64               // 采集上限，0为不限，若在规则中设置初始值为LIMIT则为自定义限制，否则默认限制请求数
-	ProxyMinute    int64               // 代理IP更换的间隔分钟数
+	Id                  int
+	Spiders             []map[string]string // 蜘蛛规则name字段与keyin字段，规定格式map[string]string{"name":"baidu","keyin":"henry"}
+	ThreadNum           int                 // 全局最大并发量
+	Pausetime           int64               // 暂停时长参考/ms(随机: Pausetime/2 ~ Pausetime*2)
+	OutType             string              // 输出方式
+	DockerCap           int                 // 分段转储容器容量
+	DockerQueueCap      int                 // 分段输出池容量，不小于2
+	SuccessInherit      bool                // 继承历史成功记录
+	FailureInherit      bool                // 继承历史失败记录
+	Limit               int64               // 采集上限，0为不限，若在规则中设置初始值为LIMIT则为自定义限制，否则默认限制请求数
+	ProxyMinute         int64               // 代理IP更换的间隔分钟数
+	PriorityAging       float64             // 优先级老化速率，即请求每等待1秒所提升的优先级，0为严格按优先级调度
+	StopGrace           int64               // 队列为空后确认停止前的等待秒数，0为立即停止
+	MaxRequests         int64               // 本次任务处理请求的总数上限，0为不限
+	FileSkipExists      bool                // 文件输出时跳过已存在的文件，不再重复下载
+	MaxFailRate         float64             // 最近100个请求的失败率达到该值(0~1)时终止任务，0为不限
+	MaxConsecutiveFails int                 // 连续失败的请求数达到该值时终止任务，0为不限
+	RetryAllErrors      bool                // 是否重试所有失败的请求，为false时不重试4xx（408、429除外）等永久性错误
+	MaxRunTime          int64               // 任务运行时长上限/s，超出后停止派发新请求并等待处理中的请求完成，0为不限
+	FileThreadNum       int                 // 文件下载请求（设置了FileName的请求）的独立并发量，0为与其他请求共用全局并发量
+	FileResume          bool                // 是否断点续传文件，下载中断时保留已下载部分，再次下载时以Range请求续传
+	MaxRetries          int                 // 请求失败后在队列末尾重新执行的默认次数，可由Request.MaxRetries逐个指定
+	Strategy            string              // 抓取策略，bfs为广度优先(先进先出)，dfs为深度优先(后进先出)，优先级不同时仍按优先级调度
+	Canonical           bool                // 是否按网页<link rel="canonical">中的规范网址去重，并以规范网址作为结果的Url字段
+	InvalidUTF8         string              // 结果中非法UTF-8字符串的处理方式，none为不处理，replace为替换为U+FFFD，strip为删除，error为丢弃整条数据
+	OrderedOutput       bool                // 是否按请求入队顺序输出文本结果，须缓存先完成的请求的结果
+	CollectorThreadNum  int                 // 文本数据收集输出的并发协程数，各协程独立分批输出，有序输出模式下固定为1
+	OutputSchema        bool                // 任务开始时是否在文本结果目录输出各规则结果字段的说明文件(json)
+	DNSCacheTTL         int64               // 下载器DNS缓存的时长/s，0为不缓存，即每次连接均按系统或自定义解析器解析
+	DNSCacheSize        int                 // 下载器DNS缓存最多缓存的主机数
+	OnDuplicate         string              // 数据库输出遇到重复键时的处理方式：fail整批失败，skip跳过重复的行，update以新数据更新重复的行
+	StartJitter         int64               // 分布式子节点开始执行任务前的随机延迟上限秒数，使集群的抓取压力平缓上升，0为不延迟
+	CollectOnce         bool                // 是否为每条结果生成幂等键（请求指纹加字段值的哈希），请求重试或重新执行时不重复收集相同的结果
+	URLNormalize        bool                // 去重前是否规范化网址：主机名小写，去除默认端口、锚点及路径末尾的斜杠，查询参数排序，并去除dropparams中的参数
+	DropParams          string              // 规范化网址时去除的跟踪参数，以逗号分隔，以*结尾时按前缀匹配
+	RotateRows          int                 // csv、excel输出时单个文件的最大结果数，超出时另起新文件并重新写入表头，0为不限（每批输出仍各为一个文件）
+	RotateInterval      int64               // 按时长滚动输出的间隔秒数，距上次输出达到该时长时即输出已缓存的结果，使文件按时段关闭以便下游逐个处理，0为不按时长滚动
+	OutputWait          int64               // 输出目标(如Mysql、MongoDB)在任务开始时不可用时，等待其恢复的最长秒数，0为立即放弃本次任务
+	SeedWait            int64               // 任务开始时请求队列为空的最长等待秒数，期间收到首个请求前不停止任务，以免分布式种子迟到时提前结束，为-1时分布式子节点等待10秒、其余模式不等待
+	Profile             string              // 礼貌度预设(gentle/normal/aggressive)，显式设置或与默认值不同的单项配置优先，为空时不采用
+	Explicit            map[string]bool     // 显式设置的配置项(字段名)，不被礼貌度预设替换
+	InflightWarn        int64               // 请求开始执行后超过该秒数仍未完成时记录警告日志，用于发现卡住的下载，0为不警告
+	RunTag              bool                // 是否为每条结果附带本次运行的RunId与RunTime字段，以便区分多次运行写入同一表单的数据
+	RunId               string              // 本次运行的ID，为空时每次运行自动生成，分布式各节点需一致时须手动指定
+	ItemBuffer          int                 // 文本数据收集通道的容量，为0时采用datachancap，通道已满时按itembufferpolicy处理
+	ItemBufferPolicy    string              // 收集通道已满时的处理方式：block阻塞采集协程直至输出跟上，drop丢弃该条结果并记录日志
+	MaxConnsPerHost     int                 // 下载器同时连接每个主机的最大连接数（含正在建立及使用中的连接），超出的请求等待空闲连接，0为不限
+	NatsUrl             string              // NATS服务器地址，如nats://127.0.0.1:4222，可含user:pass@或token@，为空时不发布抓取事件
+	NatsSubject         string              // 发布抓取事件的NATS主题前缀，事件主题为 前缀.事件类型，如pholcus.page.success
+	MaxBodySize         int64               // 单个响应内容读取的最大字节数，超出部分不再接收，按已读取的内容正常解析，对未声明Content-Length的分块响应同样有效，0为不限
+	SamplePages         int                 // 预览模式下每个规则最多解析的成功页数，用于开发规则时快速查看各规则的输出，均达到后任务结束，0为关闭
+	Manifest            bool                // 是否在文本结果目录输出清单文件，逐行记录每条结果的序号、内容摘要及来源请求的网址、规则、状态码与时间，用于追溯数据来源
+	HeaderTimeout       int64               // 下载器等待响应头的超时秒数，0为不限
+	ReadIdleTimeout     int64               // 下载器读取响应内容时的空闲超时秒数，即连续无数据到达的最长时长，0为不限
+	TLSFingerprint      string              // 下载器https请求模拟的浏览器TLS指纹，可选chrome、firefox、safari、edge，为空时使用Go默认的TLS握手
+	Explain             bool                // 是否对所有规则开启选择器调试日志，记录Context.Find()的选择器、匹配数量及匹配内容片段，亦可按Rule.Explain单独开启
 	// 选填项
 	Keyins string // 自定义输入，后期切分为多个任务的Keyin自定义配置
 }
@@ -1,44 +1,235 @@
 package downloader
 
 import (
-	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/henrylee2cn/pholcus/app/downloader/request"
 	"github.com/henrylee2cn/pholcus/app/downloader/surfer"
 	"github.com/henrylee2cn/pholcus/app/spider"
 	"github.com/henrylee2cn/pholcus/config"
+	"github.com/henrylee2cn/pholcus/logs"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
 )
 
 type Surfer struct {
-	surf    surfer.Surfer
-	phantom surfer.Surfer
+	surf     surfer.Surfer
+	phantom  surfer.Surfer
+	ws       surfer.Surfer
+	resolver string // 当前使用的DNS解析器地址
+
+	protocols map[string]surfer.Surfer // [协议]下载内核，按请求网址的协议分派，见RegisterProtocol
+
+	middlewares []Middleware // 下载中间件
+	handler     Handler      // 经中间件包装后的处理函数
+	lock        sync.RWMutex
 }
 
 var SurferDownloader = &Surfer{
 	surf:    surfer.New(),
 	phantom: surfer.NewPhantom(config.PHANTOMJS, config.PHANTOMJS_TEMP),
+	ws:      surfer.NewWebSocket(),
+	protocols: map[string]surfer.Surfer{
+		"ftp": surfer.NewFtp(),
+	},
+}
+
+// 注册网址协议(如ftp，不区分大小写)的下载内核，该协议的请求均以h下载，不再按DownloaderID选择；
+// http、https默认按DownloaderID选择内置内核，h为nil时取消注册；需在任务开始前调用
+func (self *Surfer) RegisterProtocol(scheme string, h surfer.Surfer) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	scheme = strings.ToLower(scheme)
+	if h == nil {
+		delete(self.protocols, scheme)
+		return
+	}
+	if self.protocols == nil {
+		self.protocols = make(map[string]surfer.Surfer)
+	}
+	self.protocols[scheme] = h
+}
+
+// 返回请求网址的协议所注册的下载内核
+func (self *Surfer) protocol(rawurl string) (surfer.Surfer, bool) {
+	i := strings.Index(rawurl, "://")
+	if i <= 0 {
+		return nil, false
+	}
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	h, ok := self.protocols[strings.ToLower(rawurl[:i])]
+	return h, ok
+}
+
+// 按当前任务配置刷新下载器，每次任务开始前调用
+func (self *Surfer) Refresh() {
+	if phantom, ok := self.phantom.(*surfer.Phantom); ok {
+		phantom.SetPool(cache.Task.PhantomPool, cache.Task.PhantomMaxUses, cache.Task.PhantomSession == "shared")
+	}
+	if p, ok := config.Profiles[cache.Task.Profile]; ok {
+		surfer.RotateUserAgent = p.RotateUA
+	} else {
+		surfer.RotateUserAgent = true
+	}
+	if surf, ok := self.surf.(*surfer.Surf); ok {
+		surf.SetIdleConns(
+			cache.Task.MaxIdleConns,
+			cache.Task.MaxIdleConnsPerHost,
+			time.Duration(cache.Task.IdleConnTimeout)*time.Second,
+		)
+		surf.SetMaxConnsPerHost(cache.Task.MaxConnsPerHost)
+		surf.SetReadTimeouts(
+			time.Duration(cache.Task.HeaderTimeout)*time.Second,
+			time.Duration(cache.Task.ReadIdleTimeout)*time.Second,
+		)
+		if err := surf.SetTLSFingerprint(cache.Task.TLSFingerprint); err != nil {
+			logs.Log.Error(" *     TLS指纹设置失败，使用Go默认的TLS握手: %v\n", err)
+			surf.SetTLSFingerprint("")
+		}
+		if self.resolver != cache.Task.Resolver {
+			resolver, err := surfer.NewResolver(cache.Task.Resolver)
+			if err != nil {
+				logs.Log.Error(" *     DNS解析器设置失败，使用系统默认解析器: %v\n", err)
+			} else if resolver != nil {
+				logs.Log.Informational(" *     使用自定义DNS解析器: %v\n", cache.Task.Resolver)
+			}
+			surf.SetResolver(resolver)
+			self.resolver = cache.Task.Resolver
+		}
+		if cache.Task.DNSCacheTTL > 0 {
+			logs.Log.Informational(" *     使用DNS缓存，缓存时长 %v 秒，容量 %v\n", cache.Task.DNSCacheTTL, cache.Task.DNSCacheSize)
+		}
+		surf.SetDNSCache(time.Duration(cache.Task.DNSCacheTTL)*time.Second, cache.Task.DNSCacheSize)
+	}
+}
+
+// 预先建立到请求所在主机的连接，失败时仅记录日志，仅支持Surf下载内核
+func (self *Surfer) Warmup(req *request.Request) {
+	if req.GetDownloaderID() != request.SURF_ID {
+		return
+	}
+	surf, ok := self.surf.(*surfer.Surf)
+	if !ok {
+		return
+	}
+	start := time.Now()
+	if err := surf.Warmup(req); err != nil {
+		logs.Log.Warning(" *     预热连接失败，已跳过 [%v]: %v\n", req.GetUrl(), err)
+		return
+	}
+	logs.Log.Debug(" *     预热连接完成 [%v]，用时 %v\n", req.GetUrl(), time.Since(start))
+}
+
+// 从浏览器导出的cookie文件(Netscape格式cookies.txt或JSON格式)预置cookie，返回载入的数量，仅支持Surf下载内核；
+// domain不为空时仅载入属于该域名（含子域名）的cookie，格式不正确的条目被跳过并记录警告；
+// 可在Spider.OnStart中调用，仅对设置了EnableCookie的请求生效
+func (self *Surfer) LoadCookies(fileName, domain string) (int, error) {
+	surf, ok := self.surf.(*surfer.Surf)
+	if !ok {
+		return 0, fmt.Errorf("load cookies: unsupported downloader")
+	}
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return 0, err
+	}
+	cookies, errs := surfer.ParseCookieFile(b)
+	for _, err := range errs {
+		logs.Log.Warning(" *     跳过格式不正确的cookie [%v]: %v\n", fileName, err)
+	}
+	n := surf.LoadCookies(cookies, domain)
+	logs.Log.Informational(" *     已从 %v 载入 %v 个cookie\n", fileName, n)
+	return n, nil
 }
 
 func (self *Surfer) Download(sp *spider.Spider, cReq *request.Request) *spider.Context {
 	ctx := spider.GetContext(sp, cReq)
+	resp, err := self.getHandler()(sp, cReq)
+	ctx.SetResponse(resp).SetError(request.ClassifyError(err))
+	return ctx
+}
 
-	var resp *http.Response
-	var err error
+// 实际下载，为下载中间件链的最内层处理函数
+func (self *Surfer) fetch(sp *spider.Spider, cReq *request.Request) (resp *http.Response, err error) {
+	h, registered := self.protocol(cReq.GetUrl())
+	switch {
+	case registered:
+		resp, err = h.Download(cReq)
 
-	switch cReq.GetDownloaderID() {
-	case request.SURF_ID:
-		resp, err = self.surf.Download(cReq)
+	// HEAD请求没有可渲染的网页，PhantomJS内核亦不支持，故均以Surf内核发送
+	case cReq.GetDownloaderID() == request.SURF_ID || cReq.GetMethod() == "HEAD":
+		if auth := sp.GetAuth(cReq.GetUrl()); auth != nil && cReq.GetAuth() == nil {
+			// 凭据不写入请求，以免随失败记录等保存
+			resp, err = self.surf.Download(&authRequest{cReq, auth})
+		} else {
+			resp, err = self.surf.Download(cReq)
+		}
 
-	case request.PHANTOM_ID:
+	case cReq.GetDownloaderID() == request.PHANTOM_ID:
 		resp, err = self.phantom.Download(cReq)
+
+	case cReq.GetDownloaderID() == request.WS_ID:
+		resp, err = self.ws.Download(cReq)
+	}
+
+	// 为错误标记失败类型
+	if err != nil {
+		err = request.ClassifyError(err)
+	}
+	if resp != nil {
+		if statusErr := request.NewStatusError(resp.StatusCode, resp.Status); statusErr != nil {
+			err = statusErr
+		}
+	}
+
+	// 仅抓取部分字节时截取响应内容
+	if err == nil && resp != nil && cReq.GetSampleRange() != "" {
+		sampleBody(resp, cReq.GetSampleRange())
+	}
+	// 限制响应内容的读取字节数
+	if err == nil && resp != nil {
+		surfer.LimitBody(resp, cache.Task.MaxBodySize, func() {
+			logs.Log.Warning(" *     响应内容超过 %v 字节，仅读取前 %v 字节 [%v]\n", cache.Task.MaxBodySize, cache.Task.MaxBodySize, cReq.GetUrl())
+		})
 	}
+	return
+}
 
-	if resp.StatusCode >= 400 {
-		err = errors.New("响应状态 " + resp.Status)
+// 将响应内容截取为请求的字节范围：206响应仅限制长度，服务器忽略Range返回完整内容时跳过起始前的字节；
+// 读取到范围末尾后即关闭连接，不再接收剩余内容
+func sampleBody(resp *http.Response, sampleRange string) {
+	start, end, _ := request.ParseSampleRange(sampleRange)
+	body := resp.Body
+	reader := io.Reader(body)
+	if resp.StatusCode == http.StatusOK && start > 0 {
+		if _, err := io.CopyN(ioutil.Discard, body, start); err != nil {
+			reader = strings.NewReader("")
+		}
 	}
+	resp.Body = &sampleReader{io.LimitReader(reader, end-start+1), body}
+	resp.ContentLength = -1
+}
+
+type sampleReader struct {
+	io.Reader
+	body io.ReadCloser
+}
 
-	ctx.SetResponse(resp).SetError(err)
+func (self *sampleReader) Close() error {
+	return self.body.Close()
+}
 
-	return ctx
+// 采用Spider.Auth中主机凭据的请求
+type authRequest struct {
+	*request.Request
+	auth *surfer.Auth
+}
+
+func (self *authRequest) GetAuth() *surfer.Auth {
+	return self.auth
 }
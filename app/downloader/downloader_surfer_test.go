@@ -0,0 +1,51 @@
+package downloader
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+	"github.com/henrylee2cn/pholcus/app/downloader/surfer"
+	"github.com/henrylee2cn/pholcus/app/spider"
+)
+
+// 总是失败的下载内核
+type failSurfer struct{}
+
+func (failSurfer) Download(surfer.Request) (*http.Response, error) {
+	return nil, errors.New("unsupported")
+}
+
+// 指定PhantomJS内核的HEAD请求以Surf内核发送
+func TestFetchHeadWithSurf(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "HEAD" {
+			http.Error(w, "want HEAD", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Last-Modified", "Mon, 01 Jan 2024 00:00:00 GMT")
+	}))
+	defer srv.Close()
+
+	dl := &Surfer{surf: surfer.New(), phantom: failSurfer{}, ws: failSurfer{}}
+	req := &request.Request{Url: srv.URL, Rule: "r", Method: "HEAD", DownloaderID: request.PHANTOM_ID, TryTimes: 1}
+	if err := req.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := dl.fetch(&spider.Spider{Name: "test"}, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("Last-Modified") == "" {
+		t.Errorf("HEAD response without Last-Modified: %v", resp.Header)
+	}
+
+	req = &request.Request{Url: srv.URL, Rule: "r", DownloaderID: request.PHANTOM_ID, TryTimes: 1}
+	req.Prepare()
+	if _, err = dl.fetch(&spider.Spider{Name: "test"}, req); err == nil {
+		t.Error("GET was not sent with the phantomjs kernel")
+	}
+}
@@ -0,0 +1,40 @@
+package downloader
+
+import (
+	"net/http"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+	"github.com/henrylee2cn/pholcus/app/spider"
+)
+
+type (
+	// 下载处理函数，返回请求的响应或错误
+	Handler func(*spider.Spider, *request.Request) (*http.Response, error)
+	// 下载中间件，包装下一个处理函数并返回新的处理函数；
+	// 可在调用next前修改请求、不调用next直接返回响应（短路）、多次调用next（重试）或在返回前改写响应；
+	// next返回的错误已标记失败类型，4xx、5xx响应同时返回对应的状态错误，短路返回的响应不再按状态码判定
+	Middleware func(next Handler) Handler
+)
+
+// 添加下载中间件，先添加者位于外层，实际下载为最内层的处理函数；
+// 未添加任何中间件时与直接下载等同，需在任务开始前调用
+func (self *Surfer) Use(middlewares ...Middleware) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.middlewares = append(self.middlewares, middlewares...)
+	handler := Handler(self.fetch)
+	for i := len(self.middlewares) - 1; i >= 0; i-- {
+		handler = self.middlewares[i](handler)
+	}
+	self.handler = handler
+}
+
+// 返回经全部中间件包装后的处理函数
+func (self *Surfer) getHandler() Handler {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	if self.handler == nil {
+		return self.fetch
+	}
+	return self.handler
+}
@@ -0,0 +1,170 @@
+package request
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// 请求失败的类型
+const (
+	ERR_UNKNOWN  = "unknown" // 未知
+	ERR_DNS      = "dns"     // 域名解析失败
+	ERR_TIMEOUT  = "timeout" // 连接或下载超时
+	ERR_TLS      = "tls"     // TLS握手或证书错误
+	ERR_NETWORK  = "network" // 其他网络错误
+	ERR_HTTP_4XX = "http4xx" // 响应状态为4xx
+	ERR_HTTP_5XX = "http5xx" // 响应状态为5xx
+	ERR_PARSE    = "parse"   // 规则解析失败
+	ERR_BLOCKED  = "blocked" // 被目标站点封禁
+	ERR_AUTH     = "auth"    // HTTP认证失败，即响应状态为401
+	ERR_INVALID  = "invalid" // 响应未通过规则的校验，见spider.Rule.Validate
+	ERR_PATTERN  = "pattern" // 响应内容匹配重试规则，见spider.Spider.RetryPatterns
+)
+
+// 带有失败类型的错误，可在规则中以panic(request.NewError(...))的方式主动标记失败类型
+type Error struct {
+	class      string
+	StatusCode int   // 响应状态码，非HTTP错误时为0
+	Err        error // 原始错误
+}
+
+// 创建指定失败类型的错误
+func NewError(class string, err error) *Error {
+	return &Error{class: class, Err: err}
+}
+
+// 根据响应状态码创建错误，状态码小于400时返回nil
+func NewStatusError(statusCode int, status string) *Error {
+	var class string
+	switch {
+	case statusCode == http.StatusUnauthorized:
+		return &Error{
+			class:      ERR_AUTH,
+			StatusCode: statusCode,
+			Err:        errors.New("认证失败，请检查凭据，响应状态 " + status),
+		}
+	case statusCode >= 500:
+		class = ERR_HTTP_5XX
+	case statusCode >= 400:
+		class = ERR_HTTP_4XX
+	default:
+		return nil
+	}
+	return &Error{
+		class:      class,
+		StatusCode: statusCode,
+		Err:        errors.New("响应状态 " + status),
+	}
+}
+
+func (self *Error) Error() string {
+	if self.Err == nil {
+		return self.class
+	}
+	return self.Err.Error()
+}
+
+func (self *Error) Unwrap() error {
+	return self.Err
+}
+
+// 返回失败类型
+func (self *Error) Class() string {
+	return self.class
+}
+
+// 为错误标记失败类型，已标记的错误原样返回，nil返回nil
+func ClassifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(interface {
+		Class() string
+	}); ok {
+		return err
+	}
+	return NewError(ErrorClass(err), err)
+}
+
+// 返回错误的失败类型，nil时返回空字符串
+func ErrorClass(err error) string {
+	if err == nil {
+		return ""
+	}
+	var classifier interface {
+		Class() string
+	}
+	if errors.As(err, &classifier) {
+		return classifier.Class()
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsTimeout {
+			return ERR_TIMEOUT
+		}
+		return ERR_DNS
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ERR_TIMEOUT
+	}
+	var (
+		recordErr tls.RecordHeaderError
+		certErr   x509.CertificateInvalidError
+		authErr   x509.UnknownAuthorityError
+		hostErr   x509.HostnameError
+	)
+	if errors.As(err, &recordErr) || errors.As(err, &certErr) || errors.As(err, &authErr) || errors.As(err, &hostErr) {
+		return ERR_TLS
+	}
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "tls:") || strings.Contains(msg, "x509:"):
+		return ERR_TLS
+	case strings.Contains(msg, "i/o timeout") || strings.Contains(msg, "Client.Timeout"):
+		return ERR_TIMEOUT
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return ERR_NETWORK
+	}
+	return ERR_UNKNOWN
+}
+
+// 是否为重试也无法成功的永久性错误，即4xx响应（408、429除外）及认证失败
+func IsPermanent(err error) bool {
+	var reqErr *Error
+	if !errors.As(err, &reqErr) {
+		return false
+	}
+	if reqErr.class == ERR_AUTH {
+		return true
+	}
+	if reqErr.class != ERR_HTTP_4XX {
+		return false
+	}
+	switch reqErr.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return true
+}
+
+// 将规则解析时recover()得到的值转为带有失败类型的错误，未标记类型时为ERR_PARSE
+func PanicError(recovered interface{}) error {
+	if err, ok := recovered.(error); ok {
+		if _, ok := err.(interface {
+			Class() string
+		}); ok {
+			return err
+		}
+		return NewError(ERR_PARSE, err)
+	}
+	return NewError(ERR_PARSE, fmt.Errorf("%v", recovered))
+}
@@ -0,0 +1,69 @@
+package request
+
+import (
+	"net/url"
+	"strings"
+)
+
+// 规范化网址，用于生成去重指纹：协议及主机名小写，去除默认端口、锚点及路径末尾的斜杠，
+// 查询参数按名称排序，并去除dropParams中的参数（以*结尾时按前缀匹配，不区分大小写）；无法解析时原样返回
+func NormalizeUrl(rawurl string, dropParams []string) string {
+	u, err := url.Parse(strings.TrimSpace(rawurl))
+	if err != nil || u.Host == "" {
+		return rawurl
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	switch port := u.Port(); {
+	case port == "80" && u.Scheme == "http", port == "443" && u.Scheme == "https":
+		u.Host = strings.TrimSuffix(u.Host, ":"+port)
+	}
+	u.Fragment, u.RawFragment = "", ""
+
+	u.Path = trimSlash(u.Path)
+	if u.RawPath != "" {
+		u.RawPath = trimSlash(u.RawPath)
+	}
+
+	if u.RawQuery != "" {
+		query, err := url.ParseQuery(u.RawQuery)
+		if err == nil {
+			for key := range query {
+				if matchParam(key, dropParams) {
+					delete(query, key)
+				}
+			}
+			// Encode按参数名排序
+			u.RawQuery = query.Encode()
+		}
+	}
+	u.ForceQuery = false
+	return u.String()
+}
+
+// 去除路径末尾的斜杠，根路径统一为"/"
+func trimSlash(path string) string {
+	path = strings.TrimRight(path, "/")
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// 参数名是否属于要去除的参数
+func matchParam(key string, dropParams []string) bool {
+	key = strings.ToLower(key)
+	for _, p := range dropParams {
+		p = strings.ToLower(strings.TrimSpace(p))
+		switch {
+		case p == "":
+		case strings.HasSuffix(p, "*"):
+			if strings.HasPrefix(key, p[:len(p)-1]) {
+				return true
+			}
+		case key == p:
+			return true
+		}
+	}
+	return false
+}
@@ -2,13 +2,17 @@ package request
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/henrylee2cn/pholcus/app/downloader/surfer"
 	"github.com/henrylee2cn/pholcus/common/util"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
 )
 
 // Request represents object waiting for being crawled.
@@ -16,26 +20,45 @@ type Request struct {
 	Spider        string          //规则名，自动设置，禁止人为填写
 	Url           string          //目标URL，必须设置
 	Rule          string          //用于解析响应的规则节点名，必须设置
-	Method        string          //GET POST POST-M HEAD
+	Method        string          //GET POST POST-M HEAD PUT DELETE PATCH 等，除POST-M外原样透传
 	Header        http.Header     //请求头信息
 	EnableCookie  bool            //是否使用cookies，在Spider的EnableCookie设置
 	PostData      string          //POST values
+	GzipBody      bool            //是否以gzip压缩请求体并设置Content-Encoding: gzip，仅对Surf下载器有效
+	RawHeader     bool            //是否按Header中键名的原样大小写发送请求头，不做规范化，如Header["x-api-key"]，仅对Surf下载器有效，此时不复用连接
+	HTTP10        bool            //是否以HTTP/1.0发送请求，仅对Surf下载器有效，此时不复用连接
+	Auth          *surfer.Auth    `json:"-"` //HTTP Basic或Digest认证凭据(选填)，为nil时采用Spider.Auth中该主机的凭据，仅对Surf下载器有效；不参与序列化，以免凭据随失败记录保存，反序列化后的请求在下载时采用Spider.Auth
+	Dismiss       *surfer.Dismiss //页面加载后、获取内容前关闭Cookie同意提示、年龄确认等遮罩层的步骤(选填)，可经Spider.SetDefaultRequest()统一设置，仅对PhantomJS下载器有效
 	DialTimeout   time.Duration   //创建连接超时 dial tcp: i/o timeout
 	ConnTimeout   time.Duration   //连接状态超时 WSARecv tcp: i/o timeout
-	TryTimes      int             //尝试下载的最大次数
+	HeaderTimeout time.Duration   //等待响应头的超时，0为采用全局设置，小于0时不限
+	IdleTimeout   time.Duration   //读取响应内容时的空闲超时，即连续无数据到达的最长时长，0为采用全局设置，小于0时不限
+	TryTimes      int             //单次下载时尝试的最大次数，由下载器在本次下载内连续重试
+	MaxRetries    int             //下载失败后在队列末尾重新执行的最大次数，0时采用全局设置，小于0时不再重新执行
 	RetryPause    time.Duration   //下载失败后，下次尝试下载的等待时间
 	RedirectTimes int             //重定向的最大次数，为0时不限，小于0时禁止重定向
 	Temp          Temp            //临时数据
 	TempIsJson    map[string]bool //将Temp中以JSON存储的字段标记为true，自动设置，禁止人为填写
 	Priority      int             //指定调度优先级，默认为0（最小优先级为0）
+	Cost          int             //请求的资源权重，即处理时占用的并发量份数，默认为1，可为渲染开销大的请求设置更大的值
 	Reloadable    bool            //是否允许重复该链接下载
+	Session       string          //指定使用的会话名(选填)，为空时由Spider.Sessions会话池分配
+	Intent        string          //请求的用途(选填)，如INTENT_FILE，不同用途的同一网址分别去重，如已解析过页面时仍可下载该网址的文件
+	FileName      string          //响应作为文件输出时的文件名(选填)，设置后Context.FileOutput()默认采用该文件名，且可在下载前检查文件是否已存在
+	SampleRange   string          //仅抓取响应内容的该字节范围(选填)，格式为"起始-结束"(含两端)，如"0-4095"，以Range请求头请求，服务器不支持时由下载器截取，仅对Surf下载器有效
+	Checksum      string          //输出文件的预期校验值(选填)，格式为"算法:十六进制值"，如"sha256:9f86d0..."，支持md5、sha1、sha256、sha512
+	WsMessage     string          //WebSocket下载器连接后发送的订阅消息(选填)
+	WsMaxMessages int             //WebSocket下载器收集到该数量的消息后结束，0为不限
+	WsTerminator  string          //WebSocket下载器收到匹配该正则表达式的消息后结束(选填)
 	//Surfer下载器内核ID
 	//0为Surf高并发下载器，各种控制功能齐全
 	//1为PhantomJS下载器，特点破防力强，速度慢，低并发
+	//2为WebSocket下载器，收集ws://或wss://地址推送的消息，以ConnTimeout作为收集时长上限
 	DownloaderID int
 
 	proxy  string //当用户界面设置可使用代理IP时，自动设置代理
 	unique string //ID
+	seq    int64  //入队序号，开启有序输出时由调度器自动设置
 	lock   sync.RWMutex
 }
 
@@ -46,9 +69,13 @@ const (
 	DefaultRetryPause  = 2 * time.Second // 默认重新下载前停顿时长
 )
 
+// Temp中元数据的键名前缀
+const META_PREFIX = "__META__."
+
 const (
 	SURF_ID    = 0 // 默认的surf下载内核（Go原生），此值不可改动
 	PHANTOM_ID = 1 // 备用的phantomjs下载内核，一般不使用（效率差，头信息支持不完善）
+	WS_ID      = 2 // WebSocket下载内核，用于抓取实时推送的数据
 )
 
 // 发送请求前的准备工作，设置一系列默认值
@@ -60,9 +87,12 @@ const (
 // Request.DialTimeout默认为常量DefaultDialTimeout，小于0时不限制等待响应时长;
 // Request.ConnTimeout默认为常量DefaultConnTimeout，小于0时不限制下载超时;
 // Request.TryTimes默认为常量DefaultTryTimes，小于0时不限制失败重载次数;
+// Request.MaxRetries默认采用全局设置cache.Task.MaxRetries，小于0时失败后不再重新执行，
+// 最多共下载 TryTimes*(1+MaxRetries) 次，不可重试的4xx错误除外;
 // Request.RedirectTimes默认不限制重定向次数，小于0时可禁止重定向跳转;
 // Request.RetryPause默认为常量DefaultRetryPause;
-// Request.DownloaderID指定下载器ID，0为默认的Surf高并发下载器，功能完备，1为PhantomJS下载器，特点破防力强，速度慢，低并发。
+// Request.Cost默认为1，即处理时占用1份并发量，超出全局并发量时以全局并发量为准;
+// Request.DownloaderID指定下载器ID，0为默认的Surf高并发下载器，功能完备，1为PhantomJS下载器，特点破防力强，速度慢，低并发，2为WebSocket下载器。
 func (self *Request) Prepare() error {
 	// 确保url正确，且和Response中Url字符串相等
 	URL, err := url.Parse(self.Url)
@@ -76,12 +106,22 @@ func (self *Request) Prepare() error {
 		self.Method = "GET"
 	} else {
 		self.Method = strings.ToUpper(self.Method)
+		if !surfer.ValidMethod(self.Method) {
+			return fmt.Errorf("请求方法无效: %q [%v]", self.Method, self.Url)
+		}
 	}
 
 	if self.Header == nil {
 		self.Header = make(http.Header)
 	}
 
+	if self.SampleRange != "" {
+		if _, _, err := ParseSampleRange(self.SampleRange); err != nil {
+			return fmt.Errorf("%v [%v]", err, self.Url)
+		}
+		self.Header.Set("Range", "bytes="+self.SampleRange)
+	}
+
 	if self.DialTimeout < 0 {
 		self.DialTimeout = 0
 	} else if self.DialTimeout == 0 {
@@ -106,7 +146,7 @@ func (self *Request) Prepare() error {
 		self.Priority = 0
 	}
 
-	if self.DownloaderID < SURF_ID || self.DownloaderID > PHANTOM_ID {
+	if self.DownloaderID < SURF_ID || self.DownloaderID > WS_ID {
 		self.DownloaderID = SURF_ID
 	}
 
@@ -136,22 +176,166 @@ func (self *Request) Serialize() string {
 	return strings.Replace(util.Bytes2String(b), `\u0026`, `&`, -1)
 }
 
-// 请求的唯一识别码
+// 请求的用途，见Request.Intent
+const (
+	INTENT_PAGE = ""     // 页面请求，默认
+	INTENT_FILE = "file" // 文件下载请求
+)
+
+// 区域变体名的元数据键，由spider.Variant展开的请求自动设置，随元数据传递给子请求
+const VARIANT_META = "variant"
+
+// 各蜘蛛自定义的请求去重键函数，[Spider名]func(*Request) string
+var uniqueKeys sync.Map
+
+// 为指定蜘蛛注册自定义的请求去重键函数，返回值的哈希即为请求的唯一识别码，须在添加请求前注册；
+// fn为nil时注销，该蜘蛛的请求恢复采用DefaultUniqueKey；通常由Spider.UniqueKey自动注册
+func RegisterUniqueKey(spiderName string, fn func(req *Request) string) {
+	if fn == nil {
+		uniqueKeys.Delete(spiderName)
+		return
+	}
+	uniqueKeys.Store(spiderName, fn)
+}
+
+// 默认的请求去重键，由Spider、Rule、网址、Method、Intent及区域变体名组成，开启网址规范化时采用规范化后的网址；
+// Intent与区域变体名为空时与未区分用途及变体时的去重键相同
+func DefaultUniqueKey(req *Request) string {
+	u := req.Url
+	if cache.Task.URLNormalize {
+		u = NormalizeUrl(u, strings.Split(cache.Task.DropParams, ","))
+	}
+	key := req.Spider + req.Rule + u + req.Method
+	if req.Intent != INTENT_PAGE {
+		key += "#" + req.Intent
+	}
+	if v := req.GetVariant(); v != "" {
+		key += "@" + v
+	}
+	return key
+}
+
+// 请求的唯一识别码，由所属蜘蛛注册的去重键函数或DefaultUniqueKey生成
 func (self *Request) Unique() string {
 	if self.unique == "" {
-		self.unique = util.MakeHash(self.Spider + self.Rule + self.Url + self.Method)
+		key := DefaultUniqueKey
+		if fn, ok := uniqueKeys.Load(self.Spider); ok {
+			key = fn.(func(req *Request) string)
+		}
+		self.unique = util.MakeHash(key(self))
 	}
 	return self.unique
 }
 
+// 返回入队序号，未设置时为0
+func (self *Request) GetSeq() int64 {
+	return self.seq
+}
+
+// 设置入队序号，由调度器在请求首次入队时调用，重新执行的失败请求保留原序号
+func (self *Request) SetSeq(seq int64) *Request {
+	self.seq = seq
+	return self
+}
+
 // 获取副本
 func (self *Request) Copy() *Request {
 	reqcopy := new(Request)
 	b, _ := json.Marshal(self)
 	json.Unmarshal(b, reqcopy)
+	reqcopy.Auth = self.Auth
 	return reqcopy
 }
 
+// 从请求模板继承未设置的字段，即将模板中的非零值字段合并至自身，自身已设置的字段优先；
+// Header与Temp按键合并，Spider、Url、Rule、Intent、FileName不继承
+func (self *Request) Inherit(tmpl *Request) *Request {
+	if tmpl == nil {
+		return self
+	}
+	if self.Method == "" {
+		self.Method = tmpl.Method
+	}
+	if len(tmpl.Header) > 0 {
+		if self.Header == nil {
+			self.Header = make(http.Header)
+		}
+		for k, v := range tmpl.Header {
+			if _, ok := self.Header[k]; !ok {
+				self.Header[k] = append([]string(nil), v...)
+			}
+		}
+	}
+	if self.PostData == "" {
+		self.PostData = tmpl.PostData
+	}
+	if !self.GzipBody {
+		self.GzipBody = tmpl.GzipBody
+	}
+	if !self.RawHeader {
+		self.RawHeader = tmpl.RawHeader
+	}
+	if !self.HTTP10 {
+		self.HTTP10 = tmpl.HTTP10
+	}
+	if self.Auth == nil {
+		self.Auth = tmpl.Auth
+	}
+	if self.Dismiss == nil {
+		self.Dismiss = tmpl.Dismiss
+	}
+	if self.DialTimeout == 0 {
+		self.DialTimeout = tmpl.DialTimeout
+	}
+	if self.ConnTimeout == 0 {
+		self.ConnTimeout = tmpl.ConnTimeout
+	}
+	if self.HeaderTimeout == 0 {
+		self.HeaderTimeout = tmpl.HeaderTimeout
+	}
+	if self.IdleTimeout == 0 {
+		self.IdleTimeout = tmpl.IdleTimeout
+	}
+	if self.TryTimes == 0 {
+		self.TryTimes = tmpl.TryTimes
+	}
+	if self.MaxRetries == 0 {
+		self.MaxRetries = tmpl.MaxRetries
+	}
+	if self.RetryPause == 0 {
+		self.RetryPause = tmpl.RetryPause
+	}
+	if self.RedirectTimes == 0 {
+		self.RedirectTimes = tmpl.RedirectTimes
+	}
+	if self.Priority == 0 {
+		self.Priority = tmpl.Priority
+	}
+	if self.Cost == 0 {
+		self.Cost = tmpl.Cost
+	}
+	if !self.Reloadable {
+		self.Reloadable = tmpl.Reloadable
+	}
+	if self.Session == "" {
+		self.Session = tmpl.Session
+	}
+	if self.DownloaderID == SURF_ID {
+		self.DownloaderID = tmpl.DownloaderID
+	}
+	if len(tmpl.Temp) > 0 {
+		if self.Temp == nil {
+			self.Temp = make(Temp)
+		}
+		for k, v := range tmpl.Temp {
+			if _, ok := self.Temp[k]; !ok {
+				self.SetTemp(k, v)
+			}
+		}
+	}
+	return self
+}
+
 // 获取Url
 func (self *Request) GetUrl() string {
 	return self.Url
@@ -198,6 +382,51 @@ func (self *Request) AddHeader(key, value string) *Request {
 	return self
 }
 
+func (self *Request) GetAuth() *surfer.Auth {
+	return self.Auth
+}
+
+func (self *Request) SetAuth(auth *surfer.Auth) *Request {
+	self.Auth = auth
+	return self
+}
+
+func (self *Request) GetDismiss() *surfer.Dismiss {
+	return self.Dismiss
+}
+
+func (self *Request) SetDismiss(dismiss *surfer.Dismiss) *Request {
+	self.Dismiss = dismiss
+	return self
+}
+
+func (self *Request) GetGzipBody() bool {
+	return self.GzipBody
+}
+
+func (self *Request) SetGzipBody(gzipBody bool) *Request {
+	self.GzipBody = gzipBody
+	return self
+}
+
+func (self *Request) GetRawHeader() bool {
+	return self.RawHeader
+}
+
+func (self *Request) SetRawHeader(rawHeader bool) *Request {
+	self.RawHeader = rawHeader
+	return self
+}
+
+func (self *Request) GetHTTP10() bool {
+	return self.HTTP10
+}
+
+func (self *Request) SetHTTP10(http10 bool) *Request {
+	self.HTTP10 = http10
+	return self
+}
+
 func (self *Request) GetEnableCookie() bool {
 	return self.EnableCookie
 }
@@ -211,11 +440,86 @@ func (self *Request) GetCookies() string {
 	return self.Header.Get("Cookie")
 }
 
+// 设置请求级cookie，格式同请求头Cookie，如"a=1; b=2"
+// 启用cookie时，请求级cookie叠加在共享cookiejar之上，同名时以请求级cookie为准，
+// 且仅作用于本次请求（含重定向到同一主机），不会写入共享cookiejar，可用于多账号并发采集；
+// 未启用cookie时，原样作为请求头Cookie发送。
 func (self *Request) SetCookies(cookie string) *Request {
 	self.Header.Set("Cookie", cookie)
 	return self
 }
 
+func (self *Request) GetSession() string {
+	return self.Session
+}
+
+func (self *Request) SetSession(session string) *Request {
+	self.Session = session
+	return self
+}
+
+func (self *Request) GetSampleRange() string {
+	return self.SampleRange
+}
+
+// 设置仅抓取的字节范围[start, end]，如SetSampleRange(0, 4095)为前4KB
+func (self *Request) SetSampleRange(start, end int64) *Request {
+	self.SampleRange = strconv.FormatInt(start, 10) + "-" + strconv.FormatInt(end, 10)
+	return self
+}
+
+// 解析Request.SampleRange，返回起止字节位置（含两端）
+func ParseSampleRange(sampleRange string) (start, end int64, err error) {
+	i := strings.Index(sampleRange, "-")
+	if i > 0 {
+		start, err = strconv.ParseInt(strings.TrimSpace(sampleRange[:i]), 10, 64)
+		if err == nil {
+			end, err = strconv.ParseInt(strings.TrimSpace(sampleRange[i+1:]), 10, 64)
+		}
+	}
+	if i <= 0 || err != nil || start < 0 || end < start {
+		return 0, 0, fmt.Errorf("字节范围无效: %q", sampleRange)
+	}
+	return start, end, nil
+}
+
+func (self *Request) GetIntent() string {
+	return self.Intent
+}
+
+func (self *Request) SetIntent(intent string) *Request {
+	self.Intent = intent
+	return self
+}
+
+func (self *Request) GetFileName() string {
+	return self.FileName
+}
+
+func (self *Request) SetFileName(fileName string) *Request {
+	self.FileName = fileName
+	return self
+}
+
+// 设置输出文件的预期校验值，algorithm为md5、sha1、sha256或sha512
+func (self *Request) SetChecksum(algorithm, sum string) *Request {
+	self.Checksum = strings.ToLower(algorithm) + ":" + strings.ToLower(sum)
+	return self
+}
+
+// 返回输出文件的预期校验值，未设置时均为空
+func (self *Request) GetChecksum() (algorithm, sum string) {
+	if i := strings.Index(self.Checksum, ":"); i > 0 {
+		return strings.ToLower(self.Checksum[:i]), strings.ToLower(strings.TrimSpace(self.Checksum[i+1:]))
+	}
+	return "", ""
+}
+
+// 是否为文件下载请求，即设置了FileName的请求
+func (self *Request) IsFile() bool {
+	return self.FileName != ""
+}
+
 func (self *Request) GetDialTimeout() time.Duration {
 	return self.DialTimeout
 }
@@ -224,10 +528,27 @@ func (self *Request) GetConnTimeout() time.Duration {
 	return self.ConnTimeout
 }
 
+func (self *Request) GetHeaderTimeout() time.Duration {
+	return self.HeaderTimeout
+}
+
+func (self *Request) GetIdleTimeout() time.Duration {
+	return self.IdleTimeout
+}
+
 func (self *Request) GetTryTimes() int {
 	return self.TryTimes
 }
 
+func (self *Request) GetMaxRetries() int {
+	return self.MaxRetries
+}
+
+func (self *Request) SetMaxRetries(n int) *Request {
+	self.MaxRetries = n
+	return self
+}
+
 func (self *Request) GetRetryPause() time.Duration {
 	return self.RetryPause
 }
@@ -272,6 +593,72 @@ func (self *Request) SetReloadable(can bool) *Request {
 	return self
 }
 
+// 设置元数据，元数据随请求自动传递给由其派生的子请求（见InheritMeta）
+// 元数据保存在Temp中，可随请求队列序列化，故value须可被JSON编码，
+// 序列化后再读取时，按GetMeta()的defaultValue类型还原
+func (self *Request) SetMeta(key string, value interface{}) *Request {
+	self.lock.Lock()
+	if self.Temp == nil {
+		self.Temp = make(Temp)
+	}
+	self.Temp[META_PREFIX+key] = value
+	delete(self.TempIsJson, META_PREFIX+key)
+	self.lock.Unlock()
+	return self
+}
+
+// 获取元数据
+// defaultValue 不能为 interface{}(nil)
+func (self *Request) GetMeta(key string, defaultValue interface{}) interface{} {
+	return self.GetTemp(META_PREFIX+key, defaultValue)
+}
+
+// 获取区域变体名，未按区域变体展开时为空
+func (self *Request) GetVariant() string {
+	return self.GetMeta(VARIANT_META, "").(string)
+}
+
+// 从父请求继承全部元数据，自身已设置的同名元数据优先
+func (self *Request) InheritMeta(parent *Request) *Request {
+	if parent == nil || parent == self {
+		return self
+	}
+	var (
+		metas  = make(Temp)
+		isJson = make(map[string]bool)
+	)
+	parent.lock.RLock()
+	for k, v := range parent.Temp {
+		if strings.HasPrefix(k, META_PREFIX) {
+			metas[k] = v
+			isJson[k] = parent.TempIsJson[k]
+		}
+	}
+	parent.lock.RUnlock()
+	if len(metas) == 0 {
+		return self
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.Temp == nil {
+		self.Temp = make(Temp)
+	}
+	if self.TempIsJson == nil {
+		self.TempIsJson = make(map[string]bool)
+	}
+	for k, v := range metas {
+		if _, ok := self.Temp[k]; ok {
+			continue
+		}
+		self.Temp[k] = v
+		if isJson[k] {
+			self.TempIsJson[k] = true
+		}
+	}
+	return self
+}
+
 // 获取临时缓存数据
 // defaultValue 不能为 interface{}(nil)
 func (self *Request) GetTemp(key string, defaultValue interface{}) interface{} {
@@ -321,6 +708,46 @@ func (self *Request) SetPriority(priority int) *Request {
 	return self
 }
 
+// 返回请求的资源权重，未设置或小于1时为1
+func (self *Request) GetCost() int {
+	if self.Cost < 1 {
+		return 1
+	}
+	return self.Cost
+}
+
+func (self *Request) SetCost(cost int) *Request {
+	self.Cost = cost
+	return self
+}
+
+func (self *Request) GetWsMessage() string {
+	return self.WsMessage
+}
+
+func (self *Request) SetWsMessage(message string) *Request {
+	self.WsMessage = message
+	return self
+}
+
+func (self *Request) GetWsMaxMessages() int {
+	return self.WsMaxMessages
+}
+
+func (self *Request) SetWsMaxMessages(n int) *Request {
+	self.WsMaxMessages = n
+	return self
+}
+
+func (self *Request) GetWsTerminator() string {
+	return self.WsTerminator
+}
+
+func (self *Request) SetWsTerminator(pattern string) *Request {
+	self.WsTerminator = pattern
+	return self
+}
+
 func (self *Request) GetDownloaderID() int {
 	return self.DownloaderID
 }
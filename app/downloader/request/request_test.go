@@ -2,7 +2,11 @@ package request
 
 import (
 	"encoding/json"
+	"net/http"
+	"strings"
 	"testing"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/surfer"
 )
 
 func TestReqTemp(t *testing.T) {
@@ -57,3 +61,132 @@ func TestReqTemp(t *testing.T) {
 type x struct {
 	Name string
 }
+
+func TestPrepareMethod(t *testing.T) {
+	for method, ok := range map[string]bool{
+		"":       true,
+		"get":    true,
+		"PATCH":  true,
+		"POST-M": true,
+		"GE T":   false,
+		"GET\n":  false,
+	} {
+		req := &Request{Url: "http://www.example.com/", Rule: "test", Method: method}
+		if err := req.Prepare(); (err == nil) != ok {
+			t.Errorf("Method %q: unexpected result %v", method, err)
+		}
+	}
+}
+
+func TestInherit(t *testing.T) {
+	tmpl := &Request{
+		Method:       "POST",
+		Header:       http.Header{"User-Agent": {"tmpl"}, "X-Token": {"t"}},
+		TryTimes:     5,
+		DownloaderID: PHANTOM_ID,
+		Url:          "http://www.example.com/tmpl",
+	}
+	req := (&Request{
+		Url:    "http://www.example.com/",
+		Header: http.Header{"User-Agent": {"req"}},
+	}).Inherit(tmpl)
+
+	if req.Method != "POST" || req.TryTimes != 5 || req.DownloaderID != PHANTOM_ID {
+		t.Errorf("unexpected inherited fields: %#v", req)
+	}
+	if req.Url != "http://www.example.com/" {
+		t.Errorf("Url should not be inherited: %v", req.Url)
+	}
+	if req.Header.Get("User-Agent") != "req" || req.Header.Get("X-Token") != "t" {
+		t.Errorf("unexpected header: %#v", req.Header)
+	}
+}
+
+func TestInheritMeta(t *testing.T) {
+	parent := &Request{Url: "http://www.example.com/list", Rule: "list"}
+	parent.Prepare()
+	parent.SetMeta("category", "books").SetMeta("page", 2)
+
+	// 模拟请求队列序列化
+	parent, _ = UnSerialize(parent.Serialize())
+
+	child := (&Request{Url: "http://www.example.com/detail", Rule: "detail"}).
+		SetMeta("page", 3).
+		InheritMeta(parent)
+	child.Prepare()
+
+	if v := child.GetMeta("category", ""); v != "books" {
+		t.Errorf("category: got %#v", v)
+	}
+	if v := child.GetMeta("page", 0); v != 3 {
+		t.Errorf("page: got %#v", v)
+	}
+}
+
+func TestNormalizeUrl(t *testing.T) {
+	drop := []string{"utm_*", "gclid"}
+	cases := map[string]string{
+		"HTTP://WWW.Example.com:80/a/b/?b=2&a=1#top":     "http://www.example.com/a/b?a=1&b=2",
+		"https://example.com:443":                        "https://example.com/",
+		"https://example.com:8443/?utm_source=x&gclid=y": "https://example.com:8443/",
+		"http://example.com/list?page=2&UTM_Medium=mail": "http://example.com/list?page=2",
+		"/relative/path/":                                "/relative/path/",
+	}
+	for raw, want := range cases {
+		if got := NormalizeUrl(raw, drop); got != want {
+			t.Errorf("NormalizeUrl(%q): got %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestUniqueIntent(t *testing.T) {
+	page := &Request{Spider: "s", Rule: "r", Url: "http://example.com/a.pdf"}
+	file := &Request{Spider: "s", Rule: "r", Url: "http://example.com/a.pdf", Intent: INTENT_FILE}
+	if page.Unique() == file.Unique() {
+		t.Errorf("page and file requests share unique %q", page.Unique())
+	}
+
+	RegisterUniqueKey("custom", func(req *Request) string { return req.Url })
+	defer RegisterUniqueKey("custom", nil)
+	a := &Request{Spider: "custom", Rule: "a", Url: "http://example.com/"}
+	b := &Request{Spider: "custom", Rule: "b", Url: "http://example.com/"}
+	if a.Unique() != b.Unique() {
+		t.Errorf("custom UniqueKey ignored: %q != %q", a.Unique(), b.Unique())
+	}
+	// 其他蜘蛛不受影响
+	c := &Request{Spider: "other", Rule: "a", Url: "http://example.com/"}
+	d := &Request{Spider: "other", Rule: "b", Url: "http://example.com/"}
+	if c.Unique() == d.Unique() {
+		t.Errorf("UniqueKey of spider custom applied to spider other")
+	}
+}
+
+func TestUniqueVariant(t *testing.T) {
+	plain := &Request{Spider: "s", Rule: "r", Url: "http://example.com/"}
+	de := &Request{Spider: "s", Rule: "r", Url: "http://example.com/"}
+	de.Prepare()
+	de.SetMeta(VARIANT_META, "de")
+	fr := de.Copy()
+	fr.SetMeta(VARIANT_META, "fr")
+	if plain.Unique() == de.Unique() || de.Unique() == fr.Unique() {
+		t.Errorf("variants share unique: %q %q %q", plain.Unique(), de.Unique(), fr.Unique())
+	}
+	if got := fr.Copy().GetVariant(); got != "fr" {
+		t.Errorf("GetVariant() after Copy = %q, want %q", got, "fr")
+	}
+}
+
+func TestAuthNotSerialized(t *testing.T) {
+	req := &Request{Url: "http://intra.example.com/a", Rule: "r", Auth: &surfer.Auth{Username: "u", Password: "secret"}}
+	req.Prepare()
+	if s := req.Serialize(); strings.Contains(s, "secret") {
+		t.Fatalf("credentials serialized: %s", s)
+	}
+	if c := req.Copy(); c.GetAuth() != req.Auth {
+		t.Fatal("Copy() dropped Auth")
+	}
+	back, err := UnSerialize(req.Serialize())
+	if err != nil || back.GetAuth() != nil {
+		t.Fatalf("UnSerialize: %v %v", back.GetAuth(), err)
+	}
+}
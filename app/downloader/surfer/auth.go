@@ -0,0 +1,226 @@
+// Copyright 2015 henrylee2cn Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package surfer
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// HTTP认证凭据
+type Auth struct {
+	Username string
+	Password string
+	Digest   bool // 是否采用Digest认证，收到401质询后以摘要应答重发请求；为false时以Basic认证直接发送Authorization请求头
+}
+
+// 已收到的Digest质询，按用户名及主机缓存，后续请求直接以其应答，免去每次401往返；nonce过期时服务器重新质询
+type digestSession struct {
+	challenge map[string]string
+	nc        uint32 // 已使用该nonce的次数
+}
+
+// [用户名@主机]*digestSession
+var digestSessions sync.Map
+
+func (self *Auth) sessionKey(req *http.Request) string {
+	return self.Username + "@" + req.URL.Host
+}
+
+// 按凭据为请求设置Authorization请求头：Basic认证直接设置，Digest认证在已缓存该主机的质询时设置，
+// 已手动设置Authorization请求头时不覆盖
+func (self *Auth) authorize(req *http.Request) {
+	if req.Header.Get("Authorization") != "" {
+		return
+	}
+	var authorization string
+	if self.Digest {
+		v, ok := digestSessions.Load(self.sessionKey(req))
+		if !ok {
+			return
+		}
+		sess := v.(*digestSession)
+		var err error
+		if authorization, err = self.digestAuthorization(sess.challenge, req, atomic.AddUint32(&sess.nc, 1)); err != nil {
+			return
+		}
+	}
+	// 复制请求头，以免凭据写入原请求
+	header := make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		header[k] = v
+	}
+	req.Header = header
+	if self.Digest {
+		req.Header.Set("Authorization", authorization)
+	} else {
+		req.SetBasicAuth(self.Username, self.Password)
+	}
+}
+
+// 响应401 Digest质询时，以摘要应答重发请求，返回新的响应；无法应答时原样返回
+func (self *Auth) digest(client *http.Client, req *http.Request, resp *http.Response) (*http.Response, error) {
+	if !self.Digest || resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+	challenge := parseDigestChallenge(resp.Header.Values("WWW-Authenticate"))
+	if challenge == nil {
+		return resp, nil
+	}
+	if req.Body != nil && req.GetBody == nil {
+		// 请求体已读取且无法重建
+		return resp, nil
+	}
+	authorization, err := self.digestAuthorization(challenge, req, 1)
+	if err != nil {
+		return resp, nil
+	}
+	digestSessions.Store(self.sessionKey(req), &digestSession{challenge: challenge, nc: 1})
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	retry.Header.Set("Authorization", authorization)
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	return client.Do(retry)
+}
+
+// 解析WWW-Authenticate中的Digest质询参数，不存在时返回nil
+func parseDigestChallenge(values []string) map[string]string {
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if len(v) < 7 || !strings.EqualFold(v[:7], "Digest ") {
+			continue
+		}
+		params := make(map[string]string)
+		for _, part := range splitAuthParams(v[7:]) {
+			i := strings.Index(part, "=")
+			if i < 0 {
+				continue
+			}
+			key := strings.ToLower(strings.TrimSpace(part[:i]))
+			params[key] = strings.Trim(strings.TrimSpace(part[i+1:]), `"`)
+		}
+		if params["nonce"] == "" {
+			continue
+		}
+		return params
+	}
+	return nil
+}
+
+// 按逗号切分认证参数，忽略引号内的逗号
+func splitAuthParams(s string) []string {
+	var (
+		parts  []string
+		quoted bool
+		start  int
+	)
+	for i, c := range s {
+		switch c {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// 按RFC 7616计算Digest认证的Authorization请求头，nc为该nonce的第几次使用
+func (self *Auth) digestAuthorization(challenge map[string]string, req *http.Request, nc uint32) (string, error) {
+	algorithm := challenge["algorithm"]
+	sess := strings.HasSuffix(strings.ToUpper(algorithm), "-SESS")
+	var newHash func() hash.Hash
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "", "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm %q", algorithm)
+	}
+	h := func(s string) string {
+		hh := newHash()
+		io.WriteString(hh, s)
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+
+	var qop string
+	if challenge["qop"] != "" {
+		for _, q := range strings.Split(challenge["qop"], ",") {
+			if strings.TrimSpace(q) == "auth" {
+				qop = "auth"
+			}
+		}
+		if qop == "" {
+			// 不支持auth-int
+			return "", fmt.Errorf("unsupported digest qop %q", challenge["qop"])
+		}
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	var (
+		realm  = challenge["realm"]
+		nonce  = challenge["nonce"]
+		cnonce = hex.EncodeToString(b)
+		count  = fmt.Sprintf("%08x", nc)
+		uri    = req.URL.RequestURI()
+		ha1    = h(self.Username + ":" + realm + ":" + self.Password)
+		ha2    = h(req.Method + ":" + uri)
+	)
+	if sess {
+		ha1 = h(ha1 + ":" + nonce + ":" + cnonce)
+	}
+	var response string
+	if qop == "" {
+		response = h(ha1 + ":" + nonce + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + nonce + ":" + count + ":" + cnonce + ":" + qop + ":" + ha2)
+	}
+
+	authorization := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`,
+		self.Username, realm, nonce, uri, response)
+	if algorithm != "" {
+		authorization += ", algorithm=" + algorithm
+	}
+	if qop != "" {
+		authorization += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, qop, count, cnonce)
+	}
+	if opaque, ok := challenge["opaque"]; ok {
+		authorization += fmt.Sprintf(`, opaque="%s"`, opaque)
+	}
+	return authorization, nil
+}
@@ -0,0 +1,70 @@
+package surfer
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func md5hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// 要求Digest认证的服务器，校验应答的摘要，记录发出的质询次数及收到的nc
+func digestServer(challenges *int32, ncs *[]string) *httptest.Server {
+	const realm, nonce = "test", "abc123"
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := parseDigestChallenge([]string{r.Header.Get("Authorization")})
+		if params != nil && params["nonce"] == nonce {
+			ha1 := md5hex("user:" + realm + ":pass")
+			ha2 := md5hex(r.Method + ":" + params["uri"])
+			want := md5hex(ha1 + ":" + nonce + ":" + params["nc"] + ":" + params["cnonce"] + ":auth:" + ha2)
+			if params["response"] == want {
+				*ncs = append(*ncs, params["nc"])
+				w.Write([]byte("ok"))
+				return
+			}
+		}
+		atomic.AddInt32(challenges, 1)
+		w.Header().Set("WWW-Authenticate", `Digest realm="`+realm+`", nonce="`+nonce+`", qop="auth"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+}
+
+func TestDigestNonceCache(t *testing.T) {
+	var (
+		challenges int32
+		ncs        []string
+	)
+	srv := digestServer(&challenges, &ncs)
+	defer srv.Close()
+
+	auth := &Auth{Username: "user", Password: "pass", Digest: true}
+	surf := New()
+	for i := 0; i < 3; i++ {
+		resp, err := surf.Download(&DefaultRequest{Url: srv.URL + "/p", Auth: auth, TryTimes: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: status %d", i, resp.StatusCode)
+		}
+	}
+	if challenges != 1 {
+		t.Errorf("got %d challenges, want 1", challenges)
+	}
+	want := []string{"00000001", "00000002", "00000003"}
+	if len(ncs) != len(want) {
+		t.Fatalf("got nc %v, want %v", ncs, want)
+	}
+	for i := range want {
+		if ncs[i] != want[i] {
+			t.Fatalf("got nc %v, want %v", ncs, want)
+		}
+	}
+}
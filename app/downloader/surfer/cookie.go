@@ -0,0 +1,96 @@
+// Copyright 2015 henrylee2cn Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package surfer
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// 单次请求专用的cookiejar，将请求级cookie叠加在共享cookiejar之上
+// 优先级：请求级cookie > 共享cookiejar中的同名cookie
+// 请求级cookie仅对原请求的主机生效（含重定向到同一主机），从不写入共享cookiejar；
+// 响应中与请求级cookie同名的Set-Cookie仅在本次请求内更新，其余照常写入共享cookiejar。
+type overlayJar struct {
+	jar     http.CookieJar
+	host    string
+	cookies map[string]*http.Cookie // [name]*http.Cookie
+	order   []string
+	lock    sync.Mutex
+}
+
+func newOverlayJar(jar http.CookieJar, u *url.URL, cookies []*http.Cookie) *overlayJar {
+	self := &overlayJar{
+		jar:     jar,
+		host:    strings.ToLower(u.Hostname()),
+		cookies: make(map[string]*http.Cookie, len(cookies)),
+	}
+	for _, c := range cookies {
+		if _, ok := self.cookies[c.Name]; !ok {
+			self.order = append(self.order, c.Name)
+		}
+		self.cookies[c.Name] = c
+	}
+	return self
+}
+
+func (self *overlayJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	shared := make([]*http.Cookie, 0, len(cookies))
+	self.lock.Lock()
+	for _, c := range cookies {
+		if _, ok := self.cookies[c.Name]; ok && self.match(u) {
+			self.cookies[c.Name] = &http.Cookie{Name: c.Name, Value: c.Value}
+			continue
+		}
+		shared = append(shared, c)
+	}
+	self.lock.Unlock()
+	if len(shared) > 0 {
+		self.jar.SetCookies(u, shared)
+	}
+}
+
+func (self *overlayJar) Cookies(u *url.URL) []*http.Cookie {
+	jarCookies := self.jar.Cookies(u)
+	if !self.match(u) {
+		return jarCookies
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	cookies := make([]*http.Cookie, 0, len(jarCookies)+len(self.order))
+	for _, c := range jarCookies {
+		if _, ok := self.cookies[c.Name]; !ok {
+			cookies = append(cookies, c)
+		}
+	}
+	for _, name := range self.order {
+		cookies = append(cookies, self.cookies[name])
+	}
+	return cookies
+}
+
+func (self *overlayJar) match(u *url.URL) bool {
+	return strings.ToLower(u.Hostname()) == self.host
+}
+
+// 解析请求头中的Cookie
+func readCookies(header http.Header) []*http.Cookie {
+	if header.Get("Cookie") == "" {
+		return nil
+	}
+	return (&http.Request{Header: http.Header{"Cookie": header["Cookie"]}}).Cookies()
+}
@@ -0,0 +1,196 @@
+// Copyright 2015 henrylee2cn Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package surfer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 浏览器导出的JSON格式cookie，兼容EditThisCookie、Cookie-Editor等扩展及Playwright/Puppeteer的导出格式
+type jsonCookie struct {
+	Domain         string  `json:"domain"`
+	HostOnly       bool    `json:"hostOnly"`
+	HttpOnly       bool    `json:"httpOnly"`
+	Name           string  `json:"name"`
+	Path           string  `json:"path"`
+	Secure         bool    `json:"secure"`
+	Session        bool    `json:"session"`
+	Value          string  `json:"value"`
+	ExpirationDate float64 `json:"expirationDate"`
+	Expires        float64 `json:"expires"`
+}
+
+// 解析浏览器导出的cookie文件内容，支持Netscape格式(cookies.txt)及JSON格式，
+// 格式不正确的条目被跳过，并在errs中返回其原因；
+// 对子域名有效的cookie其Domain以"."开头，仅对该主机有效的cookie其Domain不带"."
+func ParseCookieFile(b []byte) (cookies []*http.Cookie, errs []error) {
+	b = bytes.TrimPrefix(b, []byte("\xEF\xBB\xBF"))
+	switch trimmed := bytes.TrimSpace(b); {
+	case len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{'):
+		return parseJsonCookies(trimmed)
+	default:
+		return parseNetscapeCookies(b)
+	}
+}
+
+// 解析Netscape格式：domain、includeSubdomains、path、secure、expiry、name、value，以制表符分隔
+func parseNetscapeCookies(b []byte) (cookies []*http.Cookie, errs []error) {
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := strings.HasPrefix(line, "#HttpOnly_")
+		if httpOnly {
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+		} else if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) == 6 {
+			// 值为空的cookie
+			fields = append(fields, "")
+		}
+		if len(fields) != 7 {
+			errs = append(errs, fmt.Errorf("line %v: expected 7 tab-separated fields, got %v", n, len(fields)))
+			continue
+		}
+		expiry, err := strconv.ParseInt(strings.TrimSpace(fields[4]), 10, 64)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %v: invalid expiry %q", n, fields[4]))
+			continue
+		}
+		c := &http.Cookie{
+			Domain:   fields[0],
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+		setHostOnly(c, !strings.EqualFold(fields[1], "TRUE"))
+		if expiry > 0 {
+			c.Expires = time.Unix(expiry, 0)
+		}
+		if err := checkCookie(c); err != nil {
+			errs = append(errs, fmt.Errorf("line %v: %v", n, err))
+			continue
+		}
+		cookies = append(cookies, c)
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return
+}
+
+// 解析JSON格式：cookie对象数组，或含cookies字段的对象
+func parseJsonCookies(b []byte) (cookies []*http.Cookie, errs []error) {
+	var list []json.RawMessage
+	if b[0] == '{' {
+		var wrapper struct {
+			Cookies []json.RawMessage `json:"cookies"`
+		}
+		if err := json.Unmarshal(b, &wrapper); err != nil {
+			return nil, []error{err}
+		}
+		list = wrapper.Cookies
+	} else if err := json.Unmarshal(b, &list); err != nil {
+		return nil, []error{err}
+	}
+	for i, raw := range list {
+		var jc jsonCookie
+		if err := json.Unmarshal(raw, &jc); err != nil {
+			errs = append(errs, fmt.Errorf("cookie %v: %v", i, err))
+			continue
+		}
+		c := &http.Cookie{
+			Domain:   jc.Domain,
+			Path:     jc.Path,
+			Secure:   jc.Secure,
+			Name:     jc.Name,
+			Value:    jc.Value,
+			HttpOnly: jc.HttpOnly,
+		}
+		setHostOnly(c, jc.HostOnly)
+		expires := jc.ExpirationDate
+		if expires == 0 {
+			expires = jc.Expires
+		}
+		// 会话cookie的expires为-1或0
+		if !jc.Session && expires > 0 {
+			c.Expires = time.Unix(int64(expires), 0)
+		}
+		if err := checkCookie(c); err != nil {
+			errs = append(errs, fmt.Errorf("cookie %v: %v", i, err))
+			continue
+		}
+		cookies = append(cookies, c)
+	}
+	return
+}
+
+// 以Domain是否带"."前缀区分仅对该主机有效的cookie
+func setHostOnly(c *http.Cookie, hostOnly bool) {
+	c.Domain = strings.TrimPrefix(strings.TrimSpace(c.Domain), ".")
+	if !hostOnly && c.Domain != "" {
+		c.Domain = "." + c.Domain
+	}
+}
+
+func checkCookie(c *http.Cookie) error {
+	if c.Name == "" {
+		return fmt.Errorf("empty cookie name")
+	}
+	if strings.TrimPrefix(c.Domain, ".") == "" {
+		return fmt.Errorf("cookie %q has no domain", c.Name)
+	}
+	if c.Path == "" {
+		c.Path = "/"
+	}
+	return nil
+}
+
+// 将cookie写入共享cookiejar，仅对设置了EnableCookie的请求生效；
+// domain不为空时仅写入属于该域名（含子域名）的cookie，返回写入的数量
+func (self *Surf) LoadCookies(cookies []*http.Cookie, domain string) int {
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	var n int
+	for _, c := range cookies {
+		host := strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+		if domain != "" && host != domain && !strings.HasSuffix(host, "."+domain) {
+			continue
+		}
+		u := &url.URL{Scheme: "http", Host: host, Path: c.Path}
+		if c.Secure {
+			u.Scheme = "https"
+		}
+		jc := *c
+		if !strings.HasPrefix(jc.Domain, ".") {
+			// 不带Domain属性时cookiejar视其为仅对该主机有效
+			jc.Domain = ""
+		}
+		self.cookieJar.SetCookies(u, []*http.Cookie{&jc})
+		n++
+	}
+	return n
+}
@@ -0,0 +1,154 @@
+// Copyright 2015 henrylee2cn Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package surfer
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// DNS缓存的默认容量
+const DefaultDNSCacheSize = 10000
+
+type (
+	// 进程内的DNS缓存，解析结果按固定的TTL缓存（Go的解析器不返回记录的TTL），并发安全；
+	// 同一主机的并发解析只查询一次，解析失败时不缓存
+	DNSCache struct {
+		ttl      time.Duration
+		size     int
+		resolver *net.Resolver
+		entries  map[string]*dnsEntry
+		lock     sync.Mutex
+	}
+	dnsEntry struct {
+		addrs   []string
+		err     error
+		expires time.Time
+		ready   bool          // 是否已完成解析
+		done    chan struct{} // 解析完成时关闭
+	}
+	// 经由DNS缓存解析主机名的拨号器，未开启DNS缓存时等同于net.Dialer
+	cacheDialer struct {
+		*net.Dialer
+		cache *DNSCache
+	}
+)
+
+// 创建DNS缓存，ttl为解析结果的缓存时长，size为最多缓存的主机数，不大于0时为DefaultDNSCacheSize；
+// resolver为nil时使用系统默认解析器
+func NewDNSCache(ttl time.Duration, size int, resolver *net.Resolver) *DNSCache {
+	if size <= 0 {
+		size = DefaultDNSCacheSize
+	}
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return &DNSCache{
+		ttl:      ttl,
+		size:     size,
+		resolver: resolver,
+		entries:  make(map[string]*dnsEntry),
+	}
+}
+
+// 解析主机名，返回其IP地址列表，缓存有效时不再查询
+func (self *DNSCache) LookupHost(ctx context.Context, host string) ([]string, error) {
+	self.lock.Lock()
+	entry, ok := self.entries[host]
+	if ok && (!entry.ready || time.Now().Before(entry.expires)) {
+		self.lock.Unlock()
+		select {
+		case <-entry.done:
+			return entry.addrs, entry.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	self.evict()
+	entry = &dnsEntry{done: make(chan struct{})}
+	self.entries[host] = entry
+	self.lock.Unlock()
+
+	addrs, err := self.resolver.LookupHost(ctx, host)
+
+	self.lock.Lock()
+	entry.addrs, entry.err = addrs, err
+	entry.expires = time.Now().Add(self.ttl)
+	entry.ready = true
+	if err != nil && self.entries[host] == entry {
+		delete(self.entries, host)
+	}
+	self.lock.Unlock()
+	close(entry.done)
+	return addrs, err
+}
+
+// 容量已满时移除过期的缓存，仍满时任意移除一条已完成解析的缓存，需在加锁状态下调用
+func (self *DNSCache) evict() {
+	if len(self.entries) < self.size {
+		return
+	}
+	now := time.Now()
+	for host, entry := range self.entries {
+		if entry.ready && !now.Before(entry.expires) {
+			delete(self.entries, host)
+		}
+	}
+	if len(self.entries) < self.size {
+		return
+	}
+	for host, entry := range self.entries {
+		if entry.ready {
+			delete(self.entries, host)
+			return
+		}
+	}
+}
+
+// 解析地址中的主机名并依次尝试连接其各个IP，主机名为IP或未开启DNS缓存时直接连接
+func (self *cacheDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if self.cache == nil {
+		return self.Dialer.DialContext(ctx, network, addr)
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return self.Dialer.DialContext(ctx, network, addr)
+	}
+	if self.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, self.Timeout)
+		defer cancel()
+	}
+	addrs, err := self.cache.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range addrs {
+		var conn net.Conn
+		if conn, err = self.Dialer.DialContext(ctx, network, net.JoinHostPort(ip, port)); err == nil {
+			return conn, nil
+		}
+	}
+	if err == nil {
+		err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return nil, err
+}
+
+func (self *cacheDialer) Dial(network, addr string) (net.Conn, error) {
+	return self.DialContext(context.Background(), network, addr)
+}
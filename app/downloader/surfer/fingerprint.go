@@ -0,0 +1,97 @@
+// Copyright 2015 henrylee2cn Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package surfer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	// 依赖utls的v1.6.7版本，其他版本的ClientHelloID及扩展类型可能不同，见README
+	utls "github.com/refraction-networking/utls"
+)
+
+// 可模拟的浏览器TLS指纹，以该浏览器的ClientHello进行握手，用于规避基于TLS指纹(JA3)的封禁；
+// 增减时须同步修改config中对run::tlsfingerprint的校验
+var TLSFingerprints = map[string]utls.ClientHelloID{
+	"chrome":  utls.HelloChrome_Auto,
+	"firefox": utls.HelloFirefox_Auto,
+	"safari":  utls.HelloSafari_Auto,
+	"edge":    utls.HelloEdge_Auto,
+}
+
+// 设置https请求模拟的浏览器TLS指纹，见TLSFingerprints，为空时使用Go默认的TLS握手
+func (self *Surf) SetTLSFingerprint(name string) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if _, ok := TLSFingerprints[name]; !ok && name != "" {
+		return fmt.Errorf("unknown tls fingerprint %q", name)
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.fingerprint == name {
+		return nil
+	}
+	self.fingerprint = name
+	self.resetTransports()
+	return nil
+}
+
+// 返回以指定浏览器指纹进行TLS握手的拨号函数，proxy不为nil时经HTTP CONNECT隧道连接目标主机
+func fingerprintDialer(id utls.ClientHelloID, dialer *cacheDialer, proxy *url.URL) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		var (
+			conn net.Conn
+			err  error
+		)
+		if proxy != nil {
+			conn, err = dialTunnel(dialer.Dialer, proxy, addr)
+		} else {
+			conn, err = dialer.DialContext(ctx, network, addr)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		host, _, _ := net.SplitHostPort(addr)
+		uconn := utls.UClient(conn, &utls.Config{ServerName: host, InsecureSkipVerify: true}, utls.HelloCustom)
+		spec, err := utls.UTLSIdToSpec(id)
+		if err == nil {
+			// Transport自定义TLS拨号时仅支持HTTP/1.1，故只协商http/1.1；扩展列表不变，不影响JA3指纹
+			for _, ext := range spec.Extensions {
+				if alpn, ok := ext.(*utls.ALPNExtension); ok {
+					alpn.AlpnProtocols = []string{"http/1.1"}
+				}
+			}
+			err = uconn.ApplyPreset(&spec)
+		}
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		if dialer.Timeout > 0 {
+			conn.SetDeadline(time.Now().Add(dialer.Timeout))
+		}
+		if err = uconn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn.SetDeadline(time.Time{})
+		return uconn, nil
+	}
+}
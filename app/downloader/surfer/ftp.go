@@ -0,0 +1,254 @@
+// Copyright 2015 henrylee2cn Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package surfer
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"net/textproto"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// FTP下载器，以被动模式下载ftp://地址的文件，地址以/结尾时返回目录列表；
+	// 凭据依次取自请求的Auth、地址中的用户信息，均未设置时匿名登录；不支持代理
+	Ftp struct{}
+
+	// FTP的一次会话
+	ftpConn struct {
+		*textproto.Conn
+		dialer  *net.Dialer
+		host    string
+		timeout time.Duration // 数据连接的超时，0为不限
+	}
+)
+
+func NewFtp() Surfer {
+	return new(Ftp)
+}
+
+func (self *Ftp) Download(req Request) (resp *http.Response, err error) {
+	param, err := NewParam(req)
+	if err != nil {
+		return nil, err
+	}
+	tryTimes := param.tryTimes
+	if tryTimes <= 0 {
+		tryTimes = 1
+	}
+	for i := 0; i < tryTimes; i++ {
+		if resp, err = self.fetch(param); err == nil {
+			break
+		}
+		time.Sleep(param.retryPause)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return param.writeback(resp), nil
+}
+
+// 登录并下载文件或目录列表，文件不存在及登录失败分别以404、401响应返回
+func (self *Ftp) fetch(param *Param) (*http.Response, error) {
+	c, err := self.dial(param)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	if code, msg, err := c.login(param); err != nil {
+		if code == 530 {
+			return ftpResponse(param, http.StatusUnauthorized, "text/plain; charset=utf-8", []byte(msg)), nil
+		}
+		return nil, err
+	}
+	if _, _, err = c.cmd(2, "TYPE I"); err != nil {
+		return nil, err
+	}
+
+	name := param.url.Path
+	if name == "" {
+		name = "/"
+	}
+	list := strings.HasSuffix(name, "/")
+	command := "RETR " + name
+	if list {
+		command = "LIST " + name
+	}
+	body, code, msg, err := c.transfer(command)
+	if err != nil {
+		if code == 550 {
+			return ftpResponse(param, http.StatusNotFound, "text/plain; charset=utf-8", []byte(msg)), nil
+		}
+		return nil, err
+	}
+	c.cmd(2, "QUIT")
+
+	contentType := "text/plain; charset=utf-8"
+	if !list {
+		if contentType = mime.TypeByExtension(path.Ext(name)); contentType == "" {
+			contentType = "application/octet-stream"
+		}
+	}
+	return ftpResponse(param, http.StatusOK, contentType, body), nil
+}
+
+func (self *Ftp) dial(param *Param) (*ftpConn, error) {
+	var (
+		host = param.url.Hostname()
+		addr = param.url.Host
+	)
+	if param.url.Port() == "" {
+		addr = net.JoinHostPort(host, "21")
+	}
+	dialer := &net.Dialer{Timeout: param.dialTimeout}
+	conn, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if param.connTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(param.connTimeout))
+	}
+	c := &ftpConn{textproto.NewConn(conn), dialer, host, param.connTimeout}
+	if _, _, err = c.ReadResponse(220); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// 发送命令并读取响应，响应码与expect不符时返回错误
+func (self *ftpConn) cmd(expect int, format string, args ...interface{}) (int, string, error) {
+	if _, err := self.Cmd(format, args...); err != nil {
+		return 0, "", err
+	}
+	return self.ReadResponse(expect)
+}
+
+func (self *ftpConn) login(param *Param) (int, string, error) {
+	user, pass := "anonymous", "anonymous@"
+	if param.auth != nil {
+		user, pass = param.auth.Username, param.auth.Password
+	} else if u := param.url.User; u != nil {
+		user, pass = u.Username(), passwordOf(u)
+	}
+	code, msg, err := self.cmd(0, "USER %s", user)
+	if err != nil {
+		return code, msg, err
+	}
+	switch code {
+	case 230:
+		return code, msg, nil
+	case 331:
+		return self.cmd(2, "PASS %s", pass)
+	}
+	return code, msg, &textproto.Error{Code: code, Msg: msg}
+}
+
+// 以被动模式执行数据传输命令，返回读取的全部数据
+func (self *ftpConn) transfer(command string) ([]byte, int, string, error) {
+	data, err := self.passive()
+	if err != nil {
+		return nil, 0, "", err
+	}
+	defer data.Close()
+	if code, msg, err := self.cmd(1, "%s", command); err != nil {
+		return nil, code, msg, err
+	}
+	body, err := ioutil.ReadAll(data)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	// 关闭数据连接后读取传输完成的响应
+	data.Close()
+	if code, msg, err := self.ReadResponse(2); err != nil {
+		return nil, code, msg, err
+	}
+	return body, 0, "", nil
+}
+
+// 建立被动模式的数据连接，优先使用EPSV，并总是连接控制连接所在的主机
+func (self *ftpConn) passive() (net.Conn, error) {
+	port, err := self.epsv()
+	if err != nil {
+		if port, err = self.pasv(); err != nil {
+			return nil, err
+		}
+	}
+	conn, err := self.dialer.Dial("tcp", net.JoinHostPort(self.host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+	if self.timeout > 0 {
+		conn.SetDeadline(time.Now().Add(self.timeout))
+	}
+	return conn, nil
+}
+
+// 解析229响应中的端口，如 "Entering Extended Passive Mode (|||6446|)"
+func (self *ftpConn) epsv() (int, error) {
+	_, msg, err := self.cmd(229, "EPSV")
+	if err != nil {
+		return 0, err
+	}
+	start, end := strings.Index(msg, "(|||"), strings.LastIndex(msg, "|)")
+	if start < 0 || end < start+4 {
+		return 0, fmt.Errorf("ftp: invalid EPSV response %q", msg)
+	}
+	return strconv.Atoi(msg[start+4 : end])
+}
+
+// 解析227响应中的端口，如 "Entering Passive Mode (192,168,1,2,25,46)"
+func (self *ftpConn) pasv() (int, error) {
+	_, msg, err := self.cmd(227, "PASV")
+	if err != nil {
+		return 0, err
+	}
+	start, end := strings.Index(msg, "("), strings.LastIndex(msg, ")")
+	if start < 0 || end < start {
+		return 0, fmt.Errorf("ftp: invalid PASV response %q", msg)
+	}
+	fields := strings.Split(msg[start+1:end], ",")
+	if len(fields) != 6 {
+		return 0, fmt.Errorf("ftp: invalid PASV response %q", msg)
+	}
+	p1, err1 := strconv.Atoi(strings.TrimSpace(fields[4]))
+	p2, err2 := strconv.Atoi(strings.TrimSpace(fields[5]))
+	if err1 != nil || err2 != nil {
+		return 0, fmt.Errorf("ftp: invalid PASV response %q", msg)
+	}
+	return p1<<8 | p2, nil
+}
+
+func ftpResponse(param *Param, statusCode int, contentType string, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {contentType}},
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       &http.Request{URL: param.url},
+	}
+}
@@ -0,0 +1,170 @@
+package surfer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// 模拟的FTP服务器，仅支持被动模式，用户user/pass或匿名登录；
+// noEPSV为true时拒绝EPSV，以检验退回PASV
+func fakeFtpServer(t *testing.T, files map[string]string, noEPSV bool) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFtp(conn, files, noEPSV)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func serveFtp(conn net.Conn, files map[string]string, noEPSV bool) {
+	defer conn.Close()
+	reply := func(format string, args ...interface{}) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+	var (
+		user string
+		data net.Listener
+	)
+	// 接受数据连接并写入内容
+	send := func(content string) {
+		defer data.Close()
+		dc, err := data.Accept()
+		if err != nil {
+			return
+		}
+		reply("150 opening data connection")
+		io.WriteString(dc, content)
+		dc.Close()
+		reply("226 transfer complete")
+	}
+	reply("220 ready")
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command, arg := strings.TrimSpace(line), ""
+		if i := strings.Index(command, " "); i >= 0 {
+			command, arg = command[:i], command[i+1:]
+		}
+		switch command {
+		case "USER":
+			user = arg
+			reply("331 password required")
+		case "PASS":
+			if (user == "user" && arg == "pass") || user == "anonymous" {
+				reply("230 logged in")
+			} else {
+				reply("530 login incorrect")
+			}
+		case "TYPE":
+			reply("200 type set")
+		case "EPSV", "PASV":
+			if command == "EPSV" && noEPSV {
+				reply("502 not implemented")
+				continue
+			}
+			if data, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+				reply("425 cannot open data connection")
+				continue
+			}
+			port := data.Addr().(*net.TCPAddr).Port
+			if command == "EPSV" {
+				reply("229 Entering Extended Passive Mode (|||%d|)", port)
+			} else {
+				reply("227 Entering Passive Mode (127,0,0,1,%d,%d)", port>>8, port&0xff)
+			}
+		case "RETR":
+			content, ok := files[arg]
+			if !ok {
+				data.Close()
+				reply("550 no such file")
+				continue
+			}
+			send(content)
+		case "LIST":
+			var names []string
+			for name := range files {
+				if strings.HasPrefix(name, arg) {
+					names = append(names, strings.TrimPrefix(name, arg))
+				}
+			}
+			send(strings.Join(names, "\r\n"))
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func ftpGet(t *testing.T, rawurl string, auth *Auth) (*http.Response, string) {
+	resp, err := NewFtp().Download(&DefaultRequest{Url: rawurl, Method: "GET", Auth: auth, TryTimes: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestFtpDownload(t *testing.T) {
+	addr := fakeFtpServer(t, map[string]string{"/pub/a.txt": "hello"}, false)
+
+	resp, body := ftpGet(t, "ftp://"+addr+"/pub/a.txt", nil)
+	if resp.StatusCode != http.StatusOK || body != "hello" {
+		t.Errorf("RETR: status %d, body %q", resp.StatusCode, body)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", ct)
+	}
+	if resp, body = ftpGet(t, "ftp://"+addr+"/pub/", nil); resp.StatusCode != http.StatusOK || body != "a.txt" {
+		t.Errorf("LIST: status %d, body %q", resp.StatusCode, body)
+	}
+	if resp, _ = ftpGet(t, "ftp://"+addr+"/pub/b.txt", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing file: status %d, want 404", resp.StatusCode)
+	}
+}
+
+// 凭据优先取自Auth，其次取自地址中的用户信息；登录失败以401返回
+func TestFtpLogin(t *testing.T) {
+	addr := fakeFtpServer(t, map[string]string{"/a.txt": "hello"}, false)
+
+	if resp, body := ftpGet(t, "ftp://user:pass@"+addr+"/a.txt", nil); resp.StatusCode != http.StatusOK || body != "hello" {
+		t.Errorf("url credentials: status %d, body %q", resp.StatusCode, body)
+	}
+	if resp, _ := ftpGet(t, "ftp://user:pass@"+addr+"/a.txt", &Auth{Username: "user", Password: "wrong"}); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong Auth: status %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestFtpPasvFallback(t *testing.T) {
+	addr := fakeFtpServer(t, map[string]string{"/a.bin": "\x00\x01"}, true)
+	resp, body := ftpGet(t, "ftp://"+addr+"/a.bin", nil)
+	if resp.StatusCode != http.StatusOK || body != "\x00\x01" {
+		t.Errorf("status %d, body %q", resp.StatusCode, body)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/octet-stream" {
+		t.Errorf("Content-Type = %q, want application/octet-stream", ct)
+	}
+}
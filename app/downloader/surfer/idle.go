@@ -0,0 +1,46 @@
+package surfer
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// 读取响应内容时的空闲超时错误，实现net.Error接口，Timeout()为true
+type idleTimeoutError struct{}
+
+func (idleTimeoutError) Error() string   { return "read idle timeout: no data received" }
+func (idleTimeoutError) Timeout() bool   { return true }
+func (idleTimeoutError) Temporary() bool { return true }
+
+// 带有空闲超时的响应内容读取器，连续timeout时长无数据到达时关闭底层连接，
+// 以免对端建立连接后停止发送数据时，下载协程长期阻塞
+type idleReader struct {
+	body    io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	expired int32
+}
+
+func newIdleReader(body io.ReadCloser, timeout time.Duration) *idleReader {
+	r := &idleReader{body: body, timeout: timeout}
+	r.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&r.expired, 1)
+		body.Close()
+	})
+	return r
+}
+
+func (self *idleReader) Read(p []byte) (n int, err error) {
+	n, err = self.body.Read(p)
+	if atomic.LoadInt32(&self.expired) == 1 {
+		return n, idleTimeoutError{}
+	}
+	self.timer.Reset(self.timeout)
+	return
+}
+
+func (self *idleReader) Close() error {
+	self.timer.Stop()
+	return self.body.Close()
+}
@@ -0,0 +1,53 @@
+package surfer
+
+import (
+	"io"
+	"net/http"
+)
+
+// 将响应内容限制为最多n字节，n<=0时不限；按读取进度逐步计数，
+// 故对未声明Content-Length的分块响应同样有效，且不会预先缓冲整个响应；
+// 超出部分不再接收，读取到上限时返回io.EOF，确认确有超出的内容时调用onTruncate（可为nil）
+func LimitBody(resp *http.Response, n int64, onTruncate func()) {
+	if n <= 0 || resp == nil || resp.Body == nil {
+		return
+	}
+	if resp.ContentLength >= 0 && resp.ContentLength <= n {
+		return
+	}
+	if resp.ContentLength > n {
+		resp.ContentLength = n
+	}
+	resp.Body = &limitReader{body: resp.Body, left: n, onTruncate: onTruncate}
+}
+
+// 限制读取字节数的响应内容读取器
+type limitReader struct {
+	body       io.ReadCloser
+	left       int64 // 剩余可读取的字节数
+	checked    bool  // 是否已探测过上限之后是否还有内容
+	onTruncate func()
+}
+
+func (self *limitReader) Read(p []byte) (n int, err error) {
+	if self.left <= 0 {
+		if !self.checked {
+			self.checked = true
+			var b [1]byte
+			if m, _ := io.ReadFull(self.body, b[:]); m > 0 && self.onTruncate != nil {
+				self.onTruncate()
+			}
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > self.left {
+		p = p[:self.left]
+	}
+	n, err = self.body.Read(p)
+	self.left -= int64(n)
+	return
+}
+
+func (self *limitReader) Close() error {
+	return self.body.Close()
+}
@@ -0,0 +1,103 @@
+package surfer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 分块发送响应内容，不设置Content-Length；forever为true时持续发送，模拟不结束的流式接口
+func chunkedServer(chunks int, forever bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		for i := 0; i < chunks || forever; i++ {
+			if _, err := w.Write([]byte(strings.Repeat("x", 100))); err != nil {
+				return
+			}
+			flusher.Flush()
+			time.Sleep(time.Millisecond)
+		}
+	}))
+}
+
+func TestLimitBodyChunked(t *testing.T) {
+	srv := chunkedServer(10, false)
+	defer srv.Close()
+
+	cases := []struct {
+		limit     int64
+		want      int
+		truncated bool
+	}{
+		{0, 1000, false},
+		{1000, 1000, false},
+		{2000, 1000, false},
+		{250, 250, true},
+	}
+	for _, c := range cases {
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.ContentLength != -1 || len(resp.TransferEncoding) == 0 || resp.TransferEncoding[0] != "chunked" {
+			t.Fatalf("response is not chunked: %v %v", resp.ContentLength, resp.TransferEncoding)
+		}
+		var truncated bool
+		LimitBody(resp, c.limit, func() { truncated = true })
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Errorf("limit %v: %v", c.limit, err)
+		}
+		if len(b) != c.want || truncated != c.truncated {
+			t.Errorf("limit %v: read %v bytes, truncated %v; want %v, %v", c.limit, len(b), truncated, c.want, c.truncated)
+		}
+	}
+}
+
+// 不结束的流式响应读取到上限即返回，不会阻塞
+func TestLimitBodyEndlessStream(t *testing.T) {
+	srv := chunkedServer(0, true)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	LimitBody(resp, 4096, nil)
+	done := make(chan int)
+	go func() {
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		done <- len(b)
+	}()
+	select {
+	case n := <-done:
+		if n != 4096 {
+			t.Errorf("read %v bytes, want 4096", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reading an endless stream did not stop at the limit")
+	}
+}
+
+func TestLimitBodyContentLength(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("y", 500)))
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	LimitBody(resp, 100, nil)
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if len(b) != 100 || resp.ContentLength != 100 {
+		t.Errorf("read %v bytes with ContentLength %v, want 100", len(b), resp.ContentLength)
+	}
+}
@@ -16,6 +16,7 @@ package surfer
 
 import (
 	"bytes"
+	"compress/gzip"
 	"fmt"
 	"io"
 	"math/rand"
@@ -35,11 +36,17 @@ type Param struct {
 	body          io.Reader
 	header        http.Header
 	enableCookie  bool
+	cookies       []*http.Cookie // 请求级cookie，仅作用于本次请求
 	dialTimeout   time.Duration
 	connTimeout   time.Duration
+	headerTimeout time.Duration // 等待响应头的超时，0为不限
+	idleTimeout   time.Duration // 读取响应内容时的空闲超时，0为不限
 	tryTimes      int
 	retryPause    time.Duration
 	redirectTimes int
+	auth          *Auth // HTTP认证凭据，nil为不认证
+	rawHeader     bool  // 是否按原样大小写发送请求头
+	http10        bool  // 是否以HTTP/1.0发送请求
 	client        *http.Client
 }
 
@@ -85,14 +92,39 @@ func NewParam(req Request) (param *Param, err error) {
 		param.header.Add("Content-Type", writer.FormDataContentType())
 		param.body = body
 
+	case "":
+		return nil, fmt.Errorf("http method is empty")
 	default:
-		param.method = "GET"
+		// 其他请求方法（PUT、DELETE、PATCH等）原样透传
+		if !ValidMethod(method) {
+			return nil, fmt.Errorf("invalid http method %q", req.GetMethod())
+		}
+		param.method = method
+		if postData := req.GetPostData(); postData != "" {
+			if param.header.Get("Content-Type") == "" {
+				param.header.Add("Content-Type", "application/x-www-form-urlencoded")
+			}
+			param.body = strings.NewReader(postData)
+		}
+	}
+
+	if param.body != nil && req.GetGzipBody() {
+		if param.body, err = gzipBody(param.body); err != nil {
+			return nil, err
+		}
+		param.header.Set("Content-Encoding", "gzip")
 	}
 
+	param.auth = req.GetAuth()
+	param.rawHeader = req.GetRawHeader()
+	param.http10 = req.GetHTTP10()
 	param.enableCookie = req.GetEnableCookie()
+	if param.enableCookie {
+		param.cookies = readCookies(param.header)
+	}
 
 	if len(param.header.Get("User-Agent")) == 0 {
-		if param.enableCookie {
+		if param.enableCookie || !RotateUserAgent {
 			param.header.Add("User-Agent", agent.UserAgents["common"][0])
 		} else {
 			l := len(agent.UserAgents["common"])
@@ -107,6 +139,8 @@ func NewParam(req Request) (param *Param, err error) {
 	}
 
 	param.connTimeout = req.GetConnTimeout()
+	param.headerTimeout = req.GetHeaderTimeout()
+	param.idleTimeout = req.GetIdleTimeout()
 	param.tryTimes = req.GetTryTimes()
 	param.retryPause = req.GetRetryPause()
 	param.redirectTimes = req.GetRedirectTimes()
@@ -144,3 +178,16 @@ func (self *Param) checkRedirect(req *http.Request, via []*http.Request) error {
 	}
 	return nil
 }
+
+// 以gzip压缩请求体，返回的*bytes.Reader使http.NewRequest自动以压缩后的长度设置Content-Length
+func gzipBody(body io.Reader) (*bytes.Reader, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.Copy(zw, body); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(buf.Bytes()), nil
+}
@@ -15,7 +15,10 @@
 package surfer
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"mime"
@@ -24,24 +27,60 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
 // 基于Phantomjs的下载器实现，作为surfer的补充
 // 效率较surfer会慢很多，但是因为模拟浏览器，破防性更好
-// 支持UserAgent/TryTimes/RetryPause/自定义js
+// 支持UserAgent/TryTimes/RetryPause/ConnTimeout/自定义js，ConnTimeout限制整个渲染过程
+// 可经SetPool()常驻复用phantomjs进程，请求实现PhantomRequest时可在获取内容前关闭遮罩层
 type (
 	Phantom struct {
 		PhantomjsFile string            //Phantomjs完整文件名
 		TempJsDir     string            //临时js存放目录
 		jsFileMap     map[string]string //已存在的js文件
+		pool          *phantomPool      //常驻进程池，nil时每个请求启动新进程
+		poolLock      sync.RWMutex
 	}
 	Response struct {
 		Cookie string
 		Body   string
+		Error  string // 常驻进程请求失败的原因
+	}
+
+	// PhantomJS下载器的请求，未实现时不执行遮罩层的关闭步骤
+	PhantomRequest interface {
+		Request
+		// 页面加载后、获取内容前执行的关闭步骤，nil为不执行
+		GetDismiss() *Dismiss
+	}
+
+	// 关闭Cookie同意提示、年龄确认等遮罩层的步骤，页面加载后依次点击各选择器匹配的首个元素，
+	// 元素不存在时跳过该步骤，因此遮罩层未出现时不影响抓取
+	Dismiss struct {
+		Selectors []string      // 依次点击的CSS选择器，如"#onetrust-accept-btn-handler"
+		Wait      time.Duration // 每次点击后等待遮罩层关闭或下一步骤出现的时长，无点击时不等待
 	}
 )
 
+// 传给js的关闭步骤，Wait以毫秒表示，无步骤时为空字符串
+func dismissArg(req Request) string {
+	phantomReq, ok := req.(PhantomRequest)
+	if !ok {
+		return ""
+	}
+	d := phantomReq.GetDismiss()
+	if d == nil || len(d.Selectors) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(struct {
+		Selectors []string
+		Wait      int64
+	}{d.Selectors, int64(d.Wait / time.Millisecond)})
+	return string(b)
+}
+
 func NewPhantom(phantomjsFile, tempJsDir string) Surfer {
 	phantom := &Phantom{
 		PhantomjsFile: phantomjsFile,
@@ -62,9 +101,33 @@ func NewPhantom(phantomjsFile, tempJsDir string) Surfer {
 	}
 	phantom.createJsFile("get", getJs)
 	phantom.createJsFile("post", postJs)
+	phantom.createJsFile("pool", poolJs)
 	return phantom
 }
 
+// 设置常驻复用的phantomjs进程池：size为进程数，0为每个请求启动新进程；
+// 每个进程处理maxUses个请求后由新进程替代；shared为true时同一进程的请求间共享cookie，否则每个请求前清空cookie
+func (self *Phantom) SetPool(size, maxUses int, shared bool) {
+	self.poolLock.Lock()
+	defer self.poolLock.Unlock()
+	if p := self.pool; p != nil {
+		if p.size == size && p.maxUses == maxUses && p.shared == shared {
+			return
+		}
+		p.close()
+		self.pool = nil
+	}
+	if size > 0 {
+		self.pool = newPhantomPool(self.PhantomjsFile, self.jsFileMap["pool"], size, maxUses, shared)
+	}
+}
+
+func (self *Phantom) getPool() *phantomPool {
+	self.poolLock.RLock()
+	defer self.poolLock.RUnlock()
+	return self.pool
+}
+
 // 实现surfer下载器接口
 func (self *Phantom) Download(req Request) (resp *http.Response, err error) {
 	var encoding = "utf-8"
@@ -91,6 +154,7 @@ func (self *Phantom) Download(req Request) (resp *http.Response, err error) {
 			param.header.Get("Cookie"),
 			encoding,
 			param.header.Get("User-Agent"),
+			dismissArg(req),
 		}
 	case "POST", "POST-M":
 		args = []string{
@@ -100,29 +164,45 @@ func (self *Phantom) Download(req Request) (resp *http.Response, err error) {
 			encoding,
 			param.header.Get("User-Agent"),
 			req.GetPostData(),
+			dismissArg(req),
 		}
+	default:
+		return resp, fmt.Errorf("phantomjs downloader does not support the %v method", req.GetMethod())
 	}
 
+	// 以ConnTimeout限制整个渲染过程（含各次重试），超时后结束phantomjs进程
+	ctx := context.Background()
+	if param.connTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, param.connTimeout)
+		defer cancel()
+	}
 	for i := 0; i < param.tryTimes; i++ {
-		cmd := exec.Command(self.PhantomjsFile, args...)
-		if resp.Body, err = cmd.StdoutPipe(); err != nil {
-			time.Sleep(param.retryPause)
-			continue
-		}
-		if cmd.Start() != nil || resp.Body == nil {
-			time.Sleep(param.retryPause)
-			continue
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(param.retryPause):
+			}
 		}
 		var b []byte
-		b, err = ioutil.ReadAll(resp.Body)
+		if pool := self.getPool(); pool != nil {
+			b, err = pool.render(ctx, args[1:])
+		} else {
+			b, err = self.run(ctx, args)
+		}
+		if ctx.Err() != nil {
+			err = fmt.Errorf("phantomjs render exceeded the %v timeout: %w", param.connTimeout, ctx.Err())
+			break
+		}
 		if err != nil {
-			time.Sleep(param.retryPause)
 			continue
 		}
 		retResp := Response{}
-		err = json.Unmarshal(b, &retResp)
-		if err != nil {
-			time.Sleep(param.retryPause)
+		if err = json.Unmarshal(b, &retResp); err != nil {
+			continue
+		}
+		if retResp.Error != "" {
+			err = errors.New(retResp.Error)
 			continue
 		}
 		resp.Header = param.header
@@ -141,6 +221,15 @@ func (self *Phantom) Download(req Request) (resp *http.Response, err error) {
 	return
 }
 
+// 执行phantomjs并返回其输出，ctx结束时结束进程；无论成败均等待进程退出，不遗留进程
+func (self *Phantom) run(ctx context.Context, args []string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, self.PhantomjsFile, args...)
+	killGroup(cmd)
+	// 进程被结束后，最多再等待其输出管道关闭1秒
+	cmd.WaitDelay = time.Second
+	return cmd.Output()
+}
+
 //销毁js临时文件
 func (self *Phantom) DestroyJsFiles() {
 	p, _ := filepath.Split(self.TempJsDir)
@@ -164,6 +253,39 @@ func (self *Phantom) createJsFile(fileName, jsCode string) {
 	self.jsFileMap[fileName] = fullFileName
 }
 
+/*
+* 遮罩层的关闭步骤，steps为{"Selectors": [...], "Wait": 毫秒}或null，
+* 依次点击各选择器匹配的首个元素，不存在时跳过，完成后调用done
+ */
+const dismissJs string = `
+function dismiss(page, steps, done) {
+    var selectors = steps && steps.Selectors || [];
+    var i = 0;
+    var next = function() {
+        if (i >= selectors.length) {
+            done();
+            return;
+        }
+        var clicked = page.evaluate(function(s) {
+            try {
+                var el = document.querySelector(s);
+                if (!el) {
+                    return false;
+                }
+                var ev = document.createEvent('MouseEvents');
+                ev.initMouseEvent('click', true, true, window, 0, 0, 0, 0, 0, false, false, false, false, 0, null);
+                el.dispatchEvent(ev);
+                return true;
+            } catch (e) {
+                return false;
+            }
+        }, selectors[i++]);
+        setTimeout(next, clicked ? steps.Wait || 0 : 0);
+    };
+    next();
+}
+`
+
 /*
 * GET method
 * system.args[0] == get.js
@@ -171,15 +293,17 @@ func (self *Phantom) createJsFile(fileName, jsCode string) {
 * system.args[2] == cookie
 * system.args[3] == pageEncode
 * system.args[4] == userAgent
+* system.args[5] == dismiss steps (json, optional)
  */
 
-const getJs string = `
+const getJs string = dismissJs + `
 var system = require('system');
 var page = require('webpage').create();
 var url = system.args[1];
 var cookie = system.args[2];
 var pageEncode = system.args[3];
 var userAgent = system.args[4];
+var steps = system.args[5] ? JSON.parse(system.args[5]) : null;
 page.onResourceRequested = function(requestData, request) {
     request.setHeader('Cookie', cookie)
 };
@@ -188,8 +312,11 @@ page.settings.userAgent = userAgent;
 page.open(url, function(status) {
     if (status !== 'success') {
         console.log('Unable to access network');
-    } else {
-       	var cookie = page.evaluate(function(s) {
+        phantom.exit();
+        return;
+    }
+    dismiss(page, steps, function() {
+        var cookie = page.evaluate(function(s) {
             return document.cookie;
         });
         var resp = {
@@ -197,8 +324,8 @@ page.open(url, function(status) {
             "Body": page.content
         };
         console.log(JSON.stringify(resp));
-    }
-    phantom.exit();
+        phantom.exit();
+    });
 });
 `
 
@@ -210,8 +337,9 @@ page.open(url, function(status) {
 * system.args[3] == pageEncode
 * system.args[4] == userAgent
 * system.args[5] == postdata
+* system.args[6] == dismiss steps (json, optional)
  */
-const postJs string = `
+const postJs string = dismissJs + `
 var system = require('system');
 var page = require('webpage').create();
 var url = system.args[1];
@@ -219,6 +347,7 @@ var cookie = system.args[2];
 var pageEncode = system.args[3];
 var userAgent = system.args[4];
 var postdata = system.args[5];
+var steps = system.args[6] ? JSON.parse(system.args[6]) : null;
 page.onResourceRequested = function(requestData, request) {
     request.setHeader('Cookie', cookie)
 };
@@ -227,7 +356,10 @@ page.settings.userAgent = userAgent;
 page.open(url, 'post', postdata, function(status) {
     if (status !== 'success') {
         console.log('Unable to access network');
-    } else {
+        phantom.exit();
+        return;
+    }
+    dismiss(page, steps, function() {
         var cookie = page.evaluate(function(s) {
             return document.cookie;
         });
@@ -236,7 +368,7 @@ page.open(url, 'post', postdata, function(status) {
             "Body": page.content
         };
         console.log(JSON.stringify(resp));
-    }
-    phantom.exit();
+        phantom.exit();
+    });
 });
 `
@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package surfer
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// 使phantomjs在独立的进程组中运行，超时时结束整个进程组，不遗留其子进程
+func killGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
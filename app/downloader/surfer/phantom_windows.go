@@ -0,0 +1,8 @@
+package surfer
+
+import (
+	"os/exec"
+)
+
+// Windows下超时时仅结束phantomjs进程
+func killGroup(cmd *exec.Cmd) {}
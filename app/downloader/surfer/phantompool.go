@@ -0,0 +1,220 @@
+package surfer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 常驻复用的phantomjs进程池，各进程经标准输入逐行接收请求、经标准输出逐行返回结果，
+// 免去每个请求启动新进程的开销；进程处理maxUses个请求后，或出错、超时后即被结束并由新进程替代
+type phantomPool struct {
+	file    string              // phantomjs完整文件名
+	script  string              // 常驻进程执行的js文件
+	size    int                 // 进程数上限
+	maxUses int                 // 单个进程最多处理的请求数
+	shared  bool                // 是否在同一进程的请求间共享cookie
+	slots   chan struct{}       // 处理中的请求数，不超过size
+	idle    chan *phantomWorker // 空闲的进程
+	closed  int32               // 是否已关闭，关闭后进程处理完当前请求即结束
+}
+
+// 常驻的phantomjs进程
+type phantomWorker struct {
+	cmd    *exec.Cmd
+	cancel context.CancelFunc
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	uses   int
+	once   sync.Once
+}
+
+func newPhantomPool(file, script string, size, maxUses int, shared bool) *phantomPool {
+	return &phantomPool{
+		file:    file,
+		script:  script,
+		size:    size,
+		maxUses: maxUses,
+		shared:  shared,
+		slots:   make(chan struct{}, size),
+		idle:    make(chan *phantomWorker, size),
+	}
+}
+
+// 以空闲的进程（无空闲进程时启动新进程）处理请求，返回一行json结果；
+// task为传给get.js或post.js的参数（不含js文件名），ctx结束时结束该进程
+func (self *phantomPool) render(ctx context.Context, task []string) ([]byte, error) {
+	select {
+	case self.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-self.slots }()
+
+	var w *phantomWorker
+	select {
+	case w = <-self.idle:
+	default:
+		var err error
+		if w, err = self.start(); err != nil {
+			return nil, err
+		}
+	}
+	line, err := w.do(ctx, task)
+	if err != nil {
+		w.close()
+		return nil, err
+	}
+	if w.uses++; w.uses >= self.maxUses || atomic.LoadInt32(&self.closed) == 1 {
+		w.close()
+	} else if self.idle <- w; atomic.LoadInt32(&self.closed) == 1 {
+		self.close()
+	}
+	return line, nil
+}
+
+// 启动常驻进程
+func (self *phantomPool) start() (*phantomWorker, error) {
+	session := "fresh"
+	if self.shared {
+		session = "shared"
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := exec.CommandContext(ctx, self.file, self.script, session)
+	killGroup(cmd)
+	cmd.WaitDelay = time.Second
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		cancel()
+		return nil, err
+	}
+	return &phantomWorker{
+		cmd:    cmd,
+		cancel: cancel,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+	}, nil
+}
+
+// 结束全部空闲的进程，处理中的进程在完成当前请求后结束
+func (self *phantomPool) close() {
+	atomic.StoreInt32(&self.closed, 1)
+	for {
+		select {
+		case w := <-self.idle:
+			w.close()
+		default:
+			return
+		}
+	}
+}
+
+// 发送一个请求并读取一行结果
+func (self *phantomWorker) do(ctx context.Context, task []string) ([]byte, error) {
+	b, err := json.Marshal(task)
+	if err != nil {
+		return nil, err
+	}
+	type result struct {
+		line []byte
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		if _, err := self.stdin.Write(append(b, '\n')); err != nil {
+			ch <- result{nil, err}
+			return
+		}
+		line, err := self.stdout.ReadBytes('\n')
+		ch <- result{line, err}
+	}()
+	select {
+	case r := <-ch:
+		return r.line, r.err
+	case <-ctx.Done():
+		self.close()
+		return nil, ctx.Err()
+	}
+}
+
+// 结束进程并等待其退出
+func (self *phantomWorker) close() {
+	self.once.Do(func() {
+		self.stdin.Close()
+		self.cancel()
+		self.cmd.Wait()
+	})
+}
+
+/*
+* 常驻进程
+* system.args[0] == pool.js
+* system.args[1] == fresh（每个请求前清空cookie）或shared（共享cookie）
+* 标准输入每行为一个json数组：[url, cookie, pageEncode, userAgent(, postdata), dismiss]，含postdata时以POST方法请求，
+* dismiss为json格式的遮罩层关闭步骤，可为空字符串
+* 标准输出每行为一个json结果：{"Cookie": ..., "Body": ...}，失败时为{"Error": ...}
+ */
+const poolJs string = dismissJs + `
+var system = require('system');
+var webpage = require('webpage');
+var shared = system.args[1] === 'shared';
+function next() {
+    var line = system.stdin.readLine();
+    if (!line) {
+        phantom.exit();
+        return;
+    }
+    var task = JSON.parse(line);
+    if (!shared) {
+        phantom.clearCookies();
+    }
+    var page = webpage.create();
+    var cookie = task[1];
+    page.onResourceRequested = function(requestData, request) {
+        request.setHeader('Cookie', cookie)
+    };
+    phantom.outputEncoding = task[2];
+    page.settings.userAgent = task[3];
+    var steps = task[task.length - 1] ? JSON.parse(task[task.length - 1]) : null;
+    var reply = function(resp) {
+        system.stdout.writeLine(JSON.stringify(resp));
+        system.stdout.flush();
+        page.close();
+        setTimeout(next, 0);
+    };
+    var done = function(status) {
+        if (status !== 'success') {
+            reply({"Error": "Unable to access network"});
+            return;
+        }
+        dismiss(page, steps, function() {
+            reply({
+                "Cookie": page.evaluate(function(s) {
+                    return document.cookie;
+                }),
+                "Body": page.content
+            });
+        });
+    };
+    if (task.length > 5) {
+        page.open(task[0], 'post', task[4], done);
+    } else {
+        page.open(task[0], done);
+    }
+}
+next();
+`
@@ -0,0 +1,87 @@
+package surfer
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 以sh脚本模拟常驻的phantomjs进程：每行请求返回进程号、已处理的请求数及cookie隔离方式，请求为["slow"]时不响应
+const fakePoolSh = `n=0
+while read line; do
+  n=$((n+1))
+  [ "$line" = '["slow"]' ] && sleep 10
+  echo "{\"Pid\":$$,\"Uses\":$n,\"Session\":\"$1\"}"
+done
+`
+
+type fakePoolResult struct {
+	Pid     int
+	Uses    int
+	Session string
+}
+
+func fakePool(t *testing.T, maxUses int, shared bool) *phantomPool {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("/bin/sh not found")
+	}
+	script := filepath.Join(t.TempDir(), "pool.sh")
+	if err := os.WriteFile(script, []byte(fakePoolSh), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pool := newPhantomPool("/bin/sh", script, 1, maxUses, shared)
+	t.Cleanup(pool.close)
+	return pool
+}
+
+func fakeRender(t *testing.T, pool *phantomPool, task ...string) fakePoolResult {
+	line, err := pool.render(context.Background(), task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r fakePoolResult
+	if err = json.Unmarshal(line, &r); err != nil {
+		t.Fatalf("%v: %s", err, line)
+	}
+	return r
+}
+
+// 进程被复用至maxUses个请求后由新进程替代，cookie隔离方式作为参数传给进程
+func TestPhantomPoolReuse(t *testing.T) {
+	pool := fakePool(t, 2, true)
+	first, second, third := fakeRender(t, pool, "a"), fakeRender(t, pool, "b"), fakeRender(t, pool, "c")
+	if first.Session != "shared" {
+		t.Errorf("session = %q, want shared", first.Session)
+	}
+	if second.Pid != first.Pid || second.Uses != 2 {
+		t.Errorf("second request got %+v, want the reused process %d", second, first.Pid)
+	}
+	if third.Pid == first.Pid || third.Uses != 1 {
+		t.Errorf("third request got %+v, want a new process", third)
+	}
+	if fakeRender(t, fakePool(t, 2, false), "a").Session != "fresh" {
+		t.Error("session is not fresh")
+	}
+}
+
+// 超时的请求结束其进程，此后的请求由新进程处理
+func TestPhantomPoolTimeout(t *testing.T) {
+	pool := fakePool(t, 10, false)
+	first := fakeRender(t, pool, "a")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := pool.render(ctx, []string{"slow"}); err != context.DeadlineExceeded {
+		t.Fatalf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("render returned after %v", d)
+	}
+	if r := fakeRender(t, pool, "b"); r.Pid == first.Pid {
+		t.Errorf("got the timed-out process %d", r.Pid)
+	}
+}
@@ -25,10 +25,12 @@ type (
 	Request interface {
 		// url
 		GetUrl() string
-		// GET POST POST-M HEAD
+		// GET POST POST-M HEAD PUT DELETE PATCH 等
 		GetMethod() string
 		// POST values
 		GetPostData() string
+		// 是否以gzip压缩请求体
+		GetGzipBody() bool
 		// http header
 		GetHeader() http.Header
 		// enable http cookies
@@ -37,6 +39,10 @@ type (
 		GetDialTimeout() time.Duration
 		// WSARecv tcp: i/o timeout
 		GetConnTimeout() time.Duration
+		// 等待响应头的超时，0为采用下载器全局设置，小于0时不限
+		GetHeaderTimeout() time.Duration
+		// 读取响应内容时的空闲超时，0为采用下载器全局设置，小于0时不限
+		GetIdleTimeout() time.Duration
 		// the max times of download
 		GetTryTimes() int
 		// the pause time of retry
@@ -47,13 +53,19 @@ type (
 		GetRedirectTimes() int
 		// select Surf ro PhomtomJS
 		GetDownloaderID() int
+		// HTTP认证凭据，nil为不认证，仅Surf下载器有效
+		GetAuth() *Auth
+		// 是否按原样大小写发送请求头，仅Surf下载器有效
+		GetRawHeader() bool
+		// 是否以HTTP/1.0发送请求，仅Surf下载器有效
+		GetHTTP10() bool
 	}
 
 	// 默认实现的Request
 	DefaultRequest struct {
 		// url (必须填写)
 		Url string
-		// GET POST POST-M HEAD (默认为GET)
+		// GET POST POST-M HEAD PUT DELETE PATCH 等 (默认为GET)
 		Method string
 		// http header
 		Header http.Header
@@ -61,10 +73,16 @@ type (
 		EnableCookie bool
 		// POST values
 		PostData string
+		// 是否以gzip压缩请求体并设置Content-Encoding: gzip
+		GzipBody bool
 		// dial tcp: i/o timeout
 		DialTimeout time.Duration
 		// WSARecv tcp: i/o timeout
 		ConnTimeout time.Duration
+		// 等待响应头的超时，0为采用下载器全局设置，小于0时不限
+		HeaderTimeout time.Duration
+		// 读取响应内容时的空闲超时，0为采用下载器全局设置，小于0时不限
+		IdleTimeout time.Duration
 		// the max times of download
 		TryTimes int
 		// how long pause when retry
@@ -75,10 +93,17 @@ type (
 		RedirectTimes int
 		// the download ProxyHost
 		Proxy string
+		// HTTP认证凭据，nil为不认证
+		Auth *Auth
+		// 是否按Header中键名的原样大小写发送请求头，不做规范化
+		RawHeader bool
+		// 是否以HTTP/1.0发送请求
+		HTTP10 bool
 
 		// 指定下载器ID
 		// 0为Surf高并发下载器，各种控制功能齐全
 		// 1为PhantomJS下载器，特点破防力强，速度慢，低并发
+		// 2为WebSocket下载器，收集ws://或wss://地址推送的消息
 		DownloaderID int
 
 		// 保证prepare只调用一次
@@ -89,6 +114,7 @@ type (
 const (
 	SurfID             = 0               // Surf下载器标识符
 	PhomtomJsID        = 1               // PhomtomJs下载器标识符
+	WsID               = 2               // WebSocket下载器标识符
 	DefaultMethod      = "GET"           // 默认请求方法
 	DefaultDialTimeout = 2 * time.Minute // 默认请求服务器超时
 	DefaultConnTimeout = 2 * time.Minute // 默认下载超时
@@ -126,7 +152,7 @@ func (self *DefaultRequest) prepare() {
 		self.RetryPause = DefaultRetryPause
 	}
 
-	if self.DownloaderID != PhomtomJsID {
+	if self.DownloaderID != PhomtomJsID && self.DownloaderID != WsID {
 		self.DownloaderID = SurfID
 	}
 }
@@ -149,6 +175,12 @@ func (self *DefaultRequest) GetPostData() string {
 	return self.PostData
 }
 
+// 是否以gzip压缩请求体
+func (self *DefaultRequest) GetGzipBody() bool {
+	self.once.Do(self.prepare)
+	return self.GzipBody
+}
+
 // http header
 func (self *DefaultRequest) GetHeader() http.Header {
 	self.once.Do(self.prepare)
@@ -173,6 +205,18 @@ func (self *DefaultRequest) GetConnTimeout() time.Duration {
 	return self.ConnTimeout
 }
 
+// 等待响应头的超时
+func (self *DefaultRequest) GetHeaderTimeout() time.Duration {
+	self.once.Do(self.prepare)
+	return self.HeaderTimeout
+}
+
+// 读取响应内容时的空闲超时
+func (self *DefaultRequest) GetIdleTimeout() time.Duration {
+	self.once.Do(self.prepare)
+	return self.IdleTimeout
+}
+
 // the max times of download
 func (self *DefaultRequest) GetTryTimes() int {
 	self.once.Do(self.prepare)
@@ -202,3 +246,21 @@ func (self *DefaultRequest) GetDownloaderID() int {
 	self.once.Do(self.prepare)
 	return self.DownloaderID
 }
+
+// HTTP认证凭据
+func (self *DefaultRequest) GetAuth() *Auth {
+	self.once.Do(self.prepare)
+	return self.Auth
+}
+
+// 是否按原样大小写发送请求头
+func (self *DefaultRequest) GetRawHeader() bool {
+	self.once.Do(self.prepare)
+	return self.RawHeader
+}
+
+// 是否以HTTP/1.0发送请求
+func (self *DefaultRequest) GetHTTP10() bool {
+	self.once.Do(self.prepare)
+	return self.HTTP10
+}
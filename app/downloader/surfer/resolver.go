@@ -0,0 +1,155 @@
+// Copyright 2015 henrylee2cn Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package surfer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 根据地址创建自定义DNS解析器，用于替代系统DNS
+// addr为空时返回nil，即使用系统默认解析器；
+// addr以 https:// 开头时，使用DNS-over-HTTPS（RFC 8484），如 https://1.1.1.1/dns-query；
+// 否则视为DNS服务器地址，如 8.8.8.8:53，未指定端口时默认为53。
+func NewResolver(addr string) (*net.Resolver, error) {
+	addr = strings.TrimSpace(addr)
+	switch {
+	case addr == "":
+		return nil, nil
+
+	case strings.HasPrefix(strings.ToLower(addr), "https://"):
+		if _, err := UrlEncode(addr); err != nil {
+			return nil, err
+		}
+		client := &http.Client{Timeout: 10 * time.Second}
+		return &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return &dohConn{url: addr, client: client, ctx: ctx}, nil
+			},
+		}, nil
+
+	default:
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "53")
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, fmt.Errorf("invalid dns server address %q: %v", addr, err)
+		}
+		return &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		}, nil
+	}
+}
+
+// 基于DNS-over-HTTPS的虚拟连接，供net.Resolver使用
+// 未实现net.PacketConn，故net.Resolver始终以tcp方式（报文带2字节长度前缀）读写；
+// 每写入一条完整的DNS查询报文，即通过HTTPS POST发送，并在随后的Read中返回应答报文
+type dohConn struct {
+	url      string
+	client   *http.Client
+	ctx      context.Context
+	query    bytes.Buffer
+	response bytes.Buffer
+	deadline time.Time
+	lock     sync.Mutex
+}
+
+func (self *dohConn) Write(b []byte) (int, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.query.Write(b)
+	msg := self.query.Bytes()
+	if len(msg) < 2 || len(msg) < 2+int(binary.BigEndian.Uint16(msg)) {
+		// 报文尚未写完整
+		return len(b), nil
+	}
+	msg = msg[2 : 2+int(binary.BigEndian.Uint16(msg))]
+	answer, err := self.exchange(msg)
+	self.query.Reset()
+	if err != nil {
+		return 0, err
+	}
+	var l [2]byte
+	binary.BigEndian.PutUint16(l[:], uint16(len(answer)))
+	self.response.Write(l[:])
+	self.response.Write(answer)
+	return len(b), nil
+}
+
+func (self *dohConn) Read(b []byte) (int, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.response.Len() == 0 {
+		return 0, errors.New("doh: no pending dns response")
+	}
+	return self.response.Read(b)
+}
+
+// 发送一条DNS查询报文，返回应答报文
+func (self *dohConn) exchange(msg []byte) ([]byte, error) {
+	req, err := http.NewRequest("POST", self.url, bytes.NewReader(msg))
+	if err != nil {
+		return nil, err
+	}
+	ctx := self.ctx
+	if !self.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, self.deadline)
+		defer cancel()
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := self.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("doh: unexpected status %v", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (self *dohConn) Close() error                       { return nil }
+func (self *dohConn) LocalAddr() net.Addr                { return dohAddr(self.url) }
+func (self *dohConn) RemoteAddr() net.Addr               { return dohAddr(self.url) }
+func (self *dohConn) SetReadDeadline(t time.Time) error  { return nil }
+func (self *dohConn) SetWriteDeadline(t time.Time) error { return self.SetDeadline(t) }
+func (self *dohConn) SetDeadline(t time.Time) error {
+	self.lock.Lock()
+	self.deadline = t
+	self.lock.Unlock()
+	return nil
+}
+
+type dohAddr string
+
+func (self dohAddr) Network() string { return "doh" }
+func (self dohAddr) String() string  { return string(self) }
@@ -19,12 +19,16 @@ import (
 	"compress/gzip"
 	"compress/zlib"
 	"crypto/tls"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"math/rand"
 	"net"
 	"net/http"
 	"net/http/cookiejar"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/henrylee2cn/pholcus/app/downloader/surfer/agent"
@@ -32,24 +36,80 @@ import (
 
 // Default is the default Download implementation.
 type Surf struct {
-	cookieJar *cookiejar.Jar
+	cookieJar           *cookiejar.Jar
+	transports          map[string]*http.Transport // 按拨号超时、响应头超时、代理、协议复用的Transport
+	transportKeys       []string                   // 已缓存的Transport，按最近使用的先后排列，最近使用的在末尾
+	maxIdleConns        int                        // 空闲连接的最大总数，0为不限
+	maxIdleConnsPerHost int                        // 每个主机的最大空闲连接数，0为采用http.DefaultMaxIdleConnsPerHost
+	idleConnTimeout     time.Duration              // 空闲连接的超时时长，0为不限
+	maxConnsPerHost     int                        // 同时连接每个主机的最大连接数，0为不限
+	headerTimeout       time.Duration              // 等待响应头的默认超时，0为不限
+	idleTimeout         time.Duration              // 读取响应内容时的默认空闲超时，0为不限
+	resolver            *net.Resolver              // 自定义DNS解析器，nil为系统默认
+	dnsCache            *DNSCache                  // DNS缓存，nil为不缓存
+	dnsCacheTTL         time.Duration              // DNS缓存的时长
+	dnsCacheSize        int                        // DNS缓存的容量
+	fingerprint         string                     // https请求模拟的浏览器TLS指纹，为空时使用Go默认的TLS握手
+	lock                sync.Mutex
 }
 
 func New() Surfer {
 	s := new(Surf)
 	s.cookieJar, _ = cookiejar.New(nil)
+	s.transports = make(map[string]*http.Transport)
 	return s
 }
 
+// 配置连接池的空闲连接（keep-alive）参数，配置变化时关闭已有的空闲连接
+func (self *Surf) SetIdleConns(maxIdleConns, maxIdleConnsPerHost int, idleConnTimeout time.Duration) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.maxIdleConns == maxIdleConns &&
+		self.maxIdleConnsPerHost == maxIdleConnsPerHost &&
+		self.idleConnTimeout == idleConnTimeout {
+		return
+	}
+	self.maxIdleConns = maxIdleConns
+	self.maxIdleConnsPerHost = maxIdleConnsPerHost
+	self.idleConnTimeout = idleConnTimeout
+	self.resetTransports()
+}
+
+// 设置同时连接每个主机的最大连接数，0为不限，配置变化时关闭已有的空闲连接
+func (self *Surf) SetMaxConnsPerHost(n int) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.maxConnsPerHost == n {
+		return
+	}
+	self.maxConnsPerHost = n
+	self.resetTransports()
+}
+
+// 设置等待响应头的默认超时及读取响应内容时的默认空闲超时，0为不限，可被请求中的设置覆盖
+func (self *Surf) SetReadTimeouts(headerTimeout, idleTimeout time.Duration) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.headerTimeout = headerTimeout
+	self.idleTimeout = idleTimeout
+}
+
 func (self *Surf) Download(req Request) (resp *http.Response, err error) {
 	param, err := NewParam(req)
 	if err != nil {
 		return nil, err
 	}
+	self.applyReadTimeouts(param)
 	param.client = self.buildClient(param)
 	resp, err = self.httpRequest(param)
 
-	if err == nil {
+	// 读取响应内容时长时间无数据到达则中止
+	if err == nil && param.idleTimeout > 0 {
+		resp.Body = newIdleReader(resp.Body, param.idleTimeout)
+	}
+
+	// HEAD请求的响应没有body，无需解压
+	if err == nil && param.method != "HEAD" {
 		switch resp.Header.Get("Content-Encoding") {
 		case "gzip":
 			var gzipReader *gzip.Reader
@@ -75,39 +135,211 @@ func (self *Surf) Download(req Request) (resp *http.Response, err error) {
 	return
 }
 
+// 请求未设置读取超时时采用默认值，小于0时不限
+func (self *Surf) applyReadTimeouts(param *Param) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	switch {
+	case param.headerTimeout == 0:
+		param.headerTimeout = self.headerTimeout
+	case param.headerTimeout < 0:
+		param.headerTimeout = 0
+	}
+	switch {
+	case param.idleTimeout == 0:
+		param.idleTimeout = self.idleTimeout
+	case param.idleTimeout < 0:
+		param.idleTimeout = 0
+	}
+}
+
+// 预先建立到请求所在主机的连接（DNS解析、TCP连接及TLS握手），
+// 使用与下载相同的Transport，连接放回连接池后供后续请求复用
+func (self *Surf) Warmup(req Request) error {
+	u, err := UrlEncode(req.GetUrl())
+	if err != nil {
+		return err
+	}
+	param := &Param{
+		url:         &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"},
+		dialTimeout: req.GetDialTimeout(),
+		connTimeout: req.GetConnTimeout(),
+	}
+	if req.GetProxy() != "" {
+		if param.proxy, err = url.Parse(req.GetProxy()); err != nil {
+			return err
+		}
+	}
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Timeout:   param.connTimeout,
+		Transport: self.getTransport(param),
+	}
+	hreq, err := http.NewRequest("HEAD", param.url.String(), nil)
+	if err != nil {
+		return err
+	}
+	hreq.Header.Set("User-Agent", agent.UserAgents["common"][0])
+	resp, err := client.Do(hreq)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
 // buildClient creates, configures, and returns a *http.Client type.
 func (self *Surf) buildClient(param *Param) *http.Client {
 	client := &http.Client{
 		CheckRedirect: param.checkRedirect,
+		// 连接被复用，故下载超时作用于单次请求，而非连接
+		Timeout: param.connTimeout,
+	}
+	if param.rawHeader || param.http10 {
+		client.Transport = self.getWireTransport(param)
+	} else {
+		client.Transport = self.getTransport(param)
 	}
 
 	if param.enableCookie {
-		client.Jar = self.cookieJar
+		if len(param.cookies) > 0 {
+			// 请求级cookie叠加并覆盖共享cookie，且不写入共享cookiejar
+			client.Jar = newOverlayJar(self.cookieJar, param.url, param.cookies)
+		} else {
+			client.Jar = self.cookieJar
+		}
+	}
+	return client
+}
+
+// 设置所有请求使用的DNS解析器，nil为系统默认，可由NewResolver()创建
+func (self *Surf) SetResolver(resolver *net.Resolver) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.resolver == resolver {
+		return
+	}
+	self.resolver = resolver
+	if self.dnsCache != nil {
+		self.dnsCache = NewDNSCache(self.dnsCacheTTL, self.dnsCacheSize, resolver)
+	}
+	self.resetTransports()
+}
+
+// 设置DNS缓存，ttl为解析结果的缓存时长，不大于0时不缓存，size为最多缓存的主机数，不大于0时为DefaultDNSCacheSize；
+// 配置变化时清空已有的缓存
+func (self *Surf) SetDNSCache(ttl time.Duration, size int) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if ttl <= 0 {
+		ttl, size = 0, 0
+	}
+	if self.dnsCacheTTL == ttl && self.dnsCacheSize == size {
+		return
+	}
+	self.dnsCacheTTL, self.dnsCacheSize = ttl, size
+	if ttl > 0 {
+		self.dnsCache = NewDNSCache(ttl, size, self.resolver)
+	} else {
+		self.dnsCache = nil
+	}
+	self.resetTransports()
+}
+
+// 获取可复用的Transport，不存在时创建
+func (self *Surf) getTransport(param *Param) *http.Transport {
+	var (
+		isHttps = strings.ToLower(param.url.Scheme) == "https"
+		key     = fmt.Sprintf("%v|%v|%v|%v", param.dialTimeout, param.headerTimeout, param.proxy, isHttps)
+	)
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if transport, ok := self.transports[key]; ok {
+		self.touchTransport(key)
+		return transport
 	}
 
+	dialer := &cacheDialer{
+		Dialer: &net.Dialer{Timeout: param.dialTimeout, Resolver: self.resolver},
+		cache:  self.dnsCache,
+	}
 	transport := &http.Transport{
-		Dial: func(network, addr string) (net.Conn, error) {
-			c, err := net.DialTimeout(network, addr, param.dialTimeout)
-			if err != nil {
-				return nil, err
-			}
-			if param.connTimeout > 0 {
-				c.SetDeadline(time.Now().Add(param.connTimeout))
-			}
-			return c, nil
-		},
+		DialContext:         dialer.DialContext,
+		MaxIdleConns:        self.maxIdleConns,
+		MaxIdleConnsPerHost: self.maxIdleConnsPerHost,
+		IdleConnTimeout:     self.idleConnTimeout,
+		MaxConnsPerHost:     self.maxConnsPerHost,
+		// 连接建立后迟迟不返回响应头时中止
+		ResponseHeaderTimeout: param.headerTimeout,
 	}
 
 	if param.proxy != nil {
 		transport.Proxy = http.ProxyURL(param.proxy)
 	}
 
-	if strings.ToLower(param.url.Scheme) == "https" {
+	if isHttps {
 		transport.TLSClientConfig = &tls.Config{RootCAs: nil, InsecureSkipVerify: true}
 		transport.DisableCompression = true
+		if id, ok := TLSFingerprints[self.fingerprint]; ok {
+			// 模拟浏览器的TLS握手，经由代理时改在拨号函数中建立隧道
+			transport.DialTLSContext = fingerprintDialer(id, dialer, param.proxy)
+			transport.Proxy = nil
+		}
 	}
-	client.Transport = transport
-	return client
+	self.transports[key] = transport
+	self.transportKeys = append(self.transportKeys, key)
+	// 代理轮换时组合众多，超出上限后关闭最久未使用的Transport
+	if len(self.transportKeys) > maxTransports {
+		oldest := self.transportKeys[0]
+		self.transportKeys = self.transportKeys[1:]
+		self.transports[oldest].CloseIdleConnections()
+		delete(self.transports, oldest)
+	}
+	return transport
+}
+
+// 缓存Transport的上限
+const maxTransports = 64
+
+// 将Transport标记为最近使用，需在加锁状态下调用
+func (self *Surf) touchTransport(key string) {
+	for i, k := range self.transportKeys {
+		if k == key {
+			self.transportKeys = append(append(self.transportKeys[:i:i], self.transportKeys[i+1:]...), key)
+			return
+		}
+	}
+}
+
+// 创建自行写出请求的Transport，用于按原样大小写发送请求头或以HTTP/1.0发送请求，不复用连接
+func (self *Surf) getWireTransport(param *Param) *wireTransport {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	dialer := &cacheDialer{
+		Dialer: &net.Dialer{Timeout: param.dialTimeout, Resolver: self.resolver},
+		cache:  self.dnsCache,
+	}
+	transport := &wireTransport{
+		dialer:        dialer,
+		proxy:         param.proxy,
+		http10:        param.http10,
+		headerTimeout: param.headerTimeout,
+	}
+	if id, ok := TLSFingerprints[self.fingerprint]; ok {
+		transport.dialTLS = fingerprintDialer(id, dialer, param.proxy)
+	}
+	return transport
+}
+
+// 关闭并清空已有的Transport，需在加锁状态下调用
+func (self *Surf) resetTransports() {
+	for key, transport := range self.transports {
+		transport.CloseIdleConnections()
+		delete(self.transports, key)
+	}
+	self.transportKeys = nil
 }
 
 // send uses the given *http.Request to make an HTTP request.
@@ -118,6 +350,18 @@ func (self *Surf) httpRequest(param *Param) (resp *http.Response, err error) {
 	}
 
 	req.Header = param.header
+	if len(param.cookies) > 0 {
+		// 请求级cookie改由cookiejar合并发送，避免与共享cookie重复
+		req.Header = make(http.Header, len(param.header))
+		for k, v := range param.header {
+			if k != "Cookie" {
+				req.Header[k] = v
+			}
+		}
+	}
+	if param.auth != nil {
+		param.auth.authorize(req)
+	}
 
 	if param.tryTimes <= 0 {
 		for {
@@ -149,5 +393,10 @@ func (self *Surf) httpRequest(param *Param) (resp *http.Response, err error) {
 		}
 	}
 
+	// Digest认证的质询应答
+	if err == nil && param.auth != nil {
+		resp, err = param.auth.digest(param.client, req, resp)
+	}
+
 	return resp, err
 }
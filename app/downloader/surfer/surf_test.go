@@ -0,0 +1,39 @@
+package surfer
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// 经众多代理下载时，缓存的Transport不超过上限，且保留最近使用的
+func TestTransportCacheBounded(t *testing.T) {
+	s := New().(*Surf)
+	target, _ := url.Parse("http://example.com/")
+	param := func(i int) *Param {
+		proxy, _ := url.Parse(fmt.Sprintf("http://127.0.0.1:%d", 10000+i))
+		return &Param{url: target, proxy: proxy}
+	}
+
+	p0 := param(0)
+	first := s.getTransport(p0)
+	for i := 1; i < maxTransports*2; i++ {
+		s.getTransport(param(i))
+		// 持续使用第一个，使其不被淘汰
+		if s.getTransport(param(0)) != first {
+			t.Fatalf("recently used transport evicted after %d proxies", i)
+		}
+	}
+	if n := len(s.transports); n != maxTransports || len(s.transportKeys) != n {
+		t.Fatalf("cached %d transports (%d keys), want %d", n, len(s.transportKeys), maxTransports)
+	}
+	if last := s.transportKeys[len(s.transportKeys)-1]; !strings.Contains(last, p0.proxy.Host+"|") {
+		t.Errorf("most recently used key = %q, want the first proxy", last)
+	}
+	for _, key := range s.transportKeys {
+		if strings.Contains(key, ":10001|") {
+			t.Error("least recently used transport was not evicted")
+		}
+	}
+}
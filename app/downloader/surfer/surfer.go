@@ -26,18 +26,31 @@ var (
 	phantom       Surfer
 	once_surf     sync.Once
 	once_phantom  sync.Once
+	once_ws       sync.Once
+	websocket     Surfer
 	tempJsDir     = "./tmp"
 	phantomjsFile = os.Getenv("GOPATH") + `\src\github.com\henrylee2cn\surfer\phantomjs\phantomjs`
+
+	// 未指定User-Agent且未启用cookie的请求是否随机轮换User-Agent，为false时始终使用同一User-Agent
+	RotateUserAgent = true
 )
 
 func Download(req Request) (resp *http.Response, err error) {
-	switch req.GetDownloaderID() {
+	id := req.GetDownloaderID()
+	// HEAD请求没有可渲染的网页，以Surf下载器发送
+	if id == PhomtomJsID && req.GetMethod() == "HEAD" {
+		id = SurfID
+	}
+	switch id {
 	case SurfID:
 		once_surf.Do(func() { surf = New() })
 		resp, err = surf.Download(req)
 	case PhomtomJsID:
 		once_phantom.Do(func() { phantom = NewPhantom(phantomjsFile, tempJsDir) })
 		resp, err = phantom.Download(req)
+	case WsID:
+		once_ws.Do(func() { websocket = NewWebSocket() })
+		resp, err = websocket.Download(req)
 	}
 	return
 }
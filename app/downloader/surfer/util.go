@@ -54,6 +54,22 @@ func UrlEncode(urlStr string) (*url.URL, error) {
 	return urlObj, err
 }
 
+// 检查请求方法是否为合法的HTTP token（RFC 7230），如 GET、PUT、PATCH 等
+func ValidMethod(method string) bool {
+	if method == "" {
+		return false
+	}
+	for _, c := range method {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 // The GetWDPath gets the work directory path.
 func GetWDPath() string {
 	wd := os.Getenv("GOPATH")
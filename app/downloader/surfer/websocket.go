@@ -0,0 +1,255 @@
+// Copyright 2015 henrylee2cn Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package surfer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	ws "github.com/henrylee2cn/pholcus/common/websocket"
+)
+
+type (
+	// WebSocket下载器的请求，未实现时仅以下载超时作为收集消息的结束条件
+	WsRequest interface {
+		Request
+		// 连接后发送的订阅消息，为空时不发送
+		GetWsMessage() string
+		// 收集到该数量的消息后结束，0为不限
+		GetWsMaxMessages() int
+		// 收到匹配该正则表达式的消息后结束，为空时不限
+		GetWsTerminator() string
+	}
+
+	// WebSocket下载器，连接ws://或wss://地址并发送订阅消息，
+	// 收集消息直至达到消息数量、收到结束消息、下载超时(ConnTimeout)或服务端关闭连接，
+	// 以换行符连接各条消息作为响应内容返回
+	WebSocket struct{}
+)
+
+func NewWebSocket() Surfer {
+	return new(WebSocket)
+}
+
+func (self *WebSocket) Download(req Request) (resp *http.Response, err error) {
+	param, err := NewParam(req)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		message    string
+		maxCount   int
+		terminator *regexp.Regexp
+	)
+	if wsReq, ok := req.(WsRequest); ok {
+		message = wsReq.GetWsMessage()
+		maxCount = wsReq.GetWsMaxMessages()
+		if pattern := wsReq.GetWsTerminator(); pattern != "" {
+			if terminator, err = regexp.Compile(pattern); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var conn *ws.Conn
+	tryTimes := param.tryTimes
+	if tryTimes <= 0 {
+		tryTimes = 1
+	}
+	for i := 0; i < tryTimes; i++ {
+		if conn, err = self.dial(param); err == nil {
+			break
+		}
+		time.Sleep(param.retryPause)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if param.connTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(param.connTimeout))
+	}
+	if message != "" {
+		if _, err = ws.Message.Send(conn, message); err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		body  bytes.Buffer
+		count int
+	)
+	for maxCount <= 0 || count < maxCount {
+		var frame string
+		if err = ws.Message.Receive(conn, &frame); err != nil {
+			// 超时或服务端关闭连接时正常结束
+			if netErr, ok := err.(net.Error); (ok && netErr.Timeout()) || err == io.EOF {
+				err = nil
+			}
+			break
+		}
+		if count > 0 {
+			body.WriteByte('\n')
+		}
+		body.WriteString(frame)
+		count++
+		if terminator != nil && terminator.MatchString(frame) {
+			break
+		}
+	}
+	if err != nil && count == 0 {
+		return nil, err
+	}
+	err = nil
+
+	resp = &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+		Body:          ioutil.NopCloser(&body),
+		ContentLength: int64(body.Len()),
+		Request:       &http.Request{URL: param.url},
+	}
+	return param.writeback(resp), nil
+}
+
+// 建立连接并完成WebSocket握手，设置了代理时通过HTTP CONNECT隧道连接
+func (self *WebSocket) dial(param *Param) (*ws.Conn, error) {
+	var (
+		location = param.url
+		secure   bool
+	)
+	switch strings.ToLower(location.Scheme) {
+	case "ws", "http":
+	case "wss", "https":
+		secure = true
+	default:
+		return nil, fmt.Errorf("unsupported websocket scheme %q", location.Scheme)
+	}
+	addr := location.Host
+	if location.Port() == "" {
+		if secure {
+			addr = net.JoinHostPort(location.Hostname(), "443")
+		} else {
+			addr = net.JoinHostPort(location.Hostname(), "80")
+		}
+	}
+
+	header := make(http.Header, len(param.header))
+	for k, v := range param.header {
+		header[k] = v
+	}
+	origin := header.Get("Origin")
+	header.Del("Origin")
+	if origin == "" {
+		if secure {
+			origin = "https://" + location.Host
+		} else {
+			origin = "http://" + location.Host
+		}
+	}
+	config, err := ws.NewConfig(location.String(), origin)
+	if err != nil {
+		return nil, err
+	}
+	config.Header = header
+
+	dialer := &net.Dialer{Timeout: param.dialTimeout}
+	var conn net.Conn
+	if param.proxy != nil {
+		conn, err = dialTunnel(dialer, param.proxy, addr)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if param.dialTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(param.dialTimeout))
+	}
+	if secure {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: location.Hostname(), InsecureSkipVerify: true})
+		if err = tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	wsConn, err := ws.NewClient(config, conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return wsConn, nil
+}
+
+// 通过HTTP代理的CONNECT方法建立到addr的隧道
+func dialTunnel(dialer *net.Dialer, proxy *url.URL, addr string) (net.Conn, error) {
+	switch strings.ToLower(proxy.Scheme) {
+	case "http", "https", "":
+	default:
+		return nil, fmt.Errorf("unsupported websocket proxy scheme %q", proxy.Scheme)
+	}
+	conn, err := dialer.Dial("tcp", proxy.Host)
+	if err != nil {
+		return nil, err
+	}
+	connectReq := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if proxy.User != nil {
+		connectReq.SetBasicAuth(proxy.User.Username(), passwordOf(proxy.User))
+		connectReq.Header.Set("Proxy-Authorization", connectReq.Header.Get("Authorization"))
+		connectReq.Header.Del("Authorization")
+	}
+	if err = connectReq.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), connectReq)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT %v: %v", addr, resp.Status)
+	}
+	return conn, nil
+}
+
+func passwordOf(user *url.Userinfo) string {
+	password, _ := user.Password()
+	return password
+}
@@ -0,0 +1,89 @@
+package surfer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	ws "github.com/henrylee2cn/pholcus/common/websocket"
+)
+
+type testWsRequest struct {
+	DefaultRequest
+	message, terminator string
+	max                 int
+}
+
+func (self *testWsRequest) GetWsMessage() string    { return self.message }
+func (self *testWsRequest) GetWsMaxMessages() int   { return self.max }
+func (self *testWsRequest) GetWsTerminator() string { return self.terminator }
+
+// 模拟的WebSocket服务端：收到订阅消息后依次推送回执及messages，hold为true时推送完毕仍保持连接
+func wsServer(t *testing.T, hold bool, messages ...string) string {
+	srv := httptest.NewServer(ws.Handler(func(conn *ws.Conn) {
+		var sub string
+		if err := ws.Message.Receive(conn, &sub); err != nil {
+			return
+		}
+		ws.Message.Send(conn, "ack:"+sub+":"+conn.Request().Header.Get("X-Token"))
+		for _, m := range messages {
+			ws.Message.Send(conn, m)
+		}
+		if hold {
+			time.Sleep(time.Second)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func wsGet(t *testing.T, req *testWsRequest) string {
+	req.Method = "GET"
+	req.TryTimes = 1
+	if req.ConnTimeout == 0 {
+		req.ConnTimeout = 5 * time.Second
+	}
+	resp, err := NewWebSocket().Download(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	return string(body)
+}
+
+// 发送订阅消息后收集推送的消息，按消息数量、结束消息、服务端关闭连接或超时结束
+func TestWebSocketDownload(t *testing.T) {
+	addr := wsServer(t, false, "1", "2", "end", "3")
+	header := http.Header{"X-Token": {"t"}}
+	for _, c := range []struct {
+		name string
+		req  *testWsRequest
+		want string
+	}{
+		{"max messages", &testWsRequest{message: "sub", max: 2}, "ack:sub:t\n1"},
+		{"terminator", &testWsRequest{message: "sub", terminator: `^end$`}, "ack:sub:t\n1\n2\nend"},
+		{"closed by server", &testWsRequest{message: "sub"}, "ack:sub:t\n1\n2\nend\n3"},
+	} {
+		c.req.Url, c.req.Header = addr, header
+		if got := wsGet(t, c.req); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+
+	req := &testWsRequest{message: "sub"}
+	req.Url, req.Header, req.ConnTimeout = wsServer(t, true, "1"), header, 200*time.Millisecond
+	start := time.Now()
+	if got := wsGet(t, req); got != "ack:sub:t\n1" {
+		t.Errorf("timeout: got %q", got)
+	}
+	if d := time.Since(start); d > 900*time.Millisecond {
+		t.Errorf("timeout: returned after %v", d)
+	}
+}
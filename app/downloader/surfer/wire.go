@@ -0,0 +1,232 @@
+// Copyright 2015 henrylee2cn Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package surfer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+type (
+	// 自行写出请求的Transport：请求头键名按原样大小写发送，可选以HTTP/1.0发送；
+	// 每个请求新建连接，读取完响应后即关闭，不复用
+	wireTransport struct {
+		dialer        *cacheDialer
+		proxy         *url.URL
+		http10        bool
+		headerTimeout time.Duration // 等待响应头的超时，0为不限
+		// 建立TLS连接的拨号函数，nil时使用Go默认的TLS握手
+		dialTLS func(ctx context.Context, network, addr string) (net.Conn, error)
+	}
+	// 关闭时一并关闭连接的响应内容
+	wireBody struct {
+		io.ReadCloser
+		conn net.Conn
+		stop func() bool
+	}
+)
+
+// 替换请求头值中的换行，防止注入额外的请求头
+var headerValueReplacer = strings.NewReplacer("\r", " ", "\n", " ")
+
+func (self *wireTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var (
+		ctx     = req.Context()
+		isHttps = strings.ToLower(req.URL.Scheme) == "https"
+		addr    = canonicalAddr(req.URL)
+	)
+	conn, viaProxy, err := self.dial(ctx, addr, isHttps)
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	// 请求被取消或超时时关闭连接，以中止阻塞中的读写
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+
+	b, err := self.encode(req, viaProxy)
+	if err == nil {
+		_, err = conn.Write(b)
+	}
+	if err != nil {
+		stop()
+		conn.Close()
+		return nil, err
+	}
+
+	if self.headerTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(self.headerTimeout))
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		stop()
+		conn.Close()
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, err
+	}
+	conn.SetReadDeadline(time.Time{})
+	resp.Body = &wireBody{resp.Body, conn, stop}
+	return resp, nil
+}
+
+// 连接目标主机，返回连接及是否需以绝对URI经由HTTP代理转发
+func (self *wireTransport) dial(ctx context.Context, addr string, isHttps bool) (conn net.Conn, viaProxy bool, err error) {
+	switch {
+	case isHttps && self.dialTLS != nil:
+		conn, err = self.dialTLS(ctx, "tcp", addr)
+		return
+
+	case isHttps && self.proxy != nil:
+		conn, err = dialTunnel(self.dialer.Dialer, self.proxy, addr)
+
+	case self.proxy != nil:
+		conn, err = self.dialer.DialContext(ctx, "tcp", self.proxy.Host)
+		return conn, true, err
+
+	default:
+		conn, err = self.dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil || !isHttps {
+		return
+	}
+
+	host, _, _ := net.SplitHostPort(addr)
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: host, InsecureSkipVerify: true})
+	if self.dialer.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(self.dialer.Timeout))
+	}
+	if err = tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+	conn.SetDeadline(time.Time{})
+	return tlsConn, false, nil
+}
+
+// 按原样大小写写出请求行、请求头及请求体；
+// 同一请求头同时存在原样与规范化的键名时（如默认添加的User-Agent），仅发送原样的键名
+func (self *wireTransport) encode(req *http.Request, viaProxy bool) ([]byte, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		buf   bytes.Buffer
+		proto = "HTTP/1.1"
+		uri   = req.URL.RequestURI()
+		keys  = make([]string, 0, len(req.Header))
+		raw   = make(map[string]bool, len(req.Header))
+	)
+	if self.http10 {
+		proto = "HTTP/1.0"
+	}
+	if viaProxy {
+		uri = req.URL.String()
+	}
+	fmt.Fprintf(&buf, "%s %s %s\r\n", req.Method, uri, proto)
+
+	for key := range req.Header {
+		if canonical := http.CanonicalHeaderKey(key); canonical != key {
+			raw[canonical] = true
+		}
+	}
+	for key := range req.Header {
+		canonical := http.CanonicalHeaderKey(key)
+		if canonical == key && raw[key] {
+			continue
+		}
+		switch canonical {
+		case "Content-Length", "Connection", "Transfer-Encoding":
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if !hasHeader(req.Header, "Host") {
+		host := req.Host
+		if host == "" {
+			host = req.URL.Host
+		}
+		fmt.Fprintf(&buf, "Host: %s\r\n", host)
+	}
+	for _, key := range keys {
+		for _, value := range req.Header[key] {
+			fmt.Fprintf(&buf, "%s: %s\r\n", key, headerValueReplacer.Replace(value))
+		}
+	}
+	if viaProxy && self.proxy.User != nil && !hasHeader(req.Header, "Proxy-Authorization") {
+		auth := base64.StdEncoding.EncodeToString([]byte(self.proxy.User.Username() + ":" + passwordOf(self.proxy.User)))
+		fmt.Fprintf(&buf, "Proxy-Authorization: Basic %s\r\n", auth)
+	}
+	if body != nil || req.Method == "POST" || req.Method == "PUT" || req.Method == "PATCH" {
+		fmt.Fprintf(&buf, "Content-Length: %d\r\n", len(body))
+	}
+	if !self.http10 {
+		buf.WriteString("Connection: close\r\n")
+	}
+	buf.WriteString("\r\n")
+	buf.Write(body)
+	return buf.Bytes(), nil
+}
+
+func (self *wireBody) Close() error {
+	err := self.ReadCloser.Close()
+	self.stop()
+	self.conn.Close()
+	return err
+}
+
+// 不区分大小写地判断请求头是否存在
+func hasHeader(header http.Header, key string) bool {
+	for k := range header {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// 返回URL的主机及端口，未指定端口时按协议补全
+func canonicalAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if strings.ToLower(u.Scheme) == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
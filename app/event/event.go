@@ -0,0 +1,115 @@
+// 抓取事件的实时发布，开启后将页面成败、结果收集、蜘蛛启停等事件以JSON格式发布至NATS，
+// 供其他服务无需轮询即可响应进行中的抓取
+package event
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/henrylee2cn/pholcus/common/nats"
+	"github.com/henrylee2cn/pholcus/logs"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+// 事件类型，发布的主题为 cache.Task.NatsSubject + "." + 事件类型
+const (
+	CRAWL_START  = "crawl.start"  // 蜘蛛开始执行
+	CRAWL_STOP   = "crawl.stop"   // 蜘蛛执行结束，含主动终止
+	PAGE_SUCCESS = "page.success" // 页面处理成功
+	PAGE_FAIL    = "page.fail"    // 页面下载、校验或解析失败，每次失败各发布一次
+	ITEM         = "item"         // 收集到一条结果
+)
+
+const (
+	// 待发布事件的缓冲数量，超出时丢弃新事件，以免阻塞抓取
+	bufferSize = 4096
+	// 连接NATS服务器的超时
+	dialTimeout = 5 * time.Second
+	// 连接失败后再次连接前的等待时长，期间的事件被丢弃
+	redialPause = 10 * time.Second
+)
+
+// 抓取事件
+type Event struct {
+	Type   string                 `json:"type"`
+	Time   time.Time              `json:"time"`
+	Spider string                 `json:"spider,omitempty"`
+	Rule   string                 `json:"rule,omitempty"`
+	Url    string                 `json:"url,omitempty"`
+	Method string                 `json:"method,omitempty"`
+	Class  string                 `json:"class,omitempty"` // 失败类型，见request.ErrorClass
+	Error  string                 `json:"error,omitempty"`
+	Item   map[string]interface{} `json:"item,omitempty"` // ITEM事件的结果数据
+}
+
+type message struct {
+	subject string
+	data    []byte
+}
+
+var (
+	queue chan message
+	once  sync.Once
+)
+
+// 是否开启了事件发布
+func Enabled() bool {
+	return cache.Task.NatsUrl != ""
+}
+
+// 发布事件，未开启时忽略；e在返回前即已序列化，调用方随后可复用其中的数据
+func Emit(e Event) {
+	if !Enabled() {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		logs.Log.Warning(" *     [event] 事件 %v 序列化失败: %v\n", e.Type, err)
+		return
+	}
+	once.Do(func() {
+		queue = make(chan message, bufferSize)
+		go publish()
+	})
+	select {
+	case queue <- message{cache.Task.NatsSubject + "." + e.Type, data}:
+	default:
+		logs.Log.Warning(" *     [event] 待发布事件过多，丢弃 %v 事件\n", e.Type)
+	}
+}
+
+// 依次发布缓冲的事件，按需连接NATS服务器，地址变更或连接失效时重新连接
+func publish() {
+	var (
+		conn     *nats.Conn
+		url      string
+		failedAt time.Time
+	)
+	for msg := range queue {
+		if u := cache.Task.NatsUrl; conn == nil || u != url {
+			if conn != nil {
+				conn.Close()
+				conn = nil
+			}
+			if u == "" || u == url && time.Since(failedAt) < redialPause {
+				continue
+			}
+			var err error
+			if conn, err = nats.Dial(u, dialTimeout); err != nil {
+				url, failedAt = u, time.Now()
+				logs.Log.Error(" *     [event] 连接NATS服务器 %v 失败: %v\n", u, err)
+				continue
+			}
+			url = u
+		}
+		if err := conn.Publish(msg.subject, msg.data); err != nil {
+			logs.Log.Error(" *     [event] 发布 %v 失败: %v\n", msg.subject, err)
+			conn.Close()
+			conn, failedAt = nil, time.Now()
+		}
+	}
+}
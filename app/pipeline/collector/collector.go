@@ -3,11 +3,15 @@ package collector
 
 import (
 	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/henrylee2cn/pholcus/app/event"
 	"github.com/henrylee2cn/pholcus/app/pipeline/collector/data"
 	"github.com/henrylee2cn/pholcus/app/spider"
 	"github.com/henrylee2cn/pholcus/config"
+	"github.com/henrylee2cn/pholcus/logs"
 	"github.com/henrylee2cn/pholcus/runtime/cache"
 )
 
@@ -18,16 +22,23 @@ type Collector struct {
 	DataChan       chan data.DataCell //文本数据收集通道
 	FileChan       chan data.FileCell //文件收集通道
 	ctrl           chan bool          //长度为零时退出并输出
-	outType        string             //输出方式
+	outTypes       []string           //输出方式，可同时输出至多处
+	stream         chan data.DataCell //文本数据的实时推送通道，未调用Stream()时为nil
+	streamOnly     bool               //是否仅推送而不输出
+	manifest       *manifest          //结果清单文件，未开启run::manifest时为nil
 	timing         time.Time          //上次输出完成的时间点
-	outCount       [4]uint            //[文本输出开始，文本输出结束，文件输出开始，文件输出结束]
-	sum            [4]uint64          //收集的数据总数[上次输出后文本总数，本次输出后文本总数，上次输出后文件总数，本次输出后文件总数]，非并发安全
+	outCount       [4]uint32          //[文本输出开始，文本输出结束，文件输出开始，文件输出结束]
+	sum            [4]uint64          //收集的数据总数[上次输出后文本总数，本次输出后文本总数，上次输出后文件总数，本次输出后文件总数]，其中文件总数非并发安全
+	sumLock        sync.Mutex         //文本总数的锁
+	dataRanges     map[int][2]uint64  //[缓存块下标]输出中的各批文本数据的总数区间，用于生成输出文件名，由sumLock保护
+	dropped        uint64             //收集通道已满而丢弃的文本数据数，原子操作
+	memory         MemoryBuffer       //内存输出的缓冲区，输出方式不含memory时为nil
 	// size     [2]uint64 //数据总输出流量统计[文本，文件]，文本暂时未统计
 }
 
 func NewCollector() *Collector {
 	self := &Collector{
-		DataChan:    make(chan data.DataCell, config.DATA_CHAN_CAP),
+		DataChan:    make(chan data.DataCell, dataChanCap()),
 		FileChan:    make(chan data.FileCell, 512),
 		DockerQueue: NewDockerQueue(),
 		ctrl:        make(chan bool, 1),
@@ -37,21 +48,103 @@ func NewCollector() *Collector {
 
 func (self *Collector) Init(sp *spider.Spider) {
 	self.Spider = sp
-	self.outType = cache.Task.OutType
-	self.DataChan = make(chan data.DataCell, config.DATA_CHAN_CAP)
+	self.outTypes = cache.OutTypes()
+	self.DataChan = make(chan data.DataCell, dataChanCap())
 	self.FileChan = make(chan data.FileCell, 512)
 	self.DockerQueue = NewDockerQueue()
 	self.ctrl = make(chan bool, 1)
+	self.manifest = nil
 	self.sum = [4]uint64{}
+	self.dataRanges = make(map[int][2]uint64)
+	self.dropped = 0
 	// self.size = [2]uint64{}
-	self.outCount = [4]uint{}
+	self.outCount = [4]uint32{}
 	self.timing = cache.StartTime
 }
 
 func (self *Collector) CollectData(dataCell data.DataCell) {
+	// 取出来源请求，不随结果输出
+	src, _ := dataCell[data.SOURCE].(*data.Source)
+	delete(dataCell, data.SOURCE)
+	// 幂等键随结果留待输出成功后记录，结果被丢弃时移除其标记
+	key, _ := dataCell[data.ITEM_KEY].(string)
+	// 依次执行转换函数
+	for _, transform := range DataTransforms {
+		if dataCell = transform(dataCell); dataCell == nil {
+			self.Spider.ForgetItem(key)
+			return
+		}
+	}
+	// 收集通道已满且设置为丢弃时，不计入采集上限
+	if self.dropFull(dataCell) {
+		self.Spider.ForgetItem(key)
+		return
+	}
+	// 超出结果数据单元采集上限时丢弃
+	ruleName, _ := dataCell["RuleName"].(string)
+	if !self.Spider.AddItemCount(ruleName) {
+		self.Spider.ForgetItem(key)
+		return
+	}
+	if self.manifest != nil && src != nil {
+		self.manifest.add(ruleName, dataCell["Data"], src)
+	}
+	url, _ := dataCell["Url"].(string)
+	event.Emit(event.Event{
+		Type:   event.ITEM,
+		Spider: self.Spider.GetName(),
+		Rule:   ruleName,
+		Url:    url,
+		Item:   dataCell,
+	})
+	if self.stream != nil {
+		if self.streamOnly {
+			delete(dataCell, data.ITEM_KEY)
+			self.stream <- dataCell
+			self.Spider.RecordItem(key)
+			return
+		}
+		// 输出后dataCell会被回收复用，故推送其副本
+		cell := make(data.DataCell, len(dataCell))
+		for k, v := range dataCell {
+			cell[k] = v
+		}
+		delete(cell, data.ITEM_KEY)
+		self.stream <- cell
+	}
 	self.DataChan <- dataCell
 }
 
+// 文本数据收集通道的容量，未设置run::itembuffer时采用datachancap；输出慢于采集时，
+// 通道已满后采集协程阻塞于Output()而不再继续下载，内存占用以此为限
+func dataChanCap() int {
+	if cache.Task.ItemBuffer > 0 {
+		return cache.Task.ItemBuffer
+	}
+	return config.DATA_CHAN_CAP
+}
+
+// run::itembufferpolicy为drop且收集通道已满时丢弃数据并返回true，首次丢弃时记录日志，总数于输出完成后报告；
+// 多个采集协程并发收集时仍可能短暂阻塞
+func (self *Collector) dropFull(dataCell data.DataCell) bool {
+	if cache.Task.ItemBufferPolicy != "drop" || self.streamOnly || len(self.DataChan) < cap(self.DataChan) {
+		return false
+	}
+	if atomic.AddUint64(&self.dropped, 1) == 1 {
+		url, _ := dataCell["Url"].(string)
+		logs.Log.Warning(" *     [%v] 收集通道已满(%v)，开始丢弃结果 [%v]\n", self.Spider.GetName(), cap(self.DataChan), url)
+	}
+	return true
+}
+
+// 开启文本数据的实时推送，返回的通道在Stop()时关闭，须在Start()前调用；
+// only为true时数据仅推送而不再输出；通道无缓冲，消费过慢时会减缓采集，故须持续读取直至关闭
+func (self *Collector) Stream(only bool) <-chan data.DataCell {
+	self.stream = make(chan data.DataCell)
+	self.streamOnly = only
+	return self.stream
+}
+
 func (self *Collector) CollectFile(fileCell data.FileCell) {
 	self.FileChan <- fileCell
 }
@@ -64,6 +157,10 @@ func (self *Collector) beStopping() bool {
 // 停止
 func (self *Collector) Stop() {
 	<-self.ctrl
+	if self.stream != nil {
+		close(self.stream)
+		self.stream = nil
+	}
 }
 
 // 启动数据收集/输出管道
@@ -71,57 +168,148 @@ func (self *Collector) Start() {
 	// 标记程序已启动
 	self.ctrl <- true
 
-	// 启动输出协程
+	// 输出结果说明
+	if cache.Task.OutputSchema {
+		self.outputSchema()
+	}
+
+	// 创建结果清单文件
+	if cache.Task.Manifest {
+		self.openManifest()
+	}
+
+	// 启动输出协程，每个协程独立分批输出文本数据
 	go func() {
+		var wg sync.WaitGroup
+		for i, n := 0, self.threadNum(); i < n; i++ {
+			wg.Add(1)
+			go func(curr int) {
+				defer wg.Done()
+				self.work(curr)
+			}(self.take(i))
+		}
+		wg.Wait()
+
+		// 等待所有输出完成
+		for (atomic.LoadUint32(&self.outCount[0]) > atomic.LoadUint32(&self.outCount[1])) || (self.outCount[2] > self.outCount[3]) || len(self.FileChan) > 0 {
+			runtime.Gosched()
+		}
+
+		if n := atomic.LoadUint64(&self.dropped); n > 0 {
+			logs.Log.Warning(" *     [%v] 因收集通道已满共丢弃结果 %v 条\n", self.Spider.GetName(), n)
+		}
 
-		// 只有当收到退出通知并且通道内无数据时，才退出循环
-		for !(self.beStopping() && len(self.DataChan) == 0 && len(self.FileChan) == 0) {
-			select {
-			case data := <-self.DataChan:
-				// 追加数据
-				self.Dockers[self.Curr] = append(self.Dockers[self.Curr], data)
+		// 关闭结果清单文件
+		if self.manifest != nil {
+			self.manifest.close()
+		}
 
-				// 未达到设定的分批量时，仅缓存
-				if len(self.Dockers[self.Curr]) < cache.Task.DockerCap {
-					continue
-				}
+		// 返回报告
+		self.Report()
+	}()
+}
 
-				// 执行输出
-				self.outputData()
+// 文本数据收集输出的并发协程数，有序输出模式下为1以保证输出顺序
+func (self *Collector) threadNum() int {
+	if cache.Task.OrderedOutput || cache.Task.CollectorThreadNum < 1 {
+		return 1
+	}
+	return cache.Task.CollectorThreadNum
+}
 
-				// 更换一个空Docker用于curDocker
-				self.DockerQueue.Change()
+// 为第i个输出协程分配缓存块，首个协程使用当前缓存块
+func (self *Collector) take(i int) int {
+	if i == 0 {
+		return self.DockerQueue.Curr
+	}
+	return self.DockerQueue.Take()
+}
+
+// 从通道接收数据并以下标为curr的缓存块分批输出
+func (self *Collector) work(curr int) {
+	var (
+		interval   = time.Duration(cache.Task.RotateInterval) * time.Second
+		lastOutput = time.Now()
+	)
+	// 开启按时长滚动输出且距上次输出已达到间隔时，即使未达到分批量也输出
+	rotateDue := func() bool {
+		return interval > 0 && len(self.Dockers[curr]) > 0 && time.Since(lastOutput) >= interval
+	}
 
-			case file := <-self.FileChan:
-				go self.outputFile(file)
+	// 只有当收到退出通知并且通道内无数据时，才退出循环
+	for !(self.beStopping() && len(self.DataChan) == 0 && len(self.FileChan) == 0) {
+		select {
+		case data := <-self.DataChan:
+			// 追加数据
+			self.Dockers[curr] = append(self.Dockers[curr], data)
 
-			default:
+			// 未达到设定的分批量时，仅缓存
+			if len(self.Dockers[curr]) < cache.Task.DockerCap && !rotateDue() {
+				continue
+			}
+
+		case file := <-self.FileChan:
+			go self.outputFile(file)
+			continue
+
+		default:
+			if !rotateDue() {
 				runtime.Gosched()
+				continue
 			}
 		}
 
-		// 将剩余收集到但未输出的数据输出
-		self.outputData()
+		// 执行输出
+		self.outputData(curr)
 
-		// 等待所有输出完成
-		for (self.outCount[0] > self.outCount[1]) || (self.outCount[2] > self.outCount[3]) || len(self.FileChan) > 0 {
-			runtime.Gosched()
-		}
+		// 更换一个空Docker用于接收数据
+		curr = self.DockerQueue.Take()
+		lastOutput = time.Now()
+	}
 
-		// 返回报告
-		self.Report()
-	}()
+	// 将剩余收集到但未输出的数据输出
+	self.outputData(curr)
+}
+
+// 是否采用了其中任一输出方式
+func (self *Collector) hasOutType(outTypes ...string) bool {
+	for _, v := range self.outTypes {
+		for _, outType := range outTypes {
+			if v == outType {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // 获取文本数据总量
 func (self *Collector) dataSum() uint64 {
+	self.sumLock.Lock()
+	defer self.sumLock.Unlock()
 	return self.sum[1]
 }
 
-// 更新文本数据总量
-func (self *Collector) addDataSum(add uint64) {
+// 为下标为dataIndex的缓存块更新文本数据总量，返回本批数据的总数区间[输出前文本总数，输出后文本总数]，
+// 多个输出协程可能并发调用
+func (self *Collector) addDataSum(dataIndex int, add uint64) [2]uint64 {
+	self.sumLock.Lock()
+	defer self.sumLock.Unlock()
 	self.sum[0] = self.sum[1]
 	self.sum[1] += add
+	r := [2]uint64{self.sum[0], self.sum[1]}
+	if self.dataRanges == nil {
+		self.dataRanges = make(map[int][2]uint64)
+	}
+	self.dataRanges[dataIndex] = r
+	return r
+}
+
+// 获取下标为dataIndex的缓存块中文本数据的总数区间，见addDataSum()
+func (self *Collector) dataRange(dataIndex int) [2]uint64 {
+	self.sumLock.Lock()
+	defer self.sumLock.Unlock()
+	return self.dataRanges[dataIndex]
 }
 
 // 获取文件数据总量
@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/henrylee2cn/pholcus/app/pipeline/collector/data"
+	"github.com/henrylee2cn/pholcus/app/spider"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+// 创建收集通道容量为size的收集器
+func bufferedCollector(t *testing.T, size int) *Collector {
+	defer func(size int) { cache.Task.ItemBuffer = size }(cache.Task.ItemBuffer)
+	cache.Task.ItemBuffer = size
+	self := NewCollector()
+	self.Spider = &spider.Spider{Name: "test"}
+	if cap(self.DataChan) != size {
+		t.Fatalf("DataChan capacity = %d, want %d", cap(self.DataChan), size)
+	}
+	return self
+}
+
+// 收集通道已满时，drop丢弃结果且不计入采集数，block阻塞直至通道有空位
+func TestItemBufferPolicy(t *testing.T) {
+	self := bufferedCollector(t, 2)
+	defer func(policy string) { cache.Task.ItemBufferPolicy = policy }(cache.Task.ItemBufferPolicy)
+	cache.Task.ItemBufferPolicy = "drop"
+	for i := 0; i < 3; i++ {
+		self.CollectData(data.DataCell{"RuleName": "r", "Url": "http://a/"})
+	}
+	if len(self.DataChan) != 2 || self.dropped != 1 || self.Spider.GetItemCount() != 2 {
+		t.Fatalf("queued %d, dropped %d, counted %d; want 2, 1, 2", len(self.DataChan), self.dropped, self.Spider.GetItemCount())
+	}
+
+	self = bufferedCollector(t, 1)
+	cache.Task.ItemBufferPolicy = "block"
+	self.CollectData(data.DataCell{"RuleName": "r"})
+	done := make(chan bool)
+	go func() {
+		self.CollectData(data.DataCell{"RuleName": "r"})
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("CollectData returned while the buffer is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	<-self.DataChan
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("CollectData still blocked after the buffer drained")
+	}
+	if self.dropped != 0 {
+		t.Errorf("dropped %d under block", self.dropped)
+	}
+}
+
+// 并发输出的各批数据取得互不重叠的总数区间，输出文件名不会重复
+func TestAddDataSum(t *testing.T) {
+	self := NewCollector()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			self.addDataSum(i, 10)
+		}(i)
+	}
+	wg.Wait()
+	seen := make(map[uint64]bool)
+	for i := 0; i < 50; i++ {
+		r := self.dataRange(i)
+		if r[1]-r[0] != 10 || seen[r[0]] {
+			t.Fatalf("batch %d got range %v", i, r)
+		}
+		seen[r[0]] = true
+	}
+	if n := self.dataSum(); n != 500 {
+		t.Fatalf("dataSum() = %d, want 500", n)
+	}
+}
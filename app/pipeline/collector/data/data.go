@@ -2,16 +2,38 @@ package data
 
 import (
 	"sync"
+	"time"
 )
 
 type (
 	// 数据存储单元
 	DataCell map[string]interface{}
 	// 文件存储单元
-	// FileCell存储的完整文件名为： file/"Dir"/"RuleName"/"time"/"Name"
+	// FileCell存储的完整文件名由config.FILE_LAYOUT模板决定，默认为： file/"Namespace"/"Name"
 	FileCell map[string]interface{}
 )
 
+// 开启run::manifest时附于DataCell的来源请求的键名，收集时即被移除，不会输出
+const SOURCE = "Source"
+
+// 开启run::collectonce时附于DataCell的结果幂等键的键名，输出前即被移除，输出成功后方记录该键
+const ITEM_KEY = "ItemKey"
+
+// 开启run::runtag时为每条结果附带的字段名，分别为本次运行的ID及开始时间
+const (
+	RUN_ID_FIELD   = "RunId"
+	RUN_TIME_FIELD = "RunTime"
+)
+
+// 结果数据单元的来源请求
+type Source struct {
+	Url        string    // 请求的网址
+	Rule       string    // 请求的规则名
+	Method     string    // 请求方法
+	StatusCode int       // 响应状态码，无响应时为0
+	Time       time.Time // 输出该结果的时间
+}
+
 var (
 	dataCellPool = &sync.Pool{
 		New: func() interface{} {
@@ -35,11 +57,12 @@ func GetDataCell(ruleName string, data map[string]interface{}, url string, paren
 	return cell
 }
 
-func GetFileCell(ruleName, name string, bytes []byte) FileCell {
+func GetFileCell(ruleName, name string, bytes []byte, url string) FileCell {
 	cell := fileCellPool.Get().(FileCell)
 	cell["RuleName"] = ruleName //存储路径中的一部分
 	cell["Name"] = name         //规定文件名
 	cell["Bytes"] = bytes       //文件内容
+	cell["Url"] = url           //文件来源，用于按主机名组织目录
 	return cell
 }
 
@@ -56,5 +79,9 @@ func PutFileCell(cell FileCell) {
 	cell["RuleName"] = nil
 	cell["Name"] = nil
 	cell["Bytes"] = nil
+	cell["Url"] = nil
+	cell["Offset"] = nil
+	cell["Partial"] = nil
+	cell["Checksum"] = nil
 	fileCellPool.Put(cell)
 }
@@ -27,33 +27,43 @@ func NewDockerQueue() *DockerQueue {
 	if cache.Task.DockerQueueCap < 2 {
 		queueCap = 2
 	}
+	// 每个输出协程至少需要一个接收数据的缓存块及一个输出中的缓存块
+	if n := cache.Task.CollectorThreadNum * 2; queueCap < n {
+		queueCap = n
+	}
 
 	dockerQueue := &DockerQueue{
-		Curr:    0,
-		Cap:     queueCap,
-		Using:   make(map[int]bool, queueCap),
-		Dockers: make([][]data.DataCell, 0),
+		Curr:  0,
+		Cap:   queueCap,
+		Using: make(map[int]bool, queueCap),
+		// 预先分配列表长度，新增缓存块时不改动列表本身，以免影响其他协程正在写入的缓存块
+		Dockers: make([][]data.DataCell, queueCap),
 	}
 
 	dockerQueue.Using[0] = true
 
-	dockerQueue.Dockers = append(dockerQueue.Dockers, NewDocker())
+	dockerQueue.Dockers[0] = NewDocker()
 
 	return dockerQueue
 }
 
 func (self *DockerQueue) Change() {
-	changeMutex.Lock()
-	defer changeMutex.Unlock()
+	self.Curr = self.Take()
+}
+
+// 取得一个空闲的缓存块并标记为使用中，返回其下标，无空闲时等待，并发安全
+func (self *DockerQueue) Take() int {
 	for {
+		changeMutex.Lock()
 		for k, v := range self.Using {
 			if !v {
-				self.Curr = k
 				self.Using[k] = true
-				return
+				changeMutex.Unlock()
+				return k
 			}
 		}
 		self.AutoAdd()
+		changeMutex.Unlock()
 		time.Sleep(5e8)
 	}
 }
@@ -63,14 +73,16 @@ func (self *DockerQueue) Recover(index int) {
 		data.PutDataCell(cell)
 	}
 	self.Dockers[index] = self.Dockers[index][:0]
+	changeMutex.Lock()
 	self.Using[index] = false
+	changeMutex.Unlock()
 }
 
 // 根据情况自动动态增加Docker
 func (self *DockerQueue) AutoAdd() {
-	count := len(self.Dockers)
+	count := len(self.Using)
 	if count < self.Cap {
-		self.Dockers = append(self.Dockers, NewDocker())
+		self.Dockers[count] = NewDocker()
 		self.Using[count] = false
 	}
 }
@@ -0,0 +1,93 @@
+package collector
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/henrylee2cn/pholcus/app/pipeline/collector/data"
+	"github.com/henrylee2cn/pholcus/common/util"
+	"github.com/henrylee2cn/pholcus/logs"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+// 结果清单文件，开启run::manifest时于文本结果目录逐行（json）记录每条结果的来源请求，并发安全
+type manifest struct {
+	fileName string
+	file     *os.File
+	w        *bufio.Writer
+	index    int64 // 已记录的结果数
+	lock     sync.Mutex
+}
+
+// 清单中的一条记录
+type manifestEntry struct {
+	Index      int64  // 结果的序号，按收集顺序自1起
+	Hash       string // 结果数据（Data字段）以json序列化后的sha1，用于在输出结果中定位该条结果
+	ItemRule   string // 结果所属的规则
+	Rule       string // 来源请求的规则
+	Url        string // 来源请求的网址
+	Method     string // 来源请求的方法
+	StatusCode int    // 来源请求的响应状态码
+	Time       string // 输出该结果的时间
+}
+
+// 在文本结果目录创建清单文件，于本次任务的excel、csv结果同一目录下
+func (self *Collector) openManifest() {
+	folder := self.textDir() + "/" + cache.StartTime.Format("2006年01月02日 15时04分05秒")
+	fileName := folder + "/" + util.FileNameReplace(self.namespace()) + "__manifest.jsonl"
+	err := os.MkdirAll(folder, 0777)
+	var file *os.File
+	if err == nil {
+		file, err = os.Create(fileName)
+	}
+	if err != nil {
+		logs.Log.Error(" *     Fail  [结果清单输出：%v]: %v\n", self.Spider.GetName(), err)
+		return
+	}
+	self.manifest = &manifest{
+		fileName: fileName,
+		file:     file,
+		w:        bufio.NewWriter(file),
+	}
+}
+
+// 记录一条结果的来源
+func (self *manifest) add(ruleName string, item interface{}, src *data.Source) {
+	b, _ := json.Marshal(item)
+	sum := sha1.Sum(b)
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.index++
+	line, _ := json.Marshal(manifestEntry{
+		Index:      self.index,
+		Hash:       hex.EncodeToString(sum[:]),
+		ItemRule:   ruleName,
+		Rule:       src.Rule,
+		Url:        src.Url,
+		Method:     src.Method,
+		StatusCode: src.StatusCode,
+		Time:       src.Time.Format("2006-01-02 15:04:05"),
+	})
+	self.w.Write(line)
+	self.w.WriteByte('\n')
+}
+
+// 写入剩余内容并关闭清单文件
+func (self *manifest) close() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	err := self.w.Flush()
+	if e := self.file.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		logs.Log.Error(" *     Fail  [结果清单输出]: %v\n", err)
+		return
+	}
+	logs.Log.Informational(" *     [结果清单输出]: %v 共 %v 条\n", self.fileName, self.index)
+}
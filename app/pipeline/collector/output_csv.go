@@ -5,8 +5,8 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/henrylee2cn/pholcus/app/pipeline/collector/data"
 	"github.com/henrylee2cn/pholcus/common/util"
-	"github.com/henrylee2cn/pholcus/config"
 	"github.com/henrylee2cn/pholcus/logs"
 	"github.com/henrylee2cn/pholcus/runtime/cache"
 )
@@ -19,65 +19,74 @@ func init() {
 				err = fmt.Errorf("%v", p)
 			}
 		}()
-		var (
-			namespace = util.FileNameReplace(self.namespace())
-			sheets    = make(map[string]*csv.Writer)
-		)
-		for _, datacell := range self.DockerQueue.Dockers[dataIndex] {
-			var subNamespace = util.FileNameReplace(self.subNamespace(datacell))
-			if _, ok := sheets[subNamespace]; !ok {
-				folder := config.TEXT_DIR + "/" + cache.StartTime.Format("2006年01月02日 15时04分05秒") + "/" + joinNamespaces(namespace, subNamespace)
-				filename := fmt.Sprintf("%v/%v-%v.csv", folder, self.sum[0], self.sum[1])
-
-				// 创建/打开目录
-				f, err := os.Stat(folder)
-				if err != nil || !f.IsDir() {
-					if err := os.MkdirAll(folder, 0777); err != nil {
-						logs.Log.Error("Error: %v\n", err)
-					}
-				}
+		sumRange := self.dataRange(dataIndex)
+		chunks := self.rotateChunks(self.DockerQueue.Dockers[dataIndex])
+		for i, cells := range chunks {
+			self.outputCsv(cells, sumRange, self.rotateSuffix(i, len(chunks)))
+		}
+		return
+	}
+}
 
-				// 按数据分类创建文件
-				file, err := os.Create(filename)
+// 将一段结果按数据分类输出为csv文件，sumRange为本批数据的总数区间，suffix为分段输出时的文件名后缀
+func (self *Collector) outputCsv(cells []data.DataCell, sumRange [2]uint64, suffix string) {
+	var (
+		namespace = util.FileNameReplace(self.namespace())
+		sheets    = make(map[string]*csv.Writer)
+	)
+	for _, datacell := range cells {
+		var subNamespace = util.FileNameReplace(self.subNamespace(datacell))
+		if _, ok := sheets[subNamespace]; !ok {
+			folder := self.textDir() + "/" + cache.StartTime.Format("2006年01月02日 15时04分05秒") + "/" + joinNamespaces(namespace, subNamespace)
+			filename := fmt.Sprintf("%v/%v-%v%v.csv", folder, sumRange[0], sumRange[1], suffix)
 
-				if err != nil {
-					logs.Log.Error("%v", err)
-					continue
+			// 创建/打开目录
+			f, err := os.Stat(folder)
+			if err != nil || !f.IsDir() {
+				if err := os.MkdirAll(folder, 0777); err != nil {
+					logs.Log.Error("Error: %v\n", err)
 				}
+			}
 
-				file.WriteString("\xEF\xBB\xBF") // 写入UTF-8 BOM
-
-				sheets[subNamespace] = csv.NewWriter(file)
-				th := self.MustGetRule(datacell["RuleName"].(string)).ItemFields
-				if self.Spider.OutDefaultField() {
-					th = append(th, "当前链接", "上级链接", "下载时间")
-				}
-				sheets[subNamespace].Write(th)
+			// 按数据分类创建文件
+			file, err := os.Create(filename)
 
-				defer func(file *os.File) {
-					// 发送缓存数据流
-					sheets[subNamespace].Flush()
-					// 关闭文件
-					file.Close()
-				}(file)
+			if err != nil {
+				logs.Log.Error("%v", err)
+				continue
 			}
 
-			row := []string{}
-			for _, title := range self.MustGetRule(datacell["RuleName"].(string)).ItemFields {
-				vd := datacell["Data"].(map[string]interface{})
-				if v, ok := vd[title].(string); ok || vd[title] == nil {
-					row = append(row, v)
-				} else {
-					row = append(row, util.JsonString(vd[title]))
-				}
-			}
+			file.WriteString("\xEF\xBB\xBF") // 写入UTF-8 BOM
+
+			sheets[subNamespace] = csv.NewWriter(file)
+			th := self.MustGetRule(datacell["RuleName"].(string)).ItemFields
 			if self.Spider.OutDefaultField() {
-				row = append(row, datacell["Url"].(string))
-				row = append(row, datacell["ParentUrl"].(string))
-				row = append(row, datacell["DownloadTime"].(string))
+				th = append(th, "当前链接", "上级链接", "下载时间")
 			}
-			sheets[subNamespace].Write(row)
+			sheets[subNamespace].Write(th)
+
+			defer func(file *os.File) {
+				// 发送缓存数据流
+				sheets[subNamespace].Flush()
+				// 关闭文件
+				file.Close()
+			}(file)
 		}
-		return
+
+		row := []string{}
+		for _, title := range self.MustGetRule(datacell["RuleName"].(string)).ItemFields {
+			vd := datacell["Data"].(map[string]interface{})
+			if v, ok := vd[title].(string); ok || vd[title] == nil {
+				row = append(row, v)
+			} else {
+				row = append(row, util.JsonString(vd[title]))
+			}
+		}
+		if self.Spider.OutDefaultField() {
+			row = append(row, datacell["Url"].(string))
+			row = append(row, datacell["ParentUrl"].(string))
+			row = append(row, datacell["DownloadTime"].(string))
+		}
+		sheets[subNamespace].Write(row)
 	}
 }
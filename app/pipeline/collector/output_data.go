@@ -1,8 +1,11 @@
 package collector
 
 import (
+	"fmt"
+	"sync/atomic"
 	"time"
 
+	"github.com/henrylee2cn/pholcus/app/pipeline/collector/data"
 	"github.com/henrylee2cn/pholcus/logs"
 )
 
@@ -10,21 +13,27 @@ var (
 	// 全局支持的输出方式
 	DataOutput = make(map[string]func(self *Collector, dataIndex int) error)
 
+	// 输出方式的目标连通性检查，在任务开始前调用，未注册的输出方式视为总是可用
+	DataCheck = make(map[string]func() error)
+
 	// 全局支持的文本数据输出方式名称列表
 	DataOutputLib []string
+
+	// 文本数据收集前依次执行的转换函数，返回nil时丢弃该数据，默认首先处理非法UTF-8字符串，再附带运行标识
+	DataTransforms = []func(data.DataCell) data.DataCell{SanitizeUTF8, TagRun}
 )
 
-// 文本数据输出
-func (self *Collector) outputData() {
+// 输出下标为dataIndex的缓存块中的文本数据
+func (self *Collector) outputData(dataIndex int) {
 	// 开始输出的计数
-	self.outCount[0]++
+	atomic.AddUint32(&self.outCount[0], 1)
 
 	go func(dataIndex int) {
 		defer func() {
 			// 回收缓存块
 			self.DockerQueue.Recover(dataIndex)
 			// 输出完成的计数
-			self.outCount[1]++
+			atomic.AddUint32(&self.outCount[1], 1)
 		}()
 
 		// 输出
@@ -44,23 +53,66 @@ func (self *Collector) outputData() {
 		}()
 
 		// 输出统计
-		self.addDataSum(dataLen)
-
-		// 执行输出
-		err := DataOutput[self.outType](self, dataIndex)
-
-		logs.Log.Informational(" * ")
-		if err != nil {
-			logs.Log.App(" *     Fail  [数据输出：%v | KEYIN：%v | 批次：%v]   数据 %v 条，用时 %v！ [ERROR]  %v\n",
-				self.Spider.GetName(), self.Spider.GetKeyin(), self.outCount[1]+1, dataLen, time.Since(self.timing), err)
-		} else {
-			logs.Log.App(" *     [数据输出：%v | KEYIN：%v | 批次：%v]   数据 %v 条，用时 %v！\n",
-				self.Spider.GetName(), self.Spider.GetKeyin(), self.outCount[1]+1, dataLen, time.Since(self.timing))
+		self.addDataSum(dataIndex, dataLen)
+
+		// 取出各结果的幂等键，不随结果输出
+		keys := takeItemKeys(self.DockerQueue.Dockers[dataIndex])
+
+		// 依次执行各输出方式，其中之一失败时不影响其余输出
+		var failed bool
+		for _, outType := range self.outTypes {
+			err := self.output(outType, dataIndex)
+
+			logs.Log.Informational(" * ")
+			if err != nil {
+				failed = true
+				logs.Log.App(" *     Fail  [数据输出：%v | KEYIN：%v | 输出方式：%v | 批次：%v]   数据 %v 条，用时 %v！ [ERROR]  %v\n",
+					self.Spider.GetName(), self.Spider.GetKeyin(), outType, self.outCount[1]+1, dataLen, time.Since(self.timing), err)
+			} else {
+				logs.Log.App(" *     [数据输出：%v | KEYIN：%v | 输出方式：%v | 批次：%v]   数据 %v 条，用时 %v！\n",
+					self.Spider.GetName(), self.Spider.GetKeyin(), outType, self.outCount[1]+1, dataLen, time.Since(self.timing))
+			}
+		}
+		if !failed {
 			self.Spider.TryFlushSuccess()
 		}
+		// 全部输出方式成功后才记录幂等键，否则请求重试或断点续抓时重新收集
+		for _, key := range keys {
+			if failed {
+				self.Spider.ForgetItem(key)
+			} else {
+				self.Spider.RecordItem(key)
+			}
+		}
 
 		// 更新计时
 		self.timing = time.Now()
 
-	}(self.Curr)
+	}(dataIndex)
+}
+
+// 取出并移除缓存块中各结果附带的幂等键
+func takeItemKeys(cells []data.DataCell) []string {
+	var keys []string
+	for _, cell := range cells {
+		if key, ok := cell[data.ITEM_KEY].(string); ok {
+			keys = append(keys, key)
+			delete(cell, data.ITEM_KEY)
+		}
+	}
+	return keys
+}
+
+// 以指定输出方式输出下标为dataIndex的缓存块，输出方式不存在或发生panic时返回错误
+func (self *Collector) output(outType string, dataIndex int) (err error) {
+	out, ok := DataOutput[outType]
+	if !ok {
+		return fmt.Errorf("unknown output type %q", outType)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%v", p)
+		}
+	}()
+	return out(self, dataIndex)
 }
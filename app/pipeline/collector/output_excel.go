@@ -4,9 +4,9 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/henrylee2cn/pholcus/app/pipeline/collector/data"
 	"github.com/henrylee2cn/pholcus/common/util"
 	"github.com/henrylee2cn/pholcus/common/xlsx"
-	"github.com/henrylee2cn/pholcus/config"
 	"github.com/henrylee2cn/pholcus/logs"
 	"github.com/henrylee2cn/pholcus/runtime/cache"
 )
@@ -20,68 +20,79 @@ func init() {
 			}
 		}()
 
-		var (
-			file   *xlsx.File
-			row    *xlsx.Row
-			cell   *xlsx.Cell
-			sheets = make(map[string]*xlsx.Sheet)
-		)
+		sumRange := self.dataRange(dataIndex)
+		chunks := self.rotateChunks(self.DockerQueue.Dockers[dataIndex])
+		for i, cells := range chunks {
+			if err = self.outputExcel(cells, sumRange, self.rotateSuffix(i, len(chunks))); err != nil {
+				return
+			}
+		}
+		return
+	}
+}
 
-		// 创建文件
-		file = xlsx.NewFile()
+// 将一段结果输出为excel文件，每种数据分类为一个工作表，sumRange为本批数据的总数区间，suffix为分段输出时的文件名后缀
+func (self *Collector) outputExcel(cells []data.DataCell, sumRange [2]uint64, suffix string) error {
+	var (
+		file   *xlsx.File
+		row    *xlsx.Row
+		cell   *xlsx.Cell
+		sheets = make(map[string]*xlsx.Sheet)
+	)
 
-		// 添加分类数据工作表
-		for _, datacell := range self.DockerQueue.Dockers[dataIndex] {
-			var subNamespace = util.FileNameReplace(self.subNamespace(datacell))
-			if _, ok := sheets[subNamespace]; !ok {
-				// 添加工作表
-				sheet, err := file.AddSheet(subNamespace)
-				if err != nil {
-					logs.Log.Error("%v", err)
-					continue
-				}
-				sheets[subNamespace] = sheet
-				// 写入表头
-				row = sheets[subNamespace].AddRow()
-				for _, title := range self.MustGetRule(datacell["RuleName"].(string)).ItemFields {
-					row.AddCell().Value = title
-				}
-				if self.Spider.OutDefaultField() {
-					row.AddCell().Value = "当前链接"
-					row.AddCell().Value = "上级链接"
-					row.AddCell().Value = "下载时间"
-				}
-			}
+	// 创建文件
+	file = xlsx.NewFile()
 
+	// 添加分类数据工作表
+	for _, datacell := range cells {
+		var subNamespace = util.FileNameReplace(self.subNamespace(datacell))
+		if _, ok := sheets[subNamespace]; !ok {
+			// 添加工作表
+			sheet, err := file.AddSheet(subNamespace)
+			if err != nil {
+				logs.Log.Error("%v", err)
+				continue
+			}
+			sheets[subNamespace] = sheet
+			// 写入表头
 			row = sheets[subNamespace].AddRow()
 			for _, title := range self.MustGetRule(datacell["RuleName"].(string)).ItemFields {
-				cell = row.AddCell()
-				vd := datacell["Data"].(map[string]interface{})
-				if v, ok := vd[title].(string); ok || vd[title] == nil {
-					cell.Value = v
-				} else {
-					cell.Value = util.JsonString(vd[title])
-				}
+				row.AddCell().Value = title
 			}
 			if self.Spider.OutDefaultField() {
-				row.AddCell().Value = datacell["Url"].(string)
-				row.AddCell().Value = datacell["ParentUrl"].(string)
-				row.AddCell().Value = datacell["DownloadTime"].(string)
+				row.AddCell().Value = "当前链接"
+				row.AddCell().Value = "上级链接"
+				row.AddCell().Value = "下载时间"
 			}
 		}
-		folder := config.TEXT_DIR + "/" + cache.StartTime.Format("2006年01月02日 15时04分05秒")
-		filename := fmt.Sprintf("%v/%v__%v-%v.xlsx", folder, util.FileNameReplace(self.namespace()), self.sum[0], self.sum[1])
 
-		// 创建/打开目录
-		f2, err := os.Stat(folder)
-		if err != nil || !f2.IsDir() {
-			if err := os.MkdirAll(folder, 0777); err != nil {
-				logs.Log.Error("Error: %v\n", err)
+		row = sheets[subNamespace].AddRow()
+		for _, title := range self.MustGetRule(datacell["RuleName"].(string)).ItemFields {
+			cell = row.AddCell()
+			vd := datacell["Data"].(map[string]interface{})
+			if v, ok := vd[title].(string); ok || vd[title] == nil {
+				cell.Value = v
+			} else {
+				cell.Value = util.JsonString(vd[title])
 			}
 		}
+		if self.Spider.OutDefaultField() {
+			row.AddCell().Value = datacell["Url"].(string)
+			row.AddCell().Value = datacell["ParentUrl"].(string)
+			row.AddCell().Value = datacell["DownloadTime"].(string)
+		}
+	}
+	folder := self.textDir() + "/" + cache.StartTime.Format("2006年01月02日 15时04分05秒")
+	filename := fmt.Sprintf("%v/%v__%v-%v%v.xlsx", folder, util.FileNameReplace(self.namespace()), sumRange[0], sumRange[1], suffix)
 
-		// 保存文件
-		err = file.Save(filename)
-		return
+	// 创建/打开目录
+	f2, err := os.Stat(folder)
+	if err != nil || !f2.IsDir() {
+		if err := os.MkdirAll(folder, 0777); err != nil {
+			logs.Log.Error("Error: %v\n", err)
+		}
 	}
+
+	// 保存文件
+	return file.Save(filename)
 }
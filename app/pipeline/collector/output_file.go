@@ -2,16 +2,32 @@ package collector
 
 import (
 	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
 	"io"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
 	"github.com/henrylee2cn/pholcus/app/pipeline/collector/data"
+	"github.com/henrylee2cn/pholcus/app/spider"
 	bytesSize "github.com/henrylee2cn/pholcus/common/bytes"
 	"github.com/henrylee2cn/pholcus/common/util"
 	"github.com/henrylee2cn/pholcus/config"
 	"github.com/henrylee2cn/pholcus/logs"
-	// "github.com/henrylee2cn/pholcus/runtime/cache"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+const (
+	PART_SUFFIX    = ".part"    // 断点续传时已下载部分的文件名后缀
+	CORRUPT_SUFFIX = ".corrupt" // 校验值不符而被隔离的文件名后缀
 )
 
 // 文件输出
@@ -21,13 +37,9 @@ func (self *Collector) outputFile(file data.FileCell) {
 
 	self.outCount[2]++
 
-	// 路径： file/"RuleName"/"time"/"Name"
-	p, n := filepath.Split(filepath.Clean(file["Name"].(string)))
-	// dir := filepath.Join(config.FILE_DIR, util.FileNameReplace(self.namespace())+"__"+cache.StartTime.Format("2006年01月02日 15时04分05秒"), p)
-	dir := filepath.Join(config.FILE_DIR, util.FileNameReplace(self.namespace()), p)
-
-	// 文件名
-	fileName := filepath.Join(dir, util.FileNameReplace(n))
+	// 路径： 输出目录/按config.FILE_LAYOUT模板生成的相对路径
+	fileName := filepath.Join(self.fileDir(), self.fileLayout(file))
+	dir := filepath.Dir(fileName)
 
 	// 创建/打开目录
 	d, err := os.Stat(dir)
@@ -42,9 +54,57 @@ func (self *Collector) outputFile(file data.FileCell) {
 		}
 	}
 
-	// 文件不存在就以0777的权限创建文件，如果存在就在写入之前清空内容
-	f, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0777)
+	// 断点续传的文件先写入.part文件，完整后再重命名
+	var (
+		offset, _  = file["Offset"].(int64)
+		partial, _ = file["Partial"].(bool)
+		target     = fileName
+		flag       = os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	)
+	if partial || offset > 0 {
+		target = fileName + PART_SUFFIX
+	}
+	if offset > 0 {
+		if info, err := os.Stat(target); err != nil || info.Size() < offset {
+			self.outCount[3]++
+			logs.Log.Error(
+				" *     Fail  [文件下载：%v | KEYIN：%v | 批次：%v]   %v [ERROR]  已下载部分与续传位置 %v 不符\n",
+				self.Spider.GetName(), self.Spider.GetKeyin(), self.outCount[3], fileName, offset,
+			)
+			return
+		}
+		flag = os.O_RDWR
+	}
+
+	// 设置了预期校验值时，边写入边计算
+	var (
+		checksum, _ = file["Checksum"].([2]string)
+		h           hash.Hash
+	)
+	err = nil
+	if checksum[0] != "" && !partial {
+		h, err = newChecksum(checksum[0])
+	}
+
+	// 文件不存在就以0777的权限创建文件，如果存在就在写入之前清空内容（续传时保留续传位置之前的内容）
+	var f *os.File
+	if err == nil {
+		f, err = os.OpenFile(target, flag, 0777)
+	}
+	if err == nil && offset > 0 {
+		if err = f.Truncate(offset); err == nil {
+			if h != nil {
+				// 读取已下载部分计入校验值，读取后恰好位于续传位置
+				_, err = io.CopyN(h, f, offset)
+			} else {
+				_, err = f.Seek(offset, io.SeekStart)
+			}
+		}
+	}
 	if err != nil {
+		if f != nil {
+			f.Close()
+		}
 		self.outCount[3]++
 		logs.Log.Error(
 			" *     Fail  [文件下载：%v | KEYIN：%v | 批次：%v]   %v [ERROR]  %v\n",
@@ -53,8 +113,33 @@ func (self *Collector) outputFile(file data.FileCell) {
 		return
 	}
 
-	size, err := io.Copy(f, bytes.NewReader(file["Bytes"].([]byte)))
+	var w io.Writer = f
+	if h != nil {
+		w = io.MultiWriter(f, h)
+	}
+	size, err := io.Copy(w, bytes.NewReader(file["Bytes"].([]byte)))
 	f.Close()
+
+	// 校验值不符时隔离该文件
+	if err == nil && h != nil {
+		if sum := hex.EncodeToString(h.Sum(nil)); sum != checksum[1] {
+			os.Rename(target, fileName+CORRUPT_SUFFIX)
+			self.outCount[3]++
+			logs.Log.Error(
+				" *     Fail  [文件下载：%v | KEYIN：%v | 批次：%v]   %v [ERROR]  %v校验值不符（预期 %v，实际 %v），已隔离为 %v\n",
+				self.Spider.GetName(), self.Spider.GetKeyin(), self.outCount[3], fileName, checksum[0], checksum[1], sum, fileName+CORRUPT_SUFFIX,
+			)
+			return
+		}
+	}
+
+	if err == nil && !partial {
+		if target != fileName {
+			err = os.Rename(target, fileName)
+		} else {
+			os.Remove(fileName + PART_SUFFIX)
+		}
+	}
 	if err != nil {
 		self.outCount[3]++
 		logs.Log.Error(
@@ -64,6 +149,16 @@ func (self *Collector) outputFile(file data.FileCell) {
 		return
 	}
 
+	// 下载中断的部分，待续传
+	if partial {
+		self.outCount[3]++
+		logs.Log.Warning(
+			" *     Part  [文件下载：%v | KEYIN：%v | 批次：%v]   %v (已下载 %s)，待续传\n",
+			self.Spider.GetName(), self.Spider.GetKeyin(), self.outCount[3], fileName, bytesSize.Format(uint64(offset+size)),
+		)
+		return
+	}
+
 	// 输出统计
 	self.outCount[3]++
 	self.addFileSum(1)
@@ -72,7 +167,82 @@ func (self *Collector) outputFile(file data.FileCell) {
 	logs.Log.Informational(" * ")
 	logs.Log.App(
 		" *     [文件下载：%v | KEYIN：%v | 批次：%v]   %v (%s)\n",
-		self.Spider.GetName(), self.Spider.GetKeyin(), self.outCount[3], fileName, bytesSize.Format(uint64(size)),
+		self.Spider.GetName(), self.Spider.GetKeyin(), self.outCount[3], fileName, bytesSize.Format(uint64(offset+size)),
 	)
 	logs.Log.Informational(" * ")
 }
+
+// 创建指定算法的校验值计算器
+func newChecksum(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("不支持的校验算法: %v", algorithm)
+}
+
+// 请求所对应的文件是否已输出过，仅对设置了Request.FileName的请求有效，
+// 输出路径处已存在非空文件时视为已输出
+func (self *Collector) HasFile(req *request.Request) bool {
+	if req.GetFileName() == "" {
+		return false
+	}
+	file := data.GetFileCell(req.GetRuleName(), spider.FileName(req.GetUrl(), req.GetFileName()), nil, req.GetUrl())
+	defer data.PutFileCell(file)
+	info, err := os.Stat(filepath.Join(self.fileDir(), self.fileLayout(file)))
+	return err == nil && !info.IsDir() && info.Size() > 0
+}
+
+// 请求所对应的文件已下载部分的大小，仅对设置了Request.FileName的请求有效，无已下载部分时返回0
+func (self *Collector) PartSize(req *request.Request) int64 {
+	if req.GetFileName() == "" {
+		return 0
+	}
+	file := data.GetFileCell(req.GetRuleName(), spider.FileName(req.GetUrl(), req.GetFileName()), nil, req.GetUrl())
+	defer data.PutFileCell(file)
+	info, err := os.Stat(filepath.Join(self.fileDir(), self.fileLayout(file)) + PART_SUFFIX)
+	if err != nil || info.IsDir() {
+		return 0
+	}
+	return info.Size()
+}
+
+// 按config.FILE_LAYOUT模板生成文件的相对路径，各级目录名均做非法字符替换
+// 模板未包含{filename}时，文件名追加在末尾
+func (self *Collector) fileLayout(file data.FileCell) string {
+	var host string
+	rawurl, _ := file["Url"].(string)
+	if u, err := url.Parse(rawurl); err == nil {
+		host = u.Host
+	}
+	layout := config.FILE_LAYOUT
+	if !strings.Contains(layout, "{filename}") {
+		layout = strings.TrimRight(layout, "/") + "/{filename}"
+	}
+	replacer := strings.NewReplacer(
+		"{namespace}", util.FileNameReplace(self.namespace()),
+		"{spider}", util.FileNameReplace(self.Spider.GetName()),
+		"{keyin}", util.FileNameReplace(self.Spider.GetKeyin()),
+		"{rule}", util.FileNameReplace(file["RuleName"].(string)),
+		"{host}", util.FileNameReplace(host),
+		"{date}", cache.StartTime.Format("2006-01-02"),
+		// 文件名中允许包含子目录
+		"{filename}", filepath.ToSlash(filepath.Clean(file["Name"].(string))),
+	)
+	var parts []string
+	for _, part := range strings.Split(replacer.Replace(layout), "/") {
+		part = strings.TrimSpace(part)
+		switch part {
+		case "", ".", "..":
+			continue
+		}
+		parts = append(parts, util.FileNameReplace(part))
+	}
+	return filepath.Join(parts...)
+}
@@ -0,0 +1,133 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/henrylee2cn/pholcus/common/gsheets"
+	"github.com/henrylee2cn/pholcus/common/util"
+	"github.com/henrylee2cn/pholcus/config"
+	"github.com/henrylee2cn/pholcus/logs"
+)
+
+/************************ Google Sheets 输出 ***************************/
+
+func init() {
+	var (
+		// 已确认存在并写入表头的工作表，多个输出协程共用
+		readySheets    = map[string]bool{}
+		readySheetLock sync.Mutex
+	)
+
+	// 确保工作表存在，为空时写入表头
+	var prepareSheet = func(c *gsheets.Client, title string, header []string) error {
+		readySheetLock.Lock()
+		defer readySheetLock.Unlock()
+		if readySheets[title] {
+			return nil
+		}
+		titles, err := c.Sheets(config.GSHEETS_SPREADSHEET)
+		if err != nil {
+			return err
+		}
+		var exists bool
+		for _, t := range titles {
+			if t == title {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			if err = c.AddSheet(config.GSHEETS_SPREADSHEET, title); err != nil {
+				return err
+			}
+		} else if empty, err := c.IsEmpty(config.GSHEETS_SPREADSHEET, title); err != nil || !empty {
+			if err == nil {
+				readySheets[title] = true
+			}
+			return err
+		}
+		if err = c.Append(config.GSHEETS_SPREADSHEET, title, [][]string{header}); err != nil {
+			return err
+		}
+		readySheets[title] = true
+		return nil
+	}
+
+	DataCheck["gsheets"] = func() error {
+		if config.GSHEETS_SPREADSHEET == "" {
+			return fmt.Errorf("未设置电子表格ID gsheets::spreadsheet")
+		}
+		if err := gsheets.Ping(); err != nil {
+			return fmt.Errorf("Google Sheets连接失败: %v", err)
+		}
+		return nil
+	}
+
+	DataOutput["gsheets"] = func(self *Collector, dataIndex int) error {
+		c, err := gsheets.Get()
+		if err != nil {
+			return fmt.Errorf("Google Sheets连接失败: %v", err)
+		}
+		if config.GSHEETS_SPREADSHEET == "" {
+			return fmt.Errorf("未设置电子表格ID gsheets::spreadsheet")
+		}
+		var (
+			namespace = util.FileNameReplace(self.namespace())
+			sheets    = make(map[string][][]string)
+			headers   = make(map[string][]string)
+			order     []string
+		)
+		for _, datacell := range self.DockerQueue.Dockers[dataIndex] {
+			subNamespace := util.FileNameReplace(self.subNamespace(datacell))
+			title := sheetTitle(joinNamespaces(namespace, subNamespace))
+			fields := self.MustGetRule(datacell["RuleName"].(string)).ItemFields
+			if _, ok := headers[title]; !ok {
+				th := append([]string{}, fields...)
+				if self.Spider.OutDefaultField() {
+					th = append(th, "当前链接", "上级链接", "下载时间")
+				}
+				headers[title] = th
+				order = append(order, title)
+			}
+			row := []string{}
+			vd := datacell["Data"].(map[string]interface{})
+			for _, field := range fields {
+				if v, ok := vd[field].(string); ok || vd[field] == nil {
+					row = append(row, v)
+				} else {
+					row = append(row, util.JsonString(vd[field]))
+				}
+			}
+			if self.Spider.OutDefaultField() {
+				row = append(row, datacell["Url"].(string), datacell["ParentUrl"].(string), datacell["DownloadTime"].(string))
+			}
+			sheets[title] = append(sheets[title], row)
+		}
+		// 各工作表分别写入，其中之一失败时不影响其余工作表
+		var errs []string
+		for _, title := range order {
+			err := prepareSheet(c, title, headers[title])
+			if err == nil {
+				err = c.Append(config.GSHEETS_SPREADSHEET, title, sheets[title])
+			}
+			if err != nil {
+				logs.Log.Error("Google Sheets：%v：%v\n", title, err)
+				errs = append(errs, title+": "+err.Error())
+			}
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("%v", strings.Join(errs, "; "))
+		}
+		return nil
+	}
+}
+
+// 工作表名称最长100个字符
+func sheetTitle(name string) string {
+	if r := []rune(name); len(r) > 100 {
+		return string(r[:100])
+	}
+	return name
+}
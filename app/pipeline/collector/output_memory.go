@@ -0,0 +1,39 @@
+package collector
+
+import (
+	"errors"
+
+	"github.com/henrylee2cn/pholcus/app/pipeline/collector/data"
+)
+
+/************************ 内存 输出 ***************************/
+
+// 内存输出的目标缓冲区，由每次运行各自创建(见pipeline.Memory)并经SetMemory()指定
+type MemoryBuffer interface {
+	AppendItems([]data.DataCell)
+}
+
+// 指定本次任务内存输出的缓冲区
+func (self *Collector) SetMemory(buf MemoryBuffer) {
+	self.memory = buf
+}
+
+func init() {
+	DataOutput["memory"] = func(self *Collector, dataIndex int) error {
+		if self.memory == nil {
+			return errors.New("未指定内存输出的缓冲区")
+		}
+		dockers := self.DockerQueue.Dockers[dataIndex]
+		cells := make([]data.DataCell, len(dockers))
+		for i, datacell := range dockers {
+			// 输出后datacell会被回收复用，故保存其副本
+			cell := make(data.DataCell, len(datacell))
+			for k, v := range datacell {
+				cell[k] = v
+			}
+			cells[i] = cell
+		}
+		self.memory.AppendItems(cells)
+		return nil
+	}
+}
@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/henrylee2cn/pholcus/app/pipeline/collector/data"
+)
+
+type testBuffer struct {
+	items []data.DataCell
+}
+
+func (self *testBuffer) AppendItems(cells []data.DataCell) {
+	self.items = append(self.items, cells...)
+}
+
+// 各收集器输出至各自指定的缓冲区，且保存的是数据副本；未指定缓冲区时报错
+func TestMemoryOutput(t *testing.T) {
+	output := DataOutput["memory"]
+	a, b := NewCollector(), NewCollector()
+	bufA, bufB := &testBuffer{}, &testBuffer{}
+	a.SetMemory(bufA)
+	b.SetMemory(bufB)
+	a.DockerQueue.Dockers[0] = []data.DataCell{{"RuleName": "a"}}
+	b.DockerQueue.Dockers[0] = []data.DataCell{{"RuleName": "b"}, {"RuleName": "b"}}
+
+	if err := output(a, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := output(b, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(bufA.items) != 1 || len(bufB.items) != 2 {
+		t.Fatalf("got %d and %d items, want 1 and 2", len(bufA.items), len(bufB.items))
+	}
+	a.DockerQueue.Dockers[0][0]["RuleName"] = "reused"
+	if bufA.items[0]["RuleName"] != "a" {
+		t.Errorf("buffered item changed with the docker: %v", bufA.items[0])
+	}
+
+	if err := output(NewCollector(), 0); err == nil {
+		t.Error("output without a buffer succeeded")
+	}
+}
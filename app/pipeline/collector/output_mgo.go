@@ -2,6 +2,7 @@ package collector
 
 import (
 	"fmt"
+	"strings"
 
 	mgov2 "gopkg.in/mgo.v2"
 
@@ -10,11 +11,19 @@ import (
 	"github.com/henrylee2cn/pholcus/common/util"
 	"github.com/henrylee2cn/pholcus/config"
 	"github.com/henrylee2cn/pholcus/logs"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
 )
 
 /************************ MongoDB 输出 ***************************/
 
 func init() {
+	DataCheck["mgo"] = func() error {
+		if err := mgo.Ping(); err != nil {
+			return fmt.Errorf("MongoBD数据库链接失败: %v", err)
+		}
+		return nil
+	}
+
 	DataOutput["mgo"] = func(self *Collector, dataIndex int) error {
 		//连接数据库
 		if mgo.Error() != nil {
@@ -36,38 +45,35 @@ func init() {
 				if _, ok := collections[subNamespace]; !ok {
 					collections[subNamespace] = db.C(cName)
 				}
+				// 在副本上展开数据，以免影响同时采用的其他输出方式
+				doc := make(map[string]interface{}, len(datacell))
+				for k, v := range datacell {
+					doc[k] = v
+				}
 				for k, v := range datacell["Data"].(map[string]interface{}) {
-					datacell[k] = v
+					doc[k] = v
 				}
-				delete(datacell, "Data")
-				delete(datacell, "RuleName")
+				delete(doc, "Data")
+				delete(doc, "RuleName")
 				if !self.Spider.OutDefaultField() {
-					delete(datacell, "Url")
-					delete(datacell, "ParentUrl")
-					delete(datacell, "DownloadTime")
+					delete(doc, "Url")
+					delete(doc, "ParentUrl")
+					delete(doc, "DownloadTime")
 				}
-				dataMap[subNamespace] = append(dataMap[subNamespace], datacell)
+				dataMap[subNamespace] = append(dataMap[subNamespace], doc)
 			}
 
+			// 各集合分别写入，遇到重复键时按run::onduplicate处理，其中之一失败时不影响其余集合
+			var errs []string
 			for collection, docs := range dataMap {
-				c := collections[collection]
-				count := len(docs)
-				loop := count / mgo.MaxLen
-				for i := 0; i < loop; i++ {
-					err = c.Insert(docs[i*mgo.MaxLen : (i+1)*mgo.MaxLen]...)
-					if err != nil {
-						logs.Log.Error("%v", err)
-					}
-				}
-				if count%mgo.MaxLen == 0 {
-					continue
-				}
-				err = c.Insert(docs[loop*mgo.MaxLen:]...)
-				if err != nil {
-					logs.Log.Error("%v", err)
+				if err = mgo.InsertDocs(collections[collection], docs, cache.Task.OnDuplicate); err != nil {
+					logs.Log.Error("MongoDB：%v：%v\n", collection, err)
+					errs = append(errs, collection+": "+err.Error())
 				}
 			}
-
+			if len(errs) > 0 {
+				return fmt.Errorf("%v", strings.Join(errs, "; "))
+			}
 			return nil
 		})
 	}
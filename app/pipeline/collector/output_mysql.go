@@ -2,11 +2,13 @@ package collector
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/henrylee2cn/pholcus/common/mysql"
 	"github.com/henrylee2cn/pholcus/common/util"
 	"github.com/henrylee2cn/pholcus/logs"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
 )
 
 /************************ Mysql 输出 ***************************/
@@ -30,6 +32,13 @@ func init() {
 		mysqlTableLock.Unlock()
 	}
 
+	DataCheck["mysql"] = func() error {
+		if err := mysql.Ping(); err != nil {
+			return fmt.Errorf("Mysql数据库链接失败: %v", err)
+		}
+		return nil
+	}
+
 	DataOutput["mysql"] = func(self *Collector, dataIndex int) error {
 		_, err := mysql.DB()
 		if err != nil {
@@ -46,6 +55,8 @@ func init() {
 			if !ok {
 				table, ok = getMysqlTable(tName)
 				if ok {
+					// 多个输出协程可能同时写入同一表单，故各自使用副本
+					table = table.Clone().SetOnDuplicate(cache.Task.OnDuplicate)
 					mysqls[tName] = table
 				} else {
 					table = mysql.New()
@@ -61,6 +72,7 @@ func init() {
 						continue
 					} else {
 						setMysqlTable(tName, table)
+						table = table.Clone().SetOnDuplicate(cache.Task.OnDuplicate)
 						mysqls[tName] = table
 					}
 				}
@@ -79,10 +91,18 @@ func init() {
 			}
 			table.AutoInsert(data)
 		}
-		for _, tab := range mysqls {
-			util.CheckErr(tab.FlushInsert())
+		// 各表单分别写入，其中之一失败时不影响其余表单
+		var errs []string
+		for tName, tab := range mysqls {
+			if err := tab.FlushInsert(); err != nil {
+				logs.Log.Error("Mysql：%v：%v\n", tName, err)
+				errs = append(errs, tName+": "+err.Error())
+			}
 		}
 		mysqls = nil
+		if len(errs) > 0 {
+			return fmt.Errorf("%v", strings.Join(errs, "; "))
+		}
 		return nil
 	}
 }
@@ -1,7 +1,15 @@
 package collector
 
 import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/henrylee2cn/pholcus/app/pipeline/collector/data"
+	"github.com/henrylee2cn/pholcus/common/util"
+	"github.com/henrylee2cn/pholcus/config"
 	"github.com/henrylee2cn/pholcus/logs"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
 )
 
 // 主命名空间相对于数据库名，不依赖具体数据内容，可选
@@ -28,6 +36,63 @@ func (self *Collector) subNamespace(dataCell map[string]interface{}) string {
 	return self.Spider.SubNamespace(self.Spider, dataCell)
 }
 
+// 将一批结果分为多段，每段输出为单独的文件：每段不超过Task.RotateRows及Spider.MaxFileRows条结果，
+// 且估算大小不超过Spider.MaxFileSize（单条结果即已超出时独占一段），均未设置或未超出时整批为一段
+func (self *Collector) rotateChunks(cells []data.DataCell) [][]data.DataCell {
+	rows := cache.Task.RotateRows
+	if n := self.Spider.MaxFileRows; n > 0 && (rows <= 0 || n < rows) {
+		rows = n
+	}
+	maxSize := self.Spider.MaxFileSize
+	if (rows <= 0 || len(cells) <= rows) && maxSize <= 0 {
+		return [][]data.DataCell{cells}
+	}
+	var (
+		chunks [][]data.DataCell
+		start  int
+		size   int64
+	)
+	for i, cell := range cells {
+		n := cellSize(cell)
+		if i > start && ((rows > 0 && i-start >= rows) || (maxSize > 0 && size+n > maxSize)) {
+			chunks = append(chunks, cells[start:i])
+			start, size = i, 0
+		}
+		size += n
+	}
+	return append(chunks, cells[start:])
+}
+
+// 分段输出时第i段文件名的后缀，未分段时为空；因Spider.MaxFileRows或MaxFileSize拆分时如 _part2，否则如 _2
+func (self *Collector) rotateSuffix(i, n int) string {
+	if n <= 1 {
+		return ""
+	}
+	if self.Spider.MaxFileRows > 0 || self.Spider.MaxFileSize > 0 {
+		return fmt.Sprintf("_part%d", i+1)
+	}
+	return fmt.Sprintf("_%d", i+1)
+}
+
+// 估算一条结果输出为文本时的字节数
+func cellSize(cell data.DataCell) int64 {
+	var size int
+	if vd, ok := cell["Data"].(map[string]interface{}); ok {
+		for _, v := range vd {
+			if s, ok := v.(string); ok || v == nil {
+				size += len(s) + 1
+			} else {
+				size += len(util.JsonString(v)) + 1
+			}
+		}
+	}
+	for _, k := range []string{"Url", "ParentUrl", "DownloadTime"} {
+		s, _ := cell[k].(string)
+		size += len(s) + 1
+	}
+	return int64(size)
+}
+
 // 下划线连接主次命名空间
 func joinNamespaces(namespace, subNamespace string) string {
 	if namespace == "" {
@@ -37,3 +102,27 @@ func joinNamespaces(namespace, subNamespace string) string {
 	}
 	return namespace
 }
+
+// 文件结果的输出目录，Spider.OutDir为空时采用全局的config.FILE_DIR
+func (self *Collector) fileDir() string {
+	return self.outDir(config.FILE_DIR)
+}
+
+// excel、csv等文本结果的输出目录，Spider.OutDir为空时采用全局的config.TEXT_DIR
+func (self *Collector) textDir() string {
+	return self.outDir(config.TEXT_DIR)
+}
+
+// 按Spider.OutDir模板生成该蜘蛛的输出目录，未设置时返回全局目录
+func (self *Collector) outDir(global string) string {
+	if self.Spider.OutDir == "" {
+		return global
+	}
+	replacer := strings.NewReplacer(
+		"{namespace}", util.FileNameReplace(self.namespace()),
+		"{spider}", util.FileNameReplace(self.Spider.GetName()),
+		"{keyin}", util.FileNameReplace(self.Spider.GetKeyin()),
+		"{date}", cache.StartTime.Format("2006-01-02"),
+	)
+	return filepath.Clean(replacer.Replace(self.Spider.OutDir))
+}
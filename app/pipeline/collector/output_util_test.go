@@ -0,0 +1,60 @@
+package collector
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/henrylee2cn/pholcus/app/pipeline/collector/data"
+	"github.com/henrylee2cn/pholcus/app/spider"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+func testCells(n int, value string) []data.DataCell {
+	cells := make([]data.DataCell, n)
+	for i := range cells {
+		cells[i] = data.DataCell{"Data": map[string]interface{}{"v": value}}
+	}
+	return cells
+}
+
+func chunkLens(chunks [][]data.DataCell) []int {
+	lens := make([]int, len(chunks))
+	for i, chunk := range chunks {
+		lens[i] = len(chunk)
+	}
+	return lens
+}
+
+// 按run::rotaterows与Spider.MaxFileRows中较小者及Spider.MaxFileSize分段，并按拆分原因命名
+func TestRotateChunks(t *testing.T) {
+	defer func(n int) { cache.Task.RotateRows = n }(cache.Task.RotateRows)
+	for _, c := range []struct {
+		name    string
+		rows    int
+		sp      *spider.Spider
+		cells   []data.DataCell
+		want    string
+		suffix2 string
+	}{
+		{name: "none", cells: testCells(5, "a"), want: "[5]"},
+		{name: "rotaterows", rows: 2, cells: testCells(5, "a"), want: "[2 2 1]", suffix2: "_2"},
+		{name: "smaller MaxFileRows", rows: 4, sp: &spider.Spider{MaxFileRows: 3}, cells: testCells(5, "a"), want: "[3 2]", suffix2: "_part2"},
+		{name: "smaller rotaterows", rows: 2, sp: &spider.Spider{MaxFileRows: 3}, cells: testCells(5, "a"), want: "[2 2 1]", suffix2: "_part2"},
+		// 每条结果约14字节
+		{name: "MaxFileSize", sp: &spider.Spider{MaxFileSize: 30}, cells: testCells(5, "abcdefghij"), want: "[2 2 1]", suffix2: "_part2"},
+		{name: "oversized cell", sp: &spider.Spider{MaxFileSize: 5}, cells: testCells(2, "abcdefghij"), want: "[1 1]", suffix2: "_part2"},
+	} {
+		cache.Task.RotateRows = c.rows
+		if c.sp == nil {
+			c.sp = &spider.Spider{}
+		}
+		self := &Collector{Spider: c.sp}
+		chunks := self.rotateChunks(c.cells)
+		if got := fmt.Sprint(chunkLens(chunks)); got != c.want {
+			t.Errorf("%s: chunks %v, want %v", c.name, got, c.want)
+		}
+		if got := self.rotateSuffix(1, len(chunks)); got != c.suffix2 {
+			t.Errorf("%s: second suffix %q, want %q", c.name, got, c.suffix2)
+		}
+	}
+}
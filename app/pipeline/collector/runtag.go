@@ -0,0 +1,19 @@
+package collector
+
+import (
+	"github.com/henrylee2cn/pholcus/app/pipeline/collector/data"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+// 开启run::runtag时，为结果附带本次运行的ID及开始时间，以便按运行筛选写入同一表单的数据；
+// 作为DataTransforms的转换函数执行，字段名见data.RUN_ID_FIELD与data.RUN_TIME_FIELD
+func TagRun(dataCell data.DataCell) data.DataCell {
+	if !cache.Task.RunTag {
+		return dataCell
+	}
+	if fields, ok := dataCell["Data"].(map[string]interface{}); ok {
+		fields[data.RUN_ID_FIELD] = cache.RunId
+		fields[data.RUN_TIME_FIELD] = cache.StartTime.Format("2006-01-02 15:04:05")
+	}
+	return dataCell
+}
@@ -0,0 +1,102 @@
+package collector
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/henrylee2cn/pholcus/common/util"
+	"github.com/henrylee2cn/pholcus/logs"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+type (
+	// 结果的说明，开启run::outputschema时于任务开始时输出，供下游使用者了解结果结构
+	Schema struct {
+		Spider    string       // 蜘蛛名
+		Keyin     string       `json:",omitempty"` // 自定义输入
+		OutType   string       // 输出方式，多个时以逗号分隔
+		StartTime string       // 任务开始时间
+		Rules     []RuleSchema // 输出文本结果的规则，按规则名排序
+	}
+	// 单个规则的结果说明
+	RuleSchema struct {
+		Rule   string        // 规则名
+		Table  string        `json:",omitempty"` // 数据库输出方式下的表名或集合名，设置了Spider.SubNamespace时依数据内容而定，为空
+		Fields []FieldSchema // 字段列表，顺序同输出
+	}
+	// 字段说明
+	FieldSchema struct {
+		Name   string // 字段名
+		Column string `json:",omitempty"` // 输出中的列名，与字段名不同时填写
+		Type   string // 字段类型，非字符串的值以json字符串输出
+		DbType string `json:",omitempty"` // mysql输出方式下列的类型
+	}
+)
+
+// 默认添加的字段及其在excel、csv中的列名与mysql中的类型
+var defaultFields = []FieldSchema{
+	{Name: "Url", Column: "当前链接", Type: "string", DbType: "VARCHAR(255)"},
+	{Name: "ParentUrl", Column: "上级链接", Type: "string", DbType: "VARCHAR(255)"},
+	{Name: "DownloadTime", Column: "下载时间", Type: "string", DbType: "VARCHAR(50)"},
+}
+
+// 生成结果说明，仅包含设置了ItemFields的规则
+func (self *Collector) Schema() *Schema {
+	schema := &Schema{
+		Spider:    self.Spider.GetName(),
+		Keyin:     self.Spider.GetKeyin(),
+		OutType:   strings.Join(self.outTypes, ","),
+		StartTime: cache.StartTime.Format("2006-01-02 15:04:05"),
+	}
+	var (
+		namespace = util.FileNameReplace(self.namespace())
+		isDb      = self.hasOutType("mysql", "mgo")
+	)
+	for name, rule := range self.Spider.RuleTree.Trunk {
+		if len(rule.ItemFields) == 0 {
+			continue
+		}
+		ruleSchema := RuleSchema{Rule: name}
+		if isDb && self.Spider.SubNamespace == nil {
+			ruleSchema.Table = joinNamespaces(namespace, util.FileNameReplace(name))
+		}
+		for _, field := range rule.ItemFields {
+			ruleSchema.Fields = append(ruleSchema.Fields, FieldSchema{Name: field, Type: "string", DbType: "MEDIUMTEXT"})
+		}
+		if self.Spider.OutDefaultField() {
+			ruleSchema.Fields = append(ruleSchema.Fields, defaultFields...)
+		}
+		for i := range ruleSchema.Fields {
+			field := &ruleSchema.Fields[i]
+			if !self.hasOutType("csv", "excel") {
+				field.Column = ""
+			}
+			if !self.hasOutType("mysql") {
+				field.DbType = ""
+			}
+		}
+		schema.Rules = append(schema.Rules, ruleSchema)
+	}
+	sort.Slice(schema.Rules, func(i, j int) bool { return schema.Rules[i].Rule < schema.Rules[j].Rule })
+	return schema
+}
+
+// 在文本结果目录输出结果说明文件，于本次任务的excel、csv结果同一目录下
+func (self *Collector) outputSchema() {
+	folder := self.textDir() + "/" + cache.StartTime.Format("2006年01月02日 15时04分05秒")
+	fileName := folder + "/" + util.FileNameReplace(self.namespace()) + "__schema.json"
+	b, err := json.MarshalIndent(self.Schema(), "", "  ")
+	if err == nil {
+		if err = os.MkdirAll(folder, 0777); err == nil {
+			err = ioutil.WriteFile(fileName, b, 0777)
+		}
+	}
+	if err != nil {
+		logs.Log.Error(" *     Fail  [结果说明输出：%v]: %v\n", self.Spider.GetName(), err)
+		return
+	}
+	logs.Log.Informational(" *     [结果说明输出：%v]: %v\n", self.Spider.GetName(), fileName)
+}
@@ -0,0 +1,58 @@
+package collector
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/henrylee2cn/pholcus/app/pipeline/collector/data"
+	"github.com/henrylee2cn/pholcus/logs"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+// 结果中非法UTF-8字符串的处理方式，由配置项run::invalidutf8选择
+const (
+	UTF8_NONE    = "none"    // 不处理
+	UTF8_REPLACE = "replace" // 将非法字节序列替换为U+FFFD
+	UTF8_STRIP   = "strip"   // 删除非法字节序列
+	UTF8_ERROR   = "error"   // 丢弃整条数据并记录错误
+)
+
+// 按配置的方式处理文本数据中的非法UTF-8字符串，以免个别字段导致整批输出失败；
+// 作为DataTransforms的首个转换函数执行，丢弃数据时返回nil
+func SanitizeUTF8(dataCell data.DataCell) data.DataCell {
+	mode := cache.Task.InvalidUTF8
+	if mode == "" || mode == UTF8_NONE {
+		return dataCell
+	}
+	var invalid string
+	sanitize := func(s string) string {
+		if utf8.ValidString(s) {
+			return s
+		}
+		invalid = s
+		switch mode {
+		case UTF8_REPLACE:
+			return strings.ToValidUTF8(s, string(utf8.RuneError))
+		case UTF8_STRIP:
+			return strings.ToValidUTF8(s, "")
+		}
+		return s
+	}
+	if fields, ok := dataCell["Data"].(map[string]interface{}); ok {
+		for k, v := range fields {
+			if s, ok := v.(string); ok {
+				fields[k] = sanitize(s)
+			}
+		}
+	}
+	for _, k := range []string{"Url", "ParentUrl"} {
+		if s, ok := dataCell[k].(string); ok {
+			dataCell[k] = sanitize(s)
+		}
+	}
+	if invalid != "" && mode == UTF8_ERROR {
+		logs.Log.Error(" *     [%v] 结果含有非法UTF-8字符串 %q，已丢弃该条数据\n", dataCell["Url"], invalid)
+		return nil
+	}
+	return dataCell
+}
@@ -0,0 +1,89 @@
+package pipeline
+
+import (
+	"sync"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+	"github.com/henrylee2cn/pholcus/app/pipeline/collector"
+	"github.com/henrylee2cn/pholcus/app/pipeline/collector/data"
+	"github.com/henrylee2cn/pholcus/app/spider"
+)
+
+// 内存收集管道，将结果保存在内存中而不输出至外部，供嵌入其他程序时使用；
+// 亦作为输出方式memory的缓冲区，由每次运行各自创建
+type Memory struct {
+	spider *spider.Spider
+	items  []data.DataCell
+	files  []data.FileCell
+	lock   sync.Mutex
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (self *Memory) Init(sp *spider.Spider) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.spider = sp
+	self.items = nil
+	self.files = nil
+}
+
+func (self *Memory) Start() {}
+
+func (self *Memory) Stop() {}
+
+func (self *Memory) CollectData(dataCell data.DataCell) {
+	// 依次执行转换函数
+	for _, transform := range collector.DataTransforms {
+		if dataCell = transform(dataCell); dataCell == nil {
+			return
+		}
+	}
+	// 超出结果数据单元采集上限时丢弃
+	ruleName, _ := dataCell["RuleName"].(string)
+	if !self.spider.AddItemCount(ruleName) {
+		return
+	}
+	self.lock.Lock()
+	self.items = append(self.items, dataCell)
+	self.lock.Unlock()
+}
+
+func (self *Memory) CollectFile(fileCell data.FileCell) {
+	self.lock.Lock()
+	self.files = append(self.files, fileCell)
+	self.lock.Unlock()
+}
+
+// 文件不落盘，总是返回false
+func (self *Memory) HasFile(*request.Request) bool {
+	return false
+}
+
+// 文件不落盘，总是返回0
+func (self *Memory) PartSize(*request.Request) int64 {
+	return 0
+}
+
+// 追加文本数据，供输出方式memory使用
+func (self *Memory) AppendItems(cells []data.DataCell) {
+	self.lock.Lock()
+	self.items = append(self.items, cells...)
+	self.lock.Unlock()
+}
+
+// 返回已收集的文本数据
+func (self *Memory) Items() []data.DataCell {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return append([]data.DataCell(nil), self.items...)
+}
+
+// 返回已收集的文件
+func (self *Memory) Files() []data.FileCell {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return append([]data.FileCell(nil), self.files...)
+}
@@ -2,10 +2,14 @@ package pipeline
 
 import (
 	"sort"
+	"time"
 
 	"github.com/henrylee2cn/pholcus/app/pipeline/collector"
+	"github.com/henrylee2cn/pholcus/app/pipeline/collector/data"
+	"github.com/henrylee2cn/pholcus/common/gsheets"
 	"github.com/henrylee2cn/pholcus/common/mgo"
 	"github.com/henrylee2cn/pholcus/common/mysql"
+	"github.com/henrylee2cn/pholcus/logs"
 	"github.com/henrylee2cn/pholcus/runtime/cache"
 )
 
@@ -17,12 +21,51 @@ func init() {
 	sort.Strings(collector.DataOutputLib)
 }
 
+// 添加文本数据的转换函数，在数据进入收集管道前按添加顺序依次执行，
+// 可用于统一地补充、脱敏或计算字段，返回nil时丢弃该数据；须在任务开始前添加
+func AddTransform(transform func(data.DataCell) data.DataCell) {
+	collector.DataTransforms = append(collector.DataTransforms, transform)
+}
+
 // 刷新输出方式的状态
 func RefreshOutput() {
-	switch cache.Task.OutType {
-	case "mgo":
-		mgo.Refresh()
-	case "mysql":
-		mysql.Refresh()
+	for _, outType := range cache.OutTypes() {
+		switch outType {
+		case "mgo":
+			mgo.Refresh()
+		case "mysql":
+			mysql.Refresh()
+		case "gsheets":
+			gsheets.Refresh()
+		}
+	}
+}
+
+// 依次以collector.DataCheck检查所选输出方式的目标是否可用，以免抓取完成后才发现无法写入，未注册检查的输出方式视为可用；
+// cache.Task.OutputWait大于0时，在该秒数内每隔数秒重试全部检查，以等待目标恢复
+func CheckOutput() error {
+	deadline := time.Now().Add(time.Duration(cache.Task.OutputWait) * time.Second)
+	for {
+		err := checkOutput()
+		wait := time.Until(deadline)
+		if err == nil || wait <= 0 {
+			return err
+		}
+		if wait > 3*time.Second {
+			wait = 3 * time.Second
+		}
+		logs.Log.Warning(" *     输出目标暂不可用，%v 后重试: %v\n", wait.Round(time.Second), err)
+		time.Sleep(wait)
+	}
+}
+
+func checkOutput() error {
+	for _, outType := range cache.OutTypes() {
+		if check, ok := collector.DataCheck[outType]; ok {
+			if err := check(); err != nil {
+				return err
+			}
+		}
 	}
+	return nil
 }
@@ -0,0 +1,35 @@
+package pipeline
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/henrylee2cn/pholcus/app/pipeline/collector"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+// 所选输出方式中注册了检查的均被检查，OutputWait内重试直至恢复，为0时立即返回错误
+func TestCheckOutput(t *testing.T) {
+	defer func(outType string, wait int64) {
+		cache.Task.OutType, cache.Task.OutputWait = outType, wait
+	}(cache.Task.OutType, cache.Task.OutputWait)
+	var calls int
+	collector.DataCheck["flaky"] = func() error {
+		if calls++; calls == 1 {
+			return errors.New("unavailable")
+		}
+		return nil
+	}
+	defer delete(collector.DataCheck, "flaky")
+
+	cache.Task.OutType, cache.Task.OutputWait = "csv,flaky", 0
+	if err := CheckOutput(); err == nil || calls != 1 {
+		t.Fatalf("err = %v after %d checks, want an error after 1", err, calls)
+	}
+
+	calls = 0
+	cache.Task.OutputWait = 1
+	if err := CheckOutput(); err != nil || calls != 2 {
+		t.Fatalf("err = %v after %d checks, want success after 2", err, calls)
+	}
+}
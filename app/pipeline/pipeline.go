@@ -2,6 +2,7 @@
 package pipeline
 
 import (
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
 	"github.com/henrylee2cn/pholcus/app/pipeline/collector"
 	"github.com/henrylee2cn/pholcus/app/pipeline/collector/data"
 	"github.com/henrylee2cn/pholcus/app/spider"
@@ -9,11 +10,13 @@ import (
 
 // 数据收集/输出管道
 type Pipeline interface {
-	Start()                    //启动
-	Stop()                     //停止
-	CollectData(data.DataCell) //收集数据单元
-	CollectFile(data.FileCell) //收集文件
-	Init(*spider.Spider)       //重置
+	Start()                          //启动
+	Stop()                           //停止
+	CollectData(data.DataCell)       //收集数据单元
+	CollectFile(data.FileCell)       //收集文件
+	HasFile(*request.Request) bool   //请求所对应的文件是否已输出过
+	PartSize(*request.Request) int64 //请求所对应的文件已下载部分的大小，用于断点续传
+	Init(*spider.Spider)             //重置
 }
 
 func New() Pipeline {
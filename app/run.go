@@ -0,0 +1,86 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/henrylee2cn/pholcus/app/crawler"
+	"github.com/henrylee2cn/pholcus/app/downloader"
+	"github.com/henrylee2cn/pholcus/app/pipeline"
+	"github.com/henrylee2cn/pholcus/app/scheduler"
+	"github.com/henrylee2cn/pholcus/app/spider"
+	"github.com/henrylee2cn/pholcus/logs"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+// LogicApp.Run()与RunSpider()同一时刻只能执行其一，二者均改动全局的运行参数及调度器状态
+var runLock sync.Mutex
+
+// 阻塞式运行单个蜘蛛直至完成，并返回收集到的文本数据，不输出至外部也不依赖界面；
+// keyin为空时采用蜘蛛自身的Keyin，limit的含义同AppConf.Limit，其余运行参数取自cache.Task；
+// ctx被取消时终止任务，并返回已收集的数据及ctx.Err()；
+// sp须为经Register()注册的蜘蛛；LogicApp或其他RunSpider正在运行任务时返回错误；
+// 运行结束后恢复cache.Task及本次运行的开始时间与ID，调度器状态在下次运行开始时重置
+func RunSpider(ctx context.Context, sp *spider.Spider, keyin string, limit int64) ([]map[string]interface{}, error) {
+	if sp == nil {
+		return nil, errors.New("蜘蛛不能为nil")
+	}
+	if !runLock.TryLock() {
+		return nil, errors.New("当前有任务正在运行")
+	}
+	defer runLock.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	task, startTime, runId := *cache.Task, cache.StartTime, cache.RunId
+	defer func() {
+		*cache.Task = task
+		cache.StartTime, cache.RunId = startTime, runId
+	}()
+
+	spcopy := sp.Copy()
+	spcopy.SetPausetime(cache.Task.Pausetime)
+	if keyin != "" {
+		spcopy.SetKeyin(keyin)
+	}
+	if spcopy.GetLimit() == spider.LIMIT {
+		spcopy.SetLimit(limit)
+	} else {
+		spcopy.SetLimit(-1 * limit)
+	}
+
+	cache.ResetPageCount()
+	scheduler.Init()
+	downloader.SurferDownloader.Refresh()
+	cache.StartRun()
+
+	var (
+		mem  = pipeline.NewMemory()
+		c    = crawler.NewWithPipeline(0, mem).Init(spcopy)
+		done = make(chan bool)
+	)
+	go func() {
+		select {
+		case <-ctx.Done():
+			logs.Log.Informational(" *     [%v] 任务被取消: %v\n", spcopy.GetName(), ctx.Err())
+			c.Stop()
+		case <-done:
+		}
+	}()
+	c.Run()
+	close(done)
+
+	cells := mem.Items()
+	items := make([]map[string]interface{}, len(cells))
+	for i, cell := range cells {
+		items[i] = cell
+	}
+	if err := ctx.Err(); err != nil {
+		return items, err
+	}
+	if cache.GetPageCount(1) == 0 && cache.GetPageCount(-1) > 0 {
+		return items, errors.New("全部请求均失败")
+	}
+	return items, nil
+}
@@ -0,0 +1,17 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/henrylee2cn/pholcus/app/spider"
+)
+
+// 已有任务运行时RunSpider立即返回错误，而不等待或并行执行
+func TestRunSpiderBusy(t *testing.T) {
+	runLock.Lock()
+	defer runLock.Unlock()
+	if _, err := RunSpider(context.Background(), &spider.Spider{Name: "busy"}, "", 0); err == nil {
+		t.Fatal("RunSpider ran while another run was in progress")
+	}
+}
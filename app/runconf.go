@@ -0,0 +1,136 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/henrylee2cn/pholcus/app/pipeline/collector"
+	"github.com/henrylee2cn/pholcus/app/scheduler"
+	"github.com/henrylee2cn/pholcus/app/spider"
+	"github.com/henrylee2cn/pholcus/config"
+	"github.com/henrylee2cn/pholcus/logs"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+type (
+	// 可导出并重放的运行配置，包含全部生效的运行参数及所选蜘蛛，用于复现或分享一次抓取
+	RunConf struct {
+		Spiders []RunMember   // 蜘蛛队列的成员，按执行顺序排列
+		Task    cache.AppConf // 生效的全局运行配置，自定义配置Keyins亦在其中
+	}
+	// 运行配置中蜘蛛队列的成员，同一蜘蛛按不同Keyin执行时各为一项
+	RunMember struct {
+		Name  string // 蜘蛛名称
+		Keyin string `json:",omitempty"` // 该成员生效的Keyin，为空时按Task.Keyins分配
+	}
+)
+
+// 兼容仅以蜘蛛名称字符串表示成员的旧格式
+func (self *RunMember) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		*self = RunMember{}
+		return json.Unmarshal(b, &self.Name)
+	}
+	type runMember RunMember
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode((*runMember)(self))
+}
+
+// 将当前生效的运行配置及蜘蛛队列中的蜘蛛（含各自生效的Keyin）以json格式保存至文件，须在SpiderPrepare()之后调用
+func (self *Logic) SaveRunConf(fileName string) error {
+	conf := RunConf{
+		Spiders: []RunMember{},
+		Task:    *self.AppConf,
+	}
+	for _, sp := range self.SpiderQueue.GetAll() {
+		member := RunMember{Name: sp.GetName()}
+		if keyin := sp.GetKeyin(); keyin != spider.KEYIN {
+			member.Keyin = keyin
+		}
+		conf.Spiders = append(conf.Spiders, member)
+	}
+	b, err := json.MarshalIndent(conf, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(fileName, b, 0644); err != nil {
+		return err
+	}
+	logs.Log.Informational(" *     运行配置已保存至: %v\n", fileName)
+	return nil
+}
+
+// 读取SaveRunConf()导出的运行配置，含未知字段、字段类型不符或取值无效时返回错误
+func ReadRunConf(fileName string) (*RunConf, error) {
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	conf := new(RunConf)
+	if err = decoder.Decode(conf); err != nil {
+		return nil, fmt.Errorf("运行配置 %v 格式错误: %v", fileName, err)
+	}
+	if err = conf.validate(); err != nil {
+		return nil, fmt.Errorf("运行配置 %v 无效: %v", fileName, err)
+	}
+	return conf, nil
+}
+
+// 检查运行配置的取值，返回全部问题
+func (self *RunConf) validate() error {
+	var problems []string
+	check := func(ok bool, format string, a ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, a...))
+		}
+	}
+	check(len(self.Spiders) > 0, "Spiders 为空")
+	for _, member := range self.Spiders {
+		check(spider.Species.GetByName(member.Name) != nil, "蜘蛛 %q 不存在", member.Name)
+	}
+	t := &self.Task
+	check(t.ThreadNum >= 1, "Task.ThreadNum 须不小于1，当前为 %v", t.ThreadNum)
+	check(t.Pausetime >= 0, "Task.Pausetime 不可为负数，当前为 %v", t.Pausetime)
+	check(t.DockerCap >= 1, "Task.DockerCap 须不小于1，当前为 %v", t.DockerCap)
+	check(t.Limit >= 0, "Task.Limit 不可为负数，当前为 %v", t.Limit)
+	check(t.MaxFailRate >= 0 && t.MaxFailRate <= 1, "Task.MaxFailRate 须在0~1之间，当前为 %v", t.MaxFailRate)
+	for _, out := range strings.Split(t.OutType, ",") {
+		out = strings.TrimSpace(out)
+		check(out != "" && contains(collector.DataOutputLib, out), "Task.OutType 中的输出方式 %q 不存在", out)
+	}
+	check(t.Strategy == "" || t.Strategy == scheduler.STRATEGY_BFS || t.Strategy == scheduler.STRATEGY_DFS,
+		"Task.Strategy 须为 %v 或 %v，当前为 %q", scheduler.STRATEGY_BFS, scheduler.STRATEGY_DFS, t.Strategy)
+	if t.QueueStore != "" {
+		_, ok := scheduler.QueueStores[t.QueueStore]
+		check(ok, "Task.QueueStore 中的存储后端 %q 不存在", t.QueueStore)
+	}
+	if t.Profile != "" {
+		_, ok := config.Profiles[t.Profile]
+		check(ok, "Task.Profile 中的礼貌度预设 %q 不存在", t.Profile)
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
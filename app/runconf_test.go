@@ -0,0 +1,54 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/henrylee2cn/pholcus/app/spider"
+)
+
+// 保存的运行配置含各成员生效的Keyin，文件权限为0644
+func TestSaveRunConf(t *testing.T) {
+	logic := newLogic()
+	for _, keyin := range []string{"go", "rust", spider.KEYIN} {
+		logic.SpiderQueue.Add(&spider.Spider{Name: "a", Keyin: keyin})
+	}
+	logic.SpiderQueue.Add(&spider.Spider{Name: "b"})
+	fileName := filepath.Join(t.TempDir(), "conf", "run.json")
+	if err := logic.SaveRunConf(fileName); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0644 {
+		t.Errorf("file mode = %v, want 0644", mode)
+	}
+	b, _ := os.ReadFile(fileName)
+	var conf RunConf
+	if err = json.Unmarshal(b, &conf); err != nil {
+		t.Fatal(err)
+	}
+	want := []RunMember{{"a", "go"}, {"a", "rust"}, {"a", ""}, {"b", ""}}
+	if !reflect.DeepEqual(conf.Spiders, want) {
+		t.Errorf("Spiders = %v, want %v", conf.Spiders, want)
+	}
+}
+
+// 成员可为仅含蜘蛛名称的字符串，对象中的未知字段视为错误
+func TestRunMemberJSON(t *testing.T) {
+	var members []RunMember
+	if err := json.Unmarshal([]byte(`["a",{"Name":"b","Keyin":"go"}]`), &members); err != nil {
+		t.Fatal(err)
+	}
+	if want := []RunMember{{"a", ""}, {"b", "go"}}; !reflect.DeepEqual(members, want) {
+		t.Errorf("got %v, want %v", members, want)
+	}
+	if err := json.Unmarshal([]byte(`[{"Name":"a","Keyins":"go"}]`), &members); err == nil {
+		t.Error("unknown field accepted")
+	}
+}
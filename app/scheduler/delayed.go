@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"container/heap"
+	"time"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+	"github.com/henrylee2cn/pholcus/runtime/status"
+)
+
+type (
+	// 延时请求，到期后才加入请求队列；到期前仅保存在Matrix的内存中，
+	// 不写入QueueStore，即使采用持久化的存储后端，进程退出时未到期的请求也会丢失
+	delayedRequest struct {
+		at  time.Time
+		req *request.Request
+	}
+	// 按到期时间排序的延时请求小顶堆
+	delayedQueue []delayedRequest
+)
+
+func (self delayedQueue) Len() int            { return len(self) }
+func (self delayedQueue) Less(i, j int) bool  { return self[i].at.Before(self[j].at) }
+func (self delayedQueue) Swap(i, j int)       { self[i], self[j] = self[j], self[i] }
+func (self *delayedQueue) Push(x interface{}) { *self = append(*self, x.(delayedRequest)) }
+func (self *delayedQueue) Pop() interface{} {
+	old := *self
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = delayedRequest{}
+	*self = old[:n-1]
+	return x
+}
+
+// 延时delay后再将请求加入队列，到期前不会被取出，尚有未到期的请求时任务不会停止；
+// 去重等过滤在到期入队时执行，delay不大于0时与Push()相同，并发安全；
+// 未到期的请求不持久化，也不出现在队列快照中
+func (self *Matrix) PushDelayed(req *request.Request, delay time.Duration) {
+	if delay <= 0 {
+		self.Push(req)
+		return
+	}
+	if sdl.checkStatus(status.STOP) {
+		return
+	}
+	self.Lock()
+	defer self.Unlock()
+	if self.maxPage >= 0 {
+		return
+	}
+	heap.Push(&self.delayed, delayedRequest{time.Now().Add(delay), req})
+}
+
+// 将已到期的延时请求加入队列，需在加锁状态下调用
+func (self *Matrix) releaseDelayed() {
+	now := time.Now()
+	for len(self.delayed) > 0 && !self.delayed[0].at.After(now) {
+		if self.maxPage >= 0 {
+			self.delayed = nil
+			return
+		}
+		self.push(heap.Pop(&self.delayed).(delayedRequest).req)
+	}
+}
@@ -0,0 +1,37 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+// 延时请求到期前不被取出、不写入存储后端，但计入队列长度；到期后按到期顺序取出
+func TestPushDelayed(t *testing.T) {
+	m := testMatrix(t, 10)
+	m.PushDelayed(testRequest("http://a/2", "r"), 60*time.Millisecond)
+	m.PushDelayed(testRequest("http://a/1", "r"), 30*time.Millisecond)
+
+	if req := m.Pull(); req != nil {
+		t.Fatalf("got %v before the delay, want nil", req.GetUrl())
+	}
+	if n := m.Len(); n != 2 {
+		t.Fatalf("Len() = %d, want 2", n)
+	}
+	if n := m.store.Len(); n != 0 {
+		t.Fatalf("store.Len() = %d, want 0", n)
+	}
+
+	time.Sleep(80 * time.Millisecond)
+	for _, want := range []string{"http://a/1", "http://a/2"} {
+		req := m.Pull()
+		if req == nil || req.GetUrl() != want {
+			t.Fatalf("got %v, want %v", req, want)
+		}
+	}
+
+	// 不大于0的延时等同于Push()
+	m.PushDelayed(testRequest("http://a/3", "r"), 0)
+	if req := m.Pull(); req == nil || req.GetUrl() != "http://a/3" {
+		t.Fatalf("got %v, want http://a/3", req)
+	}
+}
@@ -0,0 +1,101 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+	"github.com/henrylee2cn/pholcus/logs"
+)
+
+// 队列中待处理请求的快照信息
+type QueueItem struct {
+	Spider   string        // 所属Spider
+	Rule     string        // 所属规则
+	Url      string        // 请求地址
+	Method   string        // 请求方法
+	Priority int           // 优先级
+	TryTimes int           // 单次下载的最大尝试次数
+	Failed   bool          // 是否为失败后重新入队的请求
+	Waiting  time.Duration // 已在队列中等待的时长
+}
+
+// 返回当前待处理请求的快照，按优先级从高到低排列，并发安全
+func (self *Matrix) Dump() []QueueItem {
+	// 先复制失败记录，避免与CanStop()中的加锁顺序相反而死锁
+	self.failureLock.Lock()
+	failures := make(map[string]bool, len(self.failures))
+	for reqUnique := range self.failures {
+		failures[reqUnique] = true
+	}
+	self.failureLock.Unlock()
+
+	self.Lock()
+	defer self.Unlock()
+
+	var (
+		items = []QueueItem{}
+		now   = time.Now()
+	)
+	// 存储后端不支持遍历时，快照为空
+	walker, ok := self.store.(QueueWalker)
+	if !ok {
+		return items
+	}
+	walker.Walk(func(req *request.Request, pushTime time.Time) {
+		items = append(items, QueueItem{
+			Spider:   self.spiderName,
+			Rule:     req.GetRuleName(),
+			Url:      req.GetUrl(),
+			Method:   req.GetMethod(),
+			Priority: req.GetPriority(),
+			TryTimes: req.GetTryTimes(),
+			Failed:   failures[req.Unique()],
+			Waiting:  now.Sub(pushTime),
+		})
+	})
+	return items
+}
+
+// 返回所有Spider实例的待处理请求快照，并发安全
+func Dump() []QueueItem {
+	sdl.RLock()
+	matrices := make([]*Matrix, len(sdl.matrices))
+	copy(matrices, sdl.matrices)
+	sdl.RUnlock()
+
+	items := []QueueItem{}
+	for _, matrix := range matrices {
+		items = append(items, matrix.Dump()...)
+	}
+	return items
+}
+
+// 将所有待处理请求的快照以json格式写入文件
+func DumpFile(fileName string) error {
+	err := writeJSONFile(fileName, Dump())
+	if err == nil {
+		logs.Log.Informational(" *     请求队列快照已保存至: %v\n", fileName)
+	}
+	return err
+}
+
+// 将v以缩进的json格式写入文件
+func writeJSONFile(fileName string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(fileName), 0777); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0777)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(b)
+	return err
+}
@@ -0,0 +1,32 @@
+//go:build !windows
+// +build !windows
+
+package scheduler
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/henrylee2cn/pholcus/config"
+	"github.com/henrylee2cn/pholcus/logs"
+)
+
+// 收到SIGUSR1信号时，将请求队列及执行中请求的快照保存至缓存目录，用于排查任务卡住的原因
+func init() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGUSR1)
+	go func() {
+		for range c {
+			now := time.Now().Format("20060102150405")
+			if err := DumpFile(filepath.Join(config.CACHE_DIR, "queue_"+now+".json")); err != nil {
+				logs.Log.Error(" *     请求队列快照保存失败: %v\n", err)
+			}
+			if err := InflightFile(filepath.Join(config.CACHE_DIR, "inflight_"+now+".json")); err != nil {
+				logs.Log.Error(" *     执行中请求快照保存失败: %v\n", err)
+			}
+		}
+	}()
+}
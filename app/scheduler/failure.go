@@ -0,0 +1,171 @@
+package scheduler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+	"github.com/henrylee2cn/pholcus/app/event"
+	"github.com/henrylee2cn/pholcus/logs"
+)
+
+// 失败请求的报告记录
+type FailureRecord struct {
+	Spider  string // 所属Spider
+	Rule    string // 所属规则
+	Method  string // 请求方法
+	Url     string // 请求地址
+	Error   string // 最近一次失败的原因
+	Class   string // 最近一次失败的类型，见request.ERR_*
+	Tries   int    // 失败次数
+	Request string // 序列化的请求，可通过request.UnSerialize()还原后重新入队
+}
+
+// 本次任务的失败请求报告
+var failureReport = struct {
+	records map[string]*FailureRecord // [reqUnique]*FailureRecord
+	order   []string                  // 首次失败的先后顺序
+	sync.Mutex
+}{
+	records: make(map[string]*FailureRecord),
+}
+
+// 记录一次请求失败及其原因，并发安全
+func RecordFailure(req *request.Request, err error) {
+	failureReport.Lock()
+	defer failureReport.Unlock()
+	record, ok := failureReport.records[req.Unique()]
+	if !ok {
+		record = &FailureRecord{
+			Spider: req.GetSpiderName(),
+			Rule:   req.GetRuleName(),
+			Method: req.GetMethod(),
+			Url:    req.GetUrl(),
+		}
+		failureReport.records[req.Unique()] = record
+		failureReport.order = append(failureReport.order, req.Unique())
+	}
+	if err != nil {
+		record.Error = err.Error()
+		record.Class = request.ErrorClass(err)
+	}
+	record.Tries++
+	record.Request = req.Serialize()
+
+	event.Emit(event.Event{
+		Type:   event.PAGE_FAIL,
+		Spider: record.Spider,
+		Rule:   record.Rule,
+		Url:    record.Url,
+		Method: record.Method,
+		Class:  record.Class,
+		Error:  record.Error,
+	})
+}
+
+// 失败的请求重试成功后，移除其失败记录
+func removeFailure(reqUnique string) {
+	failureReport.Lock()
+	defer failureReport.Unlock()
+	if _, ok := failureReport.records[reqUnique]; !ok {
+		return
+	}
+	delete(failureReport.records, reqUnique)
+	for i, u := range failureReport.order {
+		if u == reqUnique {
+			failureReport.order = append(failureReport.order[:i], failureReport.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// 清空失败请求报告
+func resetFailures() {
+	failureReport.Lock()
+	failureReport.records = make(map[string]*FailureRecord)
+	failureReport.order = []string{}
+	failureReport.Unlock()
+}
+
+// 返回本次任务的失败请求报告，按首次失败的先后排列，并发安全
+func Failures() []FailureRecord {
+	failureReport.Lock()
+	defer failureReport.Unlock()
+	records := make([]FailureRecord, 0, len(failureReport.order))
+	for _, reqUnique := range failureReport.order {
+		records = append(records, *failureReport.records[reqUnique])
+	}
+	return records
+}
+
+// 将失败请求报告写入 fileName.json 与 fileName.csv
+func FlushFailures(fileName string) error {
+	records := Failures()
+	if err := os.MkdirAll(filepath.Dir(fileName), 0777); err != nil {
+		return err
+	}
+
+	// json
+	b, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(fileName+".json", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0777)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	// csv
+	f, err = os.OpenFile(fileName+".csv", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0777)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	f.WriteString("\xEF\xBB\xBF") // 写入UTF-8 BOM
+	w := csv.NewWriter(f)
+	w.Write([]string{"Spider", "Rule", "Method", "Url", "Error", "Class", "Tries"})
+	for _, r := range records {
+		w.Write([]string{r.Spider, r.Rule, r.Method, r.Url, r.Error, r.Class, strconv.Itoa(r.Tries)})
+	}
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return err
+	}
+	logs.Log.Informational(" *     失败请求报告（%v 条）已保存至: %v.json/.csv\n", len(records), fileName)
+	return nil
+}
+
+// 读取FlushFailures()输出的json格式失败请求报告
+func ReadFailures(fileName string) ([]FailureRecord, error) {
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	records := []FailureRecord{}
+	err = json.Unmarshal(b, &records)
+	return records, err
+}
+
+// 将失败记录还原为请求，优先使用序列化的完整请求，否则依据Url、Rule、Method重建
+func (self FailureRecord) NewRequest() *request.Request {
+	if self.Request != "" {
+		if req, err := request.UnSerialize(self.Request); err == nil {
+			return req
+		}
+	}
+	return &request.Request{
+		Url:    self.Url,
+		Rule:   self.Rule,
+		Method: self.Method,
+	}
+}
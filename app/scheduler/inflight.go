@@ -0,0 +1,104 @@
+package scheduler
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+	"github.com/henrylee2cn/pholcus/logs"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+// 执行中请求的快照信息
+type InflightItem struct {
+	Spider  string        // 所属Spider
+	Rule    string        // 所属规则
+	Url     string        // 请求地址
+	Method  string        // 请求方法
+	Proxy   string        // 所用代理IP
+	Start   time.Time     // 开始执行的时间
+	Elapsed time.Duration // 已执行的时长
+}
+
+// 执行中的请求
+type inflightReq struct {
+	start time.Time
+	warn  *time.Timer // 超时警告的计时器，未开启警告时为nil
+}
+
+// 本次任务执行中的请求，自占用并发资源起至释放为止
+var inflight = struct {
+	reqs map[*request.Request]*inflightReq
+	sync.Mutex
+}{
+	reqs: make(map[*request.Request]*inflightReq),
+}
+
+// 清空执行中的请求记录
+func resetInflight() {
+	inflight.Lock()
+	for _, r := range inflight.reqs {
+		if r.warn != nil {
+			r.warn.Stop()
+		}
+	}
+	inflight.reqs = make(map[*request.Request]*inflightReq)
+	inflight.Unlock()
+}
+
+// 记录请求开始执行，设置了run::inflightwarn时，超过该时长仍未完成则记录警告
+func startInflight(req *request.Request) {
+	r := &inflightReq{start: time.Now()}
+	if warn := time.Duration(cache.Task.InflightWarn) * time.Second; warn > 0 {
+		r.warn = time.AfterFunc(warn, func() {
+			logs.Log.Warning(" *     [%v] 请求已执行 %v 仍未完成: %v\n", req.GetSpiderName(), warn, req.GetUrl())
+		})
+	}
+	inflight.Lock()
+	inflight.reqs[req] = r
+	inflight.Unlock()
+}
+
+// 移除请求的执行记录
+func endInflight(req *request.Request) {
+	inflight.Lock()
+	r, ok := inflight.reqs[req]
+	delete(inflight.reqs, req)
+	inflight.Unlock()
+	if ok && r.warn != nil {
+		r.warn.Stop()
+	}
+}
+
+// 返回当前执行中请求的快照，按已执行时长从长到短排列，并发安全
+func Inflight() []InflightItem {
+	now := time.Now()
+	inflight.Lock()
+	items := make([]InflightItem, 0, len(inflight.reqs))
+	for req, r := range inflight.reqs {
+		items = append(items, InflightItem{
+			Spider:  req.GetSpiderName(),
+			Rule:    req.GetRuleName(),
+			Url:     req.GetUrl(),
+			Method:  req.GetMethod(),
+			Proxy:   req.GetProxy(),
+			Start:   r.start,
+			Elapsed: now.Sub(r.start),
+		})
+	}
+	inflight.Unlock()
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Elapsed > items[j].Elapsed
+	})
+	return items
+}
+
+// 将执行中请求的快照以json格式写入文件
+func InflightFile(fileName string) error {
+	err := writeJSONFile(fileName, Inflight())
+	if err == nil {
+		logs.Log.Informational(" *     执行中请求快照已保存至: %v\n", fileName)
+	}
+	return err
+}
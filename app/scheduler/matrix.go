@@ -1,46 +1,91 @@
 package scheduler
 
 import (
+	"crypto/sha1"
+	"fmt"
 	"runtime"
-	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/henrylee2cn/pholcus/app/aid/history"
 	"github.com/henrylee2cn/pholcus/app/downloader/request"
+	"github.com/henrylee2cn/pholcus/app/event"
+	"github.com/henrylee2cn/pholcus/common/util"
 	"github.com/henrylee2cn/pholcus/logs"
 	"github.com/henrylee2cn/pholcus/runtime/cache"
 	"github.com/henrylee2cn/pholcus/runtime/status"
 )
 
+// 请求矩阵，负责一个Spider实例的请求调度，Matrix为默认实现
+// 自定义实现可通过Spider.Scheduler安装，以实现按域名公平调度等策略，爬行引擎无需改动
+type ReqMatrix interface {
+	Push(req *request.Request)                    // 添加请求到队列，并发安全
+	PushBatch(reqs []*request.Request)            // 批量添加请求到队列，并发安全
+	PushDelayed(*request.Request, time.Duration)  // 延时添加请求到队列，到期前不会被取出，并发安全
+	Pull() *request.Request                       // 从队列取出请求，不存在时返回nil，并发安全
+	Use(req *request.Request)                     // 占用处理请求所需的资源
+	TryUse(req *request.Request) bool             // 不等待地占用处理请求所需的资源，资源不足时返回false
+	Requeue(req *request.Request)                 // 放回已取出但因资源不足未能处理的请求，稍后可再取出
+	Free(req *request.Request)                    // 释放处理请求所占用的资源
+	Skip(req *request.Request)                    // 放弃已取出而不处理的请求
+	Len() int                                     // 队列中待处理的请求数
+	DoHistory(req *request.Request, ok bool) bool // 记录请求结果，返回是否作为新的失败请求被添加至队列尾部
+	DoPermanentFailure(req *request.Request)      // 记录不可重试的失败请求，不加入历史失败记录
+	WillRetry(req *request.Request) bool          // 失败的请求是否仍将重新执行，须在释放该请求的资源前调用
+	SeenCanonical(canonical string) bool          // 返回规范网址是否已处理过，未处理时将其记录
+	ForgetCanonical(canonical string)             // 移除规范网址的记录，以便失败的请求重新处理
+	SeenItem(key string) bool                     // 返回结果的幂等键是否已收集过或正在输出，否则将其标记为正在输出
+	RecordItem(key string)                        // 结果输出成功后记录其幂等键
+	ForgetItem(key string)                        // 结果未能输出时移除其幂等键的标记，以便重新收集
+	CanStop() bool                                // 所有请求是否已处理完毕
+	Wait()                                        // 等待处理中的请求完成
+	TryFlushSuccess()                             // 保存历史成功记录
+	TryFlushFailure()                             // 保存历史失败记录
+}
+
+var _ ReqMatrix = new(Matrix)
+
 // 一个Spider实例的请求矩阵
 type Matrix struct {
-	maxPage         int64                       // 最大采集页数，以负数形式表示
-	resCount        int32                       // 资源使用情况计数
-	spiderName      string                      // 所属Spider
-	reqs            map[int][]*request.Request  // [优先级]队列，优先级默认为0
-	priorities      []int                       // 优先级顺序，从低到高
-	history         history.Historier           // 历史记录
-	tempHistory     map[string]bool             // 临时记录 [reqUnique(url+method)]true
-	failures        map[string]*request.Request // 历史及本次失败请求
-	tempHistoryLock sync.RWMutex
-	failureLock     sync.Mutex
+	maxPage     int64                       // 最大采集页数，以负数形式表示
+	resCount    int32                       // 资源使用情况计数
+	spiderName  string                      // 所属Spider
+	store       QueueStore                  // 请求队列及临时记录的存储后端
+	delayed     delayedQueue                // 尚未到期的延时请求
+	history     history.Historier           // 历史记录
+	failures    map[string]*request.Request // 历史及本次失败请求
+	retries     map[string]int              // [reqUnique]失败后已重新执行的次数
+	idleSince   time.Time                   // 队列开始处于空闲状态的时间，用于停止前的宽限等待
+	created     time.Time                   // 创建时间，用于任务开始时等待首个请求
+	pulled      bool                        // 是否已取出过请求
+	seedLogged  bool                        // 是否已提示等待首个请求
+	noDedup     bool                        // 是否关闭请求去重
+	seq         int64                       // 已分配的最大入队序号
+	lanes       map[string]*serialLane      // [规则名]串行规则的先进先出队列
+	laneOrder   []string                    // 串行规则的设置顺序，用于依次检查各队列
+	requeued    []*request.Request          // 因资源不足而放回的请求，见Requeue()
+	requeueTurn bool                        // 下次取出时是否优先取放回的请求
+	outputting  map[string]bool             // 已收集但尚未输出成功的结果幂等键指纹
+	failureLock sync.Mutex
+	itemLock    sync.Mutex
 	sync.Mutex
 }
 
 func newMatrix(spiderName, spiderSubName string, maxPage int64) *Matrix {
 	matrix := &Matrix{
-		spiderName:  spiderName,
-		maxPage:     maxPage,
-		reqs:        make(map[int][]*request.Request),
-		priorities:  []int{},
-		history:     history.New(spiderName, spiderSubName),
-		tempHistory: make(map[string]bool),
-		failures:    make(map[string]*request.Request),
+		spiderName: spiderName,
+		maxPage:    maxPage,
+		store:      newQueueStore(spiderName, spiderSubName),
+		history:    history.New(spiderName, spiderSubName),
+		failures:   make(map[string]*request.Request),
+		retries:    make(map[string]int),
+		outputting: make(map[string]bool),
+		created:    time.Now(),
 	}
 	if cache.Task.Mode != status.SERVER {
-		matrix.history.ReadSuccess(cache.Task.OutType, cache.Task.SuccessInherit)
-		matrix.history.ReadFailure(cache.Task.OutType, cache.Task.FailureInherit)
+		matrix.history.ReadSuccess(cache.HistoryOutType(), cache.Task.SuccessInherit)
+		matrix.history.ReadFailure(cache.HistoryOutType(), cache.Task.FailureInherit)
 		matrix.setFailures(matrix.history.PullFailure())
 	}
 	return matrix
@@ -48,6 +93,11 @@ func newMatrix(spiderName, spiderSubName string, maxPage int64) *Matrix {
 
 // 添加请求到队列，并发安全
 func (self *Matrix) Push(req *request.Request) {
+	self.PushBatch([]*request.Request{req})
+}
+
+// 批量添加请求到队列，仅加锁一次，去重等过滤仍逐个执行，并发安全
+func (self *Matrix) PushBatch(reqs []*request.Request) {
 	if sdl.checkStatus(status.STOP) {
 		return
 	}
@@ -81,27 +131,35 @@ func (self *Matrix) Push(req *request.Request) {
 		return
 	}
 
-	// 不可重复下载的req
-	if !req.IsReloadable() {
-		// 已存在成功记录时退出
-		if self.hasHistory(req.Unique()) {
+	for _, req := range reqs {
+		// 达到请求上限
+		if self.maxPage >= 0 {
 			return
 		}
-		// 添加到临时记录
-		self.insertTempHistory(req.Unique())
+		self.push(req)
 	}
+}
 
-	var priority = req.GetPriority()
+// 添加单个请求到队列，需在加锁状态下调用
+func (self *Matrix) push(req *request.Request) {
+	// 不可重复下载的req
+	if !self.reloadable(req) {
+		// 已存在成功记录或临时记录时退出，否则添加到临时记录
+		if self.history.HasSuccess(req.Unique()) || self.store.SeenAndAdd(req.Unique()) {
+			return
+		}
+	}
 
-	// 初始化该蜘蛛下该优先级队列
-	if _, found := self.reqs[priority]; !found {
-		self.priorities = append(self.priorities, priority)
-		sort.Ints(self.priorities) // 从小到大排序
-		self.reqs[priority] = []*request.Request{}
+	// 开启有序输出时，为首次入队的请求分配序号
+	if cache.Task.OrderedOutput && req.GetSeq() == 0 {
+		self.seq++
+		req.SetSeq(self.seq)
 	}
 
-	// 添加请求到队列
-	self.reqs[priority] = append(self.reqs[priority], req)
+	// 添加请求到队列，串行规则的请求进入其先进先出队列
+	if !self.pushLane(req) {
+		self.store.Push(req)
+	}
 
 	// 大致限制加入队列的请求量，并发情况下应该会比maxPage多
 	atomic.AddInt64(&self.maxPage, 1)
@@ -109,47 +167,86 @@ func (self *Matrix) Push(req *request.Request) {
 
 // 从队列取出请求，不存在时返回nil，并发安全
 func (self *Matrix) Pull() (req *request.Request) {
-	if !sdl.checkStatus(status.RUN) {
+	if !sdl.checkStatus(status.RUN) || sdl.isHalted() {
 		return
 	}
 	self.Lock()
 	defer self.Unlock()
-	// 按优先级从高到低取出请求
-	for i := len(self.reqs) - 1; i >= 0; i-- {
-		idx := self.priorities[i]
-		if len(self.reqs[idx]) > 0 {
-			req = self.reqs[idx][0]
-			self.reqs[idx] = self.reqs[idx][1:]
-			if sdl.useProxy {
-				req.SetProxy(sdl.proxy.GetOne(req.GetUrl()))
-			} else {
-				req.SetProxy("")
-			}
-			return
-		}
+	self.releaseDelayed()
+	lane := self.readyLane()
+	if lane == nil && self.store.Len() == 0 && len(self.requeued) == 0 {
+		return
+	}
+	// 达到请求总数上限后不再派发
+	if !sdl.takeBudget() {
+		return
+	}
+	switch {
+	case lane != nil:
+		req = lane.pop()
+	case self.requeuedTurn():
+		req = self.popRequeued()
+	default:
+		req = self.store.Pop()
+	}
+	if req == nil {
+		return
+	}
+	self.idleSince = time.Time{}
+	self.pulled = true
+	if sdl.useProxy {
+		req.SetProxy(sdl.proxy.GetOne(req.GetUrl()))
+	} else {
+		req.SetProxy("")
 	}
 	return
 }
 
-func (self *Matrix) Use() {
+func (self *Matrix) Use(req *request.Request) {
 	defer func() {
 		recover()
 	}()
-	sdl.count <- true
-	atomic.AddInt32(&self.resCount, 1)
+	atomic.AddInt32(&self.resCount, int32(sdl.acquire(req)))
+	startInflight(req)
 }
 
-func (self *Matrix) Free() {
-	<-sdl.count
-	atomic.AddInt32(&self.resCount, -1)
+func (self *Matrix) TryUse(req *request.Request) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	n, ok := sdl.tryAcquire(req)
+	if !ok {
+		return false
+	}
+	atomic.AddInt32(&self.resCount, int32(n))
+	startInflight(req)
+	return true
+}
+
+func (self *Matrix) Free(req *request.Request) {
+	endInflight(req)
+	atomic.AddInt32(&self.resCount, -int32(sdl.release(req)))
+	self.freeLane(req)
 }
 
 // 返回是否作为新的失败请求被添加至队列尾部
 func (self *Matrix) DoHistory(req *request.Request, ok bool) bool {
-	if !req.IsReloadable() {
-		self.tempHistoryLock.Lock()
-		delete(self.tempHistory, req.Unique())
-		self.tempHistoryLock.Unlock()
+	sdl.recordOutcome(ok)
+	if ok {
+		removeFailure(req.Unique())
+		event.Emit(event.Event{
+			Type:   event.PAGE_SUCCESS,
+			Spider: req.GetSpiderName(),
+			Rule:   req.GetRuleName(),
+			Url:    req.GetUrl(),
+			Method: req.GetMethod(),
+		})
+	}
+
+	if !self.reloadable(req) {
+		self.store.Forget(req.Unique())
 
 		if ok {
 			self.history.UpsertSuccess(req.Unique())
@@ -163,17 +260,130 @@ func (self *Matrix) DoHistory(req *request.Request, ok bool) bool {
 
 	self.failureLock.Lock()
 	defer self.failureLock.Unlock()
-	if _, ok := self.failures[req.Unique()]; !ok {
-		// 首次失败时，在任务队列末尾重新执行一次
+	_, failed := self.failures[req.Unique()]
+	if self.retryLane(req) {
+		// 串行规则的请求在队首立即重新执行
+		return self.retries[req.Unique()] == 1
+	}
+	if self.retries[req.Unique()] < maxRetries(req) {
+		// 重新执行次数未用尽时，在任务队列末尾重新执行
 		self.failures[req.Unique()] = req
 		logs.Log.Informational(" *     + 失败请求: [%v]\n", req.GetUrl())
-		return true
+		return !failed
 	}
-	// 失败两次后，加入历史失败记录
+	// 重新执行次数用尽后，加入历史失败记录
+	self.failures[req.Unique()] = nil
 	self.history.UpsertFailure(req)
+	return !failed
+}
+
+// 失败的请求是否仍将重新执行：已加入待重新执行的失败请求，或已放回串行队列首部；
+// 失败请求在所有请求释放资源后才重新入队，故须在释放该请求的资源前调用
+func (self *Matrix) WillRetry(req *request.Request) bool {
+	self.failureLock.Lock()
+	pending := self.failures[req.Unique()] != nil
+	self.failureLock.Unlock()
+	if pending {
+		return true
+	}
+	self.Lock()
+	defer self.Unlock()
+	lane, ok := self.lanes[req.GetRuleName()]
+	return ok && len(lane.queue) > 0 && lane.queue[0] == req
+}
+
+// 请求失败后在队列末尾重新执行的最大次数，Request.MaxRetries为0时采用全局设置
+func maxRetries(req *request.Request) int {
+	switch n := req.GetMaxRetries(); {
+	case n < 0:
+		return 0
+	case n > 0:
+		return n
+	}
+	return cache.Task.MaxRetries
+}
+
+// 记录不可重试的失败请求，不再重新入队；仅见于失败请求报告，不加入历史失败记录，
+// 并移除其原有的历史失败记录，以免继承失败记录时在下次任务中再次执行
+func (self *Matrix) DoPermanentFailure(req *request.Request) {
+	sdl.recordOutcome(false)
+	if !self.reloadable(req) {
+		self.store.Forget(req.Unique())
+	}
+	self.failureLock.Lock()
+	delete(self.failures, req.Unique())
+	delete(self.retries, req.Unique())
+	self.failureLock.Unlock()
+	self.history.DeleteFailure(req)
+}
+
+// 关闭请求去重，所有请求均视为可重复下载，须在添加请求前调用
+func (self *Matrix) DisableDedup() {
+	self.noDedup = true
+}
+
+// 请求是否可重复下载，关闭去重时总是返回true
+func (self *Matrix) reloadable(req *request.Request) bool {
+	return self.noDedup || req.IsReloadable()
+}
+
+// 返回规范网址是否已处理过，未处理时将其记录，关闭去重时总是返回false，并发安全
+func (self *Matrix) SeenCanonical(canonical string) bool {
+	if self.noDedup {
+		return false
+	}
+	return self.store.SeenAndAdd(canonicalUnique(self.spiderName, canonical))
+}
+
+// 移除规范网址的记录，并发安全
+func (self *Matrix) ForgetCanonical(canonical string) {
+	self.store.Forget(canonicalUnique(self.spiderName, canonical))
+}
+
+// 返回结果的幂等键是否已收集过或正在输出，否则将其标记为正在输出，并发安全；不受关闭请求去重的影响；
+// 幂等键须待输出成功后经RecordItem()才写入存储，以免输出失败的结果在断点续抓时被当作已收集
+func (self *Matrix) SeenItem(key string) bool {
+	fp := itemUnique(self.spiderName, key)
+	self.itemLock.Lock()
+	defer self.itemLock.Unlock()
+	if self.outputting[fp] {
+		return true
+	}
+	// 存储后端仅提供SeenAndAdd，未记录过时随即移除，留待输出成功后再记录
+	if self.store.SeenAndAdd(fp) {
+		return true
+	}
+	self.store.Forget(fp)
+	self.outputting[fp] = true
 	return false
 }
 
+// 结果输出成功后记录其幂等键，并发安全
+func (self *Matrix) RecordItem(key string) {
+	fp := itemUnique(self.spiderName, key)
+	self.itemLock.Lock()
+	self.store.SeenAndAdd(fp)
+	delete(self.outputting, fp)
+	self.itemLock.Unlock()
+}
+
+// 结果未能输出时移除其幂等键的标记，请求重试或重新执行时可再次收集，并发安全
+func (self *Matrix) ForgetItem(key string) {
+	self.itemLock.Lock()
+	delete(self.outputting, itemUnique(self.spiderName, key))
+	self.itemLock.Unlock()
+}
+
+// 结果幂等键的指纹，结果数量远多于请求，故采用sha1以避免碰撞
+func itemUnique(spiderName, key string) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte("item"+spiderName+key)))
+}
+
+// 规范网址的去重指纹，同一Spider下不区分规则与请求方法
+func canonicalUnique(spiderName, canonical string) string {
+	return util.MakeHash("canonical" + spiderName + canonical)
+}
+
 func (self *Matrix) CanStop() bool {
 	if sdl.checkStatus(status.STOP) {
 		return true
@@ -181,10 +391,15 @@ func (self *Matrix) CanStop() bool {
 	if self.maxPage >= 0 {
 		return true
 	}
+	if sdl.isHalted() {
+		return true
+	}
 	if self.resCount != 0 {
+		self.resetIdle()
 		return false
 	}
 	if self.Len() > 0 {
+		self.resetIdle()
 		return false
 	}
 
@@ -198,6 +413,7 @@ func (self *Matrix) CanStop() bool {
 				continue
 			}
 			self.failures[reqUnique] = nil
+			self.retries[reqUnique]++
 			goon = true
 			logs.Log.Informational(" *     - 失败请求: [%v]\n", req.GetUrl())
 			self.Push(req)
@@ -206,20 +422,74 @@ func (self *Matrix) CanStop() bool {
 			return false
 		}
 	}
+	return self.graceOver()
+}
+
+// 分布式子节点开始时默认等待首个请求的时长
+const defaultSeedWait = 10 * time.Second
+
+// 任务开始时等待首个请求的时长，cache.Task.SeedWait为-1时仅分布式子节点等待
+func seedWait() time.Duration {
+	if cache.Task.SeedWait >= 0 {
+		return time.Duration(cache.Task.SeedWait) * time.Second
+	}
+	if cache.Task.Mode == status.CLIENT {
+		return defaultSeedWait
+	}
+	return 0
+}
+
+// 尚未取出过任何请求时，在任务开始后的等待时长内不停止，以免种子请求迟到时提前结束任务
+func (self *Matrix) seedWaiting() bool {
+	wait := seedWait()
+	self.Lock()
+	defer self.Unlock()
+	if self.pulled || wait <= 0 || time.Since(self.created) >= wait {
+		return false
+	}
+	if !self.seedLogged {
+		self.seedLogged = true
+		logs.Log.Informational(" *     请求队列为空，等待首个请求最多 %v\n", wait)
+	}
 	return true
 }
 
+// 队列为空且无处理中的请求时，等待宽限期结束后才确认停止，
+// 以免队列短暂为空（如延时加入请求）时提前结束任务
+func (self *Matrix) graceOver() bool {
+	if self.seedWaiting() {
+		return false
+	}
+	if cache.Task.StopGrace <= 0 {
+		return true
+	}
+	self.Lock()
+	defer self.Unlock()
+	if self.idleSince.IsZero() {
+		self.idleSince = time.Now()
+		logs.Log.Informational(" *     请求队列已空，%v 秒内无新请求时停止任务\n", cache.Task.StopGrace)
+		return false
+	}
+	return time.Since(self.idleSince) >= time.Duration(cache.Task.StopGrace)*time.Second
+}
+
+func (self *Matrix) resetIdle() {
+	self.Lock()
+	self.idleSince = time.Time{}
+	self.Unlock()
+}
+
 // 非服务器模式下保存历史成功记录
 func (self *Matrix) TryFlushSuccess() {
 	if cache.Task.Mode != status.SERVER && cache.Task.SuccessInherit {
-		self.history.FlushSuccess(cache.Task.OutType)
+		self.history.FlushSuccess(cache.HistoryOutType())
 	}
 }
 
 // 非服务器模式下保存历史失败记录
 func (self *Matrix) TryFlushFailure() {
 	if cache.Task.Mode != status.SERVER && cache.Task.FailureInherit {
-		self.history.FlushFailure(cache.Task.OutType)
+		self.history.FlushFailure(cache.HistoryOutType())
 	}
 }
 
@@ -233,27 +503,7 @@ func (self *Matrix) Wait() {
 func (self *Matrix) Len() int {
 	self.Lock()
 	defer self.Unlock()
-	var l int
-	for _, reqs := range self.reqs {
-		l += len(reqs)
-	}
-	return l
-}
-
-func (self *Matrix) hasHistory(reqUnique string) bool {
-	if self.history.HasSuccess(reqUnique) {
-		return true
-	}
-	self.tempHistoryLock.RLock()
-	has := self.tempHistory[reqUnique]
-	self.tempHistoryLock.RUnlock()
-	return has
-}
-
-func (self *Matrix) insertTempHistory(reqUnique string) {
-	self.tempHistoryLock.Lock()
-	self.tempHistory[reqUnique] = true
-	self.tempHistoryLock.Unlock()
+	return self.store.Len() + len(self.delayed) + self.laneLen() + len(self.requeued)
 }
 
 func (self *Matrix) setFailures(reqs map[string]*request.Request) {
@@ -269,15 +519,20 @@ func (self *Matrix) setFailures(reqs map[string]*request.Request) {
 // 如：持久化保存历史失败记录，清空对象
 func (self *Matrix) windup() {
 	self.Lock()
-	self.reqs = make(map[int][]*request.Request)
-	self.priorities = []int{}
-	self.tempHistory = make(map[string]bool)
+	self.store.Reset()
+	self.delayed = nil
+	self.requeued = nil
+	for _, lane := range self.lanes {
+		lane.queue, lane.busy = nil, false
+	}
+	self.idleSince = time.Time{}
 
 	// 持久化保存历史失败记录
 	for _, req := range self.failures {
 		self.history.UpsertFailure(req)
 	}
 	self.failures = make(map[string]*request.Request)
+	self.retries = make(map[string]int)
 
 	self.Unlock()
 }
@@ -0,0 +1,100 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+// 不可重试的失败请求不加入历史失败记录，原有的记录亦被移除，继承失败记录时不再执行
+func TestPermanentFailureNotInherited(t *testing.T) {
+	m := testMatrix(t, 1)
+	retried := testRequest("http://a/1", "r")
+	retried.SetMaxRetries(-1)
+	m.DoHistory(retried, false)
+	if _, ok := m.history.PullFailure()[retried.Unique()]; !ok {
+		t.Fatal("retryable failure was not recorded")
+	}
+
+	permanent := testRequest("http://a/2", "r")
+	m.history.UpsertFailure(permanent)
+	m.DoPermanentFailure(permanent)
+	m.DoPermanentFailure(testRequest("http://a/3", "r"))
+	if failures := m.history.PullFailure(); len(failures) != 0 {
+		t.Fatalf("history failures = %v, want none", failures)
+	}
+}
+
+// 失败的请求在重新执行次数用尽前仍将重新执行
+func TestWillRetry(t *testing.T) {
+	m := testMatrix(t, 1)
+	req := testRequest("http://a/1", "r")
+	req.SetMaxRetries(1)
+	m.DoHistory(req, false)
+	if !m.WillRetry(req) {
+		t.Fatal("failed request with retries left is not retried")
+	}
+	m.CanStop()
+	retried := m.Pull()
+	if retried == nil || retried.Unique() != req.Unique() {
+		t.Fatalf("got %v, want the retried request", retried)
+	}
+	m.DoHistory(retried, false)
+	if m.WillRetry(retried) {
+		t.Fatal("request retried beyond MaxRetries")
+	}
+}
+
+// 失败的请求按Request.MaxRetries重新执行，为0时采用全局设置，小于0时不再重新执行
+func TestMaxRetries(t *testing.T) {
+	defer func(n int) { cache.Task.MaxRetries = n }(cache.Task.MaxRetries)
+	for _, c := range []struct{ max, global, want int }{
+		{max: 2, global: 1, want: 2},
+		{max: 0, global: 3, want: 3},
+		{max: -1, global: 3, want: 0},
+	} {
+		cache.Task.MaxRetries = c.global
+		m := testMatrix(t, 1)
+		req := testRequest("http://a/1", "r")
+		req.SetMaxRetries(c.max)
+		var retries int
+		for {
+			m.DoHistory(req, false)
+			m.CanStop()
+			if req = m.Pull(); req == nil {
+				break
+			}
+			retries++
+		}
+		if retries != c.want {
+			t.Errorf("MaxRetries %d with global %d: retried %d times, want %d", c.max, c.global, retries, c.want)
+		}
+	}
+}
+
+// 结果幂等键在输出成功前仅标记为正在输出，不写入存储；输出失败时移除标记以便重新收集
+func TestSeenItem(t *testing.T) {
+	m := testMatrix(t, 1)
+	if m.SeenItem("a") {
+		t.Fatal("new item reported as seen")
+	}
+	if !m.SeenItem("a") {
+		t.Fatal("item being output reported as unseen")
+	}
+	if m.store.SeenAndAdd(itemUnique(m.spiderName, "a")) {
+		t.Fatal("item recorded in store before output")
+	}
+	m.store.Forget(itemUnique(m.spiderName, "a"))
+
+	m.ForgetItem("a")
+	if m.SeenItem("a") {
+		t.Fatal("forgotten item reported as seen")
+	}
+	m.RecordItem("a")
+	if !m.store.SeenAndAdd(itemUnique(m.spiderName, "a")) {
+		t.Fatal("recorded item missing from store")
+	}
+	if !m.SeenItem("a") {
+		t.Fatal("recorded item reported as unseen")
+	}
+}
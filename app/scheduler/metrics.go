@@ -0,0 +1,122 @@
+package scheduler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/henrylee2cn/pholcus/logs"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+// 运行指标的采样器，按固定间隔记录队列深度、吞吐量与失败率
+var metrics = struct {
+	stop chan bool
+	wg   sync.WaitGroup
+	sync.Mutex
+}{}
+
+// 开始按interval间隔采样运行指标，并以csv格式写入fileName，interval<=0时不采样
+func StartMetrics(fileName string, interval time.Duration) error {
+	if interval <= 0 {
+		return nil
+	}
+	StopMetrics()
+
+	if err := os.MkdirAll(filepath.Dir(fileName), 0777); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0777)
+	if err != nil {
+		return err
+	}
+	f.WriteString("\xEF\xBB\xBF") // 写入UTF-8 BOM
+	w := csv.NewWriter(f)
+	w.Write([]string{"Time", "Elapsed", "Queue", "Running", "Success", "Fail", "Throughput", "ErrorRate"})
+	w.Flush()
+
+	metrics.Lock()
+	stop := make(chan bool)
+	metrics.stop = stop
+	metrics.wg.Add(1)
+	metrics.Unlock()
+
+	logs.Log.Informational(" *     运行指标每 %v 采样一次，保存至: %v\n", interval, fileName)
+
+	go func() {
+		defer metrics.wg.Done()
+		defer f.Close()
+		var (
+			ticker   = time.NewTicker(interval)
+			last     = time.Now()
+			lastSucc = cache.GetPageCount(1)
+			lastFail = cache.GetPageCount(-1)
+		)
+		defer ticker.Stop()
+		sample := func() {
+			var (
+				now     = time.Now()
+				succ    = cache.GetPageCount(1)
+				fail    = cache.GetPageCount(-1)
+				done    = (succ - lastSucc) + (fail - lastFail)
+				errRate float64
+			)
+			if done > 0 {
+				errRate = float64(fail-lastFail) / float64(done)
+			}
+			queue, running := depth()
+			w.Write([]string{
+				now.Format("2006-01-02 15:04:05"),
+				fmt.Sprintf("%.0f", now.Sub(cache.StartTime).Seconds()),
+				strconv.Itoa(queue),
+				strconv.Itoa(running),
+				strconv.FormatUint(succ, 10),
+				strconv.FormatUint(fail, 10),
+				fmt.Sprintf("%.2f", float64(done)/now.Sub(last).Seconds()),
+				fmt.Sprintf("%.4f", errRate),
+			})
+			w.Flush()
+			last, lastSucc, lastFail = now, succ, fail
+		}
+		for {
+			select {
+			case <-ticker.C:
+				sample()
+			case <-stop:
+				sample()
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// 停止运行指标采样，并写入最后一个采样点
+func StopMetrics() {
+	metrics.Lock()
+	if metrics.stop != nil {
+		close(metrics.stop)
+		metrics.stop = nil
+	}
+	metrics.Unlock()
+	metrics.wg.Wait()
+}
+
+// 返回所有Spider实例的待处理请求数与处理中的请求数
+func depth() (queue, running int) {
+	sdl.RLock()
+	matrices := make([]*Matrix, len(sdl.matrices))
+	copy(matrices, sdl.matrices)
+	sdl.RUnlock()
+
+	for _, matrix := range matrices {
+		queue += matrix.Len()
+		running += int(atomic.LoadInt32(&matrix.resCount))
+	}
+	return
+}
@@ -0,0 +1,50 @@
+package scheduler
+
+import (
+	"sync"
+)
+
+// 计算失败率的滑动窗口大小，即最近的请求数
+const FAIL_WINDOW = 100
+
+// 最近请求成败的滑动窗口，并发安全
+type outcomes struct {
+	window      [FAIL_WINDOW]bool // 环形缓冲，true为失败
+	next        int               // 下一个写入位置
+	count       int               // 窗口中的请求数
+	fails       int               // 窗口中的失败数
+	consecutive int               // 当前连续失败数
+	sync.Mutex
+}
+
+// 记录一次请求的成败，返回窗口已满时的失败率（未满时为0）及当前连续失败数
+func (self *outcomes) add(ok bool) (rate float64, consecutive int) {
+	self.Lock()
+	defer self.Unlock()
+	if self.count == FAIL_WINDOW {
+		if self.window[self.next] {
+			self.fails--
+		}
+	} else {
+		self.count++
+	}
+	self.window[self.next] = !ok
+	self.next = (self.next + 1) % FAIL_WINDOW
+	if ok {
+		self.consecutive = 0
+	} else {
+		self.fails++
+		self.consecutive++
+	}
+	if self.count == FAIL_WINDOW {
+		rate = float64(self.fails) / FAIL_WINDOW
+	}
+	return rate, self.consecutive
+}
+
+func (self *outcomes) reset() {
+	self.Lock()
+	self.window = [FAIL_WINDOW]bool{}
+	self.next, self.count, self.fails, self.consecutive = 0, 0, 0, 0
+	self.Unlock()
+}
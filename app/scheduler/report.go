@@ -0,0 +1,216 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+	"github.com/henrylee2cn/pholcus/logs"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+// 运行报告中耗时分布的区间上限/ms，最后一个区间不设上限
+var LATENCY_BUCKETS = []int64{100, 250, 500, 1000, 2500, 5000, 10000, 30000}
+
+// 用于估算耗时百分位的蓄水池样本数上限，请求数更多时随机替换已有样本，内存占用以此为限
+const LATENCY_SAMPLES = 10000
+
+type (
+	// 任务结束时的运行报告
+	RunReport struct {
+		Requests    uint64         // 请求总数
+		Success     uint64         // 成功数
+		Empty       uint64         // 成功数中的无数据页面数，见spider.Rule.Empty
+		Fail        uint64         // 失败数
+		FailClasses map[string]int // [失败类型]下载失败次数，类型见request.ERR_*
+		Elapsed     float64        // 运行时长/s
+		Throughput  float64        // 吞吐量，每秒处理的请求数
+		Latency     LatencyStats   // 下载耗时统计
+	}
+	// 下载耗时统计，单位均为ms
+	LatencyStats struct {
+		Count     int
+		Min       int64
+		Mean      int64
+		P50       int64
+		P95       int64
+		P99       int64
+		Max       int64
+		Histogram []LatencyBucket
+	}
+	// 耗时分布的区间
+	LatencyBucket struct {
+		UpperMs int64 // 区间上限/ms，0表示不设上限
+		Count   int
+	}
+)
+
+// 本次任务各请求的下载耗时及失败类型，仅在开启run::runreport时记录
+var timings = struct {
+	latencies   latencyRecorder
+	failClasses map[string]int
+	sync.Mutex
+}{
+	failClasses: make(map[string]int),
+}
+
+// 下载耗时的累计统计，数量、总和、最值及分布区间为精确值，百分位由至多LATENCY_SAMPLES个蓄水池样本估算
+type latencyRecorder struct {
+	count    int
+	sum      time.Duration
+	min, max time.Duration
+	buckets  []int
+	samples  []time.Duration
+}
+
+// 记录一次下载耗时，非并发安全
+func (self *latencyRecorder) add(d time.Duration) {
+	if self.buckets == nil {
+		self.buckets = make([]int, len(LATENCY_BUCKETS)+1)
+	}
+	if self.count == 0 || d < self.min {
+		self.min = d
+	}
+	if d > self.max {
+		self.max = d
+	}
+	self.count++
+	self.sum += d
+	ms := int64(d / time.Millisecond)
+	self.buckets[sort.Search(len(LATENCY_BUCKETS), func(i int) bool { return ms <= LATENCY_BUCKETS[i] })]++
+	// 蓄水池抽样，第n个耗时以LATENCY_SAMPLES/n的概率替换一个已有样本
+	if len(self.samples) < LATENCY_SAMPLES {
+		self.samples = append(self.samples, d)
+	} else if i := rand.Intn(self.count); i < LATENCY_SAMPLES {
+		self.samples[i] = d
+	}
+}
+
+// 生成下载耗时统计，非并发安全
+func (self *latencyRecorder) stats() (stats LatencyStats) {
+	stats.Histogram = make([]LatencyBucket, len(LATENCY_BUCKETS)+1)
+	for i, upper := range LATENCY_BUCKETS {
+		stats.Histogram[i].UpperMs = upper
+	}
+	stats.Count = self.count
+	if stats.Count == 0 {
+		return
+	}
+	for i, n := range self.buckets {
+		stats.Histogram[i].Count = n
+	}
+	samples := append([]time.Duration(nil), self.samples...)
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	percentile := func(p float64) int64 {
+		i := int(p*float64(len(samples))+0.5) - 1
+		if i < 0 {
+			i = 0
+		} else if i >= len(samples) {
+			i = len(samples) - 1
+		}
+		return int64(samples[i] / time.Millisecond)
+	}
+	stats.Min = int64(self.min / time.Millisecond)
+	stats.Max = int64(self.max / time.Millisecond)
+	stats.Mean = int64(self.sum / time.Duration(stats.Count) / time.Millisecond)
+	stats.P50 = percentile(0.50)
+	stats.P95 = percentile(0.95)
+	stats.P99 = percentile(0.99)
+	return
+}
+
+// 记录一次下载的耗时及失败原因(成功时为nil)，未开启运行报告时不记录，并发安全
+func RecordTiming(d time.Duration, err error) {
+	if !cache.Task.RunReport {
+		return
+	}
+	timings.Lock()
+	timings.latencies.add(d)
+	if err != nil {
+		timings.failClasses[request.ErrorClass(err)]++
+	}
+	timings.Unlock()
+}
+
+// 清空下载耗时记录
+func resetTimings() {
+	timings.Lock()
+	timings.latencies = latencyRecorder{}
+	timings.failClasses = make(map[string]int)
+	timings.Unlock()
+}
+
+// 生成本次任务的运行报告
+func Report() RunReport {
+	timings.Lock()
+	latency := timings.latencies.stats()
+	failClasses := make(map[string]int, len(timings.failClasses))
+	for class, n := range timings.failClasses {
+		failClasses[class] = n
+	}
+	timings.Unlock()
+
+	report := RunReport{
+		Requests:    cache.GetPageCount(0),
+		Success:     cache.GetPageCount(1),
+		Empty:       cache.GetPageEmptyCount(),
+		Fail:        cache.GetPageCount(-1),
+		FailClasses: failClasses,
+		Elapsed:     time.Since(cache.StartTime).Seconds(),
+		Latency:     latency,
+	}
+	if report.Elapsed > 0 {
+		report.Throughput = float64(report.Requests) / report.Elapsed
+	}
+	return report
+}
+
+// 打印运行报告，并以json格式写入fileName
+func FlushReport(fileName string) error {
+	report := Report()
+	logs.Log.Informational(" *     [运行报告] 请求 %v 个（成功 %v + 失败 %v，成功中无数据 %v），吞吐量 %.2f 个/秒\n",
+		report.Requests, report.Success, report.Fail, report.Empty, report.Throughput)
+	if len(report.FailClasses) > 0 {
+		classes := make([]string, 0, len(report.FailClasses))
+		for class := range report.FailClasses {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+		for _, class := range classes {
+			logs.Log.Informational(" *     [运行报告] 失败类型 %v: %v 次\n", class, report.FailClasses[class])
+		}
+	}
+	if lat := report.Latency; lat.Count > 0 {
+		logs.Log.Informational(" *     [运行报告] 下载耗时 p50 %vms / p95 %vms / p99 %vms（最短 %vms，平均 %vms，最长 %vms）\n",
+			lat.P50, lat.P95, lat.P99, lat.Min, lat.Mean, lat.Max)
+		lower := int64(0)
+		for _, bucket := range lat.Histogram {
+			if bucket.UpperMs == 0 {
+				logs.Log.Informational(" *     [运行报告]   >%vms: %v\n", lower, bucket.Count)
+				continue
+			}
+			logs.Log.Informational(" *     [运行报告]   %v~%vms: %v\n", lower, bucket.UpperMs, bucket.Count)
+			lower = bucket.UpperMs
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fileName), 0777); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(fileName, b, 0777); err != nil {
+		return fmt.Errorf("write run report: %v", err)
+	}
+	logs.Log.Informational(" *     运行报告已保存至: %v\n", fileName)
+	return nil
+}
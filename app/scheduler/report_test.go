@@ -0,0 +1,28 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+// 样本数以LATENCY_SAMPLES为限，数量、最值及分布区间仍为精确值，百分位为估算值
+func TestLatencyRecorder(t *testing.T) {
+	var r latencyRecorder
+	n := 2 * LATENCY_SAMPLES
+	for i := 1; i <= n; i++ {
+		r.add(time.Duration(i) * time.Millisecond)
+	}
+	if len(r.samples) != LATENCY_SAMPLES {
+		t.Fatalf("kept %d samples, want %d", len(r.samples), LATENCY_SAMPLES)
+	}
+	stats := r.stats()
+	if stats.Count != n || stats.Min != 1 || stats.Max != int64(n) || stats.Mean != int64(n+1)/2 {
+		t.Fatalf("got %+v", stats)
+	}
+	if got := stats.Histogram[0].Count; got != 100 {
+		t.Errorf("<=100ms bucket = %d, want 100", got)
+	}
+	if p50 := stats.P50; p50 < int64(n)*45/100 || p50 > int64(n)*55/100 {
+		t.Errorf("P50 = %d, want about %d", p50, n/2)
+	}
+}
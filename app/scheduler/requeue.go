@@ -0,0 +1,37 @@
+package scheduler
+
+import (
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+)
+
+// 放回已取出但因所属规则的并发量或所需并发池已满而未能处理的请求，此后与队列中的请求交替取出，
+// 以免反复取出同一请求而阻塞其他规则；串行规则的请求则回到其队列首部，以维持处理顺序；
+// 放回的请求仅存于内存，不写入run::queuestore指定的存储后端
+func (self *Matrix) Requeue(req *request.Request) {
+	self.Lock()
+	defer self.Unlock()
+	sdl.refundBudget()
+	if !self.requeueLane(req) {
+		self.requeued = append(self.requeued, req)
+	}
+}
+
+// 本次取出时是否取放回的请求：队列为空时总是取，否则与队列交替，需在加锁状态下调用
+func (self *Matrix) requeuedTurn() bool {
+	if len(self.requeued) == 0 {
+		return false
+	}
+	if self.store.Len() == 0 {
+		return true
+	}
+	self.requeueTurn = !self.requeueTurn
+	return self.requeueTurn
+}
+
+// 取出最早放回的请求，需在加锁状态下调用
+func (self *Matrix) popRequeued() *request.Request {
+	req := self.requeued[0]
+	self.requeued[0] = nil
+	self.requeued = self.requeued[1:]
+	return req
+}
@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+// 以给定的全局并发量初始化调度器，返回一个不继承历史记录的请求矩阵
+func testMatrix(t *testing.T, threads int) *Matrix {
+	cache.Task.ThreadNum = threads
+	cache.Task.SuccessInherit, cache.Task.FailureInherit = false, false
+	cache.Task.ProxyMinute = 0
+	Init()
+	return AddMatrix("test", "", -1<<30)
+}
+
+func testRequest(url, rule string) *request.Request {
+	req := &request.Request{Spider: "test", Url: url, Rule: rule}
+	req.Prepare()
+	return req
+}
+
+func TestTryUseRequeue(t *testing.T) {
+	m := testMatrix(t, 1)
+	m.PushBatch([]*request.Request{testRequest("http://a/1", "r"), testRequest("http://a/2", "r")})
+
+	first := m.Pull()
+	if !m.TryUse(first) {
+		t.Fatal("TryUse failed with a free slot")
+	}
+	second := m.Pull()
+	if m.TryUse(second) {
+		t.Fatal("TryUse succeeded with the pool full")
+	}
+	m.Requeue(second)
+	if n := m.Len(); n != 1 {
+		t.Fatalf("Len() = %d after Requeue, want 1", n)
+	}
+	m.Free(first)
+	if req := m.Pull(); req != second || !m.TryUse(req) {
+		t.Fatalf("got %v, want the requeued request", req)
+	}
+	m.Free(second)
+}
+
+func TestRequeueAlternates(t *testing.T) {
+	m := testMatrix(t, 1)
+	m.PushBatch([]*request.Request{testRequest("http://a/1", "r"), testRequest("http://a/2", "r"), testRequest("http://a/3", "r")})
+	blocked := m.Pull()
+	m.Requeue(blocked)
+	// 放回的请求与队列交替取出，不会阻塞队列中的其余请求
+	var urls []string
+	for req := m.Pull(); req != nil; req = m.Pull() {
+		urls = append(urls, req.GetUrl())
+	}
+	want := []string{"http://a/1", "http://a/2", "http://a/3"}
+	if len(urls) != 3 || urls[0] != want[0] || urls[1] != want[1] || urls[2] != want[2] {
+		t.Fatalf("got %v, want %v", urls, want)
+	}
+}
@@ -3,8 +3,11 @@ package scheduler
 import (
 	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/henrylee2cn/pholcus/app/aid/proxy"
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
 	"github.com/henrylee2cn/pholcus/logs"
 	"github.com/henrylee2cn/pholcus/runtime/cache"
 	"github.com/henrylee2cn/pholcus/runtime/status"
@@ -14,9 +17,15 @@ import (
 type scheduler struct {
 	status       int          // 运行状态
 	count        chan bool    // 总并发量计数
+	fileCount    chan bool    // 文件下载请求的独立并发量计数，未启用时为nil
 	useProxy     bool         // 标记是否使用代理IP
 	proxy        *proxy.Proxy // 全局代理IP
 	matrices     []*Matrix    // Spider实例的请求矩阵列表
+	requests     int64        // 已派发的请求总数
+	halted       int32        // 是否已停止派发新请求（请求总数达到上限或失败过多）
+	outcomes     outcomes     // 最近请求的成败记录
+	deadline     *time.Timer  // 运行时长上限的计时器
+	acquireLock  sync.Mutex   // 占用多份并发计数时加锁
 	sync.RWMutex              // 全局读写锁
 }
 
@@ -33,6 +42,30 @@ func Init() {
 	}
 	sdl.matrices = []*Matrix{}
 	sdl.count = make(chan bool, cache.Task.ThreadNum)
+	if cache.Task.FileThreadNum > 0 {
+		sdl.fileCount = make(chan bool, cache.Task.FileThreadNum)
+		logs.Log.Informational(" *     文件下载请求的并发量为 %v\n", cache.Task.FileThreadNum)
+	} else {
+		sdl.fileCount = nil
+	}
+	atomic.StoreInt64(&sdl.requests, 0)
+	atomic.StoreInt32(&sdl.halted, 0)
+	sdl.outcomes.reset()
+	resetFailures()
+	resetTimings()
+	resetInflight()
+
+	// 运行时长达到上限后停止派发新请求
+	if sdl.deadline != nil {
+		sdl.deadline.Stop()
+		sdl.deadline = nil
+	}
+	if max := cache.Task.MaxRunTime; max > 0 {
+		sdl.deadline = time.AfterFunc(time.Duration(max)*time.Second, func() {
+			sdl.halt(" *     运行时长已达上限 %v 秒，停止派发新请求\n", max)
+		})
+		logs.Log.Informational(" *     运行时长上限为 %v 秒\n", max)
+	}
 
 	if cache.Task.ProxyMinute > 0 {
 		if sdl.proxy.Count() > 0 {
@@ -60,6 +93,13 @@ func AddMatrix(spiderName, spiderSubName string, maxPage int64) *Matrix {
 	return matrix
 }
 
+// 使用代理IP时，弃用该请求所用的代理IP，其重试将换用下一个
+func ChangeProxy(req *request.Request) {
+	if sdl.useProxy && req.GetProxy() != "" {
+		sdl.proxy.Next(req.GetUrl(), req.GetProxy())
+	}
+}
+
 // 暂停\恢复所有爬行任务
 func PauseRecover() {
 	sdl.Lock()
@@ -76,6 +116,10 @@ func PauseRecover() {
 func Stop() {
 	sdl.Lock()
 	sdl.status = status.STOP
+	if sdl.deadline != nil {
+		sdl.deadline.Stop()
+		sdl.deadline = nil
+	}
 	// 清空
 	go func() {
 		defer func() {
@@ -85,6 +129,9 @@ func Stop() {
 			matrix.windup()
 		}
 		close(sdl.count)
+		if sdl.fileCount != nil {
+			close(sdl.fileCount)
+		}
 		sdl.matrices = []*Matrix{}
 	}()
 	sdl.Unlock()
@@ -92,13 +139,126 @@ func Stop() {
 
 // 每个spider实例分配到的平均资源量
 func (self *scheduler) avgRes() int32 {
-	avg := int32(cap(sdl.count) / len(sdl.matrices))
+	avg := int32((cap(sdl.count) + cap(sdl.fileCount)) / len(sdl.matrices))
 	if avg == 0 {
 		avg = 1
 	}
 	return avg
 }
 
+// 返回请求应占用的并发计数，启用独立的文件下载并发量时，文件下载请求与其他请求分别计数
+func (self *scheduler) slots(req *request.Request) chan bool {
+	if self.fileCount != nil && req.IsFile() {
+		return self.fileCount
+	}
+	return self.count
+}
+
+// 按请求的资源权重占用并发计数，返回占用的份数，权重超出并发量时以并发量为准；
+// 占用多份时加锁逐份占用，以免多个高权重请求各占一部分后相互等待
+func (self *scheduler) acquire(req *request.Request) int {
+	slots := self.slots(req)
+	n := cost(req, slots)
+	if n == 1 {
+		slots <- true
+		return 1
+	}
+	self.acquireLock.Lock()
+	defer self.acquireLock.Unlock()
+	for i := 0; i < n; i++ {
+		slots <- true
+	}
+	return n
+}
+
+// 不等待地按请求的资源权重占用并发计数，并发计数不足时不占用并返回false
+func (self *scheduler) tryAcquire(req *request.Request) (int, bool) {
+	slots := self.slots(req)
+	n := cost(req, slots)
+	if n == 1 {
+		select {
+		case slots <- true:
+			return 1, true
+		default:
+			return 0, false
+		}
+	}
+	self.acquireLock.Lock()
+	defer self.acquireLock.Unlock()
+	if cap(slots)-len(slots) < n {
+		return 0, false
+	}
+	for i := 0; i < n; i++ {
+		slots <- true
+	}
+	return n, true
+}
+
+// 释放请求占用的并发计数，返回释放的份数
+func (self *scheduler) release(req *request.Request) int {
+	slots := self.slots(req)
+	n := cost(req, slots)
+	for i := 0; i < n; i++ {
+		<-slots
+	}
+	return n
+}
+
+// 请求占用的并发计数份数，不超过并发量
+func cost(req *request.Request, slots chan bool) int {
+	n := req.GetCost()
+	if n > cap(slots) {
+		n = cap(slots)
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// 占用一个请求名额，返回是否未超出请求总数上限，并发安全
+func (self *scheduler) takeBudget() bool {
+	max := cache.Task.MaxRequests
+	if max <= 0 {
+		return true
+	}
+	if atomic.AddInt64(&self.requests, 1) <= max {
+		return true
+	}
+	self.halt(" *     请求总数已达上限 %v（request budget exhausted），停止派发新请求\n", max)
+	return false
+}
+
+// 退还已占用的请求名额，用于取出后未能派发而放回队列的请求
+func (self *scheduler) refundBudget() {
+	if cache.Task.MaxRequests > 0 {
+		atomic.AddInt64(&self.requests, -1)
+	}
+}
+
+// 停止派发新请求，处理中的请求仍会完成，仅首次调用时打印日志
+func (self *scheduler) halt(format string, v ...interface{}) {
+	if atomic.CompareAndSwapInt32(&self.halted, 0, 1) {
+		logs.Log.Warning(format, v...)
+	}
+}
+
+// 是否已停止派发新请求
+func (self *scheduler) isHalted() bool {
+	return atomic.LoadInt32(&self.halted) == 1
+}
+
+// 记录一次请求的成败，失败率或连续失败数超出阈值时停止派发新请求
+func (self *scheduler) recordOutcome(ok bool) {
+	rate, consecutive := self.outcomes.add(ok)
+	if max := cache.Task.MaxConsecutiveFails; max > 0 && consecutive >= max {
+		self.halt(" *     连续 %v 个请求失败（abort: failure threshold exceeded），终止任务\n", consecutive)
+	}
+	if max := cache.Task.MaxFailRate; max > 0 && rate >= max {
+		self.halt(" *     最近 %v 个请求的失败率为 %.0f%%（abort: failure threshold exceeded），终止任务\n", FAIL_WINDOW, rate*100)
+	}
+}
+
 func (self *scheduler) checkStatus(s int) bool {
 	self.RLock()
 	defer self.RUnlock()
@@ -0,0 +1,106 @@
+package scheduler
+
+import (
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+	"github.com/henrylee2cn/pholcus/logs"
+)
+
+// 串行规则的请求队列，按加入顺序逐个取出，同一时刻至多一个请求处于处理中
+type serialLane struct {
+	queue []*request.Request // 待处理的请求，先进先出
+	busy  bool               // 是否有取出后尚未释放的请求
+}
+
+// 将规则设为串行，其请求不再进入优先级队列，而是进入独立的先进先出队列，
+// 前一个请求释放资源(Free)或被放弃(Skip)后才取出下一个，失败重试时仍排在队首，其他规则照常调度；
+// 串行队列仅存于内存，不写入run::queuestore指定的存储后端，任务中断后其中的请求不可恢复；须在添加请求前调用
+func (self *Matrix) SetSerial(ruleNames ...string) {
+	self.Lock()
+	defer self.Unlock()
+	if self.lanes == nil {
+		self.lanes = make(map[string]*serialLane)
+	}
+	for _, name := range ruleNames {
+		if _, ok := self.lanes[name]; !ok {
+			self.lanes[name] = new(serialLane)
+			self.laneOrder = append(self.laneOrder, name)
+		}
+	}
+}
+
+// 请求属于串行规则时加入其队列尾部并返回true，需在加锁状态下调用
+func (self *Matrix) pushLane(req *request.Request) bool {
+	lane, ok := self.lanes[req.GetRuleName()]
+	if !ok {
+		return false
+	}
+	lane.queue = append(lane.queue, req)
+	return true
+}
+
+// 返回首个可取出请求的串行队列，不存在时返回nil，需在加锁状态下调用
+func (self *Matrix) readyLane() *serialLane {
+	for _, name := range self.laneOrder {
+		if lane := self.lanes[name]; !lane.busy && len(lane.queue) > 0 {
+			return lane
+		}
+	}
+	return nil
+}
+
+// 取出串行队列的队首请求，需在加锁状态下调用
+func (self *serialLane) pop() *request.Request {
+	req := self.queue[0]
+	self.queue[0] = nil
+	self.queue = self.queue[1:]
+	self.busy = true
+	return req
+}
+
+// 放弃已取出而不处理的请求，使其所属的串行队列可取出下一个请求，并发安全
+func (self *Matrix) Skip(req *request.Request) {
+	self.freeLane(req)
+}
+
+// 请求释放资源后，其所属的串行队列可取出下一个请求
+func (self *Matrix) freeLane(req *request.Request) {
+	self.Lock()
+	defer self.Unlock()
+	if lane, ok := self.lanes[req.GetRuleName()]; ok {
+		lane.busy = false
+	}
+}
+
+// 失败的串行请求在重新执行次数未用尽时放回其队列首部，以保持处理顺序，返回是否已放回；
+// 需在持有failureLock时调用
+func (self *Matrix) retryLane(req *request.Request) bool {
+	self.Lock()
+	defer self.Unlock()
+	lane, ok := self.lanes[req.GetRuleName()]
+	if !ok || self.retries[req.Unique()] >= maxRetries(req) {
+		return false
+	}
+	self.retries[req.Unique()]++
+	lane.queue = append([]*request.Request{req}, lane.queue...)
+	logs.Log.Informational(" *     - 失败请求: [%v]\n", req.GetUrl())
+	return true
+}
+
+// 未处理即放回的串行请求回到其队列首部，并使队列可再次取出，返回是否属于串行规则，需在加锁状态下调用
+func (self *Matrix) requeueLane(req *request.Request) bool {
+	lane, ok := self.lanes[req.GetRuleName()]
+	if !ok {
+		return false
+	}
+	lane.queue = append([]*request.Request{req}, lane.queue...)
+	lane.busy = false
+	return true
+}
+
+// 各串行队列中待处理的请求数，需在加锁状态下调用
+func (self *Matrix) laneLen() (n int) {
+	for _, lane := range self.lanes {
+		n += len(lane.queue)
+	}
+	return
+}
@@ -0,0 +1,95 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+)
+
+// 串行规则的请求按加入顺序逐个取出，前一个释放前不取出下一个，其他规则照常取出
+func TestSerialFIFO(t *testing.T) {
+	m := testMatrix(t, 10)
+	m.SetSerial("s")
+	m.PushBatch([]*request.Request{
+		testRequest("http://a/1", "s"),
+		testRequest("http://a/2", "s"),
+		testRequest("http://a/3", "s"),
+		testRequest("http://b/1", "r"),
+	})
+
+	first := m.Pull()
+	if first.GetUrl() != "http://a/1" {
+		t.Fatalf("first = %v, want http://a/1", first.GetUrl())
+	}
+	m.Use(first)
+	if req := m.Pull(); req == nil || req.GetUrl() != "http://b/1" {
+		t.Fatalf("got %v while the lane is busy, want http://b/1", req)
+	}
+	if req := m.Pull(); req != nil {
+		t.Fatalf("got %v while the lane is busy, want nil", req.GetUrl())
+	}
+	m.Free(first)
+
+	for _, want := range []string{"http://a/2", "http://a/3"} {
+		req := m.Pull()
+		if req == nil || req.GetUrl() != want {
+			t.Fatalf("got %v, want %v", req, want)
+		}
+		m.Use(req)
+		m.Free(req)
+	}
+}
+
+// 失败的串行请求回到队首，先于后续请求重新执行
+func TestSerialRetry(t *testing.T) {
+	m := testMatrix(t, 10)
+	m.SetSerial("s")
+	failed := testRequest("http://a/1", "s")
+	failed.SetMaxRetries(1)
+	m.PushBatch([]*request.Request{failed, testRequest("http://a/2", "s")})
+
+	req := m.Pull()
+	m.Use(req)
+	if !m.DoHistory(req, false) {
+		t.Fatal("DoHistory did not report a new failure")
+	}
+	m.Free(req)
+	if req = m.Pull(); req == nil || req.GetUrl() != "http://a/1" {
+		t.Fatalf("got %v, want the retried http://a/1", req)
+	}
+	m.Use(req)
+	// 重新执行次数用尽后不再放回
+	m.DoHistory(req, false)
+	m.Free(req)
+	if req = m.Pull(); req == nil || req.GetUrl() != "http://a/2" {
+		t.Fatalf("got %v, want http://a/2", req)
+	}
+}
+
+// 放弃或放回的串行请求均释放队列，放回的请求仍排在队首
+func TestSerialRelease(t *testing.T) {
+	m := testMatrix(t, 10)
+	m.SetSerial("s")
+	m.PushBatch([]*request.Request{
+		testRequest("http://a/1", "s"),
+		testRequest("http://a/2", "s"),
+		testRequest("http://a/3", "s"),
+	})
+
+	m.Skip(m.Pull())
+	req := m.Pull()
+	if req == nil || req.GetUrl() != "http://a/2" {
+		t.Fatalf("got %v after Skip, want http://a/2", req)
+	}
+	m.Requeue(req)
+	if n := m.Len(); n != 2 {
+		t.Fatalf("Len() = %d after Requeue, want 2", n)
+	}
+	if req = m.Pull(); req == nil || req.GetUrl() != "http://a/2" {
+		t.Fatalf("got %v after Requeue, want http://a/2", req)
+	}
+	m.Skip(req)
+	if req = m.Pull(); req == nil || req.GetUrl() != "http://a/3" {
+		t.Fatalf("got %v, want http://a/3", req)
+	}
+}
@@ -0,0 +1,199 @@
+package scheduler
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+	"github.com/henrylee2cn/pholcus/logs"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+// 抓取策略，即同一优先级的请求的取出顺序
+const (
+	STRATEGY_BFS = "bfs" // 广度优先，先进先出
+	STRATEGY_DFS = "dfs" // 深度优先，后进先出
+)
+
+// 请求队列的存储后端，每个Spider实例拥有独立的存储
+// Push、Pop、Len、Reset由Matrix在加锁状态下调用；SeenAndAdd、Forget可能被并发调用，须自行保证并发安全
+type QueueStore interface {
+	// 添加请求到队列
+	Push(req *request.Request)
+	// 按调度顺序取出下一个请求，队列为空时返回nil
+	Pop() *request.Request
+	// 返回队列中的请求数
+	Len() int
+	// 返回指纹是否已存在，不存在时将其添加，用于入队去重
+	SeenAndAdd(fingerprint string) bool
+	// 移除指纹，请求处理完毕后调用，以便失败的请求可重新入队
+	Forget(fingerprint string)
+	// 清空队列及指纹
+	Reset()
+}
+
+// 可选接口，按调度顺序遍历队列中的请求，用于生成队列快照
+type QueueWalker interface {
+	Walk(fn func(req *request.Request, pushTime time.Time))
+}
+
+// 已注册的请求队列存储后端，[名称]构造函数，由配置项run::queuestore选择
+var QueueStores = map[string]func(spiderName, spiderSubName string) QueueStore{
+	"memory": func(string, string) QueueStore { return newMemoryStore() },
+}
+
+// 创建配置项指定的存储后端，不存在时采用内存存储
+func newQueueStore(spiderName, spiderSubName string) QueueStore {
+	name := cache.Task.QueueStore
+	if name == "" {
+		name = "memory"
+	}
+	newStore, ok := QueueStores[name]
+	if !ok {
+		logs.Log.Error(" *     请求队列存储后端 [%v] 不存在，已改用内存存储\n", name)
+		newStore = QueueStores["memory"]
+	}
+	return newStore(spiderName, spiderSubName)
+}
+
+// 内存存储，按优先级分队列，同一优先级内按抓取策略排序，支持优先级老化
+type memoryStore struct {
+	reqs       map[int][]*request.Request     // [优先级]队列，优先级默认为0
+	priorities []int                          // 优先级顺序，从低到高
+	pushTime   map[*request.Request]time.Time // 请求入队时间，用于优先级老化
+	seen       map[string]bool                // 临时记录 [reqUnique(url+method)]true
+	seenLock   sync.RWMutex
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		reqs:       make(map[int][]*request.Request),
+		priorities: []int{},
+		pushTime:   make(map[*request.Request]time.Time),
+		seen:       make(map[string]bool),
+	}
+}
+
+func (self *memoryStore) Push(req *request.Request) {
+	var priority = req.GetPriority()
+
+	// 初始化该蜘蛛下该优先级队列
+	if _, found := self.reqs[priority]; !found {
+		self.priorities = append(self.priorities, priority)
+		sort.Ints(self.priorities) // 从小到大排序
+		self.reqs[priority] = []*request.Request{}
+	}
+
+	// 添加请求到队列
+	self.reqs[priority] = append(self.reqs[priority], req)
+	self.pushTime[req] = time.Now()
+}
+
+func (self *memoryStore) Pop() *request.Request {
+	idx, ok := self.pick()
+	if !ok {
+		return nil
+	}
+	var (
+		q   = self.reqs[idx]
+		req *request.Request
+	)
+	if depthFirst() {
+		req = q[len(q)-1]
+		self.reqs[idx] = q[:len(q)-1]
+	} else {
+		req = q[0]
+		self.reqs[idx] = q[1:]
+	}
+	delete(self.pushTime, req)
+	return req
+}
+
+// 选出下一个待取出请求所在的优先级队列
+// 未开启优先级老化时，按优先级从高到低选取；
+// 开启后，各队列下一个待取出请求的有效优先级 = 优先级 + 等待秒数 * 老化速率，取最大者，相同时取原优先级高者
+func (self *memoryStore) pick() (idx int, ok bool) {
+	aging := cache.Task.PriorityAging
+	if aging <= 0 {
+		for i := len(self.priorities) - 1; i >= 0; i-- {
+			if len(self.reqs[self.priorities[i]]) > 0 {
+				return self.priorities[i], true
+			}
+		}
+		return
+	}
+	var (
+		now  = time.Now()
+		dfs  = depthFirst()
+		best float64
+	)
+	for i := len(self.priorities) - 1; i >= 0; i-- {
+		p := self.priorities[i]
+		q := self.reqs[p]
+		if len(q) == 0 {
+			continue
+		}
+		next := q[0]
+		if dfs {
+			next = q[len(q)-1]
+		}
+		effective := float64(p) + now.Sub(self.pushTime[next]).Seconds()*aging
+		if !ok || effective > best {
+			idx, best, ok = p, effective, true
+		}
+	}
+	return
+}
+
+func (self *memoryStore) Len() int {
+	var l int
+	for _, reqs := range self.reqs {
+		l += len(reqs)
+	}
+	return l
+}
+
+func (self *memoryStore) SeenAndAdd(fingerprint string) bool {
+	self.seenLock.Lock()
+	defer self.seenLock.Unlock()
+	if self.seen[fingerprint] {
+		return true
+	}
+	self.seen[fingerprint] = true
+	return false
+}
+
+func (self *memoryStore) Forget(fingerprint string) {
+	self.seenLock.Lock()
+	delete(self.seen, fingerprint)
+	self.seenLock.Unlock()
+}
+
+func (self *memoryStore) Reset() {
+	self.reqs = make(map[int][]*request.Request)
+	self.priorities = []int{}
+	self.pushTime = make(map[*request.Request]time.Time)
+	self.seenLock.Lock()
+	self.seen = make(map[string]bool)
+	self.seenLock.Unlock()
+}
+
+// 按优先级从高到低、同一优先级内按抓取策略的顺序遍历
+func (self *memoryStore) Walk(fn func(req *request.Request, pushTime time.Time)) {
+	dfs := depthFirst()
+	for i := len(self.priorities) - 1; i >= 0; i-- {
+		q := self.reqs[self.priorities[i]]
+		for j := range q {
+			if dfs {
+				j = len(q) - 1 - j
+			}
+			fn(q[j], self.pushTime[q[j]])
+		}
+	}
+}
+
+// 是否采用深度优先的抓取策略
+func depthFirst() bool {
+	return cache.Task.Strategy == STRATEGY_DFS
+}
@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+// 内存存储按优先级从高到低取出，同一优先级内按抓取策略排序
+func TestMemoryStoreOrder(t *testing.T) {
+	defer func(s string) { cache.Task.Strategy = s }(cache.Task.Strategy)
+	for strategy, want := range map[string][]string{
+		STRATEGY_BFS: {"http://a/3", "http://a/1", "http://a/2"},
+		STRATEGY_DFS: {"http://a/3", "http://a/2", "http://a/1"},
+	} {
+		cache.Task.Strategy = strategy
+		store := newMemoryStore()
+		store.Push(testRequest("http://a/1", "r"))
+		store.Push(testRequest("http://a/2", "r"))
+		store.Push(testRequest("http://a/3", "r").SetPriority(1))
+		if n := store.Len(); n != 3 {
+			t.Fatalf("%s: Len() = %d, want 3", strategy, n)
+		}
+		for _, url := range want {
+			if req := store.Pop(); req == nil || req.GetUrl() != url {
+				t.Fatalf("%s: got %v, want %v", strategy, req, url)
+			}
+		}
+		if req := store.Pop(); req != nil {
+			t.Fatalf("%s: got %v from an empty store", strategy, req.GetUrl())
+		}
+	}
+}
+
+func TestMemoryStoreSeen(t *testing.T) {
+	store := newMemoryStore()
+	if store.SeenAndAdd("a") || !store.SeenAndAdd("a") {
+		t.Fatal("SeenAndAdd did not remember the fingerprint")
+	}
+	store.Forget("a")
+	if store.SeenAndAdd("a") {
+		t.Fatal("fingerprint remembered after Forget")
+	}
+	store.Push(testRequest("http://a/1", "r"))
+	store.Reset()
+	if store.Len() != 0 || store.SeenAndAdd("a") {
+		t.Fatal("Reset kept requests or fingerprints")
+	}
+}
+
+// run::queuestore选择已注册的存储后端，不存在时采用内存存储
+func TestNewQueueStore(t *testing.T) {
+	defer func(s string) { cache.Task.QueueStore = s }(cache.Task.QueueStore)
+	custom := newMemoryStore()
+	QueueStores["custom"] = func(string, string) QueueStore { return custom }
+	defer delete(QueueStores, "custom")
+
+	cache.Task.QueueStore = "custom"
+	if store := newQueueStore("test", ""); store != custom {
+		t.Errorf("got %T, want the registered store", store)
+	}
+	cache.Task.QueueStore = "unknown"
+	if _, ok := newQueueStore("test", "").(*memoryStore); !ok {
+		t.Error("unknown store did not fall back to memory")
+	}
+}
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/henrylee2cn/pholcus/app/spider"
+	"github.com/henrylee2cn/pholcus/logs"
+)
+
+type (
+	// 接收种子URL的请求体
+	seedBody struct {
+		Spider string   // 蜘蛛名，含Keyin时可为"蜘蛛名__二级标识名"，当前任务仅有一个蜘蛛时可省略
+		Rule   string   // 规则名，蜘蛛仅有一个规则时可省略
+		Urls   []string // 种子URL列表
+	}
+	// 接收种子URL的结果
+	seedResult struct {
+		Accepted int      // 加入队列的URL数，重复的URL亦计入，由去重机制忽略
+		Errors   []string `json:",omitempty"` // 未能加入队列的URL及原因
+	}
+)
+
+// 向当前任务中运行的蜘蛛加入种子URL，返回加入队列的数量及各URL的错误
+func (self *Logic) addSeeds(spiderName, rule string, urls []string) (int, []error, error) {
+	if !self.IsRunning() {
+		return 0, nil, fmt.Errorf("no running task")
+	}
+	sp, err := self.findSpider(spiderName)
+	if err != nil {
+		return 0, nil, err
+	}
+	var (
+		count int
+		errs  []error
+	)
+	for _, u := range urls {
+		if err := sp.AddSeed(u, rule); err != nil {
+			errs = append(errs, fmt.Errorf("%v: %v", u, err))
+			continue
+		}
+		count++
+	}
+	return count, errs, nil
+}
+
+// 按名称查找当前任务中的蜘蛛，name为空且仅有一个蜘蛛时返回该蜘蛛
+func (self *Logic) findSpider(name string) (*spider.Spider, error) {
+	var found []*spider.Spider
+	for _, sp := range self.SpiderQueue.GetAll() {
+		fullName := sp.GetName()
+		if sp.GetSubName() != "" {
+			fullName += "__" + sp.GetSubName()
+		}
+		if name == "" || name == sp.GetName() || name == fullName {
+			found = append(found, sp)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("spider %q not found", name)
+	case 1:
+		return found[0], nil
+	default:
+		return nil, fmt.Errorf("spider %q is ambiguous, use name__subname", name)
+	}
+}
+
+// 开启接收种子URL的HTTP服务，于任务结束时关闭；该服务无身份验证，故仅监听本机回环地址，见seedListenAddr()；
+// 请求方式为 POST /seeds，请求体为json格式，如 {"Spider":"蜘蛛名","Rule":"规则名","Urls":["http://..."]}
+func (self *Logic) startSeedServer(addr string) *http.Server {
+	addr = seedListenAddr(addr)
+	if self.AppConf.StopGrace <= 0 {
+		logs.Log.Warning(" *     未设置stopgrace，请求队列为空时任务将立即结束，不再接收种子URL\n")
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/seeds", self.seeds)
+	server := &http.Server{Handler: mux}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		logs.Log.Error(" *     种子URL接收服务启动失败: %v\n", err)
+		return nil
+	}
+	logs.Log.Informational(" *     种子URL接收服务已开启: http://%v/seeds\n", ln.Addr())
+	go server.Serve(ln)
+	return server
+}
+
+// 返回种子URL接收服务实际监听的地址：未指定主机或主机不是本机回环地址时，改为监听127.0.0.1的同一端口
+func seedListenAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return addr
+	}
+	if host != "" {
+		logs.Log.Warning(" *     种子URL接收服务无身份验证，仅监听本机回环地址，忽略主机 %v\n", host)
+	}
+	return net.JoinHostPort("127.0.0.1", port)
+}
+
+// 接收种子URL并加入请求队列，以json格式返回结果
+func (self *Logic) seeds(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body seedBody
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	count, errs, err := self.addSeeds(body.Spider, body.Rule, body.Urls)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result := seedResult{Accepted: count}
+	for _, err := range errs {
+		result.Errors = append(result.Errors, err.Error())
+	}
+	if count > 0 {
+		logs.Log.Informational(" *     从种子URL接收服务加入 %v 条请求\n", count)
+	}
+	b, _ := json.Marshal(result)
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rw.Write(b)
+}
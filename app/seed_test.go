@@ -0,0 +1,20 @@
+package app
+
+import "testing"
+
+// 种子URL接收服务只监听本机回环地址
+func TestSeedListenAddr(t *testing.T) {
+	for addr, want := range map[string]string{
+		":9091":          "127.0.0.1:9091",
+		"0.0.0.0:9091":   "127.0.0.1:9091",
+		"10.0.0.5:9091":  "127.0.0.1:9091",
+		"[::]:9091":      "127.0.0.1:9091",
+		"127.0.0.1:9091": "127.0.0.1:9091",
+		"localhost:9091": "localhost:9091",
+		"[::1]:9091":     "[::1]:9091",
+	} {
+		if got := seedListenAddr(addr); got != want {
+			t.Errorf("seedListenAddr(%q) = %q, want %q", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package spider
+
+import (
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+)
+
+// 规则解析的响应内容来源，即Rule.Body的可选值
+const (
+	BODY_AUTO     = iota // 按请求的DownloaderID下载
+	BODY_SOURCE          // 以Surf内核下载网络原始响应，速度快
+	BODY_RENDERED        // 以PhantomJS内核下载执行js渲染后的网页，速度慢
+	BODY_BOTH            // 两者均下载，GetText()、GetDom()等为渲染后的内容，原始响应可通过GetSourceText()、GetSourceDom()读取
+)
+
+// 按规则的Body设置请求所用的下载内核，返回是否须另行下载网络原始响应；WebSocket请求不受影响
+func (self *Spider) SetBodyDownloader(req *request.Request) bool {
+	rule, found := self.GetRule(req.GetRuleName())
+	if !found || req.GetDownloaderID() == request.WS_ID {
+		return false
+	}
+	switch rule.Body {
+	case BODY_SOURCE:
+		req.SetDownloaderID(request.SURF_ID)
+	case BODY_RENDERED, BODY_BOTH:
+		req.SetDownloaderID(request.PHANTOM_ID)
+	}
+	return rule.Body == BODY_BOTH
+}
+
+// 设置同时下载的网络原始响应所对应的上下文，随本上下文一同回收
+func (self *Context) SetSourceContext(source *Context) *Context {
+	self.source = source
+	return self
+}
+
+// 返回网络原始响应的文本内容，仅下载了渲染后的网页时返回空字符串
+func (self *Context) GetSourceText() string {
+	if self.source != nil {
+		return self.source.GetText()
+	}
+	if self.Request.GetDownloaderID() == request.PHANTOM_ID {
+		return ""
+	}
+	return self.GetText()
+}
+
+// 返回网络原始响应的Dom，仅下载了渲染后的网页时返回nil
+func (self *Context) GetSourceDom() *goquery.Document {
+	if self.source != nil {
+		return self.source.GetDom()
+	}
+	if self.Request.GetDownloaderID() == request.PHANTOM_ID {
+		return nil
+	}
+	return self.GetDom()
+}
+
+// 标记为渲染失败后改以Surf内核下载的上下文，err为渲染失败的原因
+func (self *Context) SetRenderError(err error) *Context {
+	self.renderErr = err
+	return self
+}
+
+// 开启Spider.RenderFallback且渲染失败时，返回渲染失败的原因，此时响应为未执行js的网络原始响应；否则返回nil
+func (self *Context) GetRenderError() error {
+	return self.renderErr
+}
+
+// 返回js渲染后网页的文本内容，未以PhantomJS内核下载时返回空字符串
+func (self *Context) GetRenderedText() string {
+	if self.Request.GetDownloaderID() != request.PHANTOM_ID {
+		return ""
+	}
+	return self.GetText()
+}
+
+// 返回js渲染后网页的Dom，未以PhantomJS内核下载时返回nil
+func (self *Context) GetRenderedDom() *goquery.Document {
+	if self.Request.GetDownloaderID() != request.PHANTOM_ID {
+		return nil
+	}
+	return self.GetDom()
+}
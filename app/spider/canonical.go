@@ -0,0 +1,72 @@
+package spider
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+// 返回网页<link rel="canonical">中的规范网址，已解析为绝对地址并去除锚点，
+// 非HTML响应或未声明时返回空字符串
+func (self *Context) GetCanonical() string {
+	if self.canonical != nil {
+		return *self.canonical
+	}
+	var canonical string
+	if self.Response != nil && self.IsHTML() {
+		self.GetDom().Find("link[rel]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+			rel, _ := s.Attr("rel")
+			if !hasToken(rel, "canonical") {
+				return true
+			}
+			href, _ := s.Attr("href")
+			canonical = self.resolveUrl(href)
+			return false
+		})
+	}
+	self.canonical = &canonical
+	return canonical
+}
+
+// 将href解析为相对于响应地址的绝对地址，并去除锚点，无法解析时返回空字符串
+func (self *Context) resolveUrl(href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" {
+		return ""
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	if self.Response.Request != nil && self.Response.Request.URL != nil {
+		ref = self.Response.Request.URL.ResolveReference(ref)
+	}
+	if !ref.IsAbs() {
+		return ""
+	}
+	ref.Fragment = ""
+	return ref.String()
+}
+
+// 以空白字符分隔的属性值中是否含有指定项，不区分大小写
+func hasToken(value, token string) bool {
+	for _, v := range strings.Fields(value) {
+		if strings.EqualFold(v, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// 结果数据的Url字段，开启规范网址去重且网页声明了规范网址时采用规范网址
+func (self *Context) itemUrl() string {
+	if cache.Task.Canonical {
+		if canonical := self.GetCanonical(); canonical != "" {
+			return canonical
+		}
+	}
+	return self.GetUrl()
+}
@@ -0,0 +1,55 @@
+package spider
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+	"github.com/henrylee2cn/pholcus/app/pipeline/collector/data"
+	"github.com/henrylee2cn/pholcus/logs"
+)
+
+// 返回结果的幂等键，由请求指纹、规则名及字段值的哈希组成；
+// n为同一请求中字段值完全相同的结果的序号，以免同页中重复的行被误判为重试产生的结果
+func itemKey(req *request.Request, ruleName, hash string, n int) string {
+	return req.Unique() + "|" + ruleName + "|" + hash + "|" + strconv.Itoa(n)
+}
+
+// 字段值的哈希，json编码时map的键已排序，无法编码时按fmt格式化
+func fieldsHash(fields interface{}) string {
+	b, err := json.Marshal(fields)
+	if err != nil {
+		b = []byte(fmt.Sprintf("%v", fields))
+	}
+	return fmt.Sprintf("%x", sha1.Sum(b))
+}
+
+// 丢弃同一请求已收集过的结果，返回其余结果，并以data.ITEM_KEY附带其幂等键，由收集管道在输出成功后记录；
+// 解析失败或输出失败时幂等键均不记录，请求重试或断点续抓后仍可完整收集
+func (self *Spider) CollectOnce(req *request.Request, items []data.DataCell) []data.DataCell {
+	var (
+		kept    = items[:0]
+		counts  = make(map[string]int, len(items))
+		skipped int
+	)
+	for _, item := range items {
+		ruleName, _ := item["RuleName"].(string)
+		hash := fieldsHash(item["Data"])
+		n := counts[ruleName+"|"+hash]
+		counts[ruleName+"|"+hash]++
+		key := itemKey(req, ruleName, hash, n)
+		if self.SeenItem(key) {
+			data.PutDataCell(item)
+			skipped++
+			continue
+		}
+		item[data.ITEM_KEY] = key
+		kept = append(kept, item)
+	}
+	if skipped > 0 {
+		logs.Log.Informational(" *     Skip  [collected]: %v 条结果已收集过 %v\n", skipped, req.GetUrl())
+	}
+	return kept
+}
@@ -2,10 +2,12 @@ package spider
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
 	"mime"
 	"net/http"
 	"path"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,27 +20,31 @@ import (
 	"github.com/henrylee2cn/pholcus/app/pipeline/collector/data"
 	"github.com/henrylee2cn/pholcus/common/util"
 	"github.com/henrylee2cn/pholcus/logs"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
 )
 
 type Context struct {
-	spider   *Spider           // 规则
-	Request  *request.Request  // 原始请求
-	Response *http.Response    // 响应流，其中URL拷贝自*request.Request
-	text     []byte            // 下载内容Body的字节流格式
-	dom      *goquery.Document // 下载内容Body为html时，可转换为Dom的对象
-	items    []data.DataCell   // 存放以文本形式输出的结果数据
-	files    []data.FileCell   // 存放欲直接输出的文件("Name": string; "Body": io.ReadCloser)
-	err      error             // 错误标记
+	spider    *Spider           // 规则
+	Request   *request.Request  // 原始请求
+	Response  *http.Response    // 响应流，其中URL拷贝自*request.Request
+	text      []byte            // 下载内容Body的字节流格式
+	raw       []byte            // 转码前的原始下载内容，与text相同时为nil
+	rawHash   string            // 原始下载内容的sha256，已保存时不为空
+	dom       *goquery.Document // 下载内容Body为html时，可转换为Dom的对象
+	canonical *string           // 网页声明的规范网址，读取后缓存
+	source    *Context          // Rule.Body为BODY_BOTH时，同时下载的网络原始响应所对应的上下文
+	renderErr error             // 渲染失败后改以Surf内核下载时，渲染失败的原因
+	items     []data.DataCell   // 存放以文本形式输出的结果数据
+	files     []data.FileCell   // 存放欲直接输出的文件("Name": string; "Body": io.ReadCloser)
+	err       error             // 错误标记
+	pooled    bool              // 是否曾放回复用池，取出时据此统计命中次数
 	sync.Mutex
 }
 
 var (
 	contextPool = &sync.Pool{
 		New: func() interface{} {
-			return &Context{
-				items: []data.DataCell{},
-				files: []data.FileCell{},
-			}
+			return newContext()
 		},
 	}
 )
@@ -46,22 +52,31 @@ var (
 //**************************************** 初始化 *******************************************\\
 
 func GetContext(sp *Spider, req *request.Request) *Context {
-	ctx := contextPool.Get().(*Context)
+	ctx := sp.getContext()
 	ctx.spider = sp
 	ctx.Request = req
 	return ctx
 }
 
 func PutContext(ctx *Context) {
+	sp := ctx.spider
 	ctx.items = ctx.items[:0]
 	ctx.files = ctx.files[:0]
 	ctx.spider = nil
 	ctx.Request = nil
 	ctx.Response = nil
 	ctx.text = nil
+	ctx.raw = nil
+	ctx.rawHash = ""
 	ctx.dom = nil
+	ctx.canonical = nil
+	if ctx.source != nil {
+		PutContext(ctx.source)
+		ctx.source = nil
+	}
+	ctx.renderErr = nil
 	ctx.err = nil
-	contextPool.Put(ctx)
+	putContext(sp, ctx)
 }
 
 func (self *Context) SetResponse(resp *http.Response) *Context {
@@ -85,22 +100,62 @@ func (self *Context) SetError(err error) {
 // Request.DialTimeout默认为常量request.DefaultDialTimeout，小于0时不限制等待响应时长;
 // Request.ConnTimeout默认为常量request.DefaultConnTimeout，小于0时不限制下载超时;
 // Request.TryTimes默认为常量request.DefaultTryTimes，小于0时不限制失败重载次数;
+// Request.MaxRetries默认采用全局设置，小于0时失败后不再重新执行;
 // Request.RedirectTimes默认不限制重定向次数，小于0时可禁止重定向跳转;
 // Request.RetryPause默认为常量request.DefaultRetryPause;
-// Request.DownloaderID指定下载器ID，0为默认的Surf高并发下载器，功能完备，1为PhantomJS下载器，特点破防力强，速度慢，低并发。
+// Request.Cost默认为1，即处理时占用1份并发量，超出全局并发量时以全局并发量为准;
+// Request.DownloaderID指定下载器ID，0为默认的Surf高并发下载器，功能完备，1为PhantomJS下载器，特点破防力强，速度慢，低并发，2为WebSocket下载器。
 // 默认自动补填Referer。
 func (self *Context) AddQueue(req *request.Request) *Context {
 	// 若已主动终止任务，则崩溃爬虫协程
 	self.spider.tryPanic()
 
+	if self.prepareRequest(req) {
+		self.spider.RequestPush(req)
+	}
+	return self
+}
+
+// 延时delay后再将请求加入队列，如提交作业30秒后再查询其状态；到期前该请求不会被派发，尚有未到期的请求时任务不会结束。
+// 预处理与AddQueue()相同，去重在到期入队时执行；未到期的请求仅保存在内存中，进程退出即丢失
+func (self *Context) AddQueueDelayed(req *request.Request, delay time.Duration) *Context {
+	// 若已主动终止任务，则崩溃爬虫协程
+	self.spider.tryPanic()
+
+	if self.prepareRequest(req) {
+		self.spider.RequestPushDelayed(req, delay)
+	}
+	return self
+}
+
+// 批量添加请求到队列，仅对请求队列加锁一次，适用于一次解析出大量链接的页面。
+// 各请求的预处理及去重与AddQueue()相同，预处理失败的请求被忽略。
+func (self *Context) AddQueueBatch(reqs []*request.Request) *Context {
+	// 若已主动终止任务，则崩溃爬虫协程
+	self.spider.tryPanic()
+
+	prepared := make([]*request.Request, 0, len(reqs))
+	for _, req := range reqs {
+		if self.prepareRequest(req) {
+			prepared = append(prepared, req)
+		}
+	}
+	self.spider.RequestPushBatch(prepared)
+	return self
+}
+
+// 添加请求前的预处理，返回是否成功
+func (self *Context) prepareRequest(req *request.Request) bool {
 	err := req.
+		Inherit(self.spider.GetDefaultRequest()).
+		InheritMeta(self.Request).
 		SetSpiderName(self.spider.GetName()).
 		SetEnableCookie(self.spider.GetEnableCookie()).
 		Prepare()
 
 	if err != nil {
 		logs.Log.Error(err.Error())
-		return self
+		return false
 	}
 
 	// 自动设置Referer
@@ -108,8 +163,31 @@ func (self *Context) AddQueue(req *request.Request) *Context {
 		req.SetReferer(self.GetUrl())
 	}
 
-	self.spider.RequestPush(req)
-	return self
+	// 预热种子请求所在主机的连接
+	if self.Response == nil {
+		self.spider.warm(req)
+	}
+	return true
+}
+
+// 添加分页请求的首页，req.Rule所指规则须设置Pager。
+// 此后该规则每页解析完成时，根据Pager.Count的返回值自动添加下一页请求，
+// 直至返回0或达到Pager.Max页。
+func (self *Context) AddPages(req *request.Request) *Context {
+	rule, found := self.spider.GetRule(req.GetRuleName())
+	if !found || rule.Pager == nil {
+		logs.Log.Error("蜘蛛 %s 调用AddPages()时，规则 %s 不存在或未设置Pager！", self.spider.GetName(), req.GetRuleName())
+		return self
+	}
+	if req.Temp == nil {
+		req.Temp = make(request.Temp)
+	}
+	req, err := rule.Pager.request(req, 1)
+	if err != nil {
+		logs.Log.Error(err.Error())
+		return self
+	}
+	return self.AddQueue(req)
 }
 
 // 用于动态规则添加请求。
@@ -136,7 +214,12 @@ func (self *Context) JsAddQueue(jreq map[string]interface{}) *Context {
 		}
 	}
 	req.PostData, _ = jreq["PostData"].(string)
+	req.GzipBody, _ = jreq["GzipBody"].(bool)
 	req.Reloadable, _ = jreq["Reloadable"].(bool)
+	req.Checksum, _ = jreq["Checksum"].(string)
+	req.SampleRange, _ = jreq["SampleRange"].(string)
+	req.WsMessage, _ = jreq["WsMessage"].(string)
+	req.WsTerminator, _ = jreq["WsTerminator"].(string)
 	if t, ok := jreq["DialTimeout"].(int64); ok {
 		req.DialTimeout = time.Duration(t)
 	}
@@ -149,12 +232,21 @@ func (self *Context) JsAddQueue(jreq map[string]interface{}) *Context {
 	if t, ok := jreq["TryTimes"].(int64); ok {
 		req.TryTimes = int(t)
 	}
+	if t, ok := jreq["MaxRetries"].(int64); ok {
+		req.MaxRetries = int(t)
+	}
+	if t, ok := jreq["WsMaxMessages"].(int64); ok {
+		req.WsMaxMessages = int(t)
+	}
 	if t, ok := jreq["RedirectTimes"].(int64); ok {
 		req.RedirectTimes = int(t)
 	}
 	if t, ok := jreq["Priority"].(int64); ok {
 		req.Priority = int(t)
 	}
+	if t, ok := jreq["Cost"].(int64); ok {
+		req.Cost = int(t)
+	}
 	if t, ok := jreq["DownloaderID"].(int64); ok {
 		req.DownloaderID = int(t)
 	}
@@ -205,28 +297,95 @@ func (self *Context) Output(item interface{}, ruleName ...string) {
 		}
 		_item = item2
 	}
-	self.Lock()
+	// 规范化文本字段值
+	if self.spider.Normalize || rule.Normalize {
+		_item = normalizeItem(rule, _item)
+	}
+	// 附带原始响应内容
+	if rule.Raw != RAW_NONE && self.Response != nil {
+		self.attachRaw(rule, _item)
+	}
+	// 附带重定向链
+	if rule.RedirectChain != 0 && self.Response != nil {
+		self.attachRedirectChain(rule, _item)
+	}
+	// 附带区域变体名
+	if self.Request != nil {
+		if v := self.Request.GetVariant(); v != "" {
+			self.spider.UpsertItemField(rule, VARIANT_FIELD)
+			_item[VARIANT_FIELD] = v
+		}
+	}
+	var cell data.DataCell
 	if self.spider.NotDefaultField {
-		self.items = append(self.items, data.GetDataCell(_ruleName, _item, "", "", ""))
+		cell = data.GetDataCell(_ruleName, _item, "", "", "")
 	} else {
-		self.items = append(self.items, data.GetDataCell(_ruleName, _item, self.GetUrl(), self.GetReferer(), time.Now().Format("2006-01-02 15:04:05")))
+		cell = data.GetDataCell(_ruleName, _item, self.itemUrl(), self.GetReferer(), time.Now().Format("2006-01-02 15:04:05"))
+	}
+	// 附带来源请求，用于输出清单文件
+	if cache.Task.Manifest {
+		cell[data.SOURCE] = self.itemSource()
 	}
+	self.Lock()
+	self.items = append(self.items, cell)
 	self.Unlock()
 }
 
+// 结果数据的来源请求
+func (self *Context) itemSource() *data.Source {
+	src := &data.Source{Time: time.Now()}
+	if self.Request != nil {
+		src.Url = self.GetUrl()
+		src.Rule = self.GetRuleName()
+		src.Method = self.GetMethod()
+	}
+	if self.Response != nil {
+		src.StatusCode = self.Response.StatusCode
+	}
+	return src
+}
+
 // 输出文件。
-// name指定文件名，为空时默认保持原文件名不变。
+// name指定文件名，为空时采用Request.FileName，仍为空时默认保持原文件名不变。
+// 开启断点续传时，下载中断前已读取的部分仍会输出，响应为206时从Content-Range的起始位置续写。
 func (self *Context) FileOutput(name ...string) {
 	// 读取完整文件流
 	bytes, err := ioutil.ReadAll(self.Response.Body)
 	self.Response.Body.Close()
-	if err != nil {
+	if err != nil && !(cache.Task.FileResume && len(bytes) > 0) {
 		panic(err.Error())
 		return
 	}
 
-	// 智能设置完整文件名
-	_, s := path.Split(self.GetUrl())
+	if len(name) == 0 && self.Request.GetFileName() != "" {
+		name = []string{self.Request.GetFileName()}
+	}
+
+	file := data.GetFileCell(self.GetRuleName(), FileName(self.GetUrl(), name...), bytes, self.GetUrl())
+	if self.Response.StatusCode == http.StatusPartialContent {
+		var offset int64
+		fmt.Sscanf(self.Response.Header.Get("Content-Range"), "bytes %d-", &offset)
+		file["Offset"] = offset
+	}
+	file["Partial"] = err != nil
+	if algorithm, sum := self.Request.GetChecksum(); algorithm != "" {
+		file["Checksum"] = [2]string{algorithm, sum}
+	}
+
+	// 保存到文件临时队列
+	self.Lock()
+	self.files = append(self.files, file)
+	self.Unlock()
+
+	if err != nil {
+		panic(err.Error())
+	}
+}
+
+// 根据文件的下载地址及指定的文件名，智能设置完整文件名。
+// name为空时保持原文件名不变，缺少扩展名时沿用原文件的扩展名，均无扩展名时为.html。
+func FileName(url string, name ...string) string {
+	_, s := path.Split(url)
 	n := strings.Split(s, "?")[0]
 
 	baseName := strings.Split(n, ".")[0]
@@ -245,11 +404,7 @@ func (self *Context) FileOutput(name ...string) {
 	if ext == "" {
 		ext = ".html"
 	}
-
-	// 保存到文件临时队列
-	self.Lock()
-	self.files = append(self.files, data.GetFileCell(self.GetRuleName(), baseName+ext, bytes))
-	self.Unlock()
+	return baseName + ext
 }
 
 // 生成文本结果。
@@ -275,6 +430,13 @@ func (self *Context) SetTemp(key string, value interface{}) *Context {
 	return self
 }
 
+// 在当前请求中保存元数据，此后添加的子请求自动继承，在子规则中通过GetMeta()读取。
+// value须可被JSON编码，以便随请求队列序列化。
+func (self *Context) SetMeta(key string, value interface{}) *Context {
+	self.Request.SetMeta(key, value)
+	return self
+}
+
 func (self *Context) SetUrl(url string) *Context {
 	self.Request.Url = url
 	return self
@@ -327,6 +489,7 @@ func (self *Context) Parse(ruleName ...string) *Context {
 		return self
 	}
 	rule.ParseFunc(self)
+	self.nextPage(rule)
 	return self
 }
 
@@ -349,6 +512,36 @@ func (self *Context) SetPausetime(pause int64, runtime ...bool) *Context {
 	return self
 }
 
+// 动态设置后续请求的暂停时长参考/ms(随机: pause/2 ~ pause*2)，如响应显示已被限速时放慢全部规则的请求，
+// 覆盖全局及规则级的暂停设置，限制在0~MAX_PAUSE之间。
+func (self *Context) SetGlobalPause(pause int64) *Context {
+	old, adjusted := self.spider.GetAdjustedPause()
+	if pause = self.spider.AdjustPause(pause); pause != old || !adjusted {
+		logs.Log.Informational(" *     [%v] 规则 %v 将暂停时长调整为 %v~%v 毫秒\n", self.spider.GetName(), self.GetRuleName(), pause/2, pause*2)
+	}
+	return self
+}
+
+// 在当前暂停时长参考的基础上增加d（可为负数以恢复速度），见SetGlobalPause()；
+// 尚未动态调整过时以当前请求的RetryPause为基础，无请求时以全局暂停时长为基础。
+func (self *Context) Backoff(d time.Duration) *Context {
+	pause, ok := self.spider.GetAdjustedPause()
+	if !ok {
+		if self.Request != nil && self.Request.GetRetryPause() > 0 {
+			pause = int64(self.Request.GetRetryPause() / time.Millisecond)
+		} else {
+			pause = cache.Task.Pausetime
+		}
+	}
+	return self.SetGlobalPause(pause + int64(d/time.Millisecond))
+}
+
+// 停止本蜘蛛，如增量采集时已遇到早于截止日期的数据；不再派发新请求，处理中的请求正常完成后任务结束，
+// 区别于主动终止，本页及其他处理中的请求的解析不会中断，reason记录于日志。
+func (self *Context) StopSpider(reason string) {
+	self.spider.StopWith(reason)
+}
+
 // 设置定时器，
 // @id为定时器唯一标识，
 // @bell==nil时为倒计时器，此时@tol为睡眠时长，
@@ -362,6 +555,15 @@ func (self *Context) RunTimer(id string) bool {
 	return self.spider.RunTimer(id)
 }
 
+// 暂停使用当前请求所用的会话，用于在规则中识别出账号被封禁时调用，
+// d<=0时采用Spider.Sessions.BenchTime。
+func (self *Context) BenchSession(d time.Duration) *Context {
+	if self.spider.Sessions != nil && self.Request.GetSession() != "" {
+		self.spider.Sessions.Bench(self.Request.GetSession(), d)
+	}
+	return self
+}
+
 // 重置下载的文本内容，
 func (self *Context) ResetText(body string) *Context {
 	x := (*[2]uintptr)(unsafe.Pointer(&body))
@@ -393,6 +595,43 @@ func (self *Context) GetStatusCode() int {
 	return self.Response.StatusCode
 }
 
+// 解析采集到的时间字符串，支持"3分钟前"、"2 hours ago"、"昨天 14:30"等相对时间，
+// 以及layouts与util.TimeLayouts中的格式，相对时间以当前时刻为基准，均不匹配时返回错误。
+func (self *Context) ParseTime(raw string, layouts ...string) (time.Time, error) {
+	return util.ParseTime(raw, time.Now(), layouts...)
+}
+
+// 获取响应的媒体类型，即去除charset等参数并转为小写后的Content-Type，如"text/html"。
+func (self *Context) GetContentType() string {
+	if self.Response == nil {
+		return ""
+	}
+	contentType := self.Response.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mediaType
+	}
+	return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+}
+
+// 响应是否为JSON，包括application/json、text/json及+json后缀的媒体类型。
+func (self *Context) IsJSON() bool {
+	switch mediaType := self.GetContentType(); mediaType {
+	case "application/json", "text/json":
+		return true
+	default:
+		return strings.HasSuffix(mediaType, "+json")
+	}
+}
+
+// 响应是否为HTML，包括text/html与application/xhtml+xml。
+func (self *Context) IsHTML() bool {
+	switch self.GetContentType() {
+	case "text/html", "application/xhtml+xml":
+		return true
+	}
+	return false
+}
+
 // 获取原始请求。
 func (self *Context) GetRequest() *request.Request {
 	return self.Request
@@ -487,6 +726,12 @@ func (self *Context) GetTemp(key string, defaultValue interface{}) interface{} {
 	return self.Request.GetTemp(key, defaultValue)
 }
 
+// 获取当前请求的元数据，含从父请求继承的元数据
+// defaultValue 不能为 interface{}(nil)
+func (self *Context) GetMeta(key string, defaultValue interface{}) interface{} {
+	return self.Request.GetMeta(key, defaultValue)
+}
+
 // 获取请求中全部缓存数据
 func (self *Context) GetTemps() request.Temp {
 	return self.Request.GetTemps()
@@ -533,6 +778,22 @@ func (self *Context) GetCookie() string {
 	return self.Response.Header.Get("Set-Cookie")
 }
 
+// 获取响应通过Set-Cookie设置的全部cookie，经重定向时为最终响应所设置的cookie。
+func (self *Context) GetResponseCookies() []*http.Cookie {
+	return self.Response.Cookies()
+}
+
+// 获取响应所设置的指定名称的cookie值，不存在时返回空字符串，可作为结果字段输出，如
+// ctx.Output(map[string]interface{}{"token": ctx.GetResponseCookie("token")})。
+func (self *Context) GetResponseCookie(name string) string {
+	for _, c := range self.Response.Cookies() {
+		if c.Name == name {
+			return c.Value
+		}
+	}
+	return ""
+}
+
 // GetHtmlParser returns goquery object binded to target crawl result.
 func (self *Context) GetDom() *goquery.Document {
 	if self.dom == nil {
@@ -549,6 +810,78 @@ func (self *Context) GetText() string {
 	return util.Bytes2String(self.text)
 }
 
+// 解析selector选中的表格（或其中的首个表格），返回表头与数据行。
+// 位于thead中、或全部由th组成的开头行视为表头，多行表头按列以空格合并；
+// colspan/rowspan合并的单元格会在其覆盖的每个位置重复填充；单元格文本已去除多余空白。
+func (self *Context) GetTable(selector string) (header []string, rows [][]string) {
+	table := self.Find(selector).First()
+	if !table.Is("table") {
+		table = table.Find("table").First()
+	}
+	if table.Length() == 0 {
+		return
+	}
+
+	var (
+		spans   = make(map[int]*tableSpan) // [列号]向下延续的单元格
+		heading = true                     // 是否仍处于开头的表头行
+		headers [][]string
+	)
+	table.Find("tr").Each(func(_ int, tr *goquery.Selection) {
+		// 跳过嵌套表格中的行
+		if tr.Closest("table").Get(0) != table.Get(0) {
+			return
+		}
+		var (
+			row []string
+			col int
+		)
+		// 填充上方单元格rowspan延续下来的位置
+		fill := func() {
+			for span, ok := spans[col]; ok; span, ok = spans[col] {
+				row = append(row, span.text)
+				if span.left--; span.left == 0 {
+					delete(spans, col)
+				}
+				col++
+			}
+		}
+		tr.ChildrenFiltered("th,td").Each(func(_ int, cell *goquery.Selection) {
+			fill()
+			text := strings.Join(strings.Fields(cell.Text()), " ")
+			colspan, rowspan := tableSpanAttr(cell, "colspan"), tableSpanAttr(cell, "rowspan")
+			for i := 0; i < colspan; i++ {
+				row = append(row, text)
+				if rowspan > 1 {
+					spans[col] = &tableSpan{text: text, left: rowspan - 1}
+				}
+				col++
+			}
+		})
+		fill()
+
+		if heading && (tr.Parent().Is("thead") || tr.ChildrenFiltered("td").Length() == 0) {
+			headers = append(headers, row)
+			return
+		}
+		heading = false
+		rows = append(rows, row)
+	})
+
+	// 合并多行表头
+	for _, h := range headers {
+		for i, text := range h {
+			switch {
+			case i >= len(header):
+				header = append(header, text)
+			case text != "" && text != header[i]:
+				header[i] = strings.TrimSpace(header[i] + " " + text)
+			}
+		}
+	}
+	return
+}
+
 //**************************************** 私有方法 *******************************************\\
 
 // 获取规则。
@@ -571,7 +904,13 @@ func (self *Context) initDom() *goquery.Document {
 		self.initText()
 	}
 	var err error
-	self.dom, err = goquery.NewDocumentFromReader(bytes.NewReader(self.text))
+	if self.spider.ParseDom != nil {
+		if self.dom, err = self.spider.ParseDom(self, self.text); err == nil && self.dom == nil {
+			err = fmt.Errorf("ParseDom returned a nil document")
+		}
+	} else {
+		self.dom, err = goquery.NewDocumentFromReader(bytes.NewReader(self.text))
+	}
 	if err != nil {
 		panic(err.Error())
 	}
@@ -580,6 +919,10 @@ func (self *Context) initDom() *goquery.Document {
 
 // GetBodyStr returns plain string crawled.
 func (self *Context) initText() {
+	// gzip压缩的内容（如.gz文件）先流式解压
+	if err := self.gunzipBody(); err != nil {
+		panic(err.Error())
+	}
 	// 采用surf内核下载时，尝试自动转码
 	if self.Request.DownloaderID == request.SURF_ID {
 		var contentType, pageEncode string
@@ -607,11 +950,18 @@ func (self *Context) initText() {
 			// 指定了编码类型，但不是utf8时，自动转码为utf8
 			// get converter to utf-8
 			// Charset auto determine. Use golang.org/x/net/html/charset. Get response body and change it to utf-8
-			destReader, err := charset.NewReaderLabel(pageEncode, self.Response.Body)
+			// 保留转码前的原始内容
+			raw, err := ioutil.ReadAll(self.Response.Body)
+			self.Response.Body.Close()
+			if err != nil {
+				panic(err.Error())
+			}
+			self.Response.Body = ioutil.NopCloser(bytes.NewReader(raw))
+			destReader, err := charset.NewReaderLabel(pageEncode, bytes.NewReader(raw))
 			if err == nil {
 				self.text, err = ioutil.ReadAll(destReader)
 				if err == nil {
-					self.Response.Body.Close()
+					self.raw = raw
 					return
 				} else {
 					logs.Log.Warning(" *     [convert][%v]: %v (ignore transcoding)\n", self.GetUrl(), err)
@@ -633,6 +983,56 @@ func (self *Context) initText() {
 
 }
 
+// 分页请求解析完成后，当前页仍有条目时添加下一页请求
+func (self *Context) nextPage(rule *Rule) {
+	if rule.Pager == nil || self.Response == nil {
+		return
+	}
+	page := pagerPage(self.Request)
+	if page == 0 {
+		return
+	}
+	var count int
+	if rule.Pager.Count != nil {
+		count = rule.Pager.Count(self)
+	} else {
+		self.Lock()
+		count = len(self.items) + len(self.files)
+		self.Unlock()
+	}
+	if count <= 0 {
+		logs.Log.Informational(" *     [%v] 第 %v 页无条目，停止翻页\n", self.GetRuleName(), page)
+		return
+	}
+	if rule.Pager.Max > 0 && page >= rule.Pager.Max {
+		return
+	}
+	req, err := rule.Pager.request(self.CopyRequest(), page+1)
+	if err != nil {
+		logs.Log.Error(err.Error())
+		return
+	}
+	self.AddQueue(req)
+}
+
+// 表格中因rowspan向下延续的单元格
+type tableSpan struct {
+	text string
+	left int // 剩余的延续行数
+}
+
+// 读取单元格的colspan/rowspan属性，非法值按1处理，并限制最大值
+func tableSpanAttr(cell *goquery.Selection, name string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(cell.AttrOr(name, "1")))
+	switch {
+	case err != nil || n < 1:
+		return 1
+	case n > 1000:
+		return 1000
+	}
+	return n
+}
+
 /**
  * 编码类型参考
  * 不区分大小写
@@ -0,0 +1,60 @@
+package spider
+
+import (
+	"sync/atomic"
+
+	"github.com/henrylee2cn/pholcus/app/pipeline/collector/data"
+)
+
+// 新建Context
+func newContext() *Context {
+	return &Context{
+		items: []data.DataCell{},
+		files: []data.FileCell{},
+	}
+}
+
+// 按Spider.ContextPool创建该蜘蛛独占的Context复用池，由Start()调用
+func (self *Spider) initContextPool() {
+	if self.ContextPool > 0 && self.ctxPool == nil {
+		self.ctxPool = make(chan *Context, self.ContextPool)
+	}
+}
+
+// 从复用池取出Context，池中没有时新建，并累计命中与未命中次数
+func (self *Spider) getContext() *Context {
+	var ctx *Context
+	if self.ctxPool == nil {
+		ctx = contextPool.Get().(*Context)
+	} else {
+		select {
+		case ctx = <-self.ctxPool:
+		default:
+			ctx = newContext()
+		}
+	}
+	if ctx.pooled {
+		atomic.AddInt64(&self.ctxHits, 1)
+	} else {
+		atomic.AddInt64(&self.ctxMisses, 1)
+	}
+	return ctx
+}
+
+// 将已重置的Context放回复用池，独占的复用池已满时丢弃；sp为nil时放回全局共享的复用池
+func putContext(sp *Spider, ctx *Context) {
+	ctx.pooled = true
+	if sp == nil || sp.ctxPool == nil {
+		contextPool.Put(ctx)
+		return
+	}
+	select {
+	case sp.ctxPool <- ctx:
+	default:
+	}
+}
+
+// 获取从Context复用池取出时命中（复用）与未命中（新建）的次数，用于按并发量调整Spider.ContextPool，并发安全
+func (self *Spider) GetContextPoolStats() (hits, misses int64) {
+	return atomic.LoadInt64(&self.ctxHits), atomic.LoadInt64(&self.ctxMisses)
+}
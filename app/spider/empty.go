@@ -0,0 +1,38 @@
+package spider
+
+import (
+	"strings"
+)
+
+// 返回Rule.Empty的判断函数：响应中存在匹配selector的元素时视为无数据页面，如".no-result"
+func EmptyIfSelector(selector string) func(*Context) bool {
+	return func(ctx *Context) bool {
+		return ctx.GetDom().Find(selector).Length() > 0
+	}
+}
+
+// 返回Rule.Empty的判断函数：响应文本中含有任一texts时视为无数据页面，如"没有找到相关结果"
+func EmptyIfContains(texts ...string) func(*Context) bool {
+	return func(ctx *Context) bool {
+		text := ctx.GetText()
+		for _, s := range texts {
+			if strings.Contains(text, s) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// 按本页所属规则的Rule.Empty判断响应是否为无数据页面，规则未设置时返回false；
+// 无数据页面视为成功，但不解析、不收集结果，单独计数
+func (self *Context) IsEmpty() bool {
+	if self.Response == nil {
+		return false
+	}
+	_, rule, found := self.getRule(self.Request.GetRuleName())
+	if !found || rule.Empty == nil {
+		return false
+	}
+	return rule.Empty(self)
+}
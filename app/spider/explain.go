@@ -0,0 +1,61 @@
+package spider
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/henrylee2cn/pholcus/logs"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+// 选择器调试日志中匹配内容片段的最大字符数
+const explainSnippetLen = 200
+
+// 在响应的Dom中查找selector，within不为nil时在其选区内查找，结果与GetDom().Find()相同；
+// 规则开启Explain或全局开启run::explain时，记录选择器、匹配数量及首个匹配元素的HTML片段
+func (self *Context) Find(selector string, within ...*goquery.Selection) *goquery.Selection {
+	var sel *goquery.Selection
+	if len(within) > 0 && within[0] != nil {
+		sel = within[0].Find(selector)
+	} else {
+		sel = self.GetDom().Find(selector)
+	}
+	if self.explaining() {
+		self.explain(selector, sel)
+	}
+	return sel
+}
+
+// 按selector查找列表中的各条目（如商品、文章），依次以各条目的选区调用fn，返回条目数；
+// fn内以sel.Find()按条目内的相对位置提取字段，或检查同一条目中的其他元素后决定是否提取，
+// 如仅提取有货商品的价格；within不为nil时仅在其选区内查找条目
+func (self *Context) ForEach(selector string, fn func(sel *goquery.Selection), within ...*goquery.Selection) int {
+	items := self.Find(selector, within...)
+	items.Each(func(_ int, sel *goquery.Selection) {
+		fn(sel)
+	})
+	return items.Length()
+}
+
+// 当前规则是否开启选择器调试日志
+func (self *Context) explaining() bool {
+	if cache.Task.Explain {
+		return true
+	}
+	rule, found := self.GetRule(self.GetRuleName())
+	return found && rule.Explain
+}
+
+// 记录选择器的匹配情况，片段中的连续空白合并为一个空格，过长时截断
+func (self *Context) explain(selector string, sel *goquery.Selection) {
+	var snippet string
+	if sel.Length() > 0 {
+		html, _ := goquery.OuterHtml(sel.First())
+		snippet = strings.Join(strings.Fields(html), " ")
+		if r := []rune(snippet); len(r) > explainSnippetLen {
+			snippet = string(r[:explainSnippetLen]) + "..."
+		}
+	}
+	logs.Log.Informational(" *     [explain][%v] %q 匹配 %v 个 [%v]: %v\n", self.GetRuleName(), selector, sel.Length(), self.GetUrl(), snippet)
+}
@@ -0,0 +1,145 @@
+package spider
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+	"github.com/henrylee2cn/pholcus/logs"
+)
+
+// RSS/Atom订阅源中的一个条目
+type FeedItem struct {
+	Title   string // 标题
+	Link    string // 链接
+	GUID    string // 唯一标识，RSS为guid，Atom为id，缺失时为链接
+	PubDate string // 发布时间，按原样保留，RSS为pubDate，Atom为published或updated
+}
+
+// 订阅源条目元素，字段按本地名匹配，同时适用于RSS 2.0、RSS 1.0及Atom
+type feedEntry struct {
+	Title     string     `xml:"title"`
+	Links     []feedLink `xml:"link"`
+	GUID      string     `xml:"guid"`
+	ID        string     `xml:"id"`
+	PubDate   string     `xml:"pubDate"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Date      string     `xml:"date"`
+}
+
+// RSS的<link>为文本，Atom的<link>为href属性
+type feedLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Text string `xml:",chardata"`
+}
+
+// 返回条目的链接，Atom中优先采用rel为alternate（或未指定rel）的链接
+func (self *feedEntry) link() string {
+	var first string
+	for _, l := range self.Links {
+		href := strings.TrimSpace(l.Href)
+		if href == "" {
+			href = strings.TrimSpace(l.Text)
+		}
+		if href == "" {
+			continue
+		}
+		if l.Rel == "" || l.Rel == "alternate" {
+			return href
+		}
+		if first == "" {
+			first = href
+		}
+	}
+	return first
+}
+
+// 解析RSS或Atom订阅源，按出现顺序返回其中的<item>或<entry>条目，内容须为UTF-8编码，gzip压缩的内容自动流式解压
+func ParseFeed(r io.Reader) ([]FeedItem, error) {
+	r, err := Gunzip(r)
+	if err != nil {
+		return nil, err
+	}
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	// 内容已是UTF-8编码，忽略XML声明中的encoding
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	var items []FeedItem
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return items, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "item" && start.Name.Local != "entry" {
+			continue
+		}
+		var entry feedEntry
+		if err = decoder.DecodeElement(&entry, &start); err != nil {
+			return items, err
+		}
+		item := FeedItem{
+			Title: strings.TrimSpace(entry.Title),
+			Link:  entry.link(),
+			GUID:  strings.TrimSpace(entry.GUID),
+		}
+		if item.GUID == "" {
+			item.GUID = strings.TrimSpace(entry.ID)
+		}
+		if item.GUID == "" {
+			item.GUID = item.Link
+		}
+		for _, date := range []string{entry.PubDate, entry.Published, entry.Updated, entry.Date} {
+			if date = strings.TrimSpace(date); date != "" {
+				item.PubDate = date
+				break
+			}
+		}
+		items = append(items, item)
+	}
+}
+
+// 将响应内容按RSS或Atom订阅源解析，各条目的链接解析为绝对地址后，添加为ruleName规则的请求，返回添加的条目数；
+// 条目的标题、发布时间及唯一标识分别以Meta键title、pubDate、guid传递，同一订阅源中GUID或链接重复的条目仅添加一次，
+// 多次抓取订阅源时，已抓取过的链接由请求去重过滤；max大于0时最多添加前max个条目；
+// tmpl不为nil时以其副本为模板生成请求（如指定Method、Header等），缺少链接的条目被忽略；
+// gzip压缩的订阅源（如.gz文件）由GetText()解压后解析，此后GetText()仍返回解压后的内容
+func (self *Context) FollowFeed(ruleName string, max int, tmpl ...*request.Request) int {
+	items, err := ParseFeed(strings.NewReader(self.GetText()))
+	if err != nil && len(items) == 0 {
+		logs.Log.Error(" *     [%v] 解析订阅源失败 [%v]: %v\n", self.GetRuleName(), self.GetUrl(), err)
+		return 0
+	}
+	var (
+		reqs = make([]*request.Request, 0, len(items))
+		seen = make(map[string]bool, len(items))
+	)
+	for _, item := range items {
+		if max > 0 && len(reqs) >= max {
+			break
+		}
+		link := self.resolveUrl(item.Link)
+		if link == "" || seen[item.GUID] || seen[link] {
+			continue
+		}
+		seen[item.GUID], seen[link] = true, true
+		req := &request.Request{}
+		if len(tmpl) > 0 && tmpl[0] != nil {
+			req = tmpl[0].Copy()
+		}
+		req.SetUrl(link).SetRuleName(ruleName)
+		req.SetMeta("title", item.Title).SetMeta("pubDate", item.PubDate).SetMeta("guid", item.GUID)
+		reqs = append(reqs, req)
+	}
+	self.AddQueueBatch(reqs)
+	return len(reqs)
+}
@@ -0,0 +1,59 @@
+package spider
+
+import (
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFeed(t *testing.T) {
+	rss := `<?xml version="1.0" encoding="GBK"?>
+<rss version="2.0"><channel><title>频道</title>
+<item><title> 第一条 &amp; 更多 </title><link>http://a/1</link><guid>g1</guid><pubDate>Mon, 01 Jan 2024 00:00:00 GMT</pubDate></item>
+<item><title>第二条&nbsp;</title><link> /2 </link><dc:date>2024-01-02</dc:date></item>
+</channel></rss>`
+	atom := `<feed xmlns="http://www.w3.org/2005/Atom">
+<entry><title>A</title><link rel="edit" href="http://a/edit"/><link href="http://a/x"/><id>urn:x</id><updated>2024-01-03</updated><published>2024-01-02</published></entry>
+<entry><title>B</title><link rel="enclosure" href="http://a/y.mp3"/></entry>
+</feed>`
+
+	for _, c := range []struct {
+		name, feed string
+		want       []FeedItem
+	}{
+		{"rss", rss, []FeedItem{
+			{Title: "第一条 & 更多", Link: "http://a/1", GUID: "g1", PubDate: "Mon, 01 Jan 2024 00:00:00 GMT"},
+			{Title: "第二条", Link: "/2", GUID: "/2", PubDate: "2024-01-02"},
+		}},
+		{"atom", atom, []FeedItem{
+			{Title: "A", Link: "http://a/x", GUID: "urn:x", PubDate: "2024-01-02"},
+			{Title: "B", Link: "http://a/y.mp3", GUID: "http://a/y.mp3"},
+		}},
+	} {
+		items, err := ParseFeed(strings.NewReader(c.feed))
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(items, c.want) {
+			t.Errorf("%s: got %+v, want %+v", c.name, items, c.want)
+		}
+	}
+
+	// gzip压缩的订阅源自动解压
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(rss))
+	zw.Close()
+	if items, err := ParseFeed(&buf); err != nil || len(items) != 2 {
+		t.Errorf("gzip: got %d items, err %v", len(items), err)
+	}
+
+	// 解析出错时返回此前已解析的条目
+	items, err := ParseFeed(strings.NewReader(`<rss><item><title>a</title></item><item><title>`))
+	if err == nil || len(items) != 1 {
+		t.Errorf("truncated: got %d items, err %v", len(items), err)
+	}
+}
@@ -0,0 +1,121 @@
+package spider
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/henrylee2cn/pholcus/config"
+	"github.com/henrylee2cn/pholcus/logs"
+)
+
+type (
+	// 网页的新鲜度元数据，取自HEAD响应，用于判断网页自上次抓取后是否变化
+	PageMeta struct {
+		ContentLength int64  // 响应头Content-Length，未知时为-1
+		LastModified  string // 响应头Last-Modified
+	}
+	// [网址]新鲜度元数据，开启Spider.HeadCheck时按蜘蛛保存于缓存目录
+	freshStore struct {
+		fileName string
+		metas    map[string]PageMeta
+		changed  bool
+		sync.Mutex
+	}
+)
+
+// 从HEAD响应中提取新鲜度元数据，均未提供时返回false
+func NewPageMeta(resp *http.Response) (PageMeta, bool) {
+	meta := PageMeta{
+		ContentLength: resp.ContentLength,
+		LastModified:  resp.Header.Get("Last-Modified"),
+	}
+	return meta, meta.ContentLength >= 0 || meta.LastModified != ""
+}
+
+// 与上次记录的元数据比较，双方均提供的字段全部相同时视为未变化
+func (self PageMeta) Unchanged(old PageMeta) bool {
+	var compared bool
+	if self.ContentLength >= 0 && old.ContentLength >= 0 {
+		if self.ContentLength != old.ContentLength {
+			return false
+		}
+		compared = true
+	}
+	if self.LastModified != "" && old.LastModified != "" {
+		if self.LastModified != old.LastModified {
+			return false
+		}
+		compared = true
+	}
+	return compared
+}
+
+// 返回该网址上次抓取成功时记录的元数据
+func (self *Spider) GetPageMeta(url string) (PageMeta, bool) {
+	store := self.freshStore()
+	store.Lock()
+	defer store.Unlock()
+	meta, ok := store.metas[url]
+	return meta, ok
+}
+
+// 记录该网址抓取成功时的元数据，任务结束时保存
+func (self *Spider) SetPageMeta(url string, meta PageMeta) {
+	store := self.freshStore()
+	store.Lock()
+	defer store.Unlock()
+	store.metas[url] = meta
+	store.changed = true
+}
+
+// 保存新鲜度元数据，未开启Spider.HeadCheck或无变化时不操作
+func (self *Spider) FlushPageMetas() {
+	self.lock.RLock()
+	store := self.fresh
+	self.lock.RUnlock()
+	if store == nil {
+		return
+	}
+	store.Lock()
+	defer store.Unlock()
+	if !store.changed {
+		return
+	}
+	b, err := json.Marshal(store.metas)
+	if err == nil {
+		os.MkdirAll(filepath.Dir(store.fileName), 0777)
+		err = ioutil.WriteFile(store.fileName, b, 0777)
+	}
+	if err != nil {
+		logs.Log.Error(" *     Fail  [保存新鲜度记录][%v]: %v\n", self.GetName(), err)
+		return
+	}
+	store.changed = false
+}
+
+// 返回新鲜度元数据，首次调用时从缓存目录读取
+func (self *Spider) freshStore() *freshStore {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.fresh != nil {
+		return self.fresh
+	}
+	fileName := config.CACHE_DIR + "/fresh__" + self.GetName()
+	if subName := self.GetSubName(); subName != "" {
+		fileName += "__" + subName
+	}
+	self.fresh = &freshStore{
+		fileName: fileName + ".json",
+		metas:    make(map[string]PageMeta),
+	}
+	if b, err := ioutil.ReadFile(self.fresh.fileName); err == nil {
+		if err = json.Unmarshal(b, &self.fresh.metas); err != nil {
+			logs.Log.Error(" *     Fail  [读取新鲜度记录][%v]: %v\n", self.GetName(), err)
+		}
+	}
+	return self.fresh
+}
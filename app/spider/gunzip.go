@@ -0,0 +1,83 @@
+package spider
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// gzip压缩内容的魔数
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// 按魔数识别gzip压缩的内容并返回流式解压的读取器，非gzip内容原样读取；
+// 适用于无响应头可依据的内容，如ParseFeed()的输入，响应内容是否解压由gzipped()判断
+func Gunzip(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	if !isGzip(br) {
+		return br, nil
+	}
+	return gzip.NewReader(br)
+}
+
+// 不消耗内容地检查是否以gzip魔数开头
+func isGzip(br *bufio.Reader) bool {
+	magic, _ := br.Peek(len(gzipMagic))
+	return bytes.Equal(magic, gzipMagic)
+}
+
+// 替换了读取方式的响应流，关闭时关闭原响应流
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// 尚未读取响应内容时，返回其是否为gzip压缩，检查时不消耗内容；
+// 仅当响应头或网址表明内容为gzip压缩时才检查魔数，以免误解压恰以魔数开头的其他内容
+func (self *Context) gzipped() bool {
+	if self.text != nil || self.Response == nil || self.Response.Body == nil || !self.declaredGzip() {
+		return false
+	}
+	br := bufio.NewReader(self.Response.Body)
+	self.Response.Body = readCloser{br, self.Response.Body}
+	return isGzip(br)
+}
+
+// 将gzip压缩的响应流替换为流式解压后的内容
+func (self *Context) gunzipBody() error {
+	if !self.gzipped() {
+		return nil
+	}
+	zr, err := gzip.NewReader(self.Response.Body)
+	if err != nil {
+		return err
+	}
+	self.Response.Body = readCloser{zr, self.Response.Body}
+	return nil
+}
+
+// 返回响应是否声明为gzip压缩：下载器未解压的Content-Encoding: gzip，
+// Content-Type为application/gzip或application/x-gzip，或网址路径以.gz结尾（如sitemap.xml.gz）
+func (self *Context) declaredGzip() bool {
+	header := self.Response.Header
+	if strings.EqualFold(strings.TrimSpace(header.Get("Content-Encoding")), "gzip") {
+		return true
+	}
+	if mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type")); err == nil &&
+		(mediaType == "application/gzip" || mediaType == "application/x-gzip") {
+		return true
+	}
+	// 优先采用重定向后的网址
+	var u *url.URL
+	if self.Response.Request != nil {
+		u = self.Response.Request.URL
+	}
+	if u == nil {
+		u, _ = url.Parse(self.GetUrl())
+	}
+	return u != nil && strings.EqualFold(path.Ext(u.Path), ".gz")
+}
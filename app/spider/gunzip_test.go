@@ -0,0 +1,85 @@
+package spider
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+	"github.com/henrylee2cn/pholcus/app/scheduler"
+	"github.com/henrylee2cn/pholcus/runtime/status"
+)
+
+func gzipBytes(s string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(s))
+	zw.Close()
+	return buf.Bytes()
+}
+
+func gzipContext(url string, header http.Header, body []byte) *Context {
+	req := &request.Request{Url: url, Rule: "feed"}
+	req.Prepare()
+	httpReq, _ := http.NewRequest("GET", url, nil)
+	return &Context{
+		spider: &Spider{
+			Name:     "test",
+			RuleTree: &RuleTree{Trunk: map[string]*Rule{"feed": {}, "item": {}}},
+		},
+		Request:  req,
+		Response: &http.Response{Header: header, Body: ioutil.NopCloser(bytes.NewReader(body)), Request: httpReq},
+	}
+}
+
+// 仅在响应头或网址表明为gzip压缩时解压，已解压或恰以魔数开头的其他内容原样保留
+func TestGunzipBody(t *testing.T) {
+	zipped := gzipBytes("<urlset/>")
+	for _, c := range []struct {
+		name, url string
+		header    http.Header
+		body      []byte
+		want      string
+	}{
+		{"extension", "http://a/sitemap.xml.GZ", http.Header{}, zipped, "<urlset/>"},
+		{"content type", "http://a/sitemap", http.Header{"Content-Type": {"application/x-gzip"}}, zipped, "<urlset/>"},
+		{"content encoding", "http://a/sitemap", http.Header{"Content-Encoding": {"gzip"}}, zipped, "<urlset/>"},
+		{"already decoded", "http://a/sitemap.xml.gz", http.Header{}, []byte("<urlset/>"), "<urlset/>"},
+		{"undeclared", "http://a/blob", http.Header{"Content-Type": {"application/octet-stream"}}, zipped, string(zipped)},
+	} {
+		if got := gzipContext(c.url, c.header, c.body).GetText(); got != c.want {
+			t.Errorf("%s: GetText() = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+// 只记录加入队列的请求
+type pushedMatrix struct {
+	scheduler.ReqMatrix
+	reqs []*request.Request
+}
+
+func (self *pushedMatrix) PushBatch(reqs []*request.Request) {
+	self.reqs = append(self.reqs, reqs...)
+}
+
+// gzip压缩的订阅源解析后GetText()仍返回解压后的内容
+func TestFollowFeedGzip(t *testing.T) {
+	feed := `<rss><channel><item><link>/1</link></item><item><link>http://b/2</link></item></channel></rss>`
+	ctx := gzipContext("http://a/feed.xml.gz", http.Header{}, gzipBytes(feed))
+	matrix := &pushedMatrix{}
+	ctx.spider.reqMatrix = matrix
+	ctx.spider.status = status.RUN
+
+	if n := ctx.FollowFeed("item", 0); n != 2 {
+		t.Fatalf("FollowFeed() = %d, want 2", n)
+	}
+	if len(matrix.reqs) != 2 || matrix.reqs[0].GetUrl() != "http://a/1" || matrix.reqs[1].GetUrl() != "http://b/2" {
+		t.Errorf("pushed %v", matrix.reqs)
+	}
+	if got := ctx.GetText(); got != feed {
+		t.Errorf("GetText() = %q after FollowFeed, want the feed", got)
+	}
+}
@@ -0,0 +1,61 @@
+package spider
+
+import (
+	"sort"
+)
+
+type (
+	// 蜘蛛的元数据，用于在界面之外枚举已注册的蜘蛛，如自定义启动器、监控面板
+	SpiderInfo struct {
+		Name         string     // 名称
+		Description  string     // 描述
+		Keyin        bool       // 是否接受自定义输入Keyin
+		CustomLimit  bool       // 是否采用规则中的自定义采集上限，否则限制请求数
+		Limit        int64      `json:",omitempty"` // 规则中定义的默认采集上限，0为不限
+		Pausetime    int64      `json:",omitempty"` // 规则中定义的暂停时长参考/ms，0为采用全局设置
+		EnableCookie bool       // 是否使用cookie记录
+		Rules        []RuleInfo // 采集规则，按规则名排序
+	}
+	// 采集规则的元数据
+	RuleInfo struct {
+		Name       string   // 规则名
+		ItemFields []string `json:",omitempty"` // 结果字段列表
+		Pager      bool     // 是否设置了分页请求生成器
+	}
+)
+
+// 返回蜘蛛的元数据
+func (self *Spider) Info() SpiderInfo {
+	info := SpiderInfo{
+		Name:         self.GetName(),
+		Description:  self.GetDescription(),
+		Keyin:        self.Keyin == KEYIN,
+		CustomLimit:  self.Limit == LIMIT,
+		Pausetime:    self.Pausetime,
+		EnableCookie: self.EnableCookie,
+	}
+	if !info.CustomLimit {
+		info.Limit = self.Limit
+	}
+	if self.RuleTree != nil {
+		for name, rule := range self.RuleTree.Trunk {
+			info.Rules = append(info.Rules, RuleInfo{
+				Name:       name,
+				ItemFields: append([]string(nil), rule.ItemFields...),
+				Pager:      rule.Pager != nil,
+			})
+		}
+		sort.Slice(info.Rules, func(i, j int) bool { return info.Rules[i].Name < info.Rules[j].Name })
+	}
+	return info
+}
+
+// 返回全部蜘蛛种类的元数据，顺序同Get()
+func (self *SpiderSpecies) Infos() []SpiderInfo {
+	list := self.Get()
+	infos := make([]SpiderInfo, len(list))
+	for i, sp := range list {
+		infos[i] = sp.Info()
+	}
+	return infos
+}
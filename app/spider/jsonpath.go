@@ -0,0 +1,191 @@
+package spider
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+	"github.com/henrylee2cn/pholcus/logs"
+)
+
+// JSONPath中的一步
+type jsonStep struct {
+	key       string // 成员名，"*"为全部成员或元素
+	index     int    // 数组下标，负数从末尾计起
+	isIndex   bool   // 是否为数组下标
+	recursive bool   // 是否为递归下降（..）
+}
+
+// 按JSONPath选取doc中的值，doc为encoding/json解码所得的数据；
+// 支持 $、.name、['name']、[n]（负数从末尾计起）、[*]、.* 及递归下降 ..name、..*，
+// 如 $.data.items[*].href、$..next
+func JSONPath(doc interface{}, path string) ([]interface{}, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []interface{}{doc}
+	for _, step := range steps {
+		if step.recursive {
+			var all []interface{}
+			for _, node := range nodes {
+				all = appendDescendants(all, node)
+			}
+			nodes = all
+		}
+		var next []interface{}
+		for _, node := range nodes {
+			next = step.apply(next, node)
+		}
+		nodes = next
+	}
+	return nodes, nil
+}
+
+// 解析JSONPath
+func parseJSONPath(path string) ([]jsonStep, error) {
+	p := strings.TrimSpace(path)
+	p = strings.TrimPrefix(p, "$")
+	var steps []jsonStep
+	for len(p) > 0 {
+		var step jsonStep
+		switch {
+		case strings.HasPrefix(p, ".."):
+			step.recursive = true
+			p = p[2:]
+			if strings.HasPrefix(p, "[") {
+				break
+			}
+			step.key, p = cutJSONName(p)
+			if step.key == "" {
+				return nil, fmt.Errorf("jsonpath %q: missing member name after ..", path)
+			}
+			steps = append(steps, step)
+			continue
+		case p[0] == '.':
+			step.key, p = cutJSONName(p[1:])
+			if step.key == "" {
+				return nil, fmt.Errorf("jsonpath %q: missing member name after .", path)
+			}
+			steps = append(steps, step)
+			continue
+		case p[0] != '[':
+			return nil, fmt.Errorf("jsonpath %q: unexpected %q", path, p)
+		}
+		end := strings.IndexByte(p, ']')
+		if end < 0 {
+			return nil, fmt.Errorf("jsonpath %q: missing ]", path)
+		}
+		inner := strings.TrimSpace(p[1:end])
+		p = p[end+1:]
+		switch {
+		case inner == "*":
+			step.key = "*"
+		case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+			step.key = inner[1 : len(inner)-1]
+		default:
+			n, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("jsonpath %q: invalid index %q", path, inner)
+			}
+			step.index, step.isIndex = n, true
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// 截取成员名，至下一个.或[为止
+func cutJSONName(p string) (name, rest string) {
+	i := strings.IndexAny(p, ".[")
+	if i < 0 {
+		return p, ""
+	}
+	return p[:i], p[i:]
+}
+
+// 将node选中的值追加至nodes
+func (self jsonStep) apply(nodes []interface{}, node interface{}) []interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		if self.isIndex {
+			return nodes
+		}
+		if self.key == "*" {
+			for _, child := range v {
+				nodes = append(nodes, child)
+			}
+		} else if child, ok := v[self.key]; ok {
+			nodes = append(nodes, child)
+		}
+	case []interface{}:
+		switch {
+		case self.key == "*":
+			nodes = append(nodes, v...)
+		case self.isIndex:
+			i := self.index
+			if i < 0 {
+				i += len(v)
+			}
+			if i >= 0 && i < len(v) {
+				nodes = append(nodes, v[i])
+			}
+		}
+	}
+	return nodes
+}
+
+// 将node及其全部子孙值追加至nodes
+func appendDescendants(nodes []interface{}, node interface{}) []interface{} {
+	nodes = append(nodes, node)
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for _, child := range v {
+			nodes = appendDescendants(nodes, child)
+		}
+	case []interface{}:
+		for _, child := range v {
+			nodes = appendDescendants(nodes, child)
+		}
+	}
+	return nodes
+}
+
+// 将响应内容按JSON解码，并按JSONPath选取其中的值
+func (self *Context) GetJSONPath(path string) ([]interface{}, error) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(self.GetText()), &doc); err != nil {
+		return nil, err
+	}
+	return JSONPath(doc, path)
+}
+
+// 从JSON响应中按JSONPath选取链接，解析为相对于响应地址的绝对地址后，添加为ruleName规则的请求，返回提取的链接数；
+// tmpl不为nil时以其副本为模板生成请求（如指定Method、Header等），非字符串的值及无法解析的链接被忽略
+func (self *Context) FollowJSON(path, ruleName string, tmpl ...*request.Request) int {
+	values, err := self.GetJSONPath(path)
+	if err != nil {
+		logs.Log.Error(" *     [%v] 从JSON中提取链接失败 [%v]: %v\n", self.GetRuleName(), path, err)
+		return 0
+	}
+	reqs := make([]*request.Request, 0, len(values))
+	for _, v := range values {
+		href, ok := v.(string)
+		if !ok {
+			continue
+		}
+		link := self.resolveUrl(href)
+		if link == "" {
+			continue
+		}
+		req := &request.Request{}
+		if len(tmpl) > 0 && tmpl[0] != nil {
+			req = tmpl[0].Copy()
+		}
+		reqs = append(reqs, req.SetUrl(link).SetRuleName(ruleName))
+	}
+	self.AddQueueBatch(reqs)
+	return len(reqs)
+}
@@ -0,0 +1,55 @@
+package spider
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestJSONPath(t *testing.T) {
+	var doc interface{}
+	json.Unmarshal([]byte(`{
+		"data": {
+			"items": [{"href": "/a"}, {"href": "/b"}, {"title": "c"}],
+			"my key": 1,
+			"next": "/p2"
+		},
+		"meta": {"next": "/m2"}
+	}`), &doc)
+
+	for path, want := range map[string]string{
+		"$":                      fmt.Sprint([]interface{}{doc}),
+		"$.data.items[*].href":   "[/a /b]",
+		"$.data.items[0].href":   "[/a]",
+		"$.data.items[-1].title": "[c]",
+		"$.data.items[5]":        "[]",
+		"$['data'][\"my key\"]":  "[1]",
+		"$..next":                "[/m2 /p2]",
+		"$..href":                "[/a /b]",
+		"$.meta.*":               "[/m2]",
+		"$.data.next[0]":         "[]",
+		"$.missing.next":         "[]",
+	} {
+		got, err := JSONPath(doc, path)
+		if err != nil {
+			t.Errorf("JSONPath(%q): %v", path, err)
+			continue
+		}
+		// 对象成员的遍历顺序不定，按文本排序后比较
+		sort.Slice(got, func(i, j int) bool { return fmt.Sprint(got[i]) < fmt.Sprint(got[j]) })
+		if s := fmt.Sprint(got); s != want {
+			t.Errorf("JSONPath(%q) = %v, want %v", path, s, want)
+		}
+	}
+
+	if got, _ := JSONPath(doc, "$..*"); len(got) != 12 {
+		t.Errorf("JSONPath($..*) selected %d values, want 12", len(got))
+	}
+
+	for _, path := range []string{"$.", "$..", "$[0", "$[x]", "$a", "data.next"} {
+		if _, err := JSONPath(doc, path); err == nil {
+			t.Errorf("JSONPath(%q) succeeded", path)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package spider
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// 解析媒体真实地址时默认依次检查的属性，延迟加载的网页常将真实地址置于data-*属性中，src仅为占位图；
+// srcset类属性取其中分辨率最高的候选地址，可修改以适应特定网站
+var MediaAttrs = []string{"data-src", "data-original", "data-lazy-src", "data-srcset", "srcset", "src"}
+
+// 返回所选首个元素的真实媒体地址，已解析为绝对地址，用于提取延迟加载的图片等；
+// 按attrs(为空时采用MediaAttrs)依次检查属性，跳过空值及data:、about:等占位地址，均不可用时返回空字符串
+func (self *Context) GetMediaUrl(sel *goquery.Selection, attrs ...string) string {
+	if sel.Length() == 0 {
+		return ""
+	}
+	if len(attrs) == 0 {
+		attrs = MediaAttrs
+	}
+	s := sel.First()
+	for _, attr := range attrs {
+		v, ok := s.Attr(attr)
+		if !ok {
+			continue
+		}
+		if strings.HasSuffix(attr, "srcset") {
+			v = bestSrcset(v)
+		}
+		if isPlaceholderUrl(v) {
+			continue
+		}
+		if link := self.resolveUrl(v); link != "" {
+			return link
+		}
+	}
+	return ""
+}
+
+// 返回所选各元素的真实媒体地址，已去重并保持先后顺序，可用于添加文件下载请求，规则同GetMediaUrl()
+func (self *Context) GetMediaUrls(sel *goquery.Selection, attrs ...string) []string {
+	var (
+		links []string
+		seen  = make(map[string]bool)
+	)
+	sel.Each(func(_ int, s *goquery.Selection) {
+		if link := self.GetMediaUrl(s, attrs...); link != "" && !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	})
+	return links
+}
+
+// 返回srcset中分辨率最高的候选地址：有宽度描述(如480w)时取宽度最大者，否则取像素密度(如2x)最大者，
+// 均未描述时取首个
+func bestSrcset(srcset string) string {
+	var (
+		best      string
+		bestValue float64
+		bestWidth bool
+	)
+	for _, candidate := range splitSrcset(srcset) {
+		var (
+			value float64 = 1
+			width bool
+		)
+		if fields := strings.Fields(candidate[1]); len(fields) > 0 {
+			d := strings.ToLower(fields[len(fields)-1])
+			if n, err := strconv.ParseFloat(d[:len(d)-1], 64); err == nil {
+				switch d[len(d)-1] {
+				case 'w':
+					value, width = n, true
+				case 'x':
+					value = n
+				}
+			}
+		}
+		// 宽度描述优先于像素密度描述
+		if best == "" || width && !bestWidth || width == bestWidth && value > bestValue {
+			best, bestValue, bestWidth = candidate[0], value, width
+		}
+	}
+	return best
+}
+
+// 按HTML规范切分srcset，返回各候选项的[地址,描述]：地址至空白为止，其中的逗号(如 w_100,h_100)属于地址，
+// 仅地址末尾或描述之后的逗号分隔候选项
+func splitSrcset(srcset string) [][2]string {
+	var (
+		candidates [][2]string
+		isSpace    = func(c byte) bool { return strings.IndexByte(" \t\r\n\f", c) >= 0 }
+	)
+	for i := 0; i < len(srcset); {
+		// 跳过候选项之间的空白及逗号
+		for i < len(srcset) && (isSpace(srcset[i]) || srcset[i] == ',') {
+			i++
+		}
+		start := i
+		for i < len(srcset) && !isSpace(srcset[i]) {
+			i++
+		}
+		link := srcset[start:i]
+		if link == "" {
+			break
+		}
+		var descriptor string
+		if trimmed := strings.TrimRight(link, ","); trimmed != link {
+			link = trimmed
+		} else {
+			start = i
+			for i < len(srcset) && srcset[i] != ',' {
+				i++
+			}
+			descriptor = strings.TrimSpace(srcset[start:i])
+		}
+		candidates = append(candidates, [2]string{link, descriptor})
+	}
+	return candidates
+}
+
+// 是否为延迟加载的占位地址，如空值、内嵌的data:图片或about:blank
+func isPlaceholderUrl(v string) bool {
+	v = strings.ToLower(strings.TrimSpace(v))
+	return v == "" || v == "#" || strings.HasPrefix(v, "data:") || strings.HasPrefix(v, "about:") || strings.HasPrefix(v, "javascript:")
+}
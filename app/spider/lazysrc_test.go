@@ -0,0 +1,43 @@
+package spider
+
+import (
+	"testing"
+)
+
+func TestBestSrcset(t *testing.T) {
+	for srcset, want := range map[string]string{
+		"a.jpg":                         "a.jpg",
+		"a.jpg 1x, b.jpg 2x":            "b.jpg",
+		"a.jpg 2x,b.jpg 1.5x":           "a.jpg",
+		"a.jpg 480w, b.jpg 960w, c.jpg": "b.jpg",
+		"a.jpg 3x, b.jpg 100w":          "b.jpg",
+		"a.jpg, b.jpg":                  "a.jpg",
+		"a.jpg,,, b.jpg 2x":             "b.jpg",
+		// 地址中的逗号不分隔候选项
+		"/img/w_100,h_100/a.jpg 100w, /img/w_800,h_800/a.jpg 800w": "/img/w_800,h_800/a.jpg",
+		"https://cdn.example.com/c_fill,w_200/b.png 2x":            "https://cdn.example.com/c_fill,w_200/b.png",
+		"\n\ta.jpg 1x,\n\tb.jpg 2x\n":                              "b.jpg",
+		"":                                                         "",
+		" , ":                                                      "",
+	} {
+		if got := bestSrcset(srcset); got != want {
+			t.Errorf("bestSrcset(%q) = %q, want %q", srcset, got, want)
+		}
+	}
+}
+
+func TestIsPlaceholderUrl(t *testing.T) {
+	for v, want := range map[string]bool{
+		"":                             true,
+		" # ":                          true,
+		"data:image/gif;base64,R0lGOD": true,
+		"about:blank":                  true,
+		"JavaScript:void(0)":           true,
+		"/img/a.jpg":                   false,
+		"https://example.com/a.jpg":    false,
+	} {
+		if got := isPlaceholderUrl(v); got != want {
+			t.Errorf("isPlaceholderUrl(%q) = %v, want %v", v, got, want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package spider
+
+import (
+	"html"
+	"strings"
+)
+
+// 规范化文本：解码HTML实体，去除首尾空白，并将内部连续的空白字符(含换行、制表符及&nbsp;)合并为一个空格
+func NormalizeText(s string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+}
+
+// 返回规范化文本字段值后的结果副本，Rule.RawFields中的字段保持原值，非文本的值不变
+func normalizeItem(rule *Rule, item map[string]interface{}) map[string]interface{} {
+	item2 := make(map[string]interface{}, len(item))
+	for k, v := range item {
+		item2[k] = v
+		if isRawField(rule, k) {
+			continue
+		}
+		switch v := v.(type) {
+		case string:
+			item2[k] = NormalizeText(v)
+		case []string:
+			vs := make([]string, len(v))
+			for i := range v {
+				vs[i] = NormalizeText(v[i])
+			}
+			item2[k] = vs
+		}
+	}
+	return item2
+}
+
+func isRawField(rule *Rule, field string) bool {
+	for _, v := range rule.RawFields {
+		if v == field {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package spider
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+)
+
+// 分页（加载更多）请求生成器，设置于Rule.Pager。
+// 通过Context.AddPages()添加首页请求后，该规则每页解析完成时，
+// 均根据Count的返回值决定是否自动添加下一页请求（页码参数递增），返回0时停止翻页。
+type Pager struct {
+	Param string             // 页码参数名，如 page、offset
+	Start int                // 首页的参数值
+	Step  int                // 参数值的增量，默认为1；以偏移量分页时可设为每页条目数
+	Max   int                // 最大页数，0为不限
+	Count func(*Context) int // 返回当前页的条目数，为nil时以本页输出的结果数代替
+}
+
+// 请求中记录当前页数（从1开始）的临时数据键名
+const PAGER_TEMP = "__PAGER__"
+
+// 返回第page页（从1开始）的参数值
+func (self *Pager) value(page int) int {
+	step := self.Step
+	if step == 0 {
+		step = 1
+	}
+	return self.Start + (page-1)*step
+}
+
+// 生成第page页的请求
+func (self *Pager) request(req *request.Request, page int) (*request.Request, error) {
+	u, err := url.Parse(req.GetUrl())
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	query.Set(self.Param, strconv.Itoa(self.value(page)))
+	u.RawQuery = query.Encode()
+	req.SetUrl(u.String())
+	req.SetTemp(PAGER_TEMP, page)
+	return req, nil
+}
+
+// 读取请求中记录的当前页数，非分页请求返回0
+func pagerPage(req *request.Request) int {
+	switch page := req.GetTemp(PAGER_TEMP, 0).(type) {
+	case int:
+		return page
+	case float64:
+		return int(page)
+	}
+	return 0
+}
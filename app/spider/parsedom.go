@@ -0,0 +1,24 @@
+package spider
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// 匹配<script>元素，含其内容
+var scriptRe = regexp.MustCompile(`(?is)<script\b[^>]*>.*?</script\s*>`)
+
+// 返回先以sanitize处理响应内容、再以goquery解析的Spider.ParseDom函数，
+// 如 ParseDom: SanitizeDom(StripScripts)；GetText()仍返回处理前的响应内容
+func SanitizeDom(sanitize func(body []byte) []byte) func(*Context, []byte) (*goquery.Document, error) {
+	return func(_ *Context, body []byte) (*goquery.Document, error) {
+		return goquery.NewDocumentFromReader(bytes.NewReader(sanitize(body)))
+	}
+}
+
+// 去除HTML中的全部<script>元素
+func StripScripts(body []byte) []byte {
+	return scriptRe.ReplaceAll(body, nil)
+}
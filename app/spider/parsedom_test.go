@@ -0,0 +1,47 @@
+package spider
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestStripScripts(t *testing.T) {
+	body := `<p>a</p><script src="x.js"></script><SCRIPT type="text/javascript">var s = "</p>";
+</SCRIPT ><p>b</p>`
+	if got := string(StripScripts([]byte(body))); got != "<p>a</p><p>b</p>" {
+		t.Errorf("StripScripts() = %q", got)
+	}
+}
+
+// Spider.ParseDom取代默认解析，GetText()仍返回原始内容；返回nil文档时报错
+func TestParseDom(t *testing.T) {
+	var parsed []byte
+	sp := &Spider{RuleTree: &RuleTree{}}
+	sp.ParseDom = SanitizeDom(func(body []byte) []byte {
+		parsed = StripScripts(body)
+		return parsed
+	})
+	sp = sp.Copy()
+
+	const body = "<p>a</p><script>x()</script>"
+	ctx := &Context{spider: sp, text: []byte(body)}
+	if ctx.GetDom() == nil {
+		t.Fatal("GetDom() returned nil")
+	}
+	if !bytes.Equal(parsed, []byte("<p>a</p>")) {
+		t.Errorf("ParseDom parsed %q", parsed)
+	}
+	if ctx.GetText() != body {
+		t.Errorf("GetText() = %q, want the original body", ctx.GetText())
+	}
+
+	sp.ParseDom = func(*Context, []byte) (*goquery.Document, error) { return nil, nil }
+	defer func() {
+		if recover() == nil {
+			t.Error("nil document from ParseDom was accepted")
+		}
+	}()
+	(&Context{spider: sp, text: []byte(body)}).GetDom()
+}
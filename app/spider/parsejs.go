@@ -29,9 +29,13 @@ type (
 		Trunk           []RuleModle `xml:"Rule"`
 	}
 	RuleModle struct {
-		Name      string `xml:"name,attr"`
-		ParseFunc string `xml:"ParseFunc>Script"`
-		AidFunc   string `xml:"AidFunc>Script"`
+		Name        string `xml:"name,attr"`
+		ParseFunc   string `xml:"ParseFunc>Script"`
+		AidFunc     string `xml:"AidFunc>Script"`
+		Concurrency int    `xml:"Concurrency"`
+		MinPause    int64  `xml:"MinPause"`
+		MaxPause    int64  `xml:"MaxPause"`
+		Raw         int    `xml:"Raw"`
 	}
 )
 
@@ -91,6 +95,10 @@ func init() {
 
 		for _, rule := range m.Trunk {
 			r := new(Rule)
+			r.Concurrency = rule.Concurrency
+			r.MinPause = rule.MinPause
+			r.MaxPause = rule.MaxPause
+			r.Raw = rule.Raw
 			r.ParseFunc = func(parse string) func(*Context) {
 				return func(ctx *Context) {
 					vm := otto.New()
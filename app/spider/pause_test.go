@@ -0,0 +1,25 @@
+package spider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+)
+
+// 尚未调整过暂停时长时以请求的RetryPause为基础退避，此后在调整后的值上累加
+func TestBackoff(t *testing.T) {
+	req := &request.Request{Url: "http://a/", Rule: "r", RetryPause: 3 * time.Second}
+	ctx := &Context{
+		spider:  &Spider{Name: "test", RuleTree: &RuleTree{Trunk: map[string]*Rule{"r": {}}}},
+		Request: req,
+	}
+	ctx.Backoff(req.GetRetryPause())
+	if pause, _ := ctx.spider.GetAdjustedPause(); pause != 6000 {
+		t.Fatalf("pause = %d, want 6000", pause)
+	}
+	ctx.Backoff(-time.Second)
+	if pause, _ := ctx.spider.GetAdjustedPause(); pause != 5000 {
+		t.Fatalf("pause = %d, want 5000", pause)
+	}
+}
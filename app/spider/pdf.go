@@ -0,0 +1,42 @@
+//go:build pdf
+// +build pdf
+
+package spider
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// 提取PDF响应内容中的文本，各页文本按页码顺序以换行符连接，页内按内容流顺序排列，
+// 非PDF格式或解析失败时返回错误；扫描件等不含文本层的PDF返回空字符串；
+// 依赖github.com/ledongthuc/pdf，须以 -tags pdf 编译，否则总是返回错误(见pdf_nopdf.go)
+func (self *Context) GetPDFText() (text string, err error) {
+	defer func() {
+		// 格式异常的PDF可能导致解析库panic
+		if p := recover(); p != nil {
+			text, err = "", fmt.Errorf("pdf: %v", p)
+		}
+	}()
+	raw := self.GetRaw()
+	r, err := pdf.NewReader(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		return "", err
+	}
+	var pages []string
+	for i := 1; i <= r.NumPage(); i++ {
+		page := r.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		s, err := page.GetPlainText(nil)
+		if err != nil {
+			return "", fmt.Errorf("pdf: page %v: %v", i, err)
+		}
+		pages = append(pages, strings.TrimSpace(s))
+	}
+	return strings.Join(pages, "\n"), nil
+}
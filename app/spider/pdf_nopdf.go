@@ -0,0 +1,13 @@
+//go:build !pdf
+// +build !pdf
+
+package spider
+
+import (
+	"errors"
+)
+
+// 未以 -tags pdf 编译时不支持PDF文本提取，总是返回错误
+func (self *Context) GetPDFText() (string, error) {
+	return "", errors.New("pdf: 未启用PDF支持，请以 -tags pdf 编译")
+}
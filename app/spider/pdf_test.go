@@ -0,0 +1,65 @@
+//go:build pdf
+// +build pdf
+
+package spider
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// 生成每页一行文本的最小PDF
+func testPDF(pages ...string) []byte {
+	n := len(pages)
+	objs := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"", // 页面树，见下
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+	}
+	var kids []string
+	for i, text := range pages {
+		page, content := 4+2*i, 5+2*i
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+		stream := fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+		objs = append(objs,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", content),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream),
+		)
+	}
+	objs[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), n)
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objs))
+	for i, obj := range objs {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, xref)
+	return buf.Bytes()
+}
+
+// 多页PDF的文本按页码顺序提取，非PDF内容返回错误
+func TestGetPDFText(t *testing.T) {
+	ctx := &Context{text: testPDF("Hello", "World")}
+	text, err := ctx.GetPDFText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hello, world := strings.Index(text, "Hello"), strings.Index(text, "World")
+	if hello < 0 || world < hello {
+		t.Errorf("GetPDFText() = %q, want Hello before World", text)
+	}
+
+	ctx = &Context{text: []byte("<html></html>")}
+	if _, err := ctx.GetPDFText(); err == nil {
+		t.Error("GetPDFText() of an html body succeeded")
+	}
+}
@@ -0,0 +1,86 @@
+package spider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/henrylee2cn/pholcus/config"
+	"github.com/henrylee2cn/pholcus/logs"
+)
+
+// 输出结果时附带原始响应内容的方式，用于追溯数据来源
+const (
+	RAW_NONE   = iota // 不附带
+	RAW_INLINE        // 以RawBody字段附带原始响应内容，存储量约增加一倍
+	RAW_BLOB          // 以RawHash字段附带原始响应内容的sha256，内容保存至config.RAW_DIR下以该值命名的文件
+)
+
+// 附带原始响应内容的字段名
+const (
+	RAW_BODY_FIELD = "RawBody"
+	RAW_HASH_FIELD = "RawHash"
+)
+
+// 返回转码前的原始下载内容
+func (self *Context) GetRaw() []byte {
+	if self.text == nil {
+		self.initText()
+	}
+	if self.raw != nil {
+		return self.raw
+	}
+	return self.text
+}
+
+// 按规则设置的方式为结果附带原始响应内容
+func (self *Context) attachRaw(rule *Rule, item map[string]interface{}) {
+	switch rule.Raw {
+	case RAW_INLINE:
+		self.spider.UpsertItemField(rule, RAW_BODY_FIELD)
+		item[RAW_BODY_FIELD] = string(self.GetRaw())
+	case RAW_BLOB:
+		self.spider.UpsertItemField(rule, RAW_HASH_FIELD)
+		item[RAW_HASH_FIELD] = self.saveRaw()
+	}
+}
+
+// 保存原始响应内容并返回其sha256，同一响应仅保存一次，内容相同的文件不重复写入
+func (self *Context) saveRaw() string {
+	self.Lock()
+	defer self.Unlock()
+	if self.rawHash != "" {
+		return self.rawHash
+	}
+	raw := self.GetRaw()
+	sum := sha256.Sum256(raw)
+	hash := hex.EncodeToString(sum[:])
+
+	fileName := filepath.Join(config.RAW_DIR, hash)
+	if _, err := os.Stat(fileName); err != nil {
+		if err := os.MkdirAll(config.RAW_DIR, 0777); err != nil {
+			logs.Log.Error(" *     [%v] 原始响应内容保存失败: %v\n", self.GetUrl(), err)
+			return hash
+		}
+		// 先写入临时文件再重命名，避免并发写入同一文件
+		f, err := ioutil.TempFile(config.RAW_DIR, hash+".*.tmp")
+		if err != nil {
+			logs.Log.Error(" *     [%v] 原始响应内容保存失败: %v\n", self.GetUrl(), err)
+			return hash
+		}
+		_, err = f.Write(raw)
+		f.Close()
+		if err == nil {
+			err = os.Rename(f.Name(), fileName)
+		}
+		if err != nil {
+			os.Remove(f.Name())
+			logs.Log.Error(" *     [%v] 原始响应内容保存失败: %v\n", self.GetUrl(), err)
+			return hash
+		}
+	}
+	self.rawHash = hash
+	return hash
+}
@@ -0,0 +1,104 @@
+package spider
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+)
+
+// 跟随软跳转（非HTTP重定向的网页内跳转）的方式，由Spider.SoftRedirect选择
+const (
+	SOFT_REDIRECT_NONE = iota // 不跟随
+	SOFT_REDIRECT_META        // 跟随<meta http-equiv="refresh">跳转
+	SOFT_REDIRECT_ALL         // 同时跟随meta refresh与<script>中简单的location跳转
+)
+
+// Request.RedirectTimes为0(不限)时软跳转的最大次数，以免循环跳转
+const DefaultSoftRedirects = 10
+
+// 记录已连续软跳转次数的Temp键名
+const softRedirectsKey = "__SOFT_REDIRECTS__"
+
+var (
+	// meta refresh的content中的目标网址，如 "0; url=/next"
+	refreshUrlRe = regexp.MustCompile(`(?i)^\s*[\d.]*\s*[;,]?\s*(?:url\s*=\s*)?['"]?([^'"]*)['"]?\s*$`)
+	// 简单的JS跳转，如 location.href = "/next"、window.location.replace('/next')
+	jsLocationRe = regexp.MustCompile(`\blocation(?:\.href)?\s*=\s*['"]([^'"]+)['"]|\blocation\.(?:replace|assign)\(\s*['"]([^'"]+)['"]\s*\)`)
+)
+
+// 返回网页中软跳转的目标网址，已解析为绝对地址并去除锚点；
+// 按Spider.SoftRedirect检测meta refresh及JS跳转，未开启、未检测到或指向自身时返回空字符串
+func (self *Context) GetSoftRedirect() string {
+	if self.spider.SoftRedirect == SOFT_REDIRECT_NONE || self.Response == nil || !self.IsHTML() {
+		return ""
+	}
+	var target string
+	self.GetDom().Find("meta[http-equiv]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		equiv, _ := s.Attr("http-equiv")
+		if !strings.EqualFold(strings.TrimSpace(equiv), "refresh") {
+			return true
+		}
+		content, _ := s.Attr("content")
+		if m := refreshUrlRe.FindStringSubmatch(content); m != nil {
+			target = self.resolveUrl(m[1])
+		}
+		return target == ""
+	})
+	if target == "" && self.spider.SoftRedirect == SOFT_REDIRECT_ALL {
+		self.GetDom().Find("script").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+			if m := jsLocationRe.FindStringSubmatch(s.Text()); m != nil {
+				target = self.resolveUrl(m[1] + m[2])
+			}
+			return target == ""
+		})
+	}
+	if target == "" || target == self.resolveUrl(self.GetUrl()) {
+		return ""
+	}
+	// 经HTTP重定向后的最终地址
+	if self.Response.Request != nil && self.Response.Request.URL != nil && target == self.resolveUrl(self.Response.Request.URL.String()) {
+		return ""
+	}
+	return target
+}
+
+// 跟随软跳转，以本页的规则添加目标网址的GET请求，返回目标网址及是否已跟随；
+// 连续跳转次数受Request.RedirectTimes限制，小于0时不跟随，为0时最多跟随DefaultSoftRedirects次
+func (self *Context) FollowSoftRedirect() (string, bool) {
+	target := self.GetSoftRedirect()
+	if target == "" {
+		return "", false
+	}
+	var hops int
+	switch n := self.Request.GetTemp(softRedirectsKey, 0).(type) {
+	case int:
+		hops = n
+	case float64:
+		// 请求经json复制后数值为float64
+		hops = int(n)
+	}
+	max := self.Request.GetRedirectTimes()
+	if max == 0 {
+		max = DefaultSoftRedirects
+	}
+	if hops >= max {
+		return target, false
+	}
+
+	req := self.Request.Copy()
+	req.Url = target
+	req.Method = "GET"
+	req.PostData = ""
+	req.GzipBody = false
+	req.Header.Del("Referer")
+	req.Header.Del("Range")
+	if req.Temp == nil {
+		req.Temp = make(request.Temp)
+	}
+	req.SetTemp(softRedirectsKey, hops+1)
+	self.AddQueue(req)
+	return target, true
+}
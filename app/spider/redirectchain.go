@@ -0,0 +1,60 @@
+package spider
+
+import (
+	"strconv"
+	"strings"
+)
+
+// 附带重定向链的字段名
+const REDIRECT_CHAIN_FIELD = "RedirectChain"
+
+// 重定向链中的一跳
+type Hop struct {
+	Url        string // 本跳请求的网址
+	StatusCode int    // 本跳的响应状态码，最后一跳为最终响应的状态码
+}
+
+func (self Hop) String() string {
+	return strconv.Itoa(self.StatusCode) + " " + self.Url
+}
+
+// 返回本次下载经过的HTTP重定向链，自原始请求起至最终响应止，未重定向时仅含最终响应一跳；
+// 各跳的网址与状态码由下载器随响应记录，无需额外设置，软跳转不计入，见FollowSoftRedirect
+func (self *Context) GetRedirectChain() []Hop {
+	if self.Response == nil {
+		return nil
+	}
+	var hops []Hop
+	for resp := self.Response; resp != nil; {
+		hop := Hop{StatusCode: resp.StatusCode}
+		if resp.Request != nil && resp.Request.URL != nil {
+			hop.Url = resp.Request.URL.String()
+		} else if resp == self.Response {
+			hop.Url = self.GetUrl()
+		}
+		hops = append(hops, hop)
+		if resp.Request == nil {
+			break
+		}
+		resp = resp.Request.Response
+	}
+	for i, j := 0, len(hops)-1; i < j; i, j = i+1, j-1 {
+		hops[i], hops[j] = hops[j], hops[i]
+	}
+	return hops
+}
+
+// 按Rule.RedirectChain为结果附带重定向链，格式如"301 http://a/ -> 200 https://a/"，超出记录跳数时以"-> ..."结尾
+func (self *Context) attachRedirectChain(rule *Rule, item map[string]interface{}) {
+	hops := self.GetRedirectChain()
+	parts := make([]string, 0, len(hops)+1)
+	for i, hop := range hops {
+		if rule.RedirectChain > 0 && i >= rule.RedirectChain {
+			parts = append(parts, "...")
+			break
+		}
+		parts = append(parts, hop.String())
+	}
+	self.spider.UpsertItemField(rule, REDIRECT_CHAIN_FIELD)
+	item[REDIRECT_CHAIN_FIELD] = strings.Join(parts, " -> ")
+}
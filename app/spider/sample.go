@@ -0,0 +1,46 @@
+package spider
+
+import (
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+// 预览模式下，ruleName规则是否已解析了cache.Task.SamplePages个成功页面，未开启预览模式时总是false，并发安全
+func (self *Spider) SampleFull(ruleName string) bool {
+	n := cache.Task.SamplePages
+	if n <= 0 {
+		return false
+	}
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.samples[ruleName] >= n
+}
+
+// 预览模式下，为ruleName规则占用一个成功页面的名额，名额已用尽时返回false，此时不应解析该页面；
+// 全部规则的名额均用尽时停止本蜘蛛，处理中的请求正常完成；未开启预览模式时总是true，并发安全
+func (self *Spider) TakeSample(ruleName string) bool {
+	n := cache.Task.SamplePages
+	if n <= 0 {
+		return true
+	}
+	self.lock.Lock()
+	if self.samples == nil {
+		self.samples = make(map[string]int)
+	}
+	if self.samples[ruleName] >= n {
+		self.lock.Unlock()
+		return false
+	}
+	self.samples[ruleName]++
+	full := len(self.samples) == len(self.RuleTree.Trunk)
+	for _, count := range self.samples {
+		if count < n {
+			full = false
+			break
+		}
+	}
+	self.lock.Unlock()
+	if full {
+		self.StopWith("预览模式下各规则均已解析足够的页面")
+	}
+	return true
+}
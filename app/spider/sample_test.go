@@ -0,0 +1,36 @@
+package spider
+
+import (
+	"testing"
+
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+// 各规则至多占用SamplePages个名额，全部规则的名额用尽时要求停止
+func TestTakeSample(t *testing.T) {
+	defer func(n int) { cache.Task.SamplePages = n }(cache.Task.SamplePages)
+	sp := &Spider{RuleTree: &RuleTree{Trunk: map[string]*Rule{"a": {}, "b": {}}}}
+
+	cache.Task.SamplePages = 0
+	for i := 0; i < 3; i++ {
+		if !sp.TakeSample("a") || sp.SampleFull("a") {
+			t.Fatal("sampling while SamplePages is 0")
+		}
+	}
+
+	cache.Task.SamplePages = 2
+	if !sp.TakeSample("a") || !sp.TakeSample("a") {
+		t.Fatal("TakeSample(a) = false within the quota")
+	}
+	if sp.TakeSample("a") || !sp.SampleFull("a") {
+		t.Error("TakeSample(a) = true beyond the quota")
+	}
+	if sp.SampleFull("b") || sp.GetStopReason() != "" {
+		t.Fatal("stopped before every rule is full")
+	}
+	sp.TakeSample("b")
+	sp.TakeSample("b")
+	if sp.GetStopReason() == "" {
+		t.Error("not stopped after every rule is full")
+	}
+}
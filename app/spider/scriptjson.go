@@ -0,0 +1,200 @@
+package spider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// 提取并解析网页<script>中内嵌的JSON数据，如 window.__INITIAL_STATE__ = {...}; 或
+// window.__DATA__ = JSON.parse("...")，常见于React、Vue等单页应用，可免于无头浏览器渲染；
+// pattern为匹配脚本内容的正则，如 `__INITIAL_STATE__\s*=`，自匹配处之后截取括号配对的首个对象或数组，
+// 或JSON.parse()的字符串参数；pattern匹配<script>的id时(如__NEXT_DATA__)解析其全部内容；
+// 返回首个可解析的值，可再以JSONPath()选取其中的字段
+func (self *Context) GetScriptJSON(pattern string) (interface{}, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		v       interface{}
+		lastErr error
+		found   bool
+	)
+	self.GetDom().Find("script").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		id, _ := s.Attr("id")
+		raw, ok, err := scriptJSON(re, id, s.Text())
+		if !ok {
+			return true
+		}
+		if err == nil {
+			err = json.Unmarshal([]byte(raw), &v)
+		}
+		if err != nil {
+			lastErr = err
+			return true
+		}
+		found = true
+		return false
+	})
+	if found {
+		return v, nil
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, fmt.Errorf("未找到匹配 %q 的script", pattern)
+}
+
+// 返回id或内容与re匹配的<script>中的JSON文本，均不匹配时ok为false：
+// id匹配时为全部内容，否则为内容中紧随匹配处之后的JSON文本
+func scriptJSON(re *regexp.Regexp, id, text string) (raw string, ok bool, err error) {
+	if id != "" && re.MatchString(id) {
+		return strings.TrimSpace(text), true, nil
+	}
+	loc := re.FindStringIndex(text)
+	if loc == nil {
+		return "", false, nil
+	}
+	raw, err = scriptJSONAfter(text[loc[1]:])
+	return raw, true, err
+}
+
+// 返回脚本中紧随标记之后的JSON文本：JSON.parse()的字符串参数解码后的内容，或括号配对的首个对象或数组
+func scriptJSONAfter(text string) (string, error) {
+	rest := strings.TrimLeft(text, " \t\r\n=:(")
+	if strings.HasPrefix(rest, "JSON.parse(") {
+		arg := strings.TrimLeft(rest[len("JSON.parse("):], " \t\r\n")
+		return unquoteJS(arg)
+	}
+	start := strings.IndexAny(rest, "{[")
+	if start < 0 {
+		return "", errors.New("标记之后未找到JSON对象或数组")
+	}
+	end, err := balanceJSON(rest[start:])
+	if err != nil {
+		return "", err
+	}
+	return rest[start : start+end], nil
+}
+
+// 返回以{或[开头的文本中与之配对的括号之后的位置，忽略字符串内的括号
+func balanceJSON(text string) (int, error) {
+	var (
+		stack []byte
+		quote byte
+	)
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if quote != 0 {
+			switch c {
+			case '\\':
+				i++
+			case quote:
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '"', '\'', '`':
+			quote = c
+		case '{':
+			stack = append(stack, '}')
+		case '[':
+			stack = append(stack, ']')
+		case '}', ']':
+			if len(stack) == 0 || stack[len(stack)-1] != c {
+				return 0, fmt.Errorf("括号不配对，位置 %v", i)
+			}
+			if stack = stack[:len(stack)-1]; len(stack) == 0 {
+				return i + 1, nil
+			}
+		}
+	}
+	return 0, errors.New("括号未闭合")
+}
+
+// 解码以'、"或`开头的JavaScript字符串字面量
+func unquoteJS(text string) (string, error) {
+	if text == "" || strings.IndexByte("'\"`", text[0]) < 0 {
+		return "", errors.New("JSON.parse()的参数不是字符串字面量")
+	}
+	var (
+		quote = text[0]
+		b     strings.Builder
+	)
+	for i := 1; i < len(text); i++ {
+		c := text[i]
+		if c == quote {
+			return b.String(), nil
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		if i++; i == len(text) {
+			break
+		}
+		switch c = text[i]; c {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'v':
+			b.WriteByte('\v')
+		case '0':
+			b.WriteByte(0)
+		case 'x', 'u':
+			r, n, err := jsCodePoint(text[i:])
+			if err != nil {
+				return "", err
+			}
+			i += n
+			// 代理对组成的字符，如 \ud83d\ude00
+			if r >= 0xd800 && r < 0xdc00 && strings.HasPrefix(text[i+1:], "\\u") {
+				if lo, m, err := jsCodePoint(text[i+2:]); err == nil && lo >= 0xdc00 && lo < 0xe000 {
+					r = (r-0xd800)<<10 + (lo - 0xdc00) + 0x10000
+					i += m + 2
+				}
+			}
+			b.WriteRune(r)
+		case '\r', '\n':
+			// 行尾的续行符
+		default:
+			// 其余如 \" \' \\ \/ 即字符本身
+			b.WriteByte(c)
+		}
+	}
+	return "", errors.New("字符串未闭合")
+}
+
+// 解析以x或u开头的转义码点，如 x41、u0041、u{1f600}，返回码点及除首字母外消耗的字节数
+func jsCodePoint(text string) (rune, int, error) {
+	digits := 2
+	if text[0] == 'u' {
+		digits = 4
+		if strings.HasPrefix(text, "u{") {
+			if end := strings.IndexByte(text, '}'); end > 2 {
+				r, err := strconv.ParseUint(text[2:end], 16, 21)
+				return rune(r), end, err
+			}
+			return 0, 0, errors.New("无效的字符串转义")
+		}
+	}
+	if len(text) <= digits {
+		return 0, 0, errors.New("字符串转义不完整")
+	}
+	r, err := strconv.ParseUint(text[1:1+digits], 16, 32)
+	return rune(r), digits, err
+}
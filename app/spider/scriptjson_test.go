@@ -0,0 +1,101 @@
+package spider
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestScriptJSON(t *testing.T) {
+	for _, c := range []struct {
+		name, pattern, id, text string
+		want                    string
+		ok, fail                bool
+	}{
+		{
+			name:    "assignment",
+			pattern: `__INITIAL_STATE__\s*=`,
+			text:    `var a = [0]; window.__INITIAL_STATE__ = {"a":{"b":[1,"}"]}}; window.x = {};`,
+			want:    `{"a":{"b":[1,"}"]}}`,
+			ok:      true,
+		},
+		{
+			name:    "array",
+			pattern: `items:`,
+			text:    `init({items: [{"s":"a]b"}, 'c\'}'], other: 1})`,
+			want:    `[{"s":"a]b"}, 'c\'}']`,
+			ok:      true,
+		},
+		{
+			name:    "JSON.parse",
+			pattern: `__DATA__\s*=`,
+			text:    `window.__DATA__ = JSON.parse("{\"a\":\"x\u0041\x42\\n\",\"e\":\"\ud83d\ude00\u{1f600}\"}");`,
+			want:    "{\"a\":\"xAB\\n\",\"e\":\"\U0001f600\U0001f600\"}",
+			ok:      true,
+		},
+		{
+			name:    "JSON.parse single quotes",
+			pattern: `__DATA__\s*=`,
+			text:    `window.__DATA__ = JSON.parse( '{"a":"it\'s"}' )`,
+			want:    `{"a":"it's"}`,
+			ok:      true,
+		},
+		{
+			name:    "__NEXT_DATA__",
+			pattern: `^__NEXT_DATA__$`,
+			id:      "__NEXT_DATA__",
+			text:    "\n  {\"props\":{}}\n",
+			want:    `{"props":{}}`,
+			ok:      true,
+		},
+		{
+			name:    "no match",
+			pattern: `__INITIAL_STATE__`,
+			id:      "other",
+			text:    `var a = {};`,
+		},
+		{
+			name:    "unbalanced",
+			pattern: `state =`,
+			text:    `state = {"a":[1}`,
+			ok:      true,
+			fail:    true,
+		},
+		{
+			name:    "unclosed",
+			pattern: `state =`,
+			text:    `state = {"a":1`,
+			ok:      true,
+			fail:    true,
+		},
+		{
+			name:    "no object",
+			pattern: `state =`,
+			text:    `state = 1;`,
+			ok:      true,
+			fail:    true,
+		},
+		{
+			name:    "unclosed string",
+			pattern: `state =`,
+			text:    `state = JSON.parse("{}`,
+			ok:      true,
+			fail:    true,
+		},
+		{
+			name:    "bad escape",
+			pattern: `state =`,
+			text:    `state = JSON.parse("\u00")`,
+			ok:      true,
+			fail:    true,
+		},
+	} {
+		raw, ok, err := scriptJSON(regexp.MustCompile(c.pattern), c.id, c.text)
+		if ok != c.ok || (err != nil) != c.fail {
+			t.Errorf("%s: ok = %v, err = %v", c.name, ok, err)
+			continue
+		}
+		if raw != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, raw, c.want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package spider
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+	"github.com/henrylee2cn/pholcus/logs"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+	"github.com/henrylee2cn/pholcus/runtime/status"
+)
+
+// 种子URL列表中的一行
+type seed struct {
+	Url  string
+	Rule string
+}
+
+// 已读取的种子URL列表，标准输入只能读取一次，故缓存读取结果
+var seedCache = struct {
+	seeds map[string][]seed
+	sync.Mutex
+}{
+	seeds: make(map[string][]seed),
+}
+
+// 读取种子URL列表，fileName为"-"时读取标准输入；
+// 每行一个URL，其后可用空白字符间隔指定规则名，空行及#开头的行被忽略
+func readSeeds(fileName string) ([]seed, error) {
+	seedCache.Lock()
+	defer seedCache.Unlock()
+	if seeds, ok := seedCache.seeds[fileName]; ok {
+		return seeds, nil
+	}
+
+	var r io.Reader
+	if fileName == "-" {
+		r = os.Stdin
+	} else {
+		f, err := os.Open(fileName)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	seeds := []seed{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		s := seed{Url: fields[0]}
+		if len(fields) > 1 {
+			s.Rule = fields[1]
+		}
+		seeds = append(seeds, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	seedCache.seeds[fileName] = seeds
+	return seeds, nil
+}
+
+// 以种子URL列表作为初始请求，代替Root入口；
+// 未指定规则名时，采用蜘蛛唯一的规则，蜘蛛有多个规则时忽略该行
+func (self *Spider) addSeeds(ctx *Context) {
+	seeds, err := readSeeds(cache.Task.UrlsFile)
+	if err != nil {
+		logs.Log.Error(" *     [%v] 读取种子URL列表出错: %v\n", self.GetName(), err)
+		return
+	}
+	var count int
+	for _, s := range seeds {
+		ruleName, found := self.seedRule(s.Rule)
+		if !found {
+			logs.Log.Error(" *     [%v] 种子URL %v 的规则 %q 不存在，已忽略\n", self.GetName(), s.Url, ruleName)
+			continue
+		}
+		ctx.AddQueue(&request.Request{
+			Url:  s.Url,
+			Rule: ruleName,
+		})
+		count++
+	}
+	logs.Log.Informational(" *     [%v] 从种子URL列表中加入 %v 条请求\n", self.GetName(), count)
+}
+
+// 向运行中的蜘蛛加入种子请求，用于任务运行期间持续接收新的种子URL；
+// rule为空时采用蜘蛛唯一的规则；请求照常去重，已抓取过或已在队列中的URL被忽略
+func (self *Spider) AddSeed(url, rule string) error {
+	self.lock.RLock()
+	running := self.status == status.RUN && self.stopped == ""
+	self.lock.RUnlock()
+	if !running {
+		return fmt.Errorf("spider %q is not running", self.GetName())
+	}
+	ruleName, found := self.seedRule(rule)
+	if !found {
+		return fmt.Errorf("rule %q not found", ruleName)
+	}
+	req := &request.Request{
+		Url:  url,
+		Rule: ruleName,
+	}
+	err := req.
+		Inherit(self.GetDefaultRequest()).
+		SetSpiderName(self.GetName()).
+		SetEnableCookie(self.GetEnableCookie()).
+		Prepare()
+	if err != nil {
+		return err
+	}
+	self.warm(req)
+	self.RequestPush(req)
+	return nil
+}
+
+// 返回种子请求所用的规则名及其是否存在，未指定时采用蜘蛛唯一的规则
+func (self *Spider) seedRule(rule string) (string, bool) {
+	if rule == "" && len(self.RuleTree.Trunk) == 1 {
+		for ruleName := range self.RuleTree.Trunk {
+			rule = ruleName
+		}
+	}
+	_, found := self.GetRule(rule)
+	return rule, found
+}
+
+// 通用的URL列表蜘蛛，配合种子URL列表使用，无需编写规则即可批量抓取网页
+var UrlList = Spider{
+	Name:        "URL列表",
+	Description: "通用URL列表采集 [需指定种子URL列表，输出网页标题、描述与正文]",
+	RuleTree: &RuleTree{
+		Root: func(ctx *Context) {
+			if cache.Task.UrlsFile == "" {
+				logs.Log.Warning(" *     [%v] 未指定种子URL列表，无请求可执行\n", ctx.GetSpider().GetName())
+			}
+		},
+		Trunk: map[string]*Rule{
+			"网页": {
+				ItemFields: []string{
+					"标题",
+					"描述",
+					"正文",
+				},
+				ParseFunc: func(ctx *Context) {
+					dom := ctx.GetDom()
+					description, _ := dom.Find(`meta[name="description"]`).Attr("content")
+					ctx.Output(map[int]interface{}{
+						0: strings.TrimSpace(dom.Find("title").First().Text()),
+						1: strings.TrimSpace(description),
+						2: strings.TrimSpace(dom.Find("body").Text()),
+					})
+				},
+			},
+		},
+	},
+}.Register()
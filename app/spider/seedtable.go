@@ -0,0 +1,182 @@
+package spider
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+	"github.com/henrylee2cn/pholcus/common/xlsx"
+	"github.com/henrylee2cn/pholcus/logs"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+// 逐行读取的种子数据表
+type tableReader interface {
+	Read() ([]string, error)
+	Close() error
+}
+
+// csv格式的种子数据表
+type csvTable struct {
+	*csv.Reader
+	file *os.File
+}
+
+func (self *csvTable) Close() error {
+	return self.file.Close()
+}
+
+// URL模板中的列名占位符，如{关键词}
+var seedColumnRe = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// 打开种子数据表，扩展名为.xlsx时按Excel读取首个工作表，否则按csv读取
+func openSeedTable(fileName string) (tableReader, error) {
+	if strings.EqualFold(filepath.Ext(fileName), ".xlsx") {
+		return xlsx.OpenRowReader(fileName)
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	r.ReuseRecord = true
+	return &csvTable{r, f}, nil
+}
+
+// 种子数据表的列映射
+type seedMapping struct {
+	columns map[string]int    // [列名]列序号
+	meta    map[string]string // [列名]Meta键名
+}
+
+// 按表头解析URL模板及Meta列的映射，模板或Meta中引用了不存在的列时返回错误；
+// metaSpec为空时全部列均作为Meta，否则以逗号分隔，可用“列名:键名”指定Meta键名
+func newSeedMapping(header []string, urlTmpl, metaSpec string) (*seedMapping, error) {
+	m := &seedMapping{
+		columns: make(map[string]int, len(header)),
+		meta:    make(map[string]string),
+	}
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		if _, ok := m.columns[name]; !ok && name != "" {
+			m.columns[name] = i
+		}
+	}
+	for _, match := range seedColumnRe.FindAllStringSubmatch(urlTmpl, -1) {
+		if _, ok := m.columns[match[1]]; !ok {
+			return nil, fmt.Errorf("url template column %q not found", match[1])
+		}
+	}
+	if strings.TrimSpace(metaSpec) == "" {
+		for name := range m.columns {
+			m.meta[name] = name
+		}
+		return m, nil
+	}
+	for _, spec := range strings.Split(metaSpec, ",") {
+		name, key, found := strings.Cut(strings.TrimSpace(spec), ":")
+		if name == "" {
+			continue
+		}
+		if !found {
+			key = name
+		}
+		if _, ok := m.columns[name]; !ok {
+			return nil, fmt.Errorf("meta column %q not found", name)
+		}
+		m.meta[name] = key
+	}
+	return m, nil
+}
+
+// 返回行中指定列的值，缺失时为空字符串
+func (self *seedMapping) value(row []string, name string) string {
+	if i := self.columns[name]; i < len(row) {
+		return strings.TrimSpace(row[i])
+	}
+	return ""
+}
+
+// 按URL模板生成该行的URL，占位符替换为经URL编码的列值；模板为空时采用url列（不区分大小写）或首列的值
+func (self *seedMapping) url(row []string, urlTmpl string) string {
+	if urlTmpl == "" {
+		for name := range self.columns {
+			if strings.EqualFold(name, "url") {
+				return self.value(row, name)
+			}
+		}
+		if len(row) > 0 {
+			return strings.TrimSpace(row[0])
+		}
+		return ""
+	}
+	return seedColumnRe.ReplaceAllStringFunc(urlTmpl, func(s string) string {
+		return url.QueryEscape(self.value(row, s[1:len(s)-1]))
+	})
+}
+
+// 以种子数据表的各行作为初始请求，代替Root入口；
+// 首行为表头，逐行读取并生成请求，不一次性载入整个文件，空行被忽略
+func (self *Spider) addTableSeeds(ctx *Context) {
+	ruleName, found := self.seedRule(cache.Task.SeedRule)
+	if !found {
+		logs.Log.Error(" *     [%v] 种子数据表的规则 %q 不存在\n", self.GetName(), ruleName)
+		return
+	}
+	table, err := openSeedTable(cache.Task.SeedTable)
+	if err != nil {
+		logs.Log.Error(" *     [%v] 读取种子数据表出错: %v\n", self.GetName(), err)
+		return
+	}
+	defer table.Close()
+
+	header, err := table.Read()
+	if err != nil {
+		logs.Log.Error(" *     [%v] 读取种子数据表的表头出错: %v\n", self.GetName(), err)
+		return
+	}
+	mapping, err := newSeedMapping(header, cache.Task.SeedUrl, cache.Task.SeedMeta)
+	if err != nil {
+		logs.Log.Error(" *     [%v] 种子数据表的列映射有误: %v\n", self.GetName(), err)
+		return
+	}
+
+	var count, line int
+	for {
+		row, err := table.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			logs.Log.Error(" *     [%v] 读取种子数据表第 %v 行出错，停止读取: %v\n", self.GetName(), line+1, err)
+			break
+		}
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+		u := mapping.url(row, cache.Task.SeedUrl)
+		if u == "" {
+			logs.Log.Warning(" *     [%v] 种子数据表第 %v 行的URL为空，已忽略\n", self.GetName(), line+1)
+			continue
+		}
+		req := &request.Request{
+			Url:  u,
+			Rule: ruleName,
+		}
+		for name, key := range mapping.meta {
+			req.SetMeta(key, mapping.value(row, name))
+		}
+		ctx.AddQueue(req)
+		count++
+	}
+	logs.Log.Informational(" *     [%v] 从种子数据表中加入 %v 条请求\n", self.GetName(), count)
+}
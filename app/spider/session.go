@@ -0,0 +1,214 @@
+package spider
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+	"github.com/henrylee2cn/pholcus/logs"
+)
+
+// 会话池的分配策略
+const (
+	SESSION_ROUND_ROBIN = iota // 轮询
+	SESSION_LRU                // 最久未使用优先
+)
+
+// 默认的会话封禁时长
+const DefaultBenchTime = 10 * time.Minute
+
+type (
+	// 已登录的会话，每个会话拥有独立的cookie及可选的代理
+	Session struct {
+		Name    string // 会话名，在会话池中唯一
+		Cookies string // 该会话的cookie，格式同请求头Cookie，如"a=1; b=2"
+		Proxy   string // 该会话固定使用的代理(选填)，优先于全局代理IP
+
+		lastUsed     time.Time // 最近一次被分配的时间
+		benchedUntil time.Time // 封禁截止时间
+	}
+	// 多账号会话池，为每个请求分配会话，以分散单个账号的访问频率
+	SessionPool struct {
+		Strategy  int           // 分配策略，SESSION_ROUND_ROBIN或SESSION_LRU
+		BenchTime time.Duration // 会话被封禁(如响应403/429)后暂停使用的时长，0为DefaultBenchTime
+
+		sessions []*Session
+		next     int
+		lock     sync.Mutex
+	}
+)
+
+// 创建会话池
+func NewSessionPool(strategy int, sessions ...*Session) *SessionPool {
+	self := &SessionPool{Strategy: strategy}
+	self.Add(sessions...)
+	return self
+}
+
+// 添加会话，同名时替换原会话
+func (self *SessionPool) Add(sessions ...*Session) *SessionPool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for _, sess := range sessions {
+		if i := self.index(sess.Name); i >= 0 {
+			self.sessions[i] = sess
+			continue
+		}
+		self.sessions = append(self.sessions, sess)
+	}
+	return self
+}
+
+// 返回会话总数
+func (self *SessionPool) Len() int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return len(self.sessions)
+}
+
+// 暂停使用指定会话，d<=0时采用BenchTime
+func (self *SessionPool) Bench(name string, d time.Duration) {
+	if d <= 0 {
+		d = self.BenchTime
+	}
+	if d <= 0 {
+		d = DefaultBenchTime
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if i := self.index(name); i >= 0 {
+		self.sessions[i].benchedUntil = time.Now().Add(d)
+		logs.Log.Warning(" *     会话 [%v] 疑似被封禁，暂停使用 %v\n", name, d)
+	}
+}
+
+// 分配会话，name不为空时返回指定会话（即使处于封禁期）；
+// 否则按分配策略从未封禁的会话中选取，全部被封禁时返回最早解封的会话
+func (self *SessionPool) Assign(name string) *Session {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if len(self.sessions) == 0 {
+		return nil
+	}
+	now := time.Now()
+	if name != "" {
+		if i := self.index(name); i >= 0 {
+			self.sessions[i].lastUsed = now
+			return self.sessions[i]
+		}
+		logs.Log.Warning(" *     会话 [%v] 不存在，改由会话池分配\n", name)
+	}
+
+	var sess *Session
+	switch self.Strategy {
+	case SESSION_LRU:
+		for _, s := range self.sessions {
+			if s.benchedUntil.After(now) {
+				continue
+			}
+			if sess == nil || s.lastUsed.Before(sess.lastUsed) {
+				sess = s
+			}
+		}
+	default:
+		for i := 0; i < len(self.sessions); i++ {
+			s := self.sessions[(self.next+i)%len(self.sessions)]
+			if s.benchedUntil.After(now) {
+				continue
+			}
+			sess = s
+			self.next = (self.next + i + 1) % len(self.sessions)
+			break
+		}
+	}
+	if sess == nil {
+		for _, s := range self.sessions {
+			if sess == nil || s.benchedUntil.Before(sess.benchedUntil) {
+				sess = s
+			}
+		}
+	}
+	sess.lastUsed = now
+	return sess
+}
+
+func (self *SessionPool) index(name string) int {
+	for i, sess := range self.sessions {
+		if sess.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// 返回应用了会话的请求副本，原请求不变，以便重试时重新分配会话
+// cookie优先级：请求自身设置的cookie > 会话cookie > 共享cookiejar
+// 代理优先级：区域变体的代理 > 会话的代理 > 全局代理IP
+func (self *Spider) UseSession(req *request.Request) *request.Request {
+	reqcopy := self.useSession(req)
+	if proxy := self.variantProxy(req); proxy != "" {
+		if reqcopy == req {
+			reqcopy = req.Copy()
+		}
+		reqcopy.SetProxy(proxy)
+	}
+	return reqcopy
+}
+
+func (self *Spider) useSession(req *request.Request) *request.Request {
+	if self.Sessions == nil || self.Sessions.Len() == 0 {
+		return req
+	}
+	sess := self.Sessions.Assign(req.GetSession())
+	if sess == nil {
+		return req
+	}
+	reqcopy := req.Copy()
+	reqcopy.SetSession(sess.Name)
+	reqcopy.SetCookies(mergeCookies(sess.Cookies, req.GetCookies()))
+	if sess.Proxy != "" {
+		reqcopy.SetProxy(sess.Proxy)
+	} else {
+		reqcopy.SetProxy(req.GetProxy())
+	}
+	return reqcopy
+}
+
+// 响应状态为403或429时，暂停使用该请求的会话
+func (self *Spider) CheckSession(ctx *Context) {
+	if self.Sessions == nil || ctx.Request.GetSession() == "" || ctx.Response == nil {
+		return
+	}
+	switch ctx.Response.StatusCode {
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		self.Sessions.Bench(ctx.Request.GetSession(), 0)
+	}
+}
+
+// 合并cookie字符串，后者覆盖前者中的同名cookie
+func mergeCookies(base, override string) string {
+	if base == "" {
+		return override
+	}
+	if override == "" {
+		return base
+	}
+	var (
+		cookies = (&http.Request{Header: http.Header{"Cookie": {base, override}}}).Cookies()
+		values  = make(map[string]string, len(cookies))
+		order   []string
+	)
+	for _, c := range cookies {
+		if _, ok := values[c.Name]; !ok {
+			order = append(order, c.Name)
+		}
+		values[c.Name] = c.Value
+	}
+	pairs := make([]string, len(order))
+	for i, name := range order {
+		pairs[i] = name + "=" + values[name]
+	}
+	return strings.Join(pairs, "; ")
+}
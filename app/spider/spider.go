@@ -2,13 +2,23 @@ package spider
 
 import (
 	"math"
+	"net/url"
+	"regexp"
+	"runtime/debug"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/PuerkitoBio/goquery"
+
 	"github.com/henrylee2cn/pholcus/app/downloader/request"
+	"github.com/henrylee2cn/pholcus/app/downloader/surfer"
+	"github.com/henrylee2cn/pholcus/app/pipeline/collector/data"
 	"github.com/henrylee2cn/pholcus/app/scheduler"
 	"github.com/henrylee2cn/pholcus/common/util"
 	"github.com/henrylee2cn/pholcus/logs"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
 	"github.com/henrylee2cn/pholcus/runtime/status"
 )
 
@@ -16,31 +26,74 @@ const (
 	KEYIN       = util.USE_KEYIN // 若使用Spider.Keyin，则须在规则中设置初始值为USE_KEYIN
 	LIMIT       = math.MaxInt64  // 如希望在规则中自定义控制Limit，则Limit初始值必须为LIMIT
 	ACTIVE_STOP = "——主动终止Spider——"
+	MAX_PAUSE   = 60000 // 规则动态调整暂停时长的上限/ms
 )
 
 type (
 	// 蜘蛛规则
 	Spider struct {
 		// 以下字段由用户定义
-		Name            string                                                     // 用户界面显示的名称（应保证唯一性）
-		Description     string                                                     // 用户界面显示的描述
-		Pausetime       int64                                                      // 随机暂停区间(50%~200%)，若规则中直接定义，则不被界面传参覆盖
-		Limit           int64                                                      // 默认限制请求数，0为不限；若规则中定义为LIMIT，则采用规则的自定义限制方案
-		Keyin           string                                                     // 自定义输入的配置信息，使用前须在规则中设置初始值为KEYIN
-		EnableCookie    bool                                                       // 所有请求是否使用cookie记录
-		NotDefaultField bool                                                       // 是否禁止输出结果中的默认字段 Url/ParentUrl/DownloadTime
-		Namespace       func(self *Spider) string                                  // 命名空间，用于输出文件、路径的命名
-		SubNamespace    func(self *Spider, dataCell map[string]interface{}) string // 次级命名，用于输出文件、路径的命名，可依赖具体数据内容
-		RuleTree        *RuleTree                                                  // 定义具体的采集规则树
+		Name            string                                                           // 用户界面显示的名称（应保证唯一性）
+		Description     string                                                           // 用户界面显示的描述
+		Pausetime       int64                                                            // 随机暂停区间(50%~200%)，若规则中直接定义，则不被界面传参覆盖
+		Limit           int64                                                            // 默认限制请求数，0为不限；若规则中定义为LIMIT，则采用规则的自定义限制方案
+		Keyin           string                                                           // 自定义输入的配置信息，使用前须在规则中设置初始值为KEYIN
+		EnableCookie    bool                                                             // 所有请求是否使用cookie记录
+		NotDefaultField bool                                                             // 是否禁止输出结果中的默认字段 Url/ParentUrl/DownloadTime
+		Namespace       func(self *Spider) string                                        // 命名空间，用于输出文件、路径的命名
+		SubNamespace    func(self *Spider, dataCell map[string]interface{}) string       // 次级命名，用于输出文件、路径的命名，可依赖具体数据内容
+		SoftRedirect    int                                                              // 跟随网页内软跳转的方式，SOFT_REDIRECT_NONE、SOFT_REDIRECT_META或SOFT_REDIRECT_ALL，默认不跟随
+		Normalize       bool                                                             // 是否规范化输出结果中的文本字段值：解码HTML实体、去除首尾空白并将内部连续空白(含换行、&nbsp;)合并为一个空格，亦可按Rule.Normalize单独开启
+		HeadCheck       bool                                                             // 是否先以HEAD请求比对Content-Length及Last-Modified，与上次抓取成功时相同则跳过下载及解析(仅Surf内核的GET请求)
+		RenderFallback  bool                                                             // PhantomJS内核渲染失败（如崩溃、超时）时，是否改以Surf内核下载网络原始响应并照常解析，此时Context.GetRenderError()返回渲染失败的原因
+		RetryPatterns   []string                                                         // 响应内容匹配其中任一正则时视为可重试的失败(选填)，如限流或验证提示页，重试前按请求的RetryPause退避
+		ParseDom        func(ctx *Context, body []byte) (*goquery.Document, error)       // 将响应内容解析为Dom的函数(选填)，可在解析前修复残缺的HTML或去除脚本等，为nil时采用goquery默认解析，见SanitizeDom
+		UniqueKey       func(req *request.Request) string                                // 自定义请求去重键的函数(选填)，返回值的哈希即为请求的唯一识别码，仅作用于本蜘蛛，为nil时采用request.DefaultUniqueKey
+		MaxFileRows     int                                                              // csv、excel输出时单个文件的最大结果数(选填)，超出时拆分为 _part1、_part2 等多个文件并各自写入表头，0为不限
+		MaxFileSize     int64                                                            // csv、excel输出时单个文件的最大字节数(选填，按字段内容估算)，超出时同样拆分，0为不限
+		OutDir          string                                                           // 该蜘蛛文件与文本结果的输出目录(选填)，可用 {namespace} {spider} {keyin} {date}，为空时采用全局的输出目录
+		RuleTree        *RuleTree                                                        // 定义具体的采集规则树
+		Auth            map[string]*surfer.Auth                                          // [主机]HTTP Basic或Digest认证凭据(选填)，主机可含端口，如"intra.example.com"，请求未设置Auth时采用
+		Sessions        *SessionPool                                                     // 多账号会话池(选填)，为每个请求分配会话的cookie与代理
+		Variants        []*Variant                                                       // 区域变体(选填)，设置了Rule.Vary的规则的请求按此展开为每个变体各一个请求，各自采用变体的请求头、cookie、会话及代理
+		OnPanic         func(rule string, recovered interface{}, stack []byte)           // 规则解析发生panic时的回调(选填)，可用于外部告警
+		OnStart         func(self *Spider)                                               // 任务开始、执行Root之前的回调(选填)，可用于准备登录信息、打开资源等
+		OnStop          func(self *Spider)                                               // 任务结束、处理中的请求均完成后的回调(选填)，主动终止时亦会调用，可用于关闭资源、输出汇总等
+		ContextPool     int                                                              // 该蜘蛛独占的Context复用池容量(选填)，高并发时可设为与并发量相当以减少争用与GC压力，0时采用全局共享的复用池
+		Downloader      Downloader                                                       // 该蜘蛛使用的下载器(选填)，为nil时使用全局默认的downloader.SurferDownloader
+		Scheduler       func(self *Spider, matrix *scheduler.Matrix) scheduler.ReqMatrix // 自定义请求调度器(选填)，matrix为默认的请求矩阵，可包装或替代之
 
 		// 以下字段系统自动赋值
-		id        int               // 自动分配的SpiderQueue中的索引
-		subName   string            // 由Keyin转换为的二级标识名
-		reqMatrix *scheduler.Matrix // 请求矩阵
-		timer     *Timer            // 定时器
-		status    int               // 执行状态
+		id        int                    // 自动分配的SpiderQueue中的索引
+		subName   string                 // 由Keyin转换为的二级标识名
+		reqMatrix scheduler.ReqMatrix    // 请求矩阵
+		timer     *Timer                 // 定时器
+		status    int                    // 执行状态
+		itemLimit int64                  // 结果数据单元的采集上限，0为不限
+		itemCount int64                  // 已收集的结果数据单元数量
+		ruleItems map[string]int64       // [规则名]已收集的结果数据单元数量
+		reqTmpl   *request.Request       // 默认请求模板
+		panics    map[string]int         // [规则名]解析时发生panic的次数
+		warmup    func(*request.Request) // 预热连接的函数，为nil时不预热
+		warmed    map[string]bool        // 已预热的主机
+		noDedup   bool                   // 是否关闭请求去重
+		pause     int64                  // 规则动态调整的暂停时长参考/ms，adjusted为false时无效
+		adjusted  bool                   // 是否已由规则动态调整暂停时长
+		fresh     *freshStore            // 开启HeadCheck时的网页新鲜度元数据
+		stopped   string                 // 规则调用Context.StopSpider()时的原因，非空时不再派发新请求
+		samples   map[string]int         // 预览模式下[规则名]已解析的成功页数
+		ctxPool   chan *Context          // 按ContextPool创建的独占Context复用池，为nil时采用全局共享的复用池
+		ctxHits   int64                  // 从Context复用池取出时的命中次数
+		ctxMisses int64                  // 从Context复用池取出时的未命中（新建）次数
 		lock      sync.RWMutex
 		once      sync.Once
+		retryRes  []*regexp.Regexp // 由RetryPatterns编译的正则
+		retryOnce sync.Once
+	}
+	// 下载器，与downloader.Downloader一致，在此声明以避免循环引用
+	// 仅需切换Surf与PhantomJS内核时，可通过SetDefaultRequest()统一设置DownloaderID
+	Downloader interface {
+		Download(*Spider, *request.Request) *Context
 	}
 	//采集规则树
 	RuleTree struct {
@@ -49,9 +102,23 @@ type (
 	}
 	// 采集规则节点
 	Rule struct {
-		ItemFields []string                                           // 结果字段列表(选填，写上可保证字段顺序)
-		ParseFunc  func(*Context)                                     // 内容解析函数
-		AidFunc    func(*Context, map[string]interface{}) interface{} // 通用辅助函数
+		ItemFields    []string                                           // 结果字段列表(选填，写上可保证字段顺序)
+		ParseFunc     func(*Context)                                     // 内容解析函数
+		AidFunc       func(*Context, map[string]interface{}) interface{} // 通用辅助函数
+		Concurrency   int                                                // 该规则下请求的最大并发量，0为不限（仅受全局并发量限制）
+		Vary          bool                                               // 是否将添加至该规则的请求按Spider.Variants展开，已带有区域变体的请求(如继承自父请求)除外
+		Serial        bool                                               // 是否严格按加入顺序逐个处理该规则的请求，前一个完成后才派发下一个，失败重试时仍先于后续请求，适用于游标分页等有先后依赖的接口；其他规则照常并发
+		MinPause      int64                                              // 派发该规则的请求后随机暂停的最短时长/ms，与MaxPause均为0时采用全局暂停时长
+		MaxPause      int64                                              // 派发该规则的请求后随机暂停的最长时长/ms
+		Pager         *Pager                                             // 分页请求生成器(选填)，配合Context.AddPages()使用
+		Raw           int                                                // 输出结果时附带原始响应内容的方式，RAW_NONE、RAW_INLINE或RAW_BLOB，默认不附带
+		RedirectChain int                                                // 输出结果时以RedirectChain字段附带的HTTP重定向链的最大跳数，0为不附带，小于0时不限，见Context.GetRedirectChain
+		Normalize     bool                                               // 是否规范化该规则输出结果中的文本字段值，Spider.Normalize为true时对所有规则生效，见NormalizeText
+		RawFields     []string                                           // 规范化时保持原值的字段
+		Body          int                                                // 解析的响应内容来源，BODY_AUTO、BODY_SOURCE、BODY_RENDERED或BODY_BOTH，默认按请求的DownloaderID下载
+		Explain       bool                                               // 是否记录该规则中Context.Find()的选择器、匹配数量及匹配内容片段，用于调试选择器
+		Validate      func(*Context) error                               // 校验响应(选填)，如内容过短、类型不符，返回错误时视为下载失败并按失败重试，未标记失败类型时为ERR_INVALID，类型为ERR_INVALID或ERR_BLOCKED时重试将更换代理IP，可用ValidateMinLength、ValidateContentType
+		Empty         func(*Context) bool                                // 判断响应是否为无数据页面(选填)，如返回200的"无结果"页，为true时不解析，视为成功并单独计数，可用EmptyIfSelector、EmptyIfContains
 	}
 )
 
@@ -169,6 +236,129 @@ func (self *Spider) SetLimit(max int64) {
 	self.Limit = max
 }
 
+// 设置结果数据单元（DataCell）的采集上限，达到上限后主动终止任务
+// <=0 表示不限
+func (self *Spider) SetItemLimit(n int) {
+	if n < 0 {
+		n = 0
+	}
+	atomic.StoreInt64(&self.itemLimit, int64(n))
+}
+
+// 获取结果数据单元的采集上限
+func (self *Spider) GetItemLimit() int64 {
+	return atomic.LoadInt64(&self.itemLimit)
+}
+
+// 获取已收集的结果数据单元数量，并发安全
+func (self *Spider) GetItemCount() int64 {
+	return atomic.LoadInt64(&self.itemCount)
+}
+
+// 累加已收集的结果数据单元数量，返回该数据单元是否在采集上限之内，并发安全
+// 在上限之内时同时累加其所属规则的数量；恰好达到上限时主动终止任务，处理中的请求仍会完成
+func (self *Spider) AddItemCount(ruleName string) bool {
+	count := atomic.AddInt64(&self.itemCount, 1)
+	limit := self.GetItemLimit()
+	if limit > 0 && count > limit {
+		return false
+	}
+	self.lock.Lock()
+	if self.ruleItems == nil {
+		self.ruleItems = make(map[string]int64)
+	}
+	self.ruleItems[ruleName]++
+	self.lock.Unlock()
+	if count == limit {
+		logs.Log.Informational(" *     [%v] 已收集 %v 条结果，达到采集上限，停止任务\n", self.GetName(), limit)
+		self.Stop()
+	}
+	return true
+}
+
+// 获取各规则已收集的结果数据单元数量，并发安全
+// 尚无结果的规则计为0，便于发现选择器失效等原因导致的无产出规则
+func (self *Spider) GetRuleItemCounts() map[string]int64 {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	counts := make(map[string]int64, len(self.RuleTree.Trunk))
+	for ruleName := range self.RuleTree.Trunk {
+		counts[ruleName] = 0
+	}
+	for ruleName, n := range self.ruleItems {
+		counts[ruleName] = n
+	}
+	return counts
+}
+
+// 设置默认请求模板，其中的非零值字段在添加请求时合并至该蜘蛛的每个请求，请求自身已设置的字段优先
+// 用于统一设置请求头、超时、下载器等，Url与Rule不继承
+func (self *Spider) SetDefaultRequest(req *request.Request) *Spider {
+	self.reqTmpl = req
+	return self
+}
+
+// 获取默认请求模板，未设置时为nil
+func (self *Spider) GetDefaultRequest() *request.Request {
+	return self.reqTmpl
+}
+
+// 记录规则解析时发生的panic，并调用OnPanic回调，并发安全
+func (self *Spider) RecordPanic(ruleName string, recovered interface{}, stack []byte) {
+	self.lock.Lock()
+	if self.panics == nil {
+		self.panics = make(map[string]int)
+	}
+	self.panics[ruleName]++
+	self.lock.Unlock()
+
+	if self.OnPanic == nil {
+		return
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			logs.Log.Error(" *     Panic  [OnPanic]: %v\n", p)
+		}
+	}()
+	self.OnPanic(ruleName, recovered, stack)
+}
+
+// 获取各规则解析时发生panic的次数，并发安全
+func (self *Spider) GetPanics() map[string]int {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	panics := make(map[string]int, len(self.panics))
+	for ruleName, n := range self.panics {
+		panics[ruleName] = n
+	}
+	return panics
+}
+
+// 设置预热连接的函数，种子请求首次出现的主机将在后台预先建立连接，为nil时不预热
+func (self *Spider) SetWarmup(warmup func(*request.Request)) {
+	self.lock.Lock()
+	self.warmup = warmup
+	self.warmed = make(map[string]bool)
+	self.lock.Unlock()
+}
+
+// 在后台预热请求所在主机的连接，每个主机仅预热一次
+func (self *Spider) warm(req *request.Request) {
+	u, err := url.Parse(req.GetUrl())
+	if err != nil {
+		return
+	}
+	self.lock.Lock()
+	warmup := self.warmup
+	if warmup == nil || self.warmed[u.Host] {
+		self.lock.Unlock()
+		return
+	}
+	self.warmed[u.Host] = true
+	self.lock.Unlock()
+	go warmup(req.Copy())
+}
+
 // 控制所有请求是否使用cookie
 func (self *Spider) GetEnableCookie() bool {
 	return self.EnableCookie
@@ -182,6 +372,28 @@ func (self *Spider) SetPausetime(pause int64, runtime ...bool) {
 	}
 }
 
+// 动态调整后续请求的暂停时长参考/ms(随机: pause/2 ~ pause*2)，作用于该蜘蛛的全部规则并覆盖全局及规则级的暂停设置，
+// 限制在0~MAX_PAUSE之间，返回调整后的值，并发安全
+func (self *Spider) AdjustPause(pause int64) int64 {
+	if pause < 0 {
+		pause = 0
+	} else if pause > MAX_PAUSE {
+		pause = MAX_PAUSE
+	}
+	self.lock.Lock()
+	self.pause = pause
+	self.adjusted = true
+	self.lock.Unlock()
+	return pause
+}
+
+// 获取动态调整后的暂停时长参考/ms，未调整时ok为false，并发安全
+func (self *Spider) GetAdjustedPause() (pause int64, ok bool) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.pause, self.adjusted
+}
+
 // 设置定时器
 // @id为定时器唯一标识
 // @bell==nil时为倒计时器，此时@tol为睡眠时长
@@ -201,6 +413,21 @@ func (self *Spider) RunTimer(id string) bool {
 	return self.timer.sleep(id)
 }
 
+// 返回Spider.Auth中该网址所在主机的认证凭据，先按含端口的主机查找，再按主机名查找，不存在时返回nil
+func (self *Spider) GetAuth(rawurl string) *surfer.Auth {
+	if len(self.Auth) == 0 {
+		return nil
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil
+	}
+	if auth, ok := self.Auth[u.Host]; ok {
+		return auth
+	}
+	return self.Auth[u.Hostname()]
+}
+
 // 返回一个自身复制品
 func (self *Spider) Copy() *Spider {
 	ghost := &Spider{}
@@ -219,17 +446,53 @@ func (self *Spider) Copy() *Spider {
 
 		ghost.RuleTree.Trunk[k].ParseFunc = v.ParseFunc
 		ghost.RuleTree.Trunk[k].AidFunc = v.AidFunc
+		ghost.RuleTree.Trunk[k].Concurrency = v.Concurrency
+		ghost.RuleTree.Trunk[k].Vary = v.Vary
+		ghost.RuleTree.Trunk[k].Serial = v.Serial
+		ghost.RuleTree.Trunk[k].MinPause = v.MinPause
+		ghost.RuleTree.Trunk[k].MaxPause = v.MaxPause
+		ghost.RuleTree.Trunk[k].Pager = v.Pager
+		ghost.RuleTree.Trunk[k].Raw = v.Raw
+		ghost.RuleTree.Trunk[k].RedirectChain = v.RedirectChain
+		ghost.RuleTree.Trunk[k].Normalize = v.Normalize
+		ghost.RuleTree.Trunk[k].RawFields = v.RawFields
+		ghost.RuleTree.Trunk[k].Body = v.Body
+		ghost.RuleTree.Trunk[k].Explain = v.Explain
+		ghost.RuleTree.Trunk[k].Validate = v.Validate
+		ghost.RuleTree.Trunk[k].Empty = v.Empty
 	}
 
 	ghost.Description = self.Description
 	ghost.Pausetime = self.Pausetime
 	ghost.EnableCookie = self.EnableCookie
+	ghost.Sessions = self.Sessions
+	ghost.Variants = self.Variants
+	ghost.Auth = self.Auth
+	ghost.OnPanic = self.OnPanic
+	ghost.OnStart = self.OnStart
+	ghost.OnStop = self.OnStop
+	ghost.Downloader = self.Downloader
+	ghost.ContextPool = self.ContextPool
+	ghost.RenderFallback = self.RenderFallback
+	ghost.UniqueKey = self.UniqueKey
+	ghost.Scheduler = self.Scheduler
+	ghost.noDedup = self.noDedup
 	ghost.Limit = self.Limit
+	ghost.itemLimit = self.GetItemLimit()
+	ghost.reqTmpl = self.reqTmpl
 	ghost.Keyin = self.Keyin
 
 	ghost.NotDefaultField = self.NotDefaultField
 	ghost.Namespace = self.Namespace
 	ghost.SubNamespace = self.SubNamespace
+	ghost.MaxFileRows = self.MaxFileRows
+	ghost.MaxFileSize = self.MaxFileSize
+	ghost.OutDir = self.OutDir
+	ghost.SoftRedirect = self.SoftRedirect
+	ghost.Normalize = self.Normalize
+	ghost.HeadCheck = self.HeadCheck
+	ghost.RetryPatterns = self.RetryPatterns
+	ghost.ParseDom = self.ParseDom
 
 	ghost.timer = self.timer
 	ghost.status = self.status
@@ -237,35 +500,144 @@ func (self *Spider) Copy() *Spider {
 	return ghost
 }
 
+// 初始化请求矩阵，设置了Scheduler时安装自定义的请求调度器
 func (self *Spider) ReqmatrixInit() *Spider {
+	var matrix *scheduler.Matrix
 	if self.Limit < 0 {
-		self.reqMatrix = scheduler.AddMatrix(self.GetName(), self.GetSubName(), self.Limit)
+		matrix = scheduler.AddMatrix(self.GetName(), self.GetSubName(), self.Limit)
 		self.SetLimit(0)
 	} else {
-		self.reqMatrix = scheduler.AddMatrix(self.GetName(), self.GetSubName(), math.MinInt64)
+		matrix = scheduler.AddMatrix(self.GetName(), self.GetSubName(), math.MinInt64)
+	}
+	if self.noDedup {
+		matrix.DisableDedup()
+	}
+	request.RegisterUniqueKey(self.GetName(), self.UniqueKey)
+	var serial []string
+	for ruleName, rule := range self.RuleTree.Trunk {
+		if rule.Serial {
+			serial = append(serial, ruleName)
+		}
 	}
+	sort.Strings(serial)
+	matrix.SetSerial(serial...)
+	self.reqMatrix = matrix
+	if self.Scheduler != nil {
+		self.reqMatrix = self.Scheduler(self, matrix)
+	}
+	return self
+}
+
+// 关闭请求去重，相同的请求每次加入队列都会执行，适用于反复轮询同一网址以监测内容变化等场景；
+// 关闭后不再记录成功记录，也不再借助去重限制队列中的请求积存量，须由规则自行控制请求的加入
+func (self *Spider) DisableDedup() *Spider {
+	self.noDedup = true
 	return self
 }
 
+// 返回请求矩阵
+func (self *Spider) ReqMatrix() scheduler.ReqMatrix {
+	return self.reqMatrix
+}
+
 // 返回是否作为新的失败请求被添加至队列尾部
 func (self *Spider) DoHistory(req *request.Request, ok bool) bool {
 	return self.reqMatrix.DoHistory(req, ok)
 }
 
+// 记录不可重试的失败请求，不再重新入队
+func (self *Spider) DoPermanentFailure(req *request.Request) {
+	self.reqMatrix.DoPermanentFailure(req)
+}
+
+// 失败的请求是否仍将重新执行，须在释放该请求的资源前调用
+func (self *Spider) WillRetry(req *request.Request) bool {
+	return self.reqMatrix.WillRetry(req)
+}
+
+// 返回规范网址是否已处理过，未处理时将其记录
+func (self *Spider) SeenCanonical(canonical string) bool {
+	return self.reqMatrix.SeenCanonical(canonical)
+}
+
+// 移除规范网址的记录，以便失败的请求重新处理
+func (self *Spider) ForgetCanonical(canonical string) {
+	self.reqMatrix.ForgetCanonical(canonical)
+}
+
+// 返回结果的幂等键是否已收集过或正在输出，否则将其标记为正在输出
+func (self *Spider) SeenItem(key string) bool {
+	return self.reqMatrix.SeenItem(key)
+}
+
+// 结果输出成功后记录其幂等键，key为空时忽略
+func (self *Spider) RecordItem(key string) {
+	if key != "" {
+		self.reqMatrix.RecordItem(key)
+	}
+}
+
+// 结果未能输出时移除其幂等键的标记，key为空时忽略
+func (self *Spider) ForgetItem(key string) {
+	if key != "" {
+		self.reqMatrix.ForgetItem(key)
+	}
+}
+
+// 记录失败请求及其原因，用于生成失败请求报告
+func (self *Spider) RecordFailure(req *request.Request, err error) {
+	scheduler.RecordFailure(req, err)
+}
+
+// 添加请求到队列，所属规则设置了Vary时按区域变体展开
 func (self *Spider) RequestPush(req *request.Request) {
-	self.reqMatrix.Push(req)
+	if reqs := self.vary(req); len(reqs) > 1 {
+		self.reqMatrix.PushBatch(reqs)
+	} else {
+		self.reqMatrix.Push(req)
+	}
+}
+
+func (self *Spider) RequestPushBatch(reqs []*request.Request) {
+	varied := make([]*request.Request, 0, len(reqs))
+	for _, req := range reqs {
+		varied = append(varied, self.vary(req)...)
+	}
+	self.reqMatrix.PushBatch(varied)
+}
+
+func (self *Spider) RequestPushDelayed(req *request.Request, delay time.Duration) {
+	for _, r := range self.vary(req) {
+		self.reqMatrix.PushDelayed(r, delay)
+	}
 }
 
 func (self *Spider) RequestPull() *request.Request {
+	// 已主动终止或规则已要求停止时不再取出请求
+	if self.isStopping() || self.GetStopReason() != "" {
+		return nil
+	}
 	return self.reqMatrix.Pull()
 }
 
-func (self *Spider) RequestUse() {
-	self.reqMatrix.Use()
+func (self *Spider) RequestUse(req *request.Request) {
+	self.reqMatrix.Use(req)
+}
+
+func (self *Spider) RequestTryUse(req *request.Request) bool {
+	return self.reqMatrix.TryUse(req)
+}
+
+func (self *Spider) RequestRequeue(req *request.Request) {
+	self.reqMatrix.Requeue(req)
 }
 
-func (self *Spider) RequestFree() {
-	self.reqMatrix.Free()
+func (self *Spider) RequestFree(req *request.Request) {
+	self.reqMatrix.Free(req)
+}
+
+func (self *Spider) RequestSkip(req *request.Request) {
+	self.reqMatrix.Skip(req)
 }
 
 func (self *Spider) RequestLen() int {
@@ -284,15 +656,85 @@ func (self *Spider) TryFlushFailure() {
 func (self *Spider) Start() {
 	defer func() {
 		if p := recover(); p != nil {
-			logs.Log.Error(" *     Panic  [root]: %v\n", p)
+			stack := debug.Stack()
+			self.RecordPanic("Root", p, stack)
+			logs.Log.Error(" *     Panic  [root]: %v\n%s", p, stack)
 		}
 		self.lock.Lock()
-		self.status = status.RUN
+		if self.status != status.STOP {
+			self.status = status.RUN
+		}
 		self.lock.Unlock()
 	}()
+	self.initContextPool()
+	self.reserveRunTag()
+	if cache.Task.RetryFailures != "" {
+		self.retryFailures(GetContext(self, nil))
+		return
+	}
+	if cache.Task.UrlsFile != "" {
+		self.addSeeds(GetContext(self, nil))
+		return
+	}
+	if cache.Task.SeedTable != "" {
+		self.addTableSeeds(GetContext(self, nil))
+		return
+	}
 	self.RuleTree.Root(GetContext(self, nil))
 }
 
+// 开启run::runtag时为各规则预留运行标识字段，以便按字段列表输出的方式写入，其值由collector.TagRun在收集时填入
+func (self *Spider) reserveRunTag() {
+	if !cache.Task.RunTag {
+		return
+	}
+	for _, rule := range self.RuleTree.Trunk {
+		self.UpsertItemField(rule, data.RUN_ID_FIELD)
+		self.UpsertItemField(rule, data.RUN_TIME_FIELD)
+	}
+}
+
+// 调用OnStart回调，由采集引擎在Start()之前调用
+func (self *Spider) CallOnStart() {
+	self.callHook("OnStart", self.OnStart)
+}
+
+// 调用OnStop回调，由采集引擎在Defer()之后调用
+func (self *Spider) CallOnStop() {
+	self.callHook("OnStop", self.OnStop)
+}
+
+// 调用生命周期回调，其中发生的panic仅记录日志，不影响任务运行
+func (self *Spider) callHook(name string, hook func(*Spider)) {
+	if hook == nil {
+		return
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			logs.Log.Error(" *     Panic  [%v]: %v\n%s", name, p, debug.Stack())
+		}
+	}()
+	hook(self)
+}
+
+// 以失败请求报告中属于该蜘蛛的请求作为种子，代替Root入口
+func (self *Spider) retryFailures(ctx *Context) {
+	records, err := scheduler.ReadFailures(cache.Task.RetryFailures)
+	if err != nil {
+		logs.Log.Error(" *     [%v] 读取失败请求报告出错: %v\n", self.GetName(), err)
+		return
+	}
+	var count int
+	for _, record := range records {
+		if record.Spider != self.GetName() {
+			continue
+		}
+		ctx.AddQueue(record.NewRequest())
+		count++
+	}
+	logs.Log.Informational(" *     [%v] 从失败请求报告中重新加入 %v 条请求\n", self.GetName(), count)
+}
+
 // 主动崩溃爬虫运行协程
 func (self *Spider) Stop() {
 	self.lock.Lock()
@@ -306,9 +748,41 @@ func (self *Spider) Stop() {
 }
 
 func (self *Spider) CanStop() bool {
+	if self.isStopping() || self.GetStopReason() != "" {
+		return true
+	}
 	return self.status != status.STOPPED && self.reqMatrix.CanStop()
 }
 
+// 由规则要求停止，不再派发新请求，处理中的请求正常完成，仅首次调用有效
+func (self *Spider) StopWith(reason string) {
+	self.lock.Lock()
+	if self.stopped != "" {
+		self.lock.Unlock()
+		return
+	}
+	if reason == "" {
+		reason = "未说明原因"
+	}
+	self.stopped = reason
+	self.lock.Unlock()
+	logs.Log.Informational(" *     [%v] 规则要求停止，不再派发新请求: %v\n", self.GetName(), reason)
+}
+
+// 返回规则要求停止的原因，未要求停止时返回空字符串
+func (self *Spider) GetStopReason() string {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.stopped
+}
+
+// 是否已主动终止任务
+func (self *Spider) isStopping() bool {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.status == status.STOP
+}
+
 // 若已主动终止任务，则崩溃爬虫协程
 func (self *Spider) tryPanic() {
 	self.lock.RLock()
@@ -330,6 +804,8 @@ func (self *Spider) Defer() {
 	self.reqMatrix.Wait()
 	// 更新失败记录
 	self.reqMatrix.TryFlushFailure()
+	// 保存网页新鲜度元数据
+	self.FlushPageMetas()
 }
 
 // 是否输出默认添加的字段 Url/ParentUrl/DownloadTime
@@ -0,0 +1,128 @@
+package spider
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/henrylee2cn/pholcus/logs"
+)
+
+// 提取并解析网页中全部application/ld+json块内的JSON-LD对象；
+// 块为数组时展开其中的各对象，含@graph时展开@graph中的各对象，无法解析的块被忽略
+func (self *Context) GetJSONLD() []map[string]interface{} {
+	var items []map[string]interface{}
+	self.GetDom().Find(`script[type="application/ld+json"]`).Each(func(_ int, s *goquery.Selection) {
+		text := strings.TrimSpace(s.Text())
+		text = strings.TrimSuffix(strings.TrimPrefix(text, "<!--"), "-->")
+		if text = strings.TrimSpace(text); text == "" {
+			return
+		}
+		var v interface{}
+		if err := json.Unmarshal([]byte(text), &v); err != nil {
+			logs.Log.Warning(" *     [%v] 解析JSON-LD出错，已忽略: %v\n", self.GetUrl(), err)
+			return
+		}
+		items = appendJSONLD(items, v)
+	})
+	return items
+}
+
+// 将JSON-LD值中的对象追加至items
+func appendJSONLD(items []map[string]interface{}, v interface{}) []map[string]interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		for _, e := range v {
+			items = appendJSONLD(items, e)
+		}
+	case map[string]interface{}:
+		if graph, ok := v["@graph"]; ok {
+			return appendJSONLD(items, graph)
+		}
+		items = append(items, v)
+	}
+	return items
+}
+
+// 提取网页中全部顶层的schema.org微数据(microdata)条目；
+// 条目的itemtype、itemid分别存为"@type"、"@id"，属性值为字符串或嵌套条目，同名属性有多个值时存为[]interface{}
+func (self *Context) GetMicrodata() []map[string]interface{} {
+	var items []map[string]interface{}
+	self.GetDom().Find("[itemscope]").Not("[itemprop]").Each(func(_ int, s *goquery.Selection) {
+		items = append(items, self.microdataItem(s))
+	})
+	return items
+}
+
+// 读取itemscope元素所表示的微数据条目
+func (self *Context) microdataItem(s *goquery.Selection) map[string]interface{} {
+	item := make(map[string]interface{})
+	if t, ok := s.Attr("itemtype"); ok && strings.TrimSpace(t) != "" {
+		item["@type"] = strings.TrimSpace(t)
+	}
+	if id, ok := s.Attr("itemid"); ok && strings.TrimSpace(id) != "" {
+		item["@id"] = strings.TrimSpace(id)
+	}
+	self.microdataProps(item, s.Children())
+	return item
+}
+
+// 逐层读取子孙元素中的属性，不进入嵌套条目的内部
+func (self *Context) microdataProps(item map[string]interface{}, children *goquery.Selection) {
+	children.Each(func(_ int, s *goquery.Selection) {
+		_, scoped := s.Attr("itemscope")
+		if names, ok := s.Attr("itemprop"); ok {
+			var value interface{}
+			if scoped {
+				value = self.microdataItem(s)
+			} else {
+				value = self.microdataValue(s)
+			}
+			for _, name := range strings.Fields(names) {
+				switch old := item[name].(type) {
+				case nil:
+					item[name] = value
+				case []interface{}:
+					item[name] = append(old, value)
+				default:
+					item[name] = []interface{}{old, value}
+				}
+			}
+		}
+		if !scoped {
+			self.microdataProps(item, s.Children())
+		}
+	})
+}
+
+// 按元素类型读取微数据属性值，链接类的值解析为绝对地址
+func (self *Context) microdataValue(s *goquery.Selection) string {
+	var attr string
+	switch goquery.NodeName(s) {
+	case "meta":
+		attr = "content"
+	case "audio", "embed", "iframe", "img", "source", "track", "video":
+		attr = "src"
+	case "a", "area", "link":
+		attr = "href"
+	case "object":
+		attr = "data"
+	case "data", "meter":
+		attr = "value"
+	case "time":
+		if v, ok := s.Attr("datetime"); ok {
+			return strings.TrimSpace(v)
+		}
+	}
+	if attr == "" {
+		return strings.TrimSpace(s.Text())
+	}
+	v := strings.TrimSpace(s.AttrOr(attr, ""))
+	if attr == "src" || attr == "href" || attr == "data" {
+		if link := self.resolveUrl(v); link != "" {
+			return link
+		}
+	}
+	return v
+}
@@ -0,0 +1,29 @@
+package spider
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// JSON-LD块中的数组及@graph展开为各对象，非对象的值被忽略
+func TestAppendJSONLD(t *testing.T) {
+	for block, want := range map[string]string{
+		`{"@type":"Product","name":"a"}`:                                                        "[Product]",
+		`[{"@type":"Product"},{"@type":"Offer"},"x",1]`:                                         "[Product Offer]",
+		`{"@context":"https://schema.org","@graph":[{"@type":"WebPage"},[{"@type":"Person"}]]}`: "[WebPage Person]",
+		`"text"`: "[]",
+	} {
+		var v interface{}
+		if err := json.Unmarshal([]byte(block), &v); err != nil {
+			t.Fatal(err)
+		}
+		var types []interface{}
+		for _, item := range appendJSONLD(nil, v) {
+			types = append(types, item["@type"])
+		}
+		if got := fmt.Sprint(types); got != want {
+			t.Errorf("appendJSONLD(%s) types = %v, want %v", block, got, want)
+		}
+	}
+}
@@ -7,7 +7,7 @@ import (
 
 func TestTimer1(t *testing.T) {
 	t.Log(time.Now())
-	ctx := NewContext(new(Spider), nil)
+	ctx := GetContext(new(Spider), nil)
 	t.Log(ctx.SetTimer("id", 3*time.Second, nil))
 	t.Log(ctx.RunTimer("id"))
 	t.Log(ctx.RunTimer("id"))
@@ -16,8 +16,10 @@ func TestTimer1(t *testing.T) {
 
 func TestTimer2(t *testing.T) {
 	t.Log(time.Now())
-	ctx := NewContext(new(Spider), nil)
-	t.Log(ctx.SetTimer("id", 2, &Bell{13, 22, 0}))
+	ctx := GetContext(new(Spider), nil)
+	// 闹铃定在2秒后，以免测试等待过久
+	bell := time.Now().Add(2 * time.Second)
+	t.Log(ctx.SetTimer("id", 1, &Bell{bell.Hour(), bell.Minute(), bell.Second()}))
 	t.Log(ctx.RunTimer("id"))
 	t.Log(time.Now())
 }
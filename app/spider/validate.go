@@ -0,0 +1,81 @@
+package spider
+
+import (
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+	"github.com/henrylee2cn/pholcus/logs"
+)
+
+// 返回Rule.Validate的校验函数：响应文本短于n字节时校验失败，用于识别空白或被截断的页面
+func ValidateMinLength(n int) func(*Context) error {
+	return func(ctx *Context) error {
+		if l := len(ctx.GetText()); l < n {
+			return fmt.Errorf("响应内容过短，仅 %v 字节，应不少于 %v 字节", l, n)
+		}
+		return nil
+	}
+}
+
+// 返回Rule.Validate的校验函数：响应的Content-Type不属于types时校验失败，如"text/html"、"application/json"
+func ValidateContentType(types ...string) func(*Context) error {
+	return func(ctx *Context) error {
+		contentType := ctx.GetHeader().Get("Content-Type")
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		for _, t := range types {
+			if strings.EqualFold(mediaType, t) {
+				return nil
+			}
+		}
+		return fmt.Errorf("响应类型 %q 不符，应为 %v", contentType, strings.Join(types, "、"))
+	}
+}
+
+// 先按Spider.RetryPatterns检查响应内容，匹配时返回ERR_PATTERN类型的错误；
+// 再按本页所属规则的Rule.Validate校验响应，规则未设置时返回nil；
+// 返回的错误均带有失败类型，未标记类型时为ERR_INVALID
+func (self *Context) Validate() error {
+	if self.Response == nil {
+		return nil
+	}
+	if re := self.spider.matchRetryPattern(self.GetText()); re != nil {
+		return request.NewError(request.ERR_PATTERN, fmt.Errorf("响应内容匹配重试规则 %q", re.String()))
+	}
+	_, rule, found := self.getRule(self.Request.GetRuleName())
+	if !found || rule.Validate == nil {
+		return nil
+	}
+	err := rule.Validate(self)
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(interface {
+		Class() string
+	}); ok {
+		return err
+	}
+	return request.NewError(request.ERR_INVALID, err)
+}
+
+// 返回text匹配的首个RetryPatterns正则，均不匹配时返回nil；无效的正则在首次调用时记录日志并忽略
+func (self *Spider) matchRetryPattern(text string) *regexp.Regexp {
+	self.retryOnce.Do(func() {
+		for _, pattern := range self.RetryPatterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				logs.Log.Error(" *     蜘蛛 %v 的重试规则 %q 无效: %v\n", self.GetName(), pattern, err)
+				continue
+			}
+			self.retryRes = append(self.retryRes, re)
+		}
+	})
+	for _, re := range self.retryRes {
+		if re.MatchString(text) {
+			return re
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package spider
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+)
+
+// 响应依次按RetryPatterns及规则的Validate校验，失败时返回带有失败类型的错误
+func TestValidate(t *testing.T) {
+	blocked := request.NewError(request.ERR_BLOCKED, errors.New("captcha"))
+	sp := &Spider{
+		RetryPatterns: []string{`请稍后再试`},
+		RuleTree: &RuleTree{Trunk: map[string]*Rule{
+			"none":   {},
+			"length": {Validate: ValidateMinLength(10)},
+			"type":   {Validate: ValidateContentType("application/json", "text/html")},
+			"custom": {Validate: func(*Context) error { return blocked }},
+		}},
+	}
+	for _, c := range []struct {
+		rule, contentType, text string
+		class                   string
+	}{
+		{rule: "none", text: "ok"},
+		{rule: "none", text: "系统繁忙，请稍后再试", class: request.ERR_PATTERN},
+		{rule: "length", text: "0123456789"},
+		{rule: "length", text: "short", class: request.ERR_INVALID},
+		{rule: "type", contentType: "text/html; charset=utf-8", text: "<p></p>"},
+		{rule: "type", contentType: "image/png", text: "png", class: request.ERR_INVALID},
+		{rule: "custom", text: "captcha", class: request.ERR_BLOCKED},
+	} {
+		ctx := &Context{
+			spider:   sp,
+			Request:  &request.Request{Rule: c.rule},
+			Response: &http.Response{Header: http.Header{"Content-Type": {c.contentType}}},
+			text:     []byte(c.text),
+		}
+		err := ctx.Validate()
+		if c.class == "" {
+			if err != nil {
+				t.Errorf("%s %q: unexpected error %v", c.rule, c.text, err)
+			}
+			continue
+		}
+		if class := request.ErrorClass(err); err == nil || class != c.class {
+			t.Errorf("%s %q: got %v (%v), want class %v", c.rule, c.text, err, class, c.class)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package spider
+
+import (
+	"net/http"
+
+	"github.com/henrylee2cn/pholcus/app/downloader/request"
+)
+
+// 输出结果时附带区域变体名的字段名
+const VARIANT_FIELD = "Variant"
+
+// 区域变体，用于以不同地区的身份抓取同一网址，如比较各地区的价格与库存；
+// Rule.Vary为true时，添加至该规则的请求按Spider.Variants展开为每个变体各一个请求，
+// 变体名记录于元数据request.VARIANT_META，由其派生的子请求继承同一变体的设置，输出结果时附带于Variant字段
+type Variant struct {
+	Name    string      // 变体名，如"de"，在Spider.Variants中唯一
+	Header  http.Header // 覆盖的请求头(选填)，如Accept-Language
+	Cookies string      // 合并的cookie(选填)，格式同请求头Cookie，如"currency=EUR"，同名时覆盖请求自身的cookie
+	Session string      // 使用的会话名(选填)，须为Spider.Sessions中的会话，如该地区的账号，请求已指定会话时不覆盖
+	Proxy   string      // 使用的代理(选填)，如该地区的代理IP，优先于会话及全局的代理IP
+}
+
+// 返回指定名称的区域变体，不存在时返回nil
+func (self *Spider) GetVariant(name string) *Variant {
+	if name == "" {
+		return nil
+	}
+	for _, v := range self.Variants {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// 按Spider.Variants展开请求：所属规则设置了Vary且请求尚无变体时，返回每个变体各一个请求副本；
+// 否则返回请求本身，已带有变体时(如继承自父请求)为其设置该变体的请求头等
+func (self *Spider) vary(req *request.Request) []*request.Request {
+	if req.GetVariant() != "" {
+		self.applyVariant(req)
+		return []*request.Request{req}
+	}
+	rule, found := self.GetRule(req.GetRuleName())
+	if !found || !rule.Vary || len(self.Variants) == 0 {
+		return []*request.Request{req}
+	}
+	reqs := make([]*request.Request, len(self.Variants))
+	for i, v := range self.Variants {
+		reqs[i] = req.Copy()
+		reqs[i].SetMeta(request.VARIANT_META, v.Name)
+		self.applyVariant(reqs[i])
+	}
+	return reqs
+}
+
+// 为请求设置其区域变体的请求头、cookie及会话，可重复调用
+func (self *Spider) applyVariant(req *request.Request) {
+	v := self.GetVariant(req.GetVariant())
+	if v == nil {
+		return
+	}
+	for key, values := range v.Header {
+		req.Header.Del(key)
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	if v.Cookies != "" {
+		req.SetCookies(mergeCookies(req.GetCookies(), v.Cookies))
+	}
+	if v.Session != "" && req.GetSession() == "" {
+		req.SetSession(v.Session)
+	}
+}
+
+// 请求区域变体的代理，未设置时为空
+func (self *Spider) variantProxy(req *request.Request) string {
+	if v := self.GetVariant(req.GetVariant()); v != nil {
+		return v.Proxy
+	}
+	return ""
+}
@@ -0,0 +1,47 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/henrylee2cn/pholcus/app/distribute"
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+// 分发的任务携带NATS事件发布的配置，子节点据此发布抓取事件
+func TestTaskNats(t *testing.T) {
+	server := &Logic{AppConf: &cache.AppConf{NatsUrl: "nats://127.0.0.1:4222", NatsSubject: "crawl"}}
+	var task distribute.Task
+	server.setTask(&task)
+
+	client := &Logic{AppConf: &cache.AppConf{}}
+	client.setAppConf(&task)
+	if client.AppConf.NatsUrl != "nats://127.0.0.1:4222" || client.AppConf.NatsSubject != "crawl" {
+		t.Errorf("client got NatsUrl %q, NatsSubject %q", client.AppConf.NatsUrl, client.AppConf.NatsSubject)
+	}
+}
+
+// 分发的任务携带各运行选项，子节点采用与服务端一致的设置
+func TestTaskRunOptions(t *testing.T) {
+	want := cache.AppConf{
+		MaxBodySize:     1 << 20,
+		SamplePages:     3,
+		Manifest:        true,
+		HeaderTimeout:   10,
+		ReadIdleTimeout: 20,
+		TLSFingerprint:  "chrome",
+		Explain:         true,
+	}
+	server := &Logic{AppConf: &want}
+	var task distribute.Task
+	server.setTask(&task)
+
+	client := &Logic{AppConf: &cache.AppConf{}}
+	client.setAppConf(&task)
+	for _, name := range []string{"MaxBodySize", "SamplePages", "Manifest", "HeaderTimeout", "ReadIdleTimeout", "TLSFingerprint", "Explain"} {
+		got := reflect.ValueOf(client.AppConf).Elem().FieldByName(name).Interface()
+		if w := reflect.ValueOf(want).FieldByName(name).Interface(); got != w {
+			t.Errorf("client got %s %v, want %v", name, got, w)
+		}
+	}
+}
@@ -17,7 +17,9 @@ import (
 )
 
 var (
-	spiderflag *string
+	spiderflag   *string
+	saveconfflag *string
+	runconfflag  *string
 )
 
 // 获取外部参数
@@ -37,6 +39,18 @@ func Flag() {
 			return "   <蜘蛛列表: 选择多蜘蛛以 \",\" 间隔>\r\n" + spiderlist
 		}())
 
+	// 导出运行配置
+	saveconfflag = flag.String(
+		"c_saveconf",
+		"",
+		"   <导出运行配置: 任务开始前将生效的运行配置及所选蜘蛛保存至该json文件，用于复现或分享本次抓取>")
+
+	// 重放运行配置
+	runconfflag = flag.String(
+		"c_runconf",
+		"",
+		"   <重放运行配置: 按c_saveconf导出的json文件设置运行配置及蜘蛛，仅运行模式、端口及服务端IP沿用命令行参数>")
+
 	// 备注说明
 	flag.String(
 		"c_z",
@@ -47,6 +61,17 @@ func Flag() {
 
 // 执行入口
 func Run() {
+	var conf *app.RunConf
+	if *runconfflag != "" {
+		var err error
+		if conf, err = app.ReadRunConf(*runconfflag); err != nil {
+			logs.Log.Error(" *     %v\n", err)
+			return
+		}
+		mode, port, master := cache.Task.Mode, cache.Task.Port, cache.Task.Master
+		*cache.Task = conf.Task
+		cache.Task.Mode, cache.Task.Port, cache.Task.Master = mode, port, master
+	}
 	app.LogicApp.Init(cache.Task.Mode, cache.Task.Port, cache.Task.Master)
 	if cache.Task.Mode == status.UNSET {
 		return
@@ -55,30 +80,48 @@ func Run() {
 	case status.SERVER:
 		for {
 			parseInput()
-			run()
+			run(nil)
 		}
 	case status.CLIENT:
-		run()
+		run(conf)
 		select {}
 	default:
-		run()
+		run(conf)
 	}
 }
 
-// 运行
-func run() {
+// 运行，conf不为nil时运行其中的蜘蛛
+func run(conf *app.RunConf) {
 	// 创建蜘蛛队列
 	sps := []*spider.Spider{}
-	for _, idx := range strings.Split(*spiderflag, ",") {
-		idx = strings.TrimSpace(idx)
-		if idx == "" {
-			continue
+	if conf != nil {
+		// 已指定Keyin的成员不再由Keyins分配
+		for _, member := range conf.Spiders {
+			sp := app.LogicApp.GetSpiderByName(member.Name)
+			if member.Keyin != "" {
+				sp = sp.Copy()
+				sp.SetKeyin(member.Keyin)
+			}
+			sps = append(sps, sp)
+		}
+	} else {
+		for _, idx := range strings.Split(*spiderflag, ",") {
+			idx = strings.TrimSpace(idx)
+			if idx == "" {
+				continue
+			}
+			i, _ := strconv.Atoi(idx)
+			sps = append(sps, app.LogicApp.GetSpiderLib()[i])
 		}
-		i, _ := strconv.Atoi(idx)
-		sps = append(sps, app.LogicApp.GetSpiderLib()[i])
 	}
 
-	app.LogicApp.SpiderPrepare(sps).Run()
+	app.LogicApp.SpiderPrepare(sps)
+	if *saveconfflag != "" {
+		if err := app.LogicApp.SaveRunConf(*saveconfflag); err != nil {
+			logs.Log.Error(" *     运行配置保存失败: %v\n", err)
+		}
+	}
+	app.LogicApp.Run()
 }
 
 // 服务器模式下接收添加任务的参数
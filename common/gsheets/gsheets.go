@@ -0,0 +1,350 @@
+package gsheets
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/henrylee2cn/pholcus/config"
+	"github.com/henrylee2cn/pholcus/logs"
+)
+
+/************************ Google Sheets 输出 ***************************/
+
+const (
+	// 读写电子表格的授权范围
+	scope = "https://www.googleapis.com/auth/spreadsheets"
+	// 默认的令牌地址，服务账号凭据中未指定token_uri时使用
+	tokenURI = "https://oauth2.googleapis.com/token"
+	// 单次追加的最大行数及近似字节数，超出时分多次追加，以免超出API的请求大小限制
+	MaxRows  = 5000
+	MaxBytes = 2 << 20
+	// 遇到429及5xx响应时的最大重试次数，见Client.do()
+	maxRetries = 5
+)
+
+var (
+	// Sheets API的地址，可替换为测试服务器
+	Endpoint = "https://sheets.googleapis.com/v4/spreadsheets/"
+
+	client *Client
+	err    error
+	lock   sync.RWMutex
+)
+
+// 基于服务账号凭据的Sheets API客户端，并发安全
+type Client struct {
+	email    string
+	key      *rsa.PrivateKey
+	tokenURI string
+	http     *http.Client
+
+	token  string    // 当前的访问令牌
+	expiry time.Time // 访问令牌的过期时间
+	lock   sync.Mutex
+}
+
+// 服务账号凭据JSON文件中用到的字段
+type credentials struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// 返回全局客户端，及最近一次初始化的错误
+func Get() (*Client, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	return client, err
+}
+
+// 按config.GSHEETS_CREDENTIALS重新创建全局客户端，并检查能否取得访问令牌
+func Refresh() {
+	lock.Lock()
+	defer lock.Unlock()
+	client, err = NewFromFile(config.GSHEETS_CREDENTIALS)
+	if err != nil {
+		logs.Log.Error("Google Sheets：%v\n", err)
+		return
+	}
+	if _, err = client.accessToken(); err != nil {
+		logs.Log.Error("Google Sheets：%v\n", err)
+	}
+}
+
+// 检查能否取得访问令牌，上次初始化失败时重新初始化
+func Ping() error {
+	lock.RLock()
+	c, e := client, err
+	lock.RUnlock()
+	if e == nil && c != nil {
+		_, e = c.accessToken()
+		return e
+	}
+	Refresh()
+	lock.RLock()
+	defer lock.RUnlock()
+	return err
+}
+
+// 从服务账号凭据的JSON文件创建客户端
+func NewFromFile(fileName string) (*Client, error) {
+	if fileName == "" {
+		return nil, errors.New("未设置服务账号凭据文件 gsheets::credentials")
+	}
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return New(b)
+}
+
+// 从服务账号凭据的JSON内容创建客户端
+func New(credentialsJSON []byte) (*Client, error) {
+	var cred credentials
+	if err := json.Unmarshal(credentialsJSON, &cred); err != nil {
+		return nil, fmt.Errorf("服务账号凭据格式错误: %v", err)
+	}
+	if cred.ClientEmail == "" || cred.PrivateKey == "" {
+		return nil, errors.New("服务账号凭据缺少client_email或private_key")
+	}
+	block, _ := pem.Decode([]byte(cred.PrivateKey))
+	if block == nil {
+		return nil, errors.New("服务账号凭据的private_key不是PEM格式")
+	}
+	var key *rsa.PrivateKey
+	if k, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		var ok bool
+		if key, ok = k.(*rsa.PrivateKey); !ok {
+			return nil, errors.New("服务账号凭据的private_key不是RSA私钥")
+		}
+	} else if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+		return nil, fmt.Errorf("服务账号凭据的private_key无法解析: %v", err)
+	}
+	if cred.TokenURI == "" {
+		cred.TokenURI = tokenURI
+	}
+	return &Client{
+		email:    cred.ClientEmail,
+		key:      key,
+		tokenURI: cred.TokenURI,
+		http:     &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+// 返回有效的访问令牌，即将过期时以签名的JWT换取新令牌
+func (self *Client) accessToken() (string, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.token != "" && time.Now().Before(self.expiry.Add(-time.Minute)) {
+		return self.token, nil
+	}
+	assertion, err := self.jwt(time.Now())
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	resp, err := self.http.PostForm(self.tokenURI, form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err = decodeResponse(resp, &result); err != nil {
+		return "", fmt.Errorf("获取访问令牌失败: %v", err)
+	}
+	self.token = result.AccessToken
+	self.expiry = time.Now().Add(time.Duration(result.ExpiresIn) * time.Second)
+	return self.token, nil
+}
+
+// 生成以RS256签名的JWT断言，有效期1小时
+func (self *Client) jwt(now time.Time) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   self.email,
+		"scope": scope,
+		"aud":   self.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, self.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// 调用Sheets API，body不为nil时以JSON发送，响应以JSON解码至out；
+// 遇到429响应时按Retry-After或指数退避重试，5xx响应仅对GET请求重试，
+// 因追加等写入请求可能已生效，重试会重复写入
+func (self *Client) do(method, path string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	backoff := time.Second
+	for i := 0; ; i++ {
+		token, err := self.accessToken()
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequest(method, Endpoint+path, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp, err := self.http.Do(req)
+		if err != nil {
+			return err
+		}
+		retry := resp.StatusCode == http.StatusTooManyRequests || (resp.StatusCode >= 500 && method == "GET")
+		if retry && i < maxRetries {
+			wait := backoff
+			if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s > 0 {
+				wait = time.Duration(s) * time.Second
+			}
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+			logs.Log.Warning(" *     Google Sheets API 响应 %v，%v 后重试\n", resp.Status, wait)
+			time.Sleep(wait)
+			backoff *= 2
+			continue
+		}
+		err = decodeResponse(resp, out)
+		resp.Body.Close()
+		return err
+	}
+}
+
+// 检查响应状态并解码JSON响应，失败时返回含API错误信息的错误
+func decodeResponse(resp *http.Response, out interface{}) error {
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+			Description string `json:"error_description"`
+		}
+		json.Unmarshal(b, &apiErr)
+		msg := apiErr.Error.Message
+		if msg == "" {
+			msg = apiErr.Description
+		}
+		if msg == "" {
+			msg = strings.TrimSpace(string(b))
+		}
+		return fmt.Errorf("%v: %v", resp.Status, msg)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(b, out)
+}
+
+// 返回电子表格中全部工作表的名称
+func (self *Client) Sheets(spreadsheetID string) ([]string, error) {
+	var result struct {
+		Sheets []struct {
+			Properties struct {
+				Title string `json:"title"`
+			} `json:"properties"`
+		} `json:"sheets"`
+	}
+	if err := self.do("GET", url.PathEscape(spreadsheetID)+"?fields=sheets.properties.title", nil, &result); err != nil {
+		return nil, err
+	}
+	titles := make([]string, len(result.Sheets))
+	for i, sheet := range result.Sheets {
+		titles[i] = sheet.Properties.Title
+	}
+	return titles, nil
+}
+
+// 在电子表格中添加工作表
+func (self *Client) AddSheet(spreadsheetID, title string) error {
+	body := map[string]interface{}{
+		"requests": []interface{}{
+			map[string]interface{}{
+				"addSheet": map[string]interface{}{
+					"properties": map[string]interface{}{"title": title},
+				},
+			},
+		},
+	}
+	return self.do("POST", url.PathEscape(spreadsheetID)+":batchUpdate", body, nil)
+}
+
+// 返回工作表的首行是否为空
+func (self *Client) IsEmpty(spreadsheetID, title string) (bool, error) {
+	var result struct {
+		Values [][]interface{} `json:"values"`
+	}
+	path := url.PathEscape(spreadsheetID) + "/values/" + url.PathEscape(a1Range(title, "1:1"))
+	if err := self.do("GET", path, nil, &result); err != nil {
+		return false, err
+	}
+	return len(result.Values) == 0, nil
+}
+
+// 在工作表已有数据之后追加多行，按MaxRows及MaxBytes分多次追加，值按原样写入
+func (self *Client) Append(spreadsheetID, title string, rows [][]string) error {
+	path := url.PathEscape(spreadsheetID) + "/values/" + url.PathEscape(a1Range(title, "A1")) +
+		":append?valueInputOption=RAW&insertDataOption=INSERT_ROWS"
+	for len(rows) > 0 {
+		n, size := 0, 0
+		for n < len(rows) && n < MaxRows {
+			for _, v := range rows[n] {
+				size += len(v) + 3
+			}
+			if size > MaxBytes && n > 0 {
+				break
+			}
+			n++
+		}
+		if err := self.do("POST", path, map[string]interface{}{"values": rows[:n]}, nil); err != nil {
+			return err
+		}
+		rows = rows[n:]
+	}
+	return nil
+}
+
+// 返回工作表中的A1表示法范围，工作表名称以单引号括起
+func a1Range(title, cells string) string {
+	return "'" + strings.Replace(title, "'", "''", -1) + "'!" + cells
+}
@@ -0,0 +1,116 @@
+package gsheets
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// 模拟的令牌地址，校验JWT断言的签名后发放令牌tok，返回凭据JSON及令牌请求次数
+func tokenServer(t *testing.T) ([]byte, *int32) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		r.ParseForm()
+		parts := strings.Split(r.PostForm.Get("assertion"), ".")
+		if r.PostForm.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || len(parts) != 3 {
+			http.Error(w, `{"error_description":"bad request"}`, http.StatusBadRequest)
+			return
+		}
+		sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		if rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], sig) != nil {
+			http.Error(w, `{"error_description":"invalid signature"}`, http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"access_token":"tok","expires_in":3600}`))
+	}))
+	t.Cleanup(srv.Close)
+	cred, _ := json.Marshal(credentials{
+		ClientEmail: "test@example.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		TokenURI:    srv.URL,
+	})
+	return cred, &requests
+}
+
+// 模拟的Sheets API，前fails次请求以503响应，记录请求次数
+func apiServer(t *testing.T, fails int32) *int32 {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if atomic.AddInt32(&requests, 1) <= fails {
+			http.Error(w, `{"error":{"message":"unavailable"}}`, http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"sheets":[{"properties":{"title":"a"}}]}`))
+	}))
+	t.Cleanup(srv.Close)
+	endpoint := Endpoint
+	Endpoint = srv.URL + "/"
+	t.Cleanup(func() { Endpoint = endpoint })
+	return &requests
+}
+
+// 访问令牌在过期前复用
+func TestAccessToken(t *testing.T) {
+	cred, tokens := tokenServer(t)
+	c, err := New(cred)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiServer(t, 0)
+	for i := 0; i < 2; i++ {
+		titles, err := c.Sheets("id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(titles) != 1 || titles[0] != "a" {
+			t.Errorf("Sheets() = %v, want [a]", titles)
+		}
+	}
+	if *tokens != 1 {
+		t.Errorf("got %d token requests, want 1", *tokens)
+	}
+}
+
+// 追加遇到5xx时不重试，以免重复写入；读取则重试
+func TestNoAppendRetry(t *testing.T) {
+	cred, _ := tokenServer(t)
+	c, err := New(cred)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := apiServer(t, 1)
+	if err = c.Append("id", "a", [][]string{{"1"}}); err == nil {
+		t.Error("Append succeeded on 503")
+	}
+	if *requests != 1 {
+		t.Errorf("Append sent %d requests, want 1", *requests)
+	}
+
+	requests = apiServer(t, 1)
+	if _, err = c.Sheets("id"); err != nil {
+		t.Fatal(err)
+	}
+	if *requests != 2 {
+		t.Errorf("Sheets sent %d requests, want 2", *requests)
+	}
+}
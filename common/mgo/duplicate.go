@@ -0,0 +1,101 @@
+package mgo
+
+import (
+	"strings"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// 插入数据遇到重复键（_id或唯一索引冲突）时的处理方式，与mysql输出的设置一致
+const (
+	DUP_FAIL   = "fail"   // 按顺序写入，遇到重复的文档即停止并返回错误，此前的文档已写入
+	DUP_SKIP   = "skip"   // 跳过重复的文档，其余照常写入
+	DUP_UPDATE = "update" // 以新数据更新重复的文档（按其冲突的唯一索引匹配），其余照常写入
+)
+
+// 按onDuplicate的方式分批插入文档，每批至多MaxLen个，文档须为map[string]interface{}
+func InsertDocs(c *mgo.Collection, docs []interface{}, onDuplicate string) error {
+	for start := 0; start < len(docs); start += MaxLen {
+		end := start + MaxLen
+		if end > len(docs) {
+			end = len(docs)
+		}
+		if err := insertBatch(c, docs[start:end], onDuplicate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 执行批量写入，便于测试时替换
+var runBulk = (*mgo.Bulk).Run
+
+func insertBatch(c *mgo.Collection, docs []interface{}, onDuplicate string) error {
+	if onDuplicate != DUP_SKIP && onDuplicate != DUP_UPDATE {
+		return c.Insert(docs...)
+	}
+	// 无序写入时重复的文档不影响其余文档
+	bulk := c.Bulk()
+	bulk.Unordered()
+	bulk.Insert(docs...)
+	_, err := runBulk(bulk)
+	if err == nil || !mgo.IsDup(err) {
+		return err
+	}
+	// 仅有重复键错误时，其余文档已写入，跳过模式下视为成功
+	if onDuplicate == DUP_SKIP {
+		return nil
+	}
+	bulkErr, ok := err.(*mgo.BulkError)
+	if !ok {
+		return err
+	}
+	indexes, err := c.Indexes()
+	if err != nil {
+		return err
+	}
+	for _, ecase := range bulkErr.Cases() {
+		doc, _ := docs[ecase.Index].(map[string]interface{})
+		selector := dupSelector(c, indexes, doc)
+		if selector == nil {
+			return ecase.Err
+		}
+		change := make(bson.M, len(doc))
+		for k, v := range doc {
+			if k != "_id" {
+				change[k] = v
+			}
+		}
+		if err = c.Update(selector, bson.M{"$set": change}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 返回与文档冲突的已有文档的选择器：依次按_id及各唯一索引以文档中的值匹配，不存在时返回nil
+func dupSelector(c *mgo.Collection, indexes []mgo.Index, doc map[string]interface{}) bson.M {
+	for _, index := range indexes {
+		if !index.Unique && index.Name != "_id_" {
+			continue
+		}
+		selector := make(bson.M, len(index.Key))
+		for _, key := range index.Key {
+			key = strings.TrimLeft(key, "+-")
+			v, ok := doc[key]
+			if !ok || strings.HasPrefix(key, "$") {
+				selector = nil
+				break
+			}
+			selector[key] = v
+		}
+		if selector == nil {
+			continue
+		}
+		if n, err := c.Find(selector).Count(); err == nil && n > 0 {
+			return selector
+		}
+	}
+	return nil
+}
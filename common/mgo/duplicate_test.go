@@ -0,0 +1,30 @@
+package mgo
+
+import (
+	"testing"
+
+	mgo "gopkg.in/mgo.v2"
+)
+
+// 跳过模式下，批量写入仅遇到重复键时不返回错误；其他错误照常返回
+func TestInsertDocsSkipDup(t *testing.T) {
+	defer func(run func(*mgo.Bulk) (*mgo.BulkResult, error)) { runBulk = run }(runBulk)
+	docs := []interface{}{
+		map[string]interface{}{"_id": 1},
+		map[string]interface{}{"_id": 1},
+	}
+
+	runBulk = func(*mgo.Bulk) (*mgo.BulkResult, error) {
+		return nil, &mgo.LastError{Err: "E11000 duplicate key error", Code: 11000}
+	}
+	if err := InsertDocs(&mgo.Collection{}, docs, DUP_SKIP); err != nil {
+		t.Fatalf("skip mode with one duplicate: %v", err)
+	}
+
+	runBulk = func(*mgo.Bulk) (*mgo.BulkResult, error) {
+		return nil, &mgo.LastError{Err: "not master", Code: 10107}
+	}
+	if err := InsertDocs(&mgo.Collection{}, docs, DUP_SKIP); err == nil {
+		t.Fatal("skip mode swallowed a non-duplicate error")
+	}
+}
@@ -1,6 +1,7 @@
 package mgo
 
 import (
+	"sync"
 	"time"
 
 	mgo "gopkg.in/mgo.v2"
@@ -18,6 +19,7 @@ var (
 	connGcSecond = time.Duration(config.MGO_CONN_GC_SECOND) * 1e9
 	session      *mgo.Session
 	err          error
+	lock         sync.RWMutex
 	MgoPool      = pool.ClassicPool(
 		config.MGO_CONN_CAP,
 		config.MGO_CONN_CAP/5,
@@ -25,12 +27,16 @@ var (
 			// if err != nil || session.Ping() != nil {
 			// 	session, err = newSession()
 			// }
+			lock.RLock()
+			defer lock.RUnlock()
 			return &MgoSrc{session.Clone()}, err
 		},
 		connGcSecond)
 )
 
 func Refresh() {
+	lock.Lock()
+	defer lock.Unlock()
 	session, err = mgo.Dial(config.MGO_CONN_STR)
 	if err != nil {
 		logs.Log.Error("MongoDB：%v\n", err)
@@ -41,6 +47,23 @@ func Refresh() {
 	}
 }
 
+// 检查数据库连接是否可用，上次连接失败时重新连接
+func Ping() error {
+	lock.RLock()
+	s, e := session, err
+	lock.RUnlock()
+	if e == nil && s != nil {
+		return s.Ping()
+	}
+	if s != nil {
+		s.Close()
+	}
+	Refresh()
+	lock.RLock()
+	defer lock.RUnlock()
+	return err
+}
+
 // 判断资源是否可用
 func (self *MgoSrc) Usable() bool {
 	if self.Session == nil || self.Session.Ping() != nil {
@@ -1,12 +1,17 @@
 package mysql
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/csv"
 	"errors"
+	"fmt"
+	"io"
 	"strings"
 	"sync"
+	"sync/atomic"
 
-	_ "github.com/go-sql-driver/mysql"
+	driver "github.com/go-sql-driver/mysql"
 
 	"github.com/henrylee2cn/pholcus/common/util"
 	"github.com/henrylee2cn/pholcus/config"
@@ -21,21 +26,48 @@ type MyTable struct {
 	rows             [][]string  // 多行数据
 	sqlCode          string
 	customPrimaryKey bool
-	size             int //内容大小的近似值
+	size             int    //内容大小的近似值
+	onDuplicate      string //遇到重复键时的处理方式，DUP_FAIL、DUP_SKIP或DUP_UPDATE
 }
 
+// 插入数据遇到重复键（主键或唯一索引冲突）时的处理方式
+const (
+	DUP_FAIL   = "fail"   // 整条语句失败，该批数据均不写入
+	DUP_SKIP   = "skip"   // 跳过重复的行，其余照常写入
+	DUP_UPDATE = "update" // 以新数据更新重复的行，其余照常写入
+)
+
 var (
 	db                 *sql.DB
 	err                error
 	maxConnChan        = make(chan bool, config.MYSQL_CONN_CAP) //最大执行数限制
 	max_allowed_packet = config.MYSQL_MAX_ALLOWED_PACKET - 1024
 	lock               sync.RWMutex
+	loadDataSeq        uint64 // LOAD DATA数据源的序号
+	loadDataDisabled   int32  // 服务器不支持LOAD DATA LOCAL INFILE时置1，此后改用INSERT
 )
 
 func DB() (*sql.DB, error) {
 	return db, err
 }
 
+// 检查数据库连接是否可用，上次连接失败时重新连接
+func Ping() error {
+	lock.RLock()
+	d, e := db, err
+	lock.RUnlock()
+	if e == nil && d != nil {
+		return d.Ping()
+	}
+	if d != nil {
+		d.Close()
+	}
+	Refresh()
+	lock.RLock()
+	defer lock.RUnlock()
+	return err
+}
+
 func Refresh() {
 	lock.Lock()
 	defer lock.Unlock()
@@ -61,6 +93,22 @@ func (self *MyTable) SetTableName(name string) *MyTable {
 	return self
 }
 
+//设置遇到重复键时的处理方式，默认为DUP_FAIL
+func (self *MyTable) SetOnDuplicate(policy string) *MyTable {
+	self.onDuplicate = policy
+	return self
+}
+
+//复制表名、表单列及设置，不含待插入的数据，用于多个协程各自插入同一表单
+func (self *MyTable) Clone() *MyTable {
+	return &MyTable{
+		tableName:        self.tableName,
+		columnNames:      self.columnNames,
+		customPrimaryKey: self.customPrimaryKey,
+		onDuplicate:      self.onDuplicate,
+	}
+}
+
 //设置表单列
 func (self *MyTable) AddColumn(names ...string) *MyTable {
 	for _, name := range names {
@@ -151,12 +199,39 @@ func (self *MyTable) AutoInsert(value []string) *MyTable {
 
 //向sqlCode添加"插入数据"的语句，执行前须保证Create()、AutoInsert()已经执行
 //insert into table1(field1,field2) values(rows[0]),(rows[1])...
+//启用config.MYSQL_LOAD_DATA时优先采用LOAD DATA LOCAL INFILE批量导入，未导入任何数据即失败时改用insert，导入中途失败时返回错误；
+//遇到重复键时按SetOnDuplicate()的设置处理，LOAD DATA中DUP_SKIP为IGNORE、DUP_UPDATE为REPLACE，
+//而LOCAL导入时服务器无法中止数据传输、总是跳过重复的行，故DUP_FAIL时不采用LOAD DATA
 func (self *MyTable) FlushInsert() error {
 	if len(self.rows) == 0 {
 		return nil
 	}
 
-	self.sqlCode = `insert into ` + "`" + self.tableName + "`" + `(`
+	if config.MYSQL_LOAD_DATA && (self.onDuplicate == DUP_SKIP || self.onDuplicate == DUP_UPDATE) && atomic.LoadInt32(&loadDataDisabled) == 0 {
+		executed, err := self.flushLoadData()
+		switch {
+		case err == nil:
+			return nil
+		case isLoadDataDenied(err):
+			// 服务器拒绝时未导入任何数据
+			if atomic.CompareAndSwapInt32(&loadDataDisabled, 0, 1) {
+				logs.Log.Warning("Mysql：服务器不支持LOAD DATA LOCAL INFILE，改用INSERT批量写入: %v\n", err)
+			}
+		case !executed:
+			logs.Log.Warning("Mysql：LOAD DATA导入失败，本批改用INSERT写入: %v\n", err)
+		default:
+			// 导入中途失败时可能已写入部分数据，改用INSERT将重复写入，故本批作为失败返回
+			self.rows = [][]string{}
+			self.size = 0
+			return fmt.Errorf("LOAD DATA导入失败，可能已写入部分数据: %v", err)
+		}
+	}
+
+	self.sqlCode = `insert into `
+	if self.onDuplicate == DUP_SKIP {
+		self.sqlCode = `insert ignore into `
+	}
+	self.sqlCode += "`" + self.tableName + "`" + `(`
 	if len(self.columnNames) != 0 {
 		for _, v := range self.columnNames {
 			self.sqlCode += "`" + v[0] + "`,"
@@ -173,7 +248,15 @@ func (self *MyTable) FlushInsert() error {
 		}
 		self.sqlCode = self.sqlCode[:len(self.sqlCode)-1] + `),`
 	}
-	self.sqlCode = self.sqlCode[:len(self.sqlCode)-1] + `;`
+	self.sqlCode = self.sqlCode[:len(self.sqlCode)-1]
+	if self.onDuplicate == DUP_UPDATE && len(self.columnNames) != 0 {
+		self.sqlCode += ` on duplicate key update `
+		for _, v := range self.columnNames {
+			self.sqlCode += "`" + v[0] + "`=values(`" + v[0] + "`),"
+		}
+		self.sqlCode = self.sqlCode[:len(self.sqlCode)-1]
+	}
+	self.sqlCode += `;`
 
 	defer func() {
 		// 清空临时数据
@@ -197,6 +280,64 @@ func (self *MyTable) FlushInsert() error {
 	return err
 }
 
+//以csv格式通过LOAD DATA LOCAL INFILE导入缓存的数据，成功时清空缓存；
+//executed为是否已向服务器执行导入语句，为false时未写入任何数据
+func (self *MyTable) flushLoadData() (executed bool, err error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	for _, row := range self.rows {
+		w.Write(row)
+	}
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return false, err
+	}
+
+	name := fmt.Sprintf("pholcus_%d", atomic.AddUint64(&loadDataSeq, 1))
+	driver.RegisterReaderHandler(name, func() io.Reader {
+		return bytes.NewReader(buf.Bytes())
+	})
+	defer driver.DeregisterReaderHandler(name)
+
+	// 重复的行按DUP_SKIP跳过，或按DUP_UPDATE以新数据替换
+	modifier := "IGNORE"
+	if self.onDuplicate == DUP_UPDATE {
+		modifier = "REPLACE"
+	}
+	sqlCode := "LOAD DATA LOCAL INFILE 'Reader::" + name + "' " + modifier + " INTO TABLE `" + self.tableName + "` CHARACTER SET utf8" +
+		" FIELDS TERMINATED BY ',' OPTIONALLY ENCLOSED BY '\"' ESCAPED BY '' LINES TERMINATED BY '\\n'"
+	if len(self.columnNames) != 0 {
+		columns := make([]string, len(self.columnNames))
+		for i, v := range self.columnNames {
+			columns[i] = "`" + v[0] + "`"
+		}
+		sqlCode += " (" + strings.Join(columns, ",") + ")"
+	}
+
+	maxConnChan <- true
+	defer func() {
+		<-maxConnChan
+	}()
+	lock.RLock()
+	_, err = db.Exec(sqlCode)
+	lock.RUnlock()
+	if err != nil {
+		return true, err
+	}
+	self.rows = [][]string{}
+	self.size = 0
+	return true, nil
+}
+
+//是否为服务器或驱动禁止LOAD DATA LOCAL INFILE所致的错误
+func isLoadDataDenied(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "Error 1148") ||
+		strings.Contains(msg, "Error 3948") ||
+		strings.Contains(msg, "local_infile") ||
+		strings.Contains(msg, "LOCAL INFILE")
+}
+
 // 获取全部数据
 func (self *MyTable) SelectAll() (*sql.Rows, error) {
 	if self.tableName == "" {
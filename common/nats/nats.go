@@ -0,0 +1,193 @@
+package nats
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/************************ NATS 发布 ***************************/
+
+// 仅支持发布消息的精简NATS客户端，实现NATS核心协议中的INFO、CONNECT、PUB、PING/PONG及-ERR，并发安全
+type Conn struct {
+	conn       net.Conn
+	w          *bufio.Writer
+	maxPayload int   // 服务器允许的最大消息长度，0为未声明
+	err        error // 连接失效的原因，不为nil时不可再发布
+	lock       sync.Mutex
+}
+
+// 服务器在连接建立时发送的INFO
+type serverInfo struct {
+	TLSRequired bool `json:"tls_required"`
+	MaxPayload  int  `json:"max_payload"`
+}
+
+// 客户端发送的CONNECT选项
+type connectOptions struct {
+	Verbose   bool   `json:"verbose"`
+	Pedantic  bool   `json:"pedantic"`
+	Name      string `json:"name"`
+	Lang      string `json:"lang"`
+	Version   string `json:"version"`
+	Protocol  int    `json:"protocol"`
+	User      string `json:"user,omitempty"`
+	Pass      string `json:"pass,omitempty"`
+	AuthToken string `json:"auth_token,omitempty"`
+}
+
+// 连接NATS服务器，rawurl形如nats://127.0.0.1:4222，可含user:pass@或token@，tls://或服务器要求时采用TLS；
+// timeout为建立连接及等待握手完成的超时
+func Dial(rawurl string, timeout time.Duration) (*Conn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	conn, err := net.DialTimeout("tcp", host, timeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	r := bufio.NewReader(conn)
+
+	// 服务器首先发送INFO
+	line, err := r.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return nil, fmt.Errorf("nats: unexpected greeting %q", strings.TrimSpace(line))
+	}
+	var info serverInfo
+	if err = json.Unmarshal([]byte(line[len("INFO "):]), &info); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("nats: invalid INFO: %v", err)
+	}
+	if info.TLSRequired || u.Scheme == "tls" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err = tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+		r = bufio.NewReader(conn)
+	}
+
+	opts := connectOptions{
+		Name:     "pholcus",
+		Lang:     "go",
+		Version:  "1.0.0",
+		Protocol: 1,
+	}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			opts.User, opts.Pass = u.User.Username(), pass
+		} else {
+			opts.AuthToken = u.User.Username()
+		}
+	}
+	b, _ := json.Marshal(opts)
+	w := bufio.NewWriter(conn)
+	fmt.Fprintf(w, "CONNECT %s\r\nPING\r\n", b)
+	if err = w.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// 以PONG确认CONNECT已被接受
+	if line, err = r.ReadString('\n'); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if line = strings.TrimSpace(line); line != "PONG" {
+		conn.Close()
+		return nil, fmt.Errorf("nats: connect failed: %s", line)
+	}
+	conn.SetDeadline(time.Time{})
+
+	self := &Conn{conn: conn, w: w, maxPayload: info.MaxPayload}
+	go self.readLoop(r)
+	return self, nil
+}
+
+// 处理服务器发来的PING及-ERR，连接断开时记录原因
+func (self *Conn) readLoop(r *bufio.Reader) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			self.fail(err)
+			return
+		}
+		switch line = strings.TrimSpace(line); {
+		case line == "PING":
+			self.lock.Lock()
+			if self.err == nil {
+				self.w.WriteString("PONG\r\n")
+				self.w.Flush()
+			}
+			self.lock.Unlock()
+		case strings.HasPrefix(line, "-ERR"):
+			self.fail(errors.New("nats: server error: " + strings.TrimSpace(line[len("-ERR"):])))
+			return
+		}
+	}
+}
+
+// 标记连接失效并关闭
+func (self *Conn) fail(err error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.err == nil {
+		self.err = err
+		self.conn.Close()
+	}
+}
+
+// 向subject发布一条消息，连接已失效时返回其原因
+func (self *Conn) Publish(subject string, data []byte) error {
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n") {
+		return fmt.Errorf("nats: invalid subject %q", subject)
+	}
+	if self.maxPayload > 0 && len(data) > self.maxPayload {
+		return fmt.Errorf("nats: payload of %d bytes exceeds the server limit of %d", len(data), self.maxPayload)
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.err != nil {
+		return self.err
+	}
+	self.w.WriteString("PUB " + subject + " " + strconv.Itoa(len(data)) + "\r\n")
+	self.w.Write(data)
+	self.w.WriteString("\r\n")
+	if err := self.w.Flush(); err != nil {
+		self.err = err
+		self.conn.Close()
+		return err
+	}
+	return nil
+}
+
+// 关闭连接，已缓冲的消息在关闭前发出
+func (self *Conn) Close() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.err != nil {
+		return nil
+	}
+	self.err = errors.New("nats: connection closed")
+	self.w.Flush()
+	return self.conn.Close()
+}
@@ -0,0 +1,74 @@
+package nats
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 模拟的NATS服务器，返回其地址及收到的CONNECT与PUB行
+func fakeServer(t *testing.T) (string, chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := make(chan string, 16)
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.WriteString(conn, "INFO {\"server_id\":\"test\",\"max_payload\":16}\r\n")
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				close(lines)
+				return
+			}
+			line = strings.TrimSpace(line)
+			switch {
+			case line == "PING":
+				io.WriteString(conn, "PONG\r\n")
+			case strings.HasPrefix(line, "CONNECT "):
+				lines <- line
+			case strings.HasPrefix(line, "PUB "):
+				payload, _ := r.ReadString('\n')
+				lines <- line + " " + strings.TrimSpace(payload)
+			}
+		}
+	}()
+	return ln.Addr().String(), lines
+}
+
+func TestPublish(t *testing.T) {
+	addr, lines := fakeServer(t)
+	conn, err := Dial("nats://token@"+addr, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line := <-lines; !strings.Contains(line, `"auth_token":"token"`) {
+		t.Errorf("unexpected CONNECT %q", line)
+	}
+	if err = conn.Publish("pholcus.item", []byte(`{"a":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if line := <-lines; line != `PUB pholcus.item 7 {"a":1}` {
+		t.Errorf("unexpected PUB %q", line)
+	}
+	if err = conn.Publish("pholcus.item", make([]byte, 17)); err == nil {
+		t.Error("payload over max_payload was accepted")
+	}
+	if err = conn.Publish("bad subject", nil); err == nil {
+		t.Error("invalid subject was accepted")
+	}
+	conn.Close()
+	if err = conn.Publish("pholcus.item", nil); err == nil {
+		t.Error("publish after Close succeeded")
+	}
+}
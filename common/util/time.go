@@ -0,0 +1,160 @@
+package util
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ParseTime默认尝试的时间格式，不含年份的格式补全为当前年份
+	TimeLayouts = []string{
+		time.RFC3339,
+		time.RFC1123Z,
+		time.RFC1123,
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		"2006-01-02T15:04:05",
+		"2006-01-02",
+		"2006/01/02 15:04:05",
+		"2006/01/02 15:04",
+		"2006/01/02",
+		"2006.01.02 15:04",
+		"2006.01.02",
+		"2006年1月2日 15:04:05",
+		"2006年1月2日 15:04",
+		"2006年1月2日",
+		"Jan 2, 2006 15:04",
+		"Jan 2, 2006",
+		"January 2, 2006",
+		"2 Jan 2006",
+		"02 Jan 2006 15:04",
+		"01-02 15:04",
+		"1月2日 15:04",
+		"1月2日",
+		"Jan 2 15:04",
+		"Jan 2",
+		"January 2",
+	}
+
+	// ParseTime识别的相对时间单位，可按需追加其他语言的写法
+	TimeUnits = map[string]time.Duration{
+		"秒":      time.Second,
+		"秒钟":     time.Second,
+		"分":      time.Minute,
+		"分钟":     time.Minute,
+		"小时":     time.Hour,
+		"个小时":    time.Hour,
+		"天":      24 * time.Hour,
+		"日":      24 * time.Hour,
+		"周":      7 * 24 * time.Hour,
+		"星期":     7 * 24 * time.Hour,
+		"个星期":    7 * 24 * time.Hour,
+		"个月":     30 * 24 * time.Hour,
+		"月":      30 * 24 * time.Hour,
+		"年":      365 * 24 * time.Hour,
+		"second": time.Second,
+		"sec":    time.Second,
+		"minute": time.Minute,
+		"min":    time.Minute,
+		"hour":   time.Hour,
+		"hr":     time.Hour,
+		"day":    24 * time.Hour,
+		"week":   7 * 24 * time.Hour,
+		"month":  30 * 24 * time.Hour,
+		"year":   365 * 24 * time.Hour,
+	}
+
+	// ParseTime识别的相对日期，值为距今的天数，可按需追加其他语言的写法
+	TimeDays = map[string]int{
+		"今天":        0,
+		"today":     0,
+		"昨天":        -1,
+		"yesterday": -1,
+		"前天":        -2,
+		"明天":        1,
+		"tomorrow":  1,
+	}
+
+	// ParseTime识别的表示当前时刻的写法
+	TimeNows = []string{"刚刚", "刚才", "just now", "now"}
+
+	reTimeAgo = regexp.MustCompile(`^(\d+|an?|半)\s*([^\d\s]+?)s?\s*(前|以前|之前|ago)$`)
+)
+
+// 解析时间字符串，依次尝试相对时间（如"刚刚"、"3分钟前"、"2 hours ago"、"昨天 14:30"）、
+// layouts及TimeLayouts中的格式、10位或13位Unix时间戳，均不匹配时返回错误。
+// 相对时间以now为基准；结果不含年份时补全为now的年份，若因此晚于now则取上一年。
+func ParseTime(raw string, now time.Time, layouts ...string) (time.Time, error) {
+	s := strings.TrimSpace(raw)
+	lower := strings.ToLower(s)
+
+	// 当前时刻
+	for _, n := range TimeNows {
+		if lower == n {
+			return now, nil
+		}
+	}
+
+	// N单位前
+	if m := reTimeAgo.FindStringSubmatch(lower); m != nil {
+		if unit, ok := TimeUnits[m[2]]; ok {
+			switch m[1] {
+			case "a", "an":
+				return now.Add(-unit), nil
+			case "半":
+				return now.Add(-unit / 2), nil
+			default:
+				n, _ := strconv.Atoi(m[1])
+				return now.Add(-time.Duration(n) * unit), nil
+			}
+		}
+	}
+
+	// 昨天 14:30
+	for word, days := range TimeDays {
+		if !strings.HasPrefix(lower, word) {
+			continue
+		}
+		day := now.AddDate(0, 0, days)
+		rest := strings.TrimSpace(s[len(word):])
+		if rest == "" {
+			return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, now.Location()), nil
+		}
+		for _, layout := range []string{"15:04:05", "15:04"} {
+			if t, err := time.ParseInLocation(layout, rest, now.Location()); err == nil {
+				return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), t.Second(), 0, now.Location()), nil
+			}
+		}
+	}
+
+	// 指定格式
+	for _, layout := range append(layouts, TimeLayouts...) {
+		t, err := time.ParseInLocation(layout, s, now.Location())
+		if err != nil {
+			continue
+		}
+		if !strings.Contains(layout, "2006") {
+			t = t.AddDate(now.Year(), 0, 0)
+			if t.After(now) {
+				t = t.AddDate(-1, 0, 0)
+			}
+		}
+		return t, nil
+	}
+
+	// Unix时间戳
+	if IsNum(s) {
+		n, _ := strconv.ParseInt(s, 10, 64)
+		switch len(s) {
+		case 10:
+			return time.Unix(n, 0).In(now.Location()), nil
+		case 13:
+			return time.Unix(n/1e3, n%1e3*1e6).In(now.Location()), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("无法解析的时间: %q", raw)
+}
@@ -0,0 +1,36 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2016, 3, 10, 12, 0, 0, 0, time.Local)
+	for raw, want := range map[string]time.Time{
+		"刚刚":               now,
+		"3分钟前":             now.Add(-3 * time.Minute),
+		"半小时前":             now.Add(-30 * time.Minute),
+		"2 hours ago":      now.Add(-2 * time.Hour),
+		"an hour ago":      now.Add(-time.Hour),
+		"昨天 14:30":         time.Date(2016, 3, 9, 14, 30, 0, 0, time.Local),
+		"Yesterday":        time.Date(2016, 3, 9, 0, 0, 0, 0, time.Local),
+		"2015-12-01 08:00": time.Date(2015, 12, 1, 8, 0, 0, 0, time.Local),
+		"2015年12月1日":       time.Date(2015, 12, 1, 0, 0, 0, 0, time.Local),
+		"Jan 5":            time.Date(2016, 1, 5, 0, 0, 0, 0, time.Local),
+		"12-25 10:00":      time.Date(2015, 12, 25, 10, 0, 0, 0, time.Local),
+		"1457582400":       time.Unix(1457582400, 0),
+	} {
+		got, err := ParseTime(raw, now)
+		if err != nil {
+			t.Errorf("%q: %v", raw, err)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("%q: got %v, want %v", raw, got, want)
+		}
+	}
+	if _, err := ParseTime("not a time", now); err == nil {
+		t.Error("expected error for unparseable input")
+	}
+}
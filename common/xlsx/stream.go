@@ -0,0 +1,167 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// RowReader reads the rows of the first worksheet of an XLSX file one
+// at a time, without loading the whole worksheet into memory.  Only
+// the shared string table is held in memory.  Cell values are returned
+// as their raw text; numbers and dates are not formatted.
+type RowReader struct {
+	zip      *zip.ReadCloser
+	sheet    io.ReadCloser
+	decoder  *xml.Decoder
+	refTable *RefTable
+}
+
+// streamRow maps a row element while streaming; unlike xlsxRow its
+// cells keep inline strings.
+type streamRow struct {
+	C []streamCell `xml:"c"`
+}
+
+type streamCell struct {
+	R  string `xml:"r,attr"`
+	T  string `xml:"t,attr,omitempty"`
+	V  string `xml:"v,omitempty"`
+	Is xlsxSI `xml:"is"`
+}
+
+// OpenRowReader opens the XLSX file at fileName for reading the rows of
+// its first worksheet.  The caller must Close the returned reader.
+func OpenRowReader(fileName string) (*RowReader, error) {
+	z, err := zip.OpenReader(fileName)
+	if err != nil {
+		return nil, err
+	}
+	r := &RowReader{zip: z}
+	if err = r.open(); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// open locates the first worksheet in workbook order and loads the
+// shared string table.
+func (r *RowReader) open() error {
+	var workbook, workbookRels, sharedStrings *zip.File
+	worksheets := make(map[string]*zip.File)
+	for _, v := range r.zip.File {
+		switch v.Name {
+		case "xl/sharedStrings.xml":
+			sharedStrings = v
+		case "xl/workbook.xml":
+			workbook = v
+		case "xl/_rels/workbook.xml.rels":
+			workbookRels = v
+		default:
+			if len(v.Name) > 14 && v.Name[0:13] == "xl/worksheets" {
+				worksheets[v.Name[14:len(v.Name)-4]] = v
+			}
+		}
+	}
+	if workbook == nil || workbookRels == nil {
+		return fmt.Errorf("xl/workbook.xml or its relations not found in input xlsx.")
+	}
+	sheetXMLMap, err := readWorkbookRelationsFromZipFile(workbookRels)
+	if err != nil {
+		return err
+	}
+	rc, err := workbook.Open()
+	if err != nil {
+		return err
+	}
+	wb := new(xlsxWorkbook)
+	err = xml.NewDecoder(rc).Decode(wb)
+	rc.Close()
+	if err != nil {
+		return err
+	}
+	if len(wb.Sheets.Sheet) == 0 {
+		return fmt.Errorf("Input xlsx contains no worksheets.")
+	}
+	f := worksheetFileForSheet(wb.Sheets.Sheet[0], worksheets, sheetXMLMap)
+	if f == nil {
+		return fmt.Errorf("Unable to find sheet '%s'", wb.Sheets.Sheet[0].Name)
+	}
+	if r.refTable, err = readSharedStringsFromZipFile(sharedStrings); err != nil {
+		return err
+	}
+	if r.sheet, err = f.Open(); err != nil {
+		return err
+	}
+	r.decoder = xml.NewDecoder(r.sheet)
+	return nil
+}
+
+// Read returns the cell values of the next row, with empty strings for
+// missing cells.  Empty rows omitted from the file are skipped.  At
+// the end of the worksheet Read returns io.EOF.
+func (r *RowReader) Read() ([]string, error) {
+	for {
+		token, err := r.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+		var row streamRow
+		if err = r.decoder.DecodeElement(&row, &start); err != nil {
+			return nil, err
+		}
+		return r.values(row)
+	}
+}
+
+// values converts the cells of a row into strings placed at their
+// column positions.
+func (r *RowReader) values(row streamRow) ([]string, error) {
+	var values []string
+	for _, c := range row.C {
+		x := len(values)
+		if c.R != "" {
+			var err error
+			if x, _, err = getCoordsFromCellIDString(c.R); err != nil {
+				return nil, err
+			}
+		}
+		for len(values) <= x {
+			values = append(values, "")
+		}
+		switch c.T {
+		case "s":
+			if c.V == "" {
+				continue
+			}
+			index, err := strconv.Atoi(c.V)
+			if err != nil || r.refTable == nil || index < 0 || index >= len(r.refTable.indexedStrings) {
+				return nil, fmt.Errorf("invalid shared string index %q in cell %s", c.V, c.R)
+			}
+			values[x] = r.refTable.ResolveSharedString(index)
+		case "inlineStr":
+			values[x] = c.Is.T
+			for _, run := range c.Is.R {
+				values[x] += run.T
+			}
+		default:
+			values[x] = c.V
+		}
+	}
+	return values, nil
+}
+
+// Close releases the worksheet and the underlying file.
+func (r *RowReader) Close() error {
+	if r.sheet != nil {
+		r.sheet.Close()
+	}
+	return r.zip.Close()
+}
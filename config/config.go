@@ -27,6 +27,10 @@ const (
 	PHANTOMJS_TEMP string = CACHE_DIR                       // Surfer-Phantom下载器：js文件临时目录
 	HISTORY_TAG    string = "history"                       // 历史记录的标识符
 	HISTORY_DIR    string = WORK_ROOT + "/" + HISTORY_TAG   // excel或csv输出方式下，历史记录目录
+	FAILURE_REPORT string = WORK_ROOT + "/failures"         // 失败请求报告的文件路径（不含扩展名）
+	RUN_REPORT     string = WORK_ROOT + "/report.json"      // 运行报告的文件路径
+	METRICS_DIR    string = WORK_ROOT + "/metrics"          // 运行指标采样文件目录
+	RAW_DIR        string = WORK_ROOT + "/raw"              // 原始响应内容的保存目录，文件以内容的sha256命名
 	SPIDER_EXT     string = ".pholcus.html"                 // 动态规则扩展名
 )
 
@@ -36,8 +40,12 @@ var (
 	DATA_CHAN_CAP            int    = setting.DefaultInt("datachancap", datachancap)                               // 收集器容量
 	PHANTOMJS                string = setting.String("phantomjs")                                                  // Surfer-Phantom下载器：phantomjs程序路径
 	PROXY                    string = setting.String("proxylib")                                                   // 代理IP文件路径
+	PROXY_API                string = setting.String("proxyapi")                                                   // 代理服务商提供代理列表的API地址，为空时不使用
+	PROXY_API_AUTH           string = setting.String("proxyapiauth")                                               // 请求代理API时附带的Authorization请求头
+	PROXY_API_MINUTE         int64  = setting.DefaultInt64("proxyapiminute", proxyapiminute)                       // 从代理API刷新代理列表的间隔分钟数
 	SPIDER_DIR               string = setting.String("spiderdir")                                                  // 动态规则目录
 	FILE_DIR                 string = setting.String("fileoutdir")                                                 // 文件（图片、HTML等）结果的输出目录
+	FILE_LAYOUT              string = setting.String("fileoutlayout")                                              // 文件结果的目录结构模板，如 {host}/{rule}/{date}/{filename}
 	TEXT_DIR                 string = setting.String("textoutdir")                                                 // excel或csv输出方式下，文本结果的输出目录
 	DB_NAME                  string = setting.String("dbname")                                                     // 数据库名称
 	MGO_CONN_STR             string = setting.String("mgo::connstring")                                            // mongodb连接字符串
@@ -46,28 +54,90 @@ var (
 	MYSQL_CONN_STR           string = setting.String("mysql::connstring")                                          // mysql连接字符串
 	MYSQL_CONN_CAP           int    = setting.DefaultInt("mysql::conncap", mysqlconncap)                           // mysql连接池容量
 	MYSQL_MAX_ALLOWED_PACKET int    = setting.DefaultInt("mysql::maxallowedpacket", mysqlmaxallowedpacketmb) << 20 // mysql通信缓冲区的最大长度
+	MYSQL_LOAD_DATA          bool   = setting.DefaultBool("mysql::loaddata", mysqlloaddata)                        // mysql是否使用LOAD DATA LOCAL INFILE批量导入
+	GSHEETS_CREDENTIALS      string = setting.String("gsheets::credentials")                                       // Google Sheets输出所用服务账号凭据的JSON文件路径
+	GSHEETS_SPREADSHEET      string = setting.String("gsheets::spreadsheet")                                       // Google Sheets输出的电子表格ID
 	LOG_CAP                  int64  = setting.DefaultInt64("log::cap", logcap)                                     // 日志缓存的容量
 	LOG_LEVEL                int    = logLevel(setting.String("log::level"))                                       // 全局日志打印级别（亦是日志文件输出级别）
 	LOG_CONSOLE_LEVEL        int    = logLevel(setting.String("log::consolelevel"))                                // 日志在控制台的显示级别
 	LOG_FEEDBACK_LEVEL       int    = logLevel(setting.String("log::feedbacklevel"))                               // 客户端反馈至服务端的日志级别
 	LOG_LINEINFO             bool   = setting.DefaultBool("log::lineinfo", loglineinfo)                            // 日志是否打印行信息                                  // 客户端反馈至服务端的日志级别
 	LOG_SAVE                 bool   = setting.DefaultBool("log::save", logsave)                                    // 是否保存所有日志到本地文件
+	LOG_DEDUP_WINDOW         int64  = setting.DefaultInt64("log::dedupwindow", logdedupwindow)                     // 重复日志的合并周期/s，0为不合并
+	LOG_DEDUP_THRESHOLD      int    = setting.DefaultInt("log::dedupthreshold", logdedupthreshold)                 // 合并周期内级别及内容相同的日志最多输出的条数
 )
 
 func init() {
 	// 主要运行时参数的初始化
 	cache.Task = &cache.AppConf{
-		Mode:           setting.DefaultInt("run::mode", mode),                 // 节点角色
-		Port:           setting.DefaultInt("run::port", port),                 // 主节点端口
-		Master:         setting.String("run::master"),                         // 服务器(主节点)地址，不含端口
-		ThreadNum:      setting.DefaultInt("run::thread", thread),             // 全局最大并发量
-		Pausetime:      setting.DefaultInt64("run::pause", pause),             // 暂停时长参考/ms(随机: Pausetime/2 ~ Pausetime*2)
-		OutType:        setting.String("run::outtype"),                        // 输出方式
-		DockerCap:      setting.DefaultInt("run::dockercap", dockercap),       // 分段转储容器容量
-		Limit:          setting.DefaultInt64("run::limit", limit),             // 采集上限，0为不限，若在规则中设置初始值为LIMIT则为自定义限制，否则默认限制请求数
-		ProxyMinute:    setting.DefaultInt64("run::proxyminute", proxyminute), // 代理IP更换的间隔分钟数
-		SuccessInherit: setting.DefaultBool("run::success", success),          // 继承历史成功记录
-		FailureInherit: setting.DefaultBool("run::failure", failure),          // 继承历史失败记录
+		Mode:                setting.DefaultInt("run::mode", mode),                               // 节点角色
+		Port:                setting.DefaultInt("run::port", port),                               // 主节点端口
+		Master:              setting.String("run::master"),                                       // 服务器(主节点)地址，不含端口
+		ThreadNum:           setting.DefaultInt("run::thread", thread),                           // 全局最大并发量
+		Pausetime:           setting.DefaultInt64("run::pause", pause),                           // 暂停时长参考/ms(随机: Pausetime/2 ~ Pausetime*2)
+		OutType:             setting.String("run::outtype"),                                      // 输出方式，多个时以逗号分隔，成功与失败记录保存于首个输出方式
+		DockerCap:           setting.DefaultInt("run::dockercap", dockercap),                     // 分段转储容器容量
+		Limit:               setting.DefaultInt64("run::limit", limit),                           // 采集上限，0为不限，若在规则中设置初始值为LIMIT则为自定义限制，否则默认限制请求数
+		ProxyMinute:         setting.DefaultInt64("run::proxyminute", proxyminute),               // 代理IP更换的间隔分钟数
+		PriorityAging:       setting.DefaultFloat("run::priorityaging", priorityaging),           // 优先级老化速率，即请求每等待1秒所提升的优先级
+		StopGrace:           setting.DefaultInt64("run::stopgrace", stopgrace),                   // 队列为空后确认停止前的等待秒数，0为立即停止
+		MaxIdleConns:        setting.DefaultInt("run::maxidleconns", maxidleconns),               // 下载器连接池中空闲连接的最大总数，0为不限
+		MaxIdleConnsPerHost: setting.DefaultInt("run::maxidleconnsperhost", maxidleconnsperhost), // 下载器连接池中每个主机的最大空闲连接数，0为采用Go默认值(2)
+		IdleConnTimeout:     setting.DefaultInt64("run::idleconntimeout", idleconntimeout),       // 下载器空闲连接的超时秒数，0为不限
+		Resolver:            setting.String("run::resolver"),                                     // 下载器DNS解析器，为空时使用系统DNS
+		FailureReport:       setting.DefaultBool("run::failurereport", failurereport),            // 任务结束时是否输出失败请求报告
+		MaxRequests:         setting.DefaultInt64("run::maxrequests", maxrequests),               // 本次任务处理请求的总数上限，0为不限
+		MetricsInterval:     setting.DefaultInt64("run::metricsinterval", metricsinterval),       // 运行指标（队列深度、吞吐量、失败率）的采样间隔秒数，0为不采样
+		FileSkipExists:      setting.DefaultBool("run::fileskipexists", fileskipexists),          // 文件输出时跳过已存在的文件，不再重复下载
+		MaxFailRate:         setting.DefaultFloat("run::maxfailrate", maxfailrate),               // 最近100个请求的失败率达到该值(0~1)时终止任务，0为不限
+		MaxConsecutiveFails: setting.DefaultInt("run::maxconsecutivefails", maxconsecutivefails), // 连续失败的请求数达到该值时终止任务，0为不限
+		Warmup:              setting.DefaultBool("run::warmup", warmup),                          // 任务开始时预先建立到种子URL所在主机的连接（DNS解析与TLS握手）
+		RetryAllErrors:      setting.DefaultBool("run::retryallerrors", retryallerrors),          // 是否重试所有失败的请求，为false时不重试4xx（408、429除外）等永久性错误
+		MaxRunTime:          setting.DefaultInt64("run::maxruntime", maxruntime),                 // 任务运行时长上限/s，超出后停止派发新请求并等待处理中的请求完成，0为不限
+		FileThreadNum:       setting.DefaultInt("run::filethread", filethread),                   // 文件下载请求（设置了FileName的请求）的独立并发量，0为与其他请求共用全局并发量
+		FileResume:          setting.DefaultBool("run::fileresume", fileresume),                  // 是否断点续传文件，下载中断时保留已下载部分，再次下载时以Range请求续传
+		MaxRetries:          setting.DefaultInt("run::maxretries", maxretries),                   // 请求失败后在队列末尾重新执行的默认次数，可由Request.MaxRetries逐个指定
+		HeaderTimeout:       setting.DefaultInt64("run::headertimeout", headertimeout),           // 下载器等待响应头的超时秒数，0为不限
+		ReadIdleTimeout:     setting.DefaultInt64("run::readidletimeout", readidletimeout),       // 下载器读取响应内容时的空闲超时秒数，即连续无数据到达的最长时长，0为不限
+		QueueStore:          setting.String("run::queuestore"),                                   // 请求队列的存储后端，默认为内存(memory)，可选值见scheduler.QueueStores
+		Strategy:            setting.String("run::strategy"),                                     // 抓取策略，bfs为广度优先(先进先出)，dfs为深度优先(后进先出)，优先级不同时仍按优先级调度
+		Canonical:           setting.DefaultBool("run::canonical", canonical),                    // 是否按网页<link rel="canonical">中的规范网址去重，并以规范网址作为结果的Url字段
+		InvalidUTF8:         setting.String("run::invalidutf8"),                                  // 结果中非法UTF-8字符串的处理方式，none为不处理，replace为替换为U+FFFD，strip为删除，error为丢弃整条数据
+		TLSFingerprint:      setting.String("run::tlsfingerprint"),                               // 下载器https请求模拟的浏览器TLS指纹，可选chrome、firefox、safari、edge，为空时使用Go默认的TLS握手
+		OrderedOutput:       setting.DefaultBool("run::orderedoutput", orderedoutput),            // 是否按请求入队顺序输出文本结果，须缓存先完成的请求的结果
+		RunReport:           setting.DefaultBool("run::runreport", runreport),                    // 任务结束时是否输出运行报告（请求数、失败分类、吞吐量及耗时分布）
+		CollectorThreadNum:  setting.DefaultInt("run::collectorthread", collectorthread),         // 文本数据收集输出的并发协程数，各协程独立分批输出，有序输出模式下固定为1
+		OutputSchema:        setting.DefaultBool("run::outputschema", outputschema),              // 任务开始时是否在文本结果目录输出各规则结果字段的说明文件(json)
+		DNSCacheTTL:         setting.DefaultInt64("run::dnscachettl", dnscachettl),               // 下载器DNS缓存的时长/s，0为不缓存，即每次连接均按系统或自定义解析器解析
+		DNSCacheSize:        setting.DefaultInt("run::dnscachesize", dnscachesize),               // 下载器DNS缓存最多缓存的主机数
+		SeedAddr:            setting.String("run::seedaddr"),                                     // 接收种子URL的HTTP服务监听地址(如:9091)，为空时不开启，无身份验证故仅监听本机回环地址，任务运行期间可向其POST新的种子URL，宜配合stopgrace使用
+		OnDuplicate:         setting.String("run::onduplicate"),                                  // 数据库输出遇到重复键时的处理方式：fail整批失败，skip跳过重复的行，update以新数据更新重复的行
+		StartJitter:         setting.DefaultInt64("run::startjitter", startjitter),               // 分布式子节点开始执行任务前的随机延迟上限秒数，使集群的抓取压力平缓上升，0为不延迟
+		CollectOnce:         setting.DefaultBool("run::collectonce", collectonce),                // 是否为每条结果生成幂等键（请求指纹加字段值的哈希），请求重试或重新执行时不重复收集相同的结果
+		URLNormalize:        setting.DefaultBool("run::urlnormalize", urlnormalize),              // 去重前是否规范化网址：主机名小写，去除默认端口、锚点及路径末尾的斜杠，查询参数排序，并去除dropparams中的参数
+		DropParams:          setting.String("run::dropparams"),                                   // 规范化网址时去除的跟踪参数，以逗号分隔，以*结尾时按前缀匹配
+		Explain:             setting.DefaultBool("run::explain", explain),                        // 是否对所有规则开启选择器调试日志，记录Context.Find()的选择器、匹配数量及匹配内容片段，亦可按Rule.Explain单独开启
+		RotateRows:          setting.DefaultInt("run::rotaterows", rotaterows),                   // csv、excel输出时单个文件的最大结果数，超出时另起新文件并重新写入表头，0为不限（每批输出仍各为一个文件）
+		RotateInterval:      setting.DefaultInt64("run::rotateinterval", rotateinterval),         // 按时长滚动输出的间隔秒数，距上次输出达到该时长时即输出已缓存的结果，使文件按时段关闭以便下游逐个处理，0为不按时长滚动
+		OutputWait:          setting.DefaultInt64("run::outputwait", outputwait),                 // 输出目标(注册了collector.DataCheck的输出方式，如Mysql、MongoDB、Google Sheets)在任务开始时不可用时，等待其恢复的最长秒数，0为立即放弃本次任务
+		SeedWait:            setting.DefaultInt64("run::seedwait", seedwait),                     // 任务开始时请求队列为空的最长等待秒数，期间收到首个请求前不停止任务，以免分布式种子迟到时提前结束，为-1时分布式子节点等待10秒、其余模式不等待
+		Profile:             setting.String("run::profile"),                                      // 礼貌度预设(gentle/normal/aggressive)，显式设置或与默认值不同的单项配置优先，为空时不采用
+		InflightWarn:        setting.DefaultInt64("run::inflightwarn", inflightwarn),             // 请求开始执行后超过该秒数仍未完成时记录警告日志，用于发现卡住的下载，0为不警告
+		PhantomPool:         setting.DefaultInt("run::phantompool", phantompool),                 // Phantom下载器常驻复用的phantomjs进程数，0为每个请求启动新进程
+		PhantomMaxUses:      setting.DefaultInt("run::phantommaxuses", phantommaxuses),           // 常驻的phantomjs进程处理该数量的请求后即退出并由新进程替代
+		PhantomSession:      setting.String("run::phantomsession"),                               // 常驻的phantomjs进程在请求间的cookie隔离方式，fresh为每个请求前清空cookie，shared为同一进程内共享cookie
+		NatsUrl:             setting.String("run::natsurl"),                                      // NATS服务器地址，如nats://127.0.0.1:4222，可含user:pass@或token@，为空时不发布抓取事件
+		NatsSubject:         setting.String("run::natssubject"),                                  // 发布抓取事件的NATS主题前缀，事件主题为 前缀.事件类型，如pholcus.page.success
+		MaxBodySize:         setting.DefaultInt64("run::maxbodysize", maxbodysize),               // 单个响应内容读取的最大字节数，超出部分不再接收，按已读取的内容正常解析，对未声明Content-Length的分块响应同样有效，0为不限
+		SamplePages:         setting.DefaultInt("run::samplepages", samplepages),                 // 预览模式下每个规则最多解析的成功页数，用于开发规则时快速查看各规则的输出，均达到后任务结束，0为关闭
+		Manifest:            setting.DefaultBool("run::manifest", manifest),                      // 是否在文本结果目录输出清单文件，逐行记录每条结果的序号、内容摘要及来源请求的网址、规则、状态码与时间，用于追溯数据来源
+		RunTag:              setting.DefaultBool("run::runtag", runtag),                          // 是否为每条结果附带本次运行的RunId与RunTime字段，以便区分多次运行写入同一表单的数据
+		RunId:               setting.String("run::runid"),                                        // 本次运行的ID，为空时每次运行自动生成，分布式各节点需一致时须手动指定
+		ItemBuffer:          setting.DefaultInt("run::itembuffer", itembuffer),                   // 文本数据收集通道的容量，为0时采用datachancap，通道已满时按itembufferpolicy处理
+		ItemBufferPolicy:    setting.String("run::itembufferpolicy"),                             // 收集通道已满时的处理方式：block阻塞采集协程直至输出跟上，drop丢弃该条结果并记录日志
+		MaxConnsPerHost:     setting.DefaultInt("run::maxconnsperhost", maxconnsperhost),         // 下载器同时连接每个主机的最大连接数（含正在建立及使用中的连接），超出的请求等待空闲连接，0为不限
+		SuccessInherit:      setting.DefaultBool("run::success", success),                        // 继承历史成功记录
+		FailureInherit:      setting.DefaultBool("run::failure", failure),                        // 继承历史失败记录
 	}
 }
 
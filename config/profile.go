@@ -0,0 +1,109 @@
+package config
+
+import (
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+// 礼貌度预设，按组合设置任务的并发量、暂停时长、每主机连接数、重试次数及UserAgent轮换
+type Profile struct {
+	ThreadNum           int   // 全局最大并发量
+	Pausetime           int64 // 暂停时长参考/ms
+	MaxConnsPerHost     int   // 下载器同时连接每个主机的最大连接数，0为不限
+	MaxIdleConnsPerHost int   // 下载器连接池中每个主机的最大空闲连接数
+	MaxRetries          int   // 请求失败后重新执行的默认次数
+	RotateUA            bool  // 未指定User-Agent的请求是否随机轮换User-Agent
+}
+
+// 可选的礼貌度预设，[名称]预设
+var Profiles = map[string]Profile{
+	// 低并发、长停顿，每个主机最多同时2个连接，始终使用同一User-Agent，适合对访问频率敏感的站点
+	"gentle": {ThreadNum: 2, Pausetime: 3000, MaxConnsPerHost: 2, MaxIdleConnsPerHost: 2, MaxRetries: 3, RotateUA: false},
+	// 与默认配置一致
+	"normal": {ThreadNum: thread, Pausetime: pause, MaxConnsPerHost: maxconnsperhost, MaxIdleConnsPerHost: maxidleconnsperhost, MaxRetries: maxretries, RotateUA: true},
+	// 高并发、短停顿，适合可承受较大压力的站点
+	"aggressive": {ThreadNum: 200, Pausetime: 20, MaxConnsPerHost: 0, MaxIdleConnsPerHost: 100, MaxRetries: 1, RotateUA: true},
+}
+
+// 一次运行所应用的预设，记录被替换的配置项及其原值，运行结束后经Restore()恢复
+type ProfileState struct {
+	task     *cache.AppConf
+	saved    Profile         // 应用预设前的配置
+	applied  Profile         // 采用的预设
+	replaced map[string]bool // 被预设替换的配置项(字段名)
+}
+
+// 按task.Profile将预设应用至任务配置，未显式设置(见AppConf.Explicit)且仍为默认值的单项配置才被预设替换；
+// 预设仅在本次运行期间有效，返回值用于运行结束后恢复原配置，预设不存在时返回false
+func ApplyProfile(task *cache.AppConf) (*ProfileState, bool) {
+	p, ok := Profiles[task.Profile]
+	if !ok {
+		return nil, false
+	}
+	state := &ProfileState{
+		task:     task,
+		saved:    profileOf(task),
+		applied:  p,
+		replaced: make(map[string]bool),
+	}
+	replace := func(field string, isDefault bool) bool {
+		if !isDefault || task.Explicit[field] {
+			return false
+		}
+		state.replaced[field] = true
+		return true
+	}
+	if replace("ThreadNum", task.ThreadNum == thread) {
+		task.ThreadNum = p.ThreadNum
+	}
+	if replace("Pausetime", task.Pausetime == pause) {
+		task.Pausetime = p.Pausetime
+	}
+	if replace("MaxConnsPerHost", task.MaxConnsPerHost == maxconnsperhost) {
+		task.MaxConnsPerHost = p.MaxConnsPerHost
+	}
+	if replace("MaxIdleConnsPerHost", task.MaxIdleConnsPerHost == maxidleconnsperhost) {
+		task.MaxIdleConnsPerHost = p.MaxIdleConnsPerHost
+	}
+	if replace("MaxRetries", task.MaxRetries == maxretries) {
+		task.MaxRetries = p.MaxRetries
+	}
+	return state, true
+}
+
+// 将被预设替换、且此后未再改动的配置项恢复为应用预设前的值，使下次运行可重新按默认值判断
+func (self *ProfileState) Restore() {
+	if self == nil {
+		return
+	}
+	var (
+		task    = self.task
+		saved   = self.saved
+		applied = self.applied
+	)
+	if self.replaced["ThreadNum"] && task.ThreadNum == applied.ThreadNum {
+		task.ThreadNum = saved.ThreadNum
+	}
+	if self.replaced["Pausetime"] && task.Pausetime == applied.Pausetime {
+		task.Pausetime = saved.Pausetime
+	}
+	if self.replaced["MaxConnsPerHost"] && task.MaxConnsPerHost == applied.MaxConnsPerHost {
+		task.MaxConnsPerHost = saved.MaxConnsPerHost
+	}
+	if self.replaced["MaxIdleConnsPerHost"] && task.MaxIdleConnsPerHost == applied.MaxIdleConnsPerHost {
+		task.MaxIdleConnsPerHost = saved.MaxIdleConnsPerHost
+	}
+	if self.replaced["MaxRetries"] && task.MaxRetries == applied.MaxRetries {
+		task.MaxRetries = saved.MaxRetries
+	}
+}
+
+// 任务配置中由预设管理的各项
+func profileOf(task *cache.AppConf) Profile {
+	return Profile{
+		ThreadNum:           task.ThreadNum,
+		Pausetime:           task.Pausetime,
+		MaxConnsPerHost:     task.MaxConnsPerHost,
+		MaxIdleConnsPerHost: task.MaxIdleConnsPerHost,
+		MaxRetries:          task.MaxRetries,
+	}
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/henrylee2cn/pholcus/runtime/cache"
+)
+
+// 预设只在本次运行期间替换默认值，恢复后下一个预设仍可替换；显式设置的配置项不被替换
+func TestApplyProfile(t *testing.T) {
+	task := &cache.AppConf{
+		ThreadNum:           thread,
+		Pausetime:           pause,
+		MaxConnsPerHost:     maxconnsperhost,
+		MaxIdleConnsPerHost: maxidleconnsperhost,
+		MaxRetries:          maxretries,
+		Profile:             "gentle",
+	}
+	task.SetExplicit("MaxRetries")
+
+	state, ok := ApplyProfile(task)
+	if !ok {
+		t.Fatal("gentle not found")
+	}
+	if task.ThreadNum != 2 || task.Pausetime != 3000 || task.MaxConnsPerHost != 2 {
+		t.Errorf("gentle applied %+v", profileOf(task))
+	}
+	if task.MaxRetries != maxretries {
+		t.Errorf("explicit MaxRetries replaced with %v", task.MaxRetries)
+	}
+	state.Restore()
+	if got := profileOf(task); got != profileOf(&cache.AppConf{
+		ThreadNum:           thread,
+		Pausetime:           pause,
+		MaxConnsPerHost:     maxconnsperhost,
+		MaxIdleConnsPerHost: maxidleconnsperhost,
+		MaxRetries:          maxretries,
+	}) {
+		t.Errorf("restored %+v", got)
+	}
+
+	task.Profile = "aggressive"
+	state, _ = ApplyProfile(task)
+	if task.ThreadNum != 200 || task.Pausetime != 20 {
+		t.Errorf("aggressive after gentle applied %+v", profileOf(task))
+	}
+
+	// 运行期间改动过的配置项不被恢复
+	task.ThreadNum = 7
+	state.Restore()
+	if task.ThreadNum != 7 || task.Pausetime != pause {
+		t.Errorf("restored %+v", profileOf(task))
+	}
+
+	if _, ok := ApplyProfile(&cache.AppConf{Profile: "unknown"}); ok {
+		t.Error("unknown profile applied")
+	}
+}
@@ -12,37 +12,103 @@ import (
 
 // 配置文件涉及的默认配置。
 const (
-	crawlcap                int    = 50                          // 蜘蛛池最大容量
-	datachancap             int    = 2 << 14                     // 收集器容量(默认65536)
-	logcap                  int64  = 10000                       // 日志缓存的容量
-	loglevel                string = "debug"                     // 全局日志打印级别（亦是日志文件输出级别）
-	logconsolelevel         string = "info"                      // 日志在控制台的显示级别
-	logfeedbacklevel        string = "error"                     // 客户端反馈至服务端的日志级别
-	loglineinfo             bool   = false                       // 日志是否打印行信息
-	logsave                 bool   = true                        // 是否保存所有日志到本地文件
-	phantomjs               string = WORK_ROOT + "/phantomjs"    // phantomjs文件路径
-	proxylib                string = WORK_ROOT + "/proxy.lib"    // 代理ip文件路径
-	spiderdir               string = WORK_ROOT + "/spiders"      // 动态规则目录
-	fileoutdir              string = WORK_ROOT + "/file_out"     // 文件（图片、HTML等）结果的输出目录
-	textoutdir              string = WORK_ROOT + "/text_out"     // excel或csv输出方式下，文本结果的输出目录
-	dbname                  string = TAG                         // 数据库名称
-	mgoconnstring           string = "127.0.0.1:27017"           // mongodb连接字符串
-	mgoconncap              int    = 1024                        // mongodb连接池容量
-	mgoconngcsecond         int64  = 600                         // mongodb连接池GC时间，单位秒
-	mysqlconnstring         string = "root:@tcp(127.0.0.1:3306)" // mysql连接字符串
-	mysqlconncap            int    = 2048                        // mysql连接池容量
-	mysqlmaxallowedpacketmb int    = 1                           // mysql通信缓冲区的最大长度，单位MB，默认1MB
-	mode                    int    = status.UNSET                // 节点角色
-	port                    int    = 2015                        // 主节点端口
-	master                  string = "127.0.0.1"                 // 服务器(主节点)地址，不含端口
-	thread                  int    = 20                          // 全局最大并发量
-	pause                   int64  = 300                         // 暂停时长参考/ms(随机: Pausetime/2 ~ Pausetime*2)
-	outtype                 string = "csv"                       // 输出方式
-	dockercap               int    = 10000                       // 分段转储容器容量
-	limit                   int64  = 0                           // 采集上限，0为不限，若在规则中设置初始值为LIMIT则为自定义限制，否则默认限制请求数
-	proxyminute             int64  = 0                           // 代理IP更换的间隔分钟数
-	success                 bool   = true                        // 继承历史成功记录
-	failure                 bool   = true                        // 继承历史失败记录
+	crawlcap                int     = 50                          // 蜘蛛池最大容量
+	datachancap             int     = 2 << 14                     // 收集器容量(默认65536)
+	logcap                  int64   = 10000                       // 日志缓存的容量
+	loglevel                string  = "debug"                     // 全局日志打印级别（亦是日志文件输出级别）
+	logconsolelevel         string  = "info"                      // 日志在控制台的显示级别
+	logfeedbacklevel        string  = "error"                     // 客户端反馈至服务端的日志级别
+	loglineinfo             bool    = false                       // 日志是否打印行信息
+	logsave                 bool    = true                        // 是否保存所有日志到本地文件
+	logdedupwindow          int64   = 0                           // 重复日志的合并周期/s，周期内级别及内容相同的日志超出logdedupthreshold条后不再输出，周期结束时汇总输出省略的条数，0为不合并
+	logdedupthreshold       int     = 10                          // 合并周期内级别及内容相同的日志最多输出的条数
+	phantomjs               string  = WORK_ROOT + "/phantomjs"    // phantomjs文件路径
+	proxylib                string  = WORK_ROOT + "/proxy.lib"    // 代理ip文件路径
+	proxyapi                string  = ""                          // 代理服务商提供代理列表的API地址(可含user:password@)，为空时不使用，返回JSON或每行一个代理
+	proxyapiauth            string  = ""                          // 请求代理API时附带的Authorization请求头，如 Bearer xxx
+	proxyapiminute          int64   = 10                          // 从代理API刷新代理列表的间隔分钟数
+	spiderdir               string  = WORK_ROOT + "/spiders"      // 动态规则目录
+	fileoutdir              string  = WORK_ROOT + "/file_out"     // 文件（图片、HTML等）结果的输出目录
+	fileoutlayout           string  = "{namespace}/{filename}"    // 文件结果的目录结构模板，可用 {namespace} {spider} {keyin} {rule} {host} {date} {filename}
+	textoutdir              string  = WORK_ROOT + "/text_out"     // excel或csv输出方式下，文本结果的输出目录
+	dbname                  string  = TAG                         // 数据库名称
+	mgoconnstring           string  = "127.0.0.1:27017"           // mongodb连接字符串
+	mgoconncap              int     = 1024                        // mongodb连接池容量
+	mgoconngcsecond         int64   = 600                         // mongodb连接池GC时间，单位秒
+	mysqlconnstring         string  = "root:@tcp(127.0.0.1:3306)" // mysql连接字符串
+	mysqlconncap            int     = 2048                        // mysql连接池容量
+	mysqlmaxallowedpacketmb int     = 1                           // mysql通信缓冲区的最大长度，单位MB，默认1MB
+	mysqlloaddata           bool    = false                       // mysql是否使用LOAD DATA LOCAL INFILE批量导入，服务器须开启local_infile，不支持时自动改用INSERT；仅在run::onduplicate为skip或update时采用
+	gsheetscredentials      string  = ""                          // Google Sheets输出所用服务账号凭据的JSON文件路径，该账号须有电子表格的编辑权限
+	gsheetsspreadsheet      string  = ""                          // Google Sheets输出的电子表格ID，即其网址中/d/与/edit之间的部分
+	mode                    int     = status.UNSET                // 节点角色
+	port                    int     = 2015                        // 主节点端口
+	master                  string  = "127.0.0.1"                 // 服务器(主节点)地址，不含端口
+	thread                  int     = 20                          // 全局最大并发量
+	pause                   int64   = 300                         // 暂停时长参考/ms(随机: Pausetime/2 ~ Pausetime*2)
+	outtype                 string  = "csv"                       // 输出方式，多个时以逗号分隔，如csv,mysql
+	dockercap               int     = 10000                       // 分段转储容器容量
+	limit                   int64   = 0                           // 采集上限，0为不限，若在规则中设置初始值为LIMIT则为自定义限制，否则默认限制请求数
+	proxyminute             int64   = 0                           // 代理IP更换的间隔分钟数
+	priorityaging           float64 = 0                           // 优先级老化速率，即请求每等待1秒所提升的优先级，0为严格按优先级调度
+	stopgrace               int64   = 0                           // 队列为空后确认停止前的等待秒数，0为立即停止
+	maxidleconns            int     = 100                         // 下载器连接池中空闲连接的最大总数，0为不限
+	maxidleconnsperhost     int     = 20                          // 下载器连接池中每个主机的最大空闲连接数，0为采用Go默认值(2)
+	idleconntimeout         int64   = 90                          // 下载器空闲连接的超时秒数，0为不限
+	resolver                string  = ""                          // 下载器DNS解析器，为空时使用系统DNS，可为DNS服务器地址(如8.8.8.8:53)或DoH地址(如https://1.1.1.1/dns-query)
+	failurereport           bool    = false                       // 任务结束时是否输出失败请求报告
+	maxrequests             int64   = 0                           // 本次任务处理请求的总数上限，0为不限
+	metricsinterval         int64   = 0                           // 运行指标（队列深度、吞吐量、失败率）的采样间隔秒数，0为不采样
+	fileskipexists          bool    = false                       // 文件输出时跳过已存在的文件，不再重复下载
+	maxfailrate             float64 = 0                           // 最近100个请求的失败率达到该值(0~1)时终止任务，0为不限
+	maxconsecutivefails     int     = 0                           // 连续失败的请求数达到该值时终止任务，0为不限
+	warmup                  bool    = false                       // 任务开始时预先建立到种子URL所在主机的连接（DNS解析与TLS握手）
+	retryallerrors          bool    = false                       // 是否重试所有失败的请求，为false时不重试4xx（408、429除外）等永久性错误
+	maxruntime              int64   = 0                           // 任务运行时长上限/s，超出后停止派发新请求并等待处理中的请求完成，0为不限
+	filethread              int     = 0                           // 文件下载请求（设置了FileName的请求）的独立并发量，0为与其他请求共用全局并发量
+	fileresume              bool    = false                       // 是否断点续传文件，下载中断时保留已下载部分，再次下载时以Range请求续传
+	maxretries              int     = 1                           // 请求失败后在队列末尾重新执行的默认次数，可由Request.MaxRetries逐个指定
+	headertimeout           int64   = 0                           // 下载器等待响应头的超时秒数，0为不限
+	readidletimeout         int64   = 0                           // 下载器读取响应内容时的空闲超时秒数，即连续无数据到达的最长时长，0为不限
+	queuestore              string  = "memory"                    // 请求队列的存储后端，默认为内存(memory)，可选值见scheduler.QueueStores
+	strategy                string  = "bfs"                       // 抓取策略，bfs为广度优先(先进先出)，dfs为深度优先(后进先出)，优先级不同时仍按优先级调度
+	canonical               bool    = false                       // 是否按网页<link rel="canonical">中的规范网址去重，并以规范网址作为结果的Url字段
+	invalidutf8             string  = "none"                      // 结果中非法UTF-8字符串的处理方式，none为不处理，replace为替换为U+FFFD，strip为删除，error为丢弃整条数据
+	tlsfingerprint          string  = ""                          // 下载器https请求模拟的浏览器TLS指纹，可选chrome、firefox、safari、edge，为空时使用Go默认的TLS握手
+	orderedoutput           bool    = false                       // 是否按请求入队顺序输出文本结果，须缓存先完成的请求的结果
+	runreport               bool    = false                       // 任务结束时是否输出运行报告（请求数、失败分类、吞吐量及耗时分布）
+	collectorthread         int     = 1                           // 文本数据收集输出的并发协程数，各协程独立分批输出，有序输出模式下固定为1
+	outputschema            bool    = false                       // 任务开始时是否在文本结果目录输出各规则结果字段的说明文件(json)
+	dnscachettl             int64   = 0                           // 下载器DNS缓存的时长/s，0为不缓存，即每次连接均按系统或自定义解析器解析
+	dnscachesize            int     = 10000                       // 下载器DNS缓存最多缓存的主机数
+	seedaddr                string  = ""                          // 接收种子URL的HTTP服务监听地址(如:9091)，为空时不开启，无身份验证故仅监听本机回环地址，任务运行期间可向其POST新的种子URL，宜配合stopgrace使用
+	onduplicate             string  = "fail"                      // 数据库输出遇到重复键时的处理方式：fail整批失败，skip跳过重复的行，update以新数据更新重复的行
+	startjitter             int64   = 0                           // 分布式子节点开始执行任务前的随机延迟上限秒数，使集群的抓取压力平缓上升，0为不延迟
+	collectonce             bool    = false                       // 是否为每条结果生成幂等键（请求指纹加字段值的哈希），请求重试或重新执行时不重复收集相同的结果
+	urlnormalize            bool    = false                       // 去重前是否规范化网址：主机名小写，去除默认端口、锚点及路径末尾的斜杠，查询参数排序，并去除dropparams中的参数
+	dropparams              string  = "utm_*,gclid,fbclid"        // 规范化网址时去除的跟踪参数，以逗号分隔，以*结尾时按前缀匹配
+	explain                 bool    = false                       // 是否对所有规则开启选择器调试日志，记录Context.Find()的选择器、匹配数量及匹配内容片段，亦可按Rule.Explain单独开启
+	rotaterows              int     = 0                           // csv、excel输出时单个文件的最大结果数，超出时另起新文件并重新写入表头，0为不限（每批输出仍各为一个文件）
+	rotateinterval          int64   = 0                           // 按时长滚动输出的间隔秒数，距上次输出达到该时长时即输出已缓存的结果，使文件按时段关闭以便下游逐个处理，0为不按时长滚动
+	outputwait              int64   = 0                           // 输出目标(注册了collector.DataCheck的输出方式，如Mysql、MongoDB、Google Sheets)在任务开始时不可用时，等待其恢复的最长秒数，0为立即放弃本次任务
+	seedwait                int64   = -1                          // 任务开始时请求队列为空的最长等待秒数，期间收到首个请求前不停止任务，以免分布式种子迟到时提前结束，为-1时分布式子节点等待10秒、其余模式不等待
+	profile                 string  = ""                          // 礼貌度预设(gentle/normal/aggressive)，显式设置或与默认值不同的单项配置优先，为空时不采用
+	inflightwarn            int64   = 0                           // 请求开始执行后超过该秒数仍未完成时记录警告日志，用于发现卡住的下载，0为不警告
+	phantompool             int     = 0                           // Phantom下载器常驻复用的phantomjs进程数，0为每个请求启动新进程
+	phantommaxuses          int     = 100                         // 常驻的phantomjs进程处理该数量的请求后即退出并由新进程替代
+	phantomsession          string  = "fresh"                     // 常驻的phantomjs进程在请求间的cookie隔离方式，fresh为每个请求前清空cookie，shared为同一进程内共享cookie
+	natsurl                 string  = ""                          // NATS服务器地址，如nats://127.0.0.1:4222，可含user:pass@或token@，为空时不发布抓取事件
+	natssubject             string  = "pholcus"                   // 发布抓取事件的NATS主题前缀，事件主题为 前缀.事件类型，如pholcus.page.success
+	maxbodysize             int64   = 0                           // 单个响应内容读取的最大字节数，超出部分不再接收，按已读取的内容正常解析，对未声明Content-Length的分块响应同样有效，0为不限
+	samplepages             int     = 0                           // 预览模式下每个规则最多解析的成功页数，用于开发规则时快速查看各规则的输出，均达到后任务结束，0为关闭
+	manifest                bool    = false                       // 是否在文本结果目录输出清单文件，逐行记录每条结果的序号、内容摘要及来源请求的网址、规则、状态码与时间，用于追溯数据来源
+	runtag                  bool    = false                       // 是否为每条结果附带本次运行的RunId与RunTime字段，以便区分多次运行写入同一表单的数据
+	runid                   string  = ""                          // 本次运行的ID，为空时每次运行自动生成，分布式各节点需一致时须手动指定
+	itembuffer              int     = 0                           // 文本数据收集通道的容量，为0时采用datachancap，通道已满时按itembufferpolicy处理
+	itembufferpolicy        string  = "block"                     // 收集通道已满时的处理方式：block阻塞采集协程直至输出跟上，drop丢弃该条结果并记录日志
+	maxconnsperhost         int     = 0                           // 下载器同时连接每个主机的最大连接数（含正在建立及使用中的连接），超出的请求等待空闲连接，0为不限
+	success                 bool    = true                        // 继承历史成功记录
+	failure                 bool    = true                        // 继承历史失败记录
 )
 
 var setting = func() config.Configer {
@@ -80,10 +146,16 @@ func defaultConfig(iniconf config.Configer) {
 	iniconf.Set("log::feedbacklevel", logfeedbacklevel)
 	iniconf.Set("log::lineinfo", fmt.Sprint(loglineinfo))
 	iniconf.Set("log::save", fmt.Sprint(logsave))
+	iniconf.Set("log::dedupwindow", strconv.FormatInt(logdedupwindow, 10))
+	iniconf.Set("log::dedupthreshold", strconv.Itoa(logdedupthreshold))
 	iniconf.Set("phantomjs", phantomjs)
 	iniconf.Set("proxylib", proxylib)
+	iniconf.Set("proxyapi", proxyapi)
+	iniconf.Set("proxyapiauth", proxyapiauth)
+	iniconf.Set("proxyapiminute", strconv.FormatInt(proxyapiminute, 10))
 	iniconf.Set("spiderdir", spiderdir)
 	iniconf.Set("fileoutdir", fileoutdir)
+	iniconf.Set("fileoutlayout", fileoutlayout)
 	iniconf.Set("textoutdir", textoutdir)
 	iniconf.Set("dbname", dbname)
 	iniconf.Set("mgo::connstring", mgoconnstring)
@@ -92,6 +164,9 @@ func defaultConfig(iniconf config.Configer) {
 	iniconf.Set("mysql::connstring", mysqlconnstring)
 	iniconf.Set("mysql::conncap", strconv.Itoa(mysqlconncap))
 	iniconf.Set("mysql::maxallowedpacketmb", strconv.Itoa(mysqlmaxallowedpacketmb))
+	iniconf.Set("mysql::loaddata", fmt.Sprint(mysqlloaddata))
+	iniconf.Set("gsheets::credentials", gsheetscredentials)
+	iniconf.Set("gsheets::spreadsheet", gsheetsspreadsheet)
 	iniconf.Set("run::mode", strconv.Itoa(mode))
 	iniconf.Set("run::port", strconv.Itoa(port))
 	iniconf.Set("run::master", master)
@@ -101,6 +176,63 @@ func defaultConfig(iniconf config.Configer) {
 	iniconf.Set("run::dockercap", strconv.Itoa(dockercap))
 	iniconf.Set("run::limit", strconv.FormatInt(limit, 10))
 	iniconf.Set("run::proxyminute", strconv.FormatInt(proxyminute, 10))
+	iniconf.Set("run::priorityaging", strconv.FormatFloat(priorityaging, 'f', -1, 64))
+	iniconf.Set("run::stopgrace", strconv.FormatInt(stopgrace, 10))
+	iniconf.Set("run::maxidleconns", strconv.Itoa(maxidleconns))
+	iniconf.Set("run::maxidleconnsperhost", strconv.Itoa(maxidleconnsperhost))
+	iniconf.Set("run::idleconntimeout", strconv.FormatInt(idleconntimeout, 10))
+	iniconf.Set("run::resolver", resolver)
+	iniconf.Set("run::failurereport", fmt.Sprint(failurereport))
+	iniconf.Set("run::maxrequests", strconv.FormatInt(maxrequests, 10))
+	iniconf.Set("run::metricsinterval", strconv.FormatInt(metricsinterval, 10))
+	iniconf.Set("run::fileskipexists", fmt.Sprint(fileskipexists))
+	iniconf.Set("run::maxfailrate", strconv.FormatFloat(maxfailrate, 'f', -1, 64))
+	iniconf.Set("run::maxconsecutivefails", strconv.Itoa(maxconsecutivefails))
+	iniconf.Set("run::warmup", fmt.Sprint(warmup))
+	iniconf.Set("run::retryallerrors", fmt.Sprint(retryallerrors))
+	iniconf.Set("run::maxruntime", strconv.FormatInt(maxruntime, 10))
+	iniconf.Set("run::filethread", strconv.Itoa(filethread))
+	iniconf.Set("run::fileresume", fmt.Sprint(fileresume))
+	iniconf.Set("run::maxretries", strconv.Itoa(maxretries))
+	iniconf.Set("run::headertimeout", strconv.FormatInt(headertimeout, 10))
+	iniconf.Set("run::readidletimeout", strconv.FormatInt(readidletimeout, 10))
+	iniconf.Set("run::queuestore", queuestore)
+	iniconf.Set("run::strategy", strategy)
+	iniconf.Set("run::canonical", fmt.Sprint(canonical))
+	iniconf.Set("run::invalidutf8", invalidutf8)
+	iniconf.Set("run::tlsfingerprint", tlsfingerprint)
+	iniconf.Set("run::orderedoutput", fmt.Sprint(orderedoutput))
+	iniconf.Set("run::runreport", fmt.Sprint(runreport))
+	iniconf.Set("run::collectorthread", strconv.Itoa(collectorthread))
+	iniconf.Set("run::outputschema", fmt.Sprint(outputschema))
+	iniconf.Set("run::dnscachettl", strconv.FormatInt(dnscachettl, 10))
+	iniconf.Set("run::dnscachesize", strconv.Itoa(dnscachesize))
+	iniconf.Set("run::seedaddr", seedaddr)
+	iniconf.Set("run::onduplicate", onduplicate)
+	iniconf.Set("run::startjitter", strconv.FormatInt(startjitter, 10))
+	iniconf.Set("run::collectonce", fmt.Sprint(collectonce))
+	iniconf.Set("run::urlnormalize", fmt.Sprint(urlnormalize))
+	iniconf.Set("run::dropparams", dropparams)
+	iniconf.Set("run::explain", fmt.Sprint(explain))
+	iniconf.Set("run::rotaterows", strconv.Itoa(rotaterows))
+	iniconf.Set("run::rotateinterval", strconv.FormatInt(rotateinterval, 10))
+	iniconf.Set("run::outputwait", strconv.FormatInt(outputwait, 10))
+	iniconf.Set("run::seedwait", strconv.FormatInt(seedwait, 10))
+	iniconf.Set("run::profile", profile)
+	iniconf.Set("run::inflightwarn", strconv.FormatInt(inflightwarn, 10))
+	iniconf.Set("run::phantompool", strconv.Itoa(phantompool))
+	iniconf.Set("run::phantommaxuses", strconv.Itoa(phantommaxuses))
+	iniconf.Set("run::phantomsession", phantomsession)
+	iniconf.Set("run::natsurl", natsurl)
+	iniconf.Set("run::natssubject", natssubject)
+	iniconf.Set("run::maxbodysize", strconv.FormatInt(maxbodysize, 10))
+	iniconf.Set("run::samplepages", strconv.Itoa(samplepages))
+	iniconf.Set("run::manifest", fmt.Sprint(manifest))
+	iniconf.Set("run::runtag", fmt.Sprint(runtag))
+	iniconf.Set("run::runid", runid)
+	iniconf.Set("run::itembuffer", strconv.Itoa(itembuffer))
+	iniconf.Set("run::itembufferpolicy", itembufferpolicy)
+	iniconf.Set("run::maxconnsperhost", strconv.Itoa(maxconnsperhost))
 	iniconf.Set("run::success", fmt.Sprint(success))
 	iniconf.Set("run::failure", fmt.Sprint(failure))
 }
@@ -144,6 +276,14 @@ func trySet(iniconf config.Configer) {
 		iniconf.Set("log::save", fmt.Sprint(logsave))
 	}
 
+	if v, e := iniconf.Int64("log::dedupwindow"); v < 0 || e != nil {
+		iniconf.Set("log::dedupwindow", strconv.FormatInt(logdedupwindow, 10))
+	}
+
+	if v, e := iniconf.Int("log::dedupthreshold"); v <= 0 || e != nil {
+		iniconf.Set("log::dedupthreshold", strconv.Itoa(logdedupthreshold))
+	}
+
 	if v := iniconf.String("phantomjs"); v == "" {
 		iniconf.Set("phantomjs", phantomjs)
 	}
@@ -152,6 +292,18 @@ func trySet(iniconf config.Configer) {
 		iniconf.Set("proxylib", proxylib)
 	}
 
+	if v := iniconf.String("proxyapi"); v == "" {
+		iniconf.Set("proxyapi", proxyapi)
+	}
+
+	if v := iniconf.String("proxyapiauth"); v == "" {
+		iniconf.Set("proxyapiauth", proxyapiauth)
+	}
+
+	if v, e := iniconf.Int64("proxyapiminute"); v <= 0 || e != nil {
+		iniconf.Set("proxyapiminute", strconv.FormatInt(proxyapiminute, 10))
+	}
+
 	if v := iniconf.String("spiderdir"); v == "" {
 		iniconf.Set("spiderdir", spiderdir)
 	}
@@ -160,6 +312,10 @@ func trySet(iniconf config.Configer) {
 		iniconf.Set("fileoutdir", fileoutdir)
 	}
 
+	if v := iniconf.String("fileoutlayout"); v == "" {
+		iniconf.Set("fileoutlayout", fileoutlayout)
+	}
+
 	if v := iniconf.String("textoutdir"); v == "" {
 		iniconf.Set("textoutdir", textoutdir)
 	}
@@ -192,6 +348,18 @@ func trySet(iniconf config.Configer) {
 		iniconf.Set("mysql::maxallowedpacketmb", strconv.Itoa(mysqlmaxallowedpacketmb))
 	}
 
+	if _, e := iniconf.Bool("mysql::loaddata"); e != nil {
+		iniconf.Set("mysql::loaddata", fmt.Sprint(mysqlloaddata))
+	}
+
+	if v := iniconf.String("gsheets::credentials"); v == "" {
+		iniconf.Set("gsheets::credentials", gsheetscredentials)
+	}
+
+	if v := iniconf.String("gsheets::spreadsheet"); v == "" {
+		iniconf.Set("gsheets::spreadsheet", gsheetsspreadsheet)
+	}
+
 	if v, e := iniconf.Int("run::mode"); v < status.UNSET || v > status.CLIENT || e != nil {
 		iniconf.Set("run::mode", strconv.Itoa(mode))
 	}
@@ -228,6 +396,235 @@ func trySet(iniconf config.Configer) {
 		iniconf.Set("run::proxyminute", strconv.FormatInt(proxyminute, 10))
 	}
 
+	if v, e := iniconf.Float("run::priorityaging"); v < 0 || e != nil {
+		iniconf.Set("run::priorityaging", strconv.FormatFloat(priorityaging, 'f', -1, 64))
+	}
+
+	if v, e := iniconf.Int64("run::stopgrace"); v < 0 || e != nil {
+		iniconf.Set("run::stopgrace", strconv.FormatInt(stopgrace, 10))
+	}
+
+	if v, e := iniconf.Int("run::maxidleconns"); v < 0 || e != nil {
+		iniconf.Set("run::maxidleconns", strconv.Itoa(maxidleconns))
+	}
+
+	if v, e := iniconf.Int("run::maxidleconnsperhost"); v < 0 || e != nil {
+		iniconf.Set("run::maxidleconnsperhost", strconv.Itoa(maxidleconnsperhost))
+	}
+
+	if v, e := iniconf.Int64("run::idleconntimeout"); v < 0 || e != nil {
+		iniconf.Set("run::idleconntimeout", strconv.FormatInt(idleconntimeout, 10))
+	}
+
+	if v := iniconf.String("run::resolver"); v == "" {
+		iniconf.Set("run::resolver", resolver)
+	}
+
+	if _, e := iniconf.Bool("run::failurereport"); e != nil {
+		iniconf.Set("run::failurereport", fmt.Sprint(failurereport))
+	}
+
+	if v, e := iniconf.Int64("run::maxrequests"); v < 0 || e != nil {
+		iniconf.Set("run::maxrequests", strconv.FormatInt(maxrequests, 10))
+	}
+
+	if v, e := iniconf.Int64("run::metricsinterval"); v < 0 || e != nil {
+		iniconf.Set("run::metricsinterval", strconv.FormatInt(metricsinterval, 10))
+	}
+
+	if _, e := iniconf.Bool("run::fileskipexists"); e != nil {
+		iniconf.Set("run::fileskipexists", fmt.Sprint(fileskipexists))
+	}
+
+	if v, e := iniconf.Float("run::maxfailrate"); v < 0 || v > 1 || e != nil {
+		iniconf.Set("run::maxfailrate", strconv.FormatFloat(maxfailrate, 'f', -1, 64))
+	}
+
+	if v, e := iniconf.Int("run::maxconsecutivefails"); v < 0 || e != nil {
+		iniconf.Set("run::maxconsecutivefails", strconv.Itoa(maxconsecutivefails))
+	}
+
+	if _, e := iniconf.Bool("run::warmup"); e != nil {
+		iniconf.Set("run::warmup", fmt.Sprint(warmup))
+	}
+
+	if _, e := iniconf.Bool("run::retryallerrors"); e != nil {
+		iniconf.Set("run::retryallerrors", fmt.Sprint(retryallerrors))
+	}
+
+	if v, e := iniconf.Int64("run::maxruntime"); v < 0 || e != nil {
+		iniconf.Set("run::maxruntime", strconv.FormatInt(maxruntime, 10))
+	}
+
+	if v, e := iniconf.Int("run::filethread"); v < 0 || e != nil {
+		iniconf.Set("run::filethread", strconv.Itoa(filethread))
+	}
+
+	if _, e := iniconf.Bool("run::fileresume"); e != nil {
+		iniconf.Set("run::fileresume", fmt.Sprint(fileresume))
+	}
+
+	if v, e := iniconf.Int("run::maxretries"); v < 0 || e != nil {
+		iniconf.Set("run::maxretries", strconv.Itoa(maxretries))
+	}
+
+	if v, e := iniconf.Int64("run::headertimeout"); v < 0 || e != nil {
+		iniconf.Set("run::headertimeout", strconv.FormatInt(headertimeout, 10))
+	}
+
+	if v, e := iniconf.Int64("run::readidletimeout"); v < 0 || e != nil {
+		iniconf.Set("run::readidletimeout", strconv.FormatInt(readidletimeout, 10))
+	}
+
+	if v := iniconf.String("run::queuestore"); v == "" {
+		iniconf.Set("run::queuestore", queuestore)
+	}
+
+	if v := iniconf.String("run::strategy"); v != "bfs" && v != "dfs" {
+		iniconf.Set("run::strategy", strategy)
+	}
+
+	if _, e := iniconf.Bool("run::canonical"); e != nil {
+		iniconf.Set("run::canonical", fmt.Sprint(canonical))
+	}
+
+	switch iniconf.String("run::invalidutf8") {
+	case "none", "replace", "strip", "error":
+	default:
+		iniconf.Set("run::invalidutf8", invalidutf8)
+	}
+
+	// 可选值须与surfer.TLSFingerprints一致
+	switch iniconf.String("run::tlsfingerprint") {
+	case "", "chrome", "firefox", "safari", "edge":
+	default:
+		iniconf.Set("run::tlsfingerprint", tlsfingerprint)
+	}
+
+	if _, e := iniconf.Bool("run::orderedoutput"); e != nil {
+		iniconf.Set("run::orderedoutput", fmt.Sprint(orderedoutput))
+	}
+
+	if _, e := iniconf.Bool("run::runreport"); e != nil {
+		iniconf.Set("run::runreport", fmt.Sprint(runreport))
+	}
+
+	if v, e := iniconf.Int("run::collectorthread"); v < 1 || e != nil {
+		iniconf.Set("run::collectorthread", strconv.Itoa(collectorthread))
+	}
+
+	if _, e := iniconf.Bool("run::outputschema"); e != nil {
+		iniconf.Set("run::outputschema", fmt.Sprint(outputschema))
+	}
+
+	if v, e := iniconf.Int64("run::dnscachettl"); v < 0 || e != nil {
+		iniconf.Set("run::dnscachettl", strconv.FormatInt(dnscachettl, 10))
+	}
+
+	if v, e := iniconf.Int("run::dnscachesize"); v < 1 || e != nil {
+		iniconf.Set("run::dnscachesize", strconv.Itoa(dnscachesize))
+	}
+
+	if v := iniconf.String("run::seedaddr"); v == "" {
+		iniconf.Set("run::seedaddr", seedaddr)
+	}
+
+	if v := iniconf.String("run::onduplicate"); v != "fail" && v != "skip" && v != "update" {
+		iniconf.Set("run::onduplicate", onduplicate)
+	}
+
+	if v, e := iniconf.Int64("run::startjitter"); v < 0 || e != nil {
+		iniconf.Set("run::startjitter", strconv.FormatInt(startjitter, 10))
+	}
+
+	if _, e := iniconf.Bool("run::collectonce"); e != nil {
+		iniconf.Set("run::collectonce", fmt.Sprint(collectonce))
+	}
+
+	if _, e := iniconf.Bool("run::urlnormalize"); e != nil {
+		iniconf.Set("run::urlnormalize", fmt.Sprint(urlnormalize))
+	}
+
+	if v := iniconf.String("run::dropparams"); v == "" {
+		iniconf.Set("run::dropparams", dropparams)
+	}
+
+	if _, e := iniconf.Bool("run::explain"); e != nil {
+		iniconf.Set("run::explain", fmt.Sprint(explain))
+	}
+
+	if v, e := iniconf.Int("run::rotaterows"); v < 0 || e != nil {
+		iniconf.Set("run::rotaterows", strconv.Itoa(rotaterows))
+	}
+
+	if v, e := iniconf.Int64("run::rotateinterval"); v < 0 || e != nil {
+		iniconf.Set("run::rotateinterval", strconv.FormatInt(rotateinterval, 10))
+	}
+
+	if v, e := iniconf.Int64("run::outputwait"); v < 0 || e != nil {
+		iniconf.Set("run::outputwait", strconv.FormatInt(outputwait, 10))
+	}
+
+	if v, e := iniconf.Int64("run::seedwait"); v < -1 || e != nil {
+		iniconf.Set("run::seedwait", strconv.FormatInt(seedwait, 10))
+	}
+
+	if _, ok := Profiles[iniconf.String("run::profile")]; !ok {
+		iniconf.Set("run::profile", profile)
+	}
+
+	if v, e := iniconf.Int64("run::inflightwarn"); v < 0 || e != nil {
+		iniconf.Set("run::inflightwarn", strconv.FormatInt(inflightwarn, 10))
+	}
+
+	if v, e := iniconf.Int("run::phantompool"); v < 0 || e != nil {
+		iniconf.Set("run::phantompool", strconv.Itoa(phantompool))
+	}
+
+	if v, e := iniconf.Int("run::phantommaxuses"); v <= 0 || e != nil {
+		iniconf.Set("run::phantommaxuses", strconv.Itoa(phantommaxuses))
+	}
+
+	if v := iniconf.String("run::phantomsession"); v != "fresh" && v != "shared" {
+		iniconf.Set("run::phantomsession", phantomsession)
+	}
+
+	if v := iniconf.String("run::natsurl"); v == "" {
+		iniconf.Set("run::natsurl", natsurl)
+	}
+
+	if v := iniconf.String("run::natssubject"); v == "" {
+		iniconf.Set("run::natssubject", natssubject)
+	}
+
+	if v, e := iniconf.Int64("run::maxbodysize"); v < 0 || e != nil {
+		iniconf.Set("run::maxbodysize", strconv.FormatInt(maxbodysize, 10))
+	}
+
+	if v, e := iniconf.Int("run::samplepages"); v < 0 || e != nil {
+		iniconf.Set("run::samplepages", strconv.Itoa(samplepages))
+	}
+
+	if _, e := iniconf.Bool("run::manifest"); e != nil {
+		iniconf.Set("run::manifest", fmt.Sprint(manifest))
+	}
+
+	if _, e := iniconf.Bool("run::runtag"); e != nil {
+		iniconf.Set("run::runtag", fmt.Sprint(runtag))
+	}
+
+	if v, e := iniconf.Int("run::itembuffer"); v < 0 || e != nil {
+		iniconf.Set("run::itembuffer", strconv.Itoa(itembuffer))
+	}
+
+	if v := iniconf.String("run::itembufferpolicy"); v != "block" && v != "drop" {
+		iniconf.Set("run::itembufferpolicy", itembufferpolicy)
+	}
+
+	if v, e := iniconf.Int("run::maxconnsperhost"); v < 0 || e != nil {
+		iniconf.Set("run::maxconnsperhost", strconv.Itoa(maxconnsperhost))
+	}
+
 	if _, e := iniconf.Bool("run::success"); e != nil {
 		iniconf.Set("run::success", fmt.Sprint(success))
 	}
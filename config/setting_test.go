@@ -0,0 +1,32 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/henrylee2cn/pholcus/common/config"
+)
+
+// 取值不在可选范围内的配置项恢复为默认值
+func TestTrySetChoices(t *testing.T) {
+	for _, c := range []struct {
+		key, value, want string
+	}{
+		{"run::phantomsession", "shared", "shared"},
+		{"run::phantomsession", "Shared", phantomsession},
+		{"run::phantomsession", "isolated", phantomsession},
+		{"run::tlsfingerprint", "firefox", "firefox"},
+		{"run::tlsfingerprint", "opera", tlsfingerprint},
+		{"run::onduplicate", "skip", "skip"},
+		{"run::onduplicate", "replace", onduplicate},
+	} {
+		iniconf, err := config.NewConfigData("ini", []byte{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		iniconf.Set(c.key, c.value)
+		trySet(iniconf)
+		if got := iniconf.String(c.key); got != c.want {
+			t.Errorf("%s = %q: got %q, want %q", c.key, c.value, got, c.want)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/henrylee2cn/pholcus/app"
+	"github.com/henrylee2cn/pholcus/app/bench"
 	"github.com/henrylee2cn/pholcus/cmd"
 	"github.com/henrylee2cn/pholcus/common/gc"
 	"github.com/henrylee2cn/pholcus/config"
@@ -30,6 +31,14 @@ var (
 	dockerflag         *int
 	successInheritflag *bool
 	failureInheritflag *bool
+	retryFailuresflag  *string
+	urlsFileflag       *string
+	seedTableflag      *string
+	seedUrlflag        *string
+	seedMetaflag       *string
+	seedRuleflag       *string
+	sampleflag         *int
+	benchflag          *string
 )
 
 func init() {
@@ -50,9 +59,29 @@ func DefaultRun(uiDefault string) {
 	flag.String("z", "", "README:   参数设置参考 [xxx] 提示，参数中包含多个值时以 \",\" 间隔。\r\n")
 	flag.Parse()
 	writeFlag()
+	if *benchflag != "" {
+		runBench(*benchflag)
+		return
+	}
 	run(*uiflag)
 }
 
+// 运行基准测试并打印结果，不启动操作界面
+func runBench(s string) {
+	opts, err := bench.ParseOptions(s)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	report, err := bench.Run(opts)
+	if err != nil {
+		fmt.Printf("基准测试出错: %v\n", err)
+	}
+	if report != nil {
+		fmt.Print(report)
+	}
+}
+
 func flagCommon() {
 	//运行模式
 	modeflag = flag.Int(
@@ -93,7 +122,7 @@ func flagCommon() {
 			for _, v := range app.LogicApp.GetOutputLib() {
 				outputlib += "[" + v + "] "
 			}
-			return "   <输出方式: > " + strings.TrimRight(outputlib, " ")
+			return "   <输出方式: > " + strings.TrimRight(outputlib, " ") + "  多个时以逗号分隔，如 csv,mysql"
 		}())
 
 	// 并发协程数
@@ -131,6 +160,55 @@ func flagCommon() {
 		"a_failure",
 		cache.Task.FailureInherit,
 		"   <继承并保存失败记录> [true] [false]")
+
+	// 重试失败请求
+	retryFailuresflag = flag.String(
+		"a_retryfailures",
+		cache.Task.RetryFailures,
+		"   <重试失败请求: 指定失败请求报告文件，如 "+config.FAILURE_REPORT+".json，仅重新抓取其中属于所选蜘蛛的请求>")
+
+	// 种子URL列表
+	urlsFileflag = flag.String(
+		"a_urlsfile",
+		cache.Task.UrlsFile,
+		"   <种子URL列表: 指定文件路径，- 为标准输入，每行一个URL，其后可空格间隔指定规则名，可配合“URL列表”蜘蛛使用>")
+
+	// 种子数据表
+	seedTableflag = flag.String(
+		"a_seedtable",
+		cache.Task.SeedTable,
+		"   <种子数据表: 指定csv或xlsx文件路径，首行为表头，其后每行生成一个请求>")
+
+	// 种子数据表的URL模板
+	seedUrlflag = flag.String(
+		"a_seedurl",
+		cache.Task.SeedUrl,
+		"   <种子数据表的URL模板: 以{列名}引用各列的值，如 https://www.example.com/s?q={关键词}，不填时采用url列或首列>")
+
+	// 种子数据表的Meta列
+	seedMetaflag = flag.String(
+		"a_seedmeta",
+		cache.Task.SeedMeta,
+		"   <种子数据表的Meta列: 以逗号分隔，可用 列名:键名 指定键名，不填时全部列均作为请求的Meta>")
+
+	// 种子数据表的规则名
+	seedRuleflag = flag.String(
+		"a_seedrule",
+		cache.Task.SeedRule,
+		"   <种子数据表的规则名: 蜘蛛仅有一个规则时可不填>")
+
+	// 预览模式
+	sampleflag = flag.Int(
+		"a_sample",
+		cache.Task.SamplePages,
+		"   <预览模式: 每个规则最多解析的成功页数，均达到后任务结束，为0时关闭> [>=0]")
+
+	// 基准测试
+	benchflag = flag.String(
+		"a_bench",
+		"",
+		"   <基准测试: 以参考蜘蛛抓取本地生成的测试网页并报告吞吐量、内存及正确性，不启动操作界面，"+
+			"格式为 页数,每页链接数,延迟ms,页面字节数，如 1000,10,20,4096，不填时不运行>")
 }
 
 func writeFlag() {
@@ -146,4 +224,20 @@ func writeFlag() {
 	cache.Task.DockerCap = *dockerflag
 	cache.Task.SuccessInherit = *successInheritflag
 	cache.Task.FailureInherit = *failureInheritflag
+	cache.Task.RetryFailures = *retryFailuresflag
+	cache.Task.UrlsFile = *urlsFileflag
+	cache.Task.SeedTable = *seedTableflag
+	cache.Task.SeedUrl = *seedUrlflag
+	cache.Task.SeedMeta = *seedMetaflag
+	cache.Task.SeedRule = *seedRuleflag
+	cache.Task.SamplePages = *sampleflag
+	// 命令行中指定的并发量及暂停时长不被礼貌度预设替换
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "a_thread":
+			cache.Task.SetExplicit("ThreadNum")
+		case "a_pause":
+			cache.Task.SetExplicit("Pausetime")
+		}
+	})
 }
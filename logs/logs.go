@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"path"
+	"time"
 
 	"github.com/henrylee2cn/pholcus/config"
 	"github.com/henrylee2cn/pholcus/logs/logs"
@@ -24,6 +25,8 @@ type (
 		Close()
 		// 返回运行状态，如0,"RUN"
 		Status() (int, string)
+		// 设置重复日志的合并：window内级别及内容相同的日志超出threshold条后不再输出，周期结束时汇总输出省略的条数，window不大于0时不合并
+		SetDedup(window time.Duration, threshold int)
 		DelLogger(adaptername string) error
 		SetLogger(adaptername string, config map[string]interface{}) error
 
@@ -63,6 +66,8 @@ var Log = func() Logs {
 	ml.BeeLogger.SetLevel(config.LOG_LEVEL)
 	// 是否异步输出日志
 	ml.BeeLogger.Async(config.LOG_ASYNC)
+	// 合并短时间内大量重复的日志
+	ml.BeeLogger.SetDedup(time.Duration(config.LOG_DEDUP_WINDOW)*time.Second, config.LOG_DEDUP_THRESHOLD)
 	// 设置日志显示位置
 	ml.BeeLogger.SetLogger("console", map[string]interface{}{
 		"level": config.LOG_CONSOLE_LEVEL,
@@ -0,0 +1,114 @@
+package logs
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dedup collapses identical log messages of the same level that are
+// logged more than threshold times within one window.  Suppressed
+// messages are summarized once the window ends.
+type dedup struct {
+	lock      sync.Mutex
+	window    time.Duration
+	threshold int
+	entries   map[string]*dedupEntry
+	stop      chan struct{}
+}
+
+type dedupEntry struct {
+	level      int
+	start      time.Time // start of the current window
+	count      int       // messages logged in the current window
+	suppressed int       // messages dropped in the current window
+	last       string    // last dropped message
+}
+
+// SetDedup enables collapsing of repeated messages: within each window,
+// only the first threshold identical messages of the same level are
+// written, and the rest are reported by one summary line at the end of
+// the window.  Messages are compared after formatting, so the same format
+// with different arguments is not a repeat.  A window <= 0 disables
+// collapsing.
+func (bl *BeeLogger) SetDedup(window time.Duration, threshold int) {
+	bl.dedup.lock.Lock()
+	defer bl.dedup.lock.Unlock()
+	if bl.dedup.stop != nil {
+		close(bl.dedup.stop)
+		bl.dedup.stop = nil
+	}
+	bl.flushDedup(time.Time{})
+	bl.dedup.window = window
+	bl.dedup.threshold = threshold
+	bl.dedup.entries = make(map[string]*dedupEntry)
+	if window <= 0 {
+		return
+	}
+	stop := make(chan struct{})
+	bl.dedup.stop = stop
+	go func() {
+		ticker := time.NewTicker(window)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				bl.dedup.lock.Lock()
+				bl.flushDedup(now)
+				bl.dedup.lock.Unlock()
+			}
+		}
+	}()
+}
+
+// suppress reports whether the message should be dropped as a repeat.
+// text is the formatted message used for comparison, msg the message as
+// written, which may carry the caller's file and line.
+func (bl *BeeLogger) suppress(level int, text, msg string) bool {
+	bl.dedup.lock.Lock()
+	defer bl.dedup.lock.Unlock()
+	if bl.dedup.window <= 0 {
+		return false
+	}
+	now := time.Now()
+	key := fmt.Sprint(level, ":", text)
+	e := bl.dedup.entries[key]
+	if e == nil || now.Sub(e.start) >= bl.dedup.window {
+		if e != nil {
+			bl.summarize(e)
+		}
+		e = &dedupEntry{level: level, start: now}
+		bl.dedup.entries[key] = e
+	}
+	e.count++
+	if e.count <= bl.dedup.threshold {
+		return false
+	}
+	e.suppressed++
+	e.last = msg
+	return true
+}
+
+// flushDedup summarizes and removes the entries whose window has ended
+// by now; a zero now flushes all entries.  The caller holds the lock.
+func (bl *BeeLogger) flushDedup(now time.Time) {
+	for key, e := range bl.dedup.entries {
+		if now.IsZero() || now.Sub(e.start) >= bl.dedup.window {
+			bl.summarize(e)
+			delete(bl.dedup.entries, key)
+		}
+	}
+}
+
+// summarize writes one line for the messages dropped from the entry.
+func (bl *BeeLogger) summarize(e *dedupEntry) {
+	if e.suppressed == 0 {
+		return
+	}
+	msg := fmt.Sprintf("%s (occurred %d times in the last %v, %d suppressed)\n",
+		strings.TrimRight(e.last, "\r\n"), e.count, bl.dedup.window, e.suppressed)
+	bl.output(&logMsg{level: e.level, msg: msg})
+}
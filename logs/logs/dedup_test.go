@@ -0,0 +1,40 @@
+package logs
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Repeated messages beyond the threshold are dropped and summarized at
+// the end of the window; other messages, including the same format with
+// different arguments, are not affected.
+func TestDedup(t *testing.T) {
+	bl := NewLogger(100, LevelDebug)
+	bl.SetDedup(50*time.Millisecond, 2)
+	defer bl.SetDedup(0, 0)
+	for i := 0; i < 5; i++ {
+		bl.Error("fail %d\n", 1)
+	}
+	bl.Error("fail %d\n", 2)
+	bl.Warning("other\n")
+
+	var msgs []string
+	for len(msgs) < 5 {
+		select {
+		case lm := <-bl.steal:
+			msgs = append(msgs, lm.msg)
+		case <-time.After(time.Second):
+			t.Fatalf("got %q, want 5 messages", msgs)
+		}
+	}
+	want := []string{"[E] fail 1\n", "[E] fail 1\n", "[E] fail 2\n", "[W] other\n"}
+	for i, w := range want {
+		if msgs[i] != w {
+			t.Errorf("message %d = %q, want %q", i, msgs[i], w)
+		}
+	}
+	if !strings.HasPrefix(msgs[4], "[E] fail 1 (occurred 5 times") || !strings.Contains(msgs[4], "3 suppressed") {
+		t.Errorf("unexpected summary %q", msgs[4])
+	}
+}
@@ -50,7 +50,7 @@ func TestFile(t *testing.T) {
 			linenum++
 		}
 	}
-	var expected = LevelDebug + 1
+	var expected = LevelDebug
 	if linenum != expected {
 		t.Fatal(linenum, "not "+strconv.Itoa(expected)+" lines")
 	}
@@ -61,7 +61,7 @@ func TestFile2(t *testing.T) {
 	log := NewLogger(10000)
 	log.SetLogger("file", map[string]interface{}{"filename": "test2.log", "level": LevelError})
 	log.Debug("debug")
-	log.Info("info")
+	log.Informational("info")
 	log.Notice("notice")
 	log.Warning("warning")
 	log.Error("error")
@@ -84,7 +84,7 @@ func TestFile2(t *testing.T) {
 			linenum++
 		}
 	}
-	var expected = LevelError + 1
+	var expected = LevelError
 	if linenum != expected {
 		t.Fatal(linenum, "not "+strconv.Itoa(expected)+" lines")
 	}
@@ -95,7 +95,7 @@ func TestFileRotate(t *testing.T) {
 	log := NewLogger(10000)
 	log.SetLogger("file", map[string]interface{}{"filename": "test3.log", "maxlines": 4})
 	log.Debug("debug")
-	log.Info("info")
+	log.Informational("info")
 	log.Notice("notice")
 	log.Warning("warning")
 	log.Error("error")
@@ -106,6 +106,7 @@ type BeeLogger struct {
 	stealLevel          int
 	outputs             map[string]LoggerInterface
 	status              int
+	dedup               dedup
 }
 
 type logMsg struct {
@@ -190,6 +191,14 @@ func (bl *BeeLogger) writerMsg(loglevel int, msg string) error {
 		lm.msg = msg
 	}
 
+	if bl.suppress(loglevel, msg, lm.msg) {
+		return nil
+	}
+	return bl.output(lm)
+}
+
+// output sends the message to the steal channel and all providers.
+func (bl *BeeLogger) output(lm *logMsg) error {
 	if lm.level <= bl.stealLevel {
 		bl.stealOne(lm)
 	}
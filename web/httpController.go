@@ -1,6 +1,7 @@
 package web
 
 import (
+	"encoding/json"
 	"net/http"
 	"text/template"
 
@@ -52,3 +53,58 @@ func web(rw http.ResponseWriter, req *http.Request) {
 	}
 	t.Execute(rw, data) //执行模板的merger操作
 }
+
+// 以json格式返回当前待处理请求的快照，用于排查任务卡住的原因
+func queue(rw http.ResponseWriter, req *http.Request) {
+	b, err := json.Marshal(app.LogicApp.GetQueueDump())
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rw.Write(b)
+}
+
+// 以json格式返回当前执行中请求的快照，用于排查卡住的下载
+func inflightReqs(rw http.ResponseWriter, req *http.Request) {
+	b, err := json.Marshal(app.LogicApp.GetInflight())
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rw.Write(b)
+}
+
+// 以json格式返回本次任务的失败请求报告
+func failures(rw http.ResponseWriter, req *http.Request) {
+	b, err := json.Marshal(app.LogicApp.GetFailures())
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rw.Write(b)
+}
+
+// 以json格式返回各蜘蛛各规则已收集的结果数量，用于实时监控各规则的产出
+func ruleItems(rw http.ResponseWriter, req *http.Request) {
+	b, err := json.Marshal(app.LogicApp.GetRuleItemCounts())
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rw.Write(b)
+}
+
+// 以json格式返回全部蜘蛛种类的元数据，用于自定义启动器等外部工具
+func spiders(rw http.ResponseWriter, req *http.Request) {
+	b, err := json.Marshal(app.LogicApp.GetSpiderInfos())
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rw.Write(b)
+}
@@ -12,6 +12,16 @@ func Router() {
 	http.Handle("/ws", ws.Handler(wsHandle))
 	// 设置websocket报告打印专用路由
 	http.Handle("/ws/log", ws.Handler(wsLogHandle))
+	// 设置请求队列快照查询路由
+	http.HandleFunc("/queue", queue)
+	// 设置执行中请求快照查询路由
+	http.HandleFunc("/inflight", inflightReqs)
+	// 设置失败请求报告查询路由
+	http.HandleFunc("/failures", failures)
+	// 设置各规则结果数量查询路由
+	http.HandleFunc("/ruleitems", ruleItems)
+	// 设置蜘蛛元数据查询路由
+	http.HandleFunc("/spiders", spiders)
 	//设置http访问的路由
 	http.HandleFunc("/", web)
 	//static file server